@@ -1,24 +1,264 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"github.com/gin-gonic/gin"
 	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"testovoe/internal/auth"
+	"testovoe/internal/background"
+	"testovoe/internal/config"
 	"testovoe/internal/database"
 	"testovoe/internal/handler"
+	"testovoe/internal/i18n"
+	"testovoe/internal/mail"
+	"testovoe/internal/pagination"
+	"testovoe/internal/ratelimit"
 	"testovoe/internal/repository"
 	"testovoe/internal/router"
 	"testovoe/internal/service"
+	"testovoe/internal/sso"
 )
 
 func main() {
-	database.ConnectDB()
+	cfg, err := config.Load(os.Args[1:])
+	switch {
+	case cfg == nil:
+		log.Fatalf("ошибка в конфигурации: %v", err)
+	case cfg.Help:
+		cfg.PrintUsage(os.Stdout)
+		return
+	case cfg.PrintConfig:
+		cfg.Print(os.Stdout)
+		if err != nil {
+			log.Fatalf("ошибка в конфигурации:\n%v", err)
+		}
+		return
+	case err != nil:
+		log.Fatalf("ошибка в конфигурации:\n%v", err)
+	}
+	setupLogging(cfg)
+
+	database.ConnectDB(cfg)
 	defer database.CloseDB()
 
+	cursorSecret := []byte(cfg.CursorSecret)
+	if len(cursorSecret) == 0 {
+		slog.Warn("CURSOR_SECRET не задан, курсоры пагинации будут недействительны после перезапуска")
+		cursorSecret = make([]byte, 32)
+		if _, err := rand.Read(cursorSecret); err != nil {
+			log.Fatalf("ошибка при генерации секрета курсоров: %v", err)
+		}
+	}
+
+	jwtConfig, err := auth.LoadJWTConfig(cfg)
+	if err != nil {
+		log.Fatalf("ошибка при загрузке ключей JWT: %v", err)
+	}
+	issuerConfig, err := auth.LoadTokenIssuerConfig(cfg)
+	if err != nil {
+		log.Fatalf("ошибка при загрузке ключа подписи JWT: %v", err)
+	}
+	mailDriver, err := mail.NewMailer(cfg)
+	if err != nil {
+		log.Fatalf("ошибка при настройке отправки писем: %v", err)
+	}
+	mailer := mail.NewQueue(mailDriver, mail.QueueConfig{
+		Size:        cfg.MailQueueSize,
+		Workers:     cfg.MailWorkers,
+		MaxAttempts: cfg.MailMaxAttempts,
+	})
+
+	// tasks выполняет работу, которую нельзя делать в запросе, например обработку запросов сброса пароля.
+	tasks := background.NewPool(cfg.MailQueueSize, cfg.MailWorkers)
+
+	transactor := repository.NewTransactor(database.DB)
 	userRepo := repository.NewUserRepository(database.DB)
-	userService := service.NewUserService(userRepo)
-	userHandler := handler.NewUserHandler(userService)
+	auditRepo := repository.NewAuditRepository(database.DB)
+	apiKeyRepo := repository.NewAPIKeyRepository(database.DB)
+	sessionRepo := repository.NewSessionRepository(database.DB)
+	passwordResetRepo := repository.NewPasswordResetRepository(database.DB)
+	mfaRepo := repository.NewMFARepository(database.DB)
+	oauthClientRepo := repository.NewOAuthClientRepository(database.DB)
+	oidcRepo := repository.NewOIDCRepository(database.DB)
+	identityRepo := repository.NewIdentityRepository(database.DB)
+	loginFailureRepo := repository.NewLoginFailureRepository(database.DB)
+	idempotencyRepo := repository.NewIdempotencyRepository(database.DB)
+
+	tokenIssuer := auth.NewTokenIssuer(issuerConfig)
+	authorizer := service.NewAuthorizer(userRepo)
+	emailVerificationService := service.NewEmailVerificationService(userRepo, auditRepo, transactor, tokenIssuer, mailer, cfg.EmailVerificationURL)
+	userService := service.NewUserService(userRepo, auditRepo, sessionRepo, transactor, pagination.NewCursorCodec(cursorSecret), emailVerificationService)
+	if cfg.BootstrapAdminEmail != "" {
+		admin, err := userService.BootstrapAdmin(context.Background(), cfg.BootstrapAdminEmail)
+		if err != nil {
+			log.Fatalf("ошибка при назначении первого администратора: %v", err)
+		}
+		if admin != nil {
+			slog.Info("роль admin выдана первому администратору", "user_id", admin.ID, "email", admin.Email)
+		}
+	}
+	auditService := service.NewAuditService(auditRepo)
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo, userRepo, auditRepo, transactor)
+	sessionService := service.NewSessionService(sessionRepo, userRepo, auditRepo, transactor)
+	passwordHasher := auth.NewPasswordHasher(auth.Argon2Params{
+		Memory:      cfg.Argon2Memory,
+		Iterations:  cfg.Argon2Iterations,
+		Parallelism: cfg.Argon2Parallelism,
+		SaltLength:  auth.DefaultArgon2Params.SaltLength,
+		KeyLength:   auth.DefaultArgon2Params.KeyLength,
+	})
+	loginGuard := service.NewLoginGuard(loginFailureRepo, userRepo, auditRepo, transactor, service.LockoutPolicy{
+		AccountThreshold: int(cfg.LockoutThreshold),
+		IPThreshold:      int(cfg.LockoutIPThreshold),
+		Duration:         cfg.LockoutDuration,
+		MaxDuration:      cfg.LockoutMaxDuration,
+		ResetAfter:       cfg.LockoutResetAfter,
+	})
+	mfaService := service.NewMFAService(userRepo, mfaRepo, sessionRepo, auditRepo, transactor, tokenIssuer, loginGuard, cfg.MFAIssuer)
+	authService := service.NewAuthService(userRepo, userRepo, sessionRepo, auditRepo, transactor, passwordHasher, tokenIssuer, mfaService, loginGuard)
+	passwordResetService := service.NewPasswordResetService(userRepo, userRepo, passwordResetRepo, sessionRepo, auditRepo, transactor, passwordHasher, mailer, tasks, service.PasswordResetConfig{
+		URL:     cfg.PasswordResetURL,
+		TTL:     cfg.PasswordResetTTL,
+		PerHour: int(cfg.PasswordResetPerHour),
+	})
+	oauthClientService := service.NewOAuthClientService(oauthClientRepo, auditRepo, transactor)
+	var oidcKeyBox *auth.SecretBox
+	if cfg.OIDCKeyEncryptionSecret != "" {
+		if oidcKeyBox, err = auth.NewSecretBox(cfg.OIDCKeyEncryptionSecret); err != nil {
+			log.Fatalf("OIDC_KEY_ENCRYPTION_SECRET: %v (нужно не меньше %d символов)", err, auth.SecretBoxMinSecretLength)
+		}
+	} else {
+		slog.Warn("OIDC_KEY_ENCRYPTION_SECRET не задан, ключи подписи ID-токенов хранятся в базе без шифрования")
+	}
+	oidcKeyService := service.NewOIDCKeyService(oidcRepo, auditRepo, transactor, oidcKeyBox, cfg.OIDCKeyRotation, cfg.OIDCKeyRetention)
+	oidcService := service.NewOIDCService(userRepo, oauthClientRepo, oidcRepo, auditRepo, transactor, oidcKeyService, service.OIDCConfig{
+		Issuer:     cfg.OIDCIssuer,
+		LoginURL:   cfg.OIDCLoginURL,
+		CodeTTL:    cfg.OIDCCodeTTL,
+		AccessTTL:  cfg.OIDCAccessTTL,
+		RefreshTTL: cfg.OIDCRefreshTTL,
+		IDTokenTTL: cfg.OIDCIDTokenTTL,
+	})
+	ssoProviders := make([]*sso.Provider, 0, len(cfg.SSOProviders))
+	for _, provider := range cfg.SSOProviders {
+		ssoProviders = append(ssoProviders, sso.NewProvider(sso.ProviderConfig{
+			Name:         provider.Name,
+			DisplayName:  provider.DisplayName,
+			Issuer:       provider.Issuer,
+			ClientID:     provider.ClientID,
+			ClientSecret: provider.ClientSecret,
+			Scopes:       provider.Scopes,
+			RedirectURL:  strings.TrimSuffix(cfg.SSORedirectBaseURL, "/") + "/auth/sso/" + provider.Name + "/callback",
+		}, nil))
+	}
+	ssoService := service.NewSSOService(ssoProviders, userRepo, identityRepo, sessionRepo, auditRepo, transactor, tokenIssuer, mfaService, loginGuard, cfg.SSOStateTTL)
+
+	jwtVerifier, err := auth.NewJWTVerifier(issuerConfig.PublicKeys(jwtConfig), sessionService)
+	if err != nil {
+		log.Fatalf("ошибка при настройке проверки JWT: %v", err)
+	}
+	authenticators := []auth.Authenticator{jwtVerifier, auth.NewAPIKeyAuthenticator(apiKeyService)}
+
+	var rateLimitStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if cfg.RateLimitBackend == "postgres" {
+		rateLimitStore = repository.NewRateLimitRepository(database.DB)
+	}
+	limiter := ratelimit.NewLimiter(rateLimitStore, cfg.RateLimitDefault, cfg.RateLimitRoutes)
 
-	r := router.SetupRouter(userHandler)
-	if err := r.Run(":8080"); err != nil {
+	r := router.SetupRouter(router.Deps{
+		UserHandler:              handler.NewUserHandler(service.NewAuthorizedUserService(userService, authorizer)),
+		AuditHandler:             handler.NewAuditHandler(service.NewAuthorizedAuditService(auditService, authorizer)),
+		APIKeyHandler:            handler.NewAPIKeyHandler(service.NewAuthorizedAPIKeyService(apiKeyService, authorizer)),
+		AuthHandler:              handler.NewAuthHandler(service.NewAuthorizedAuthService(authService, authorizer)),
+		SessionHandler:           handler.NewSessionHandler(service.NewAuthorizedSessionService(sessionService, authorizer)),
+		EmailVerificationHandler: handler.NewEmailVerificationHandler(service.NewAuthorizedEmailVerificationService(emailVerificationService, authorizer)),
+		PasswordResetHandler:     handler.NewPasswordResetHandler(service.NewAuthorizedPasswordResetService(passwordResetService, authorizer)),
+		MFAHandler:               handler.NewMFAHandler(service.NewAuthorizedMFAService(mfaService, authorizer)),
+		OIDCHandler:              handler.NewOIDCHandler(service.NewAuthorizedOIDCService(oidcService, authorizer)),
+		OAuthClientHandler:       handler.NewOAuthClientHandler(service.NewAuthorizedOAuthClientService(oauthClientService, authorizer)),
+		SSOHandler:               handler.NewSSOHandler(service.NewAuthorizedSSOService(ssoService, authorizer)),
+		LockoutHandler:           handler.NewLockoutHandler(service.NewAuthorizedLockoutService(loginGuard, authorizer)),
+		Authenticators:           authenticators,
+		Limiter:                  limiter,
+		Idempotency:              idempotencyRepo,
+		IdempotencyTTL:           cfg.IdempotencyTTL,
+		IdempotencyLockTimeout:   cfg.IdempotencyLockTimeout,
+		Languages:                i18n.NewMatcher(cfg.DefaultLanguage),
+		AdminToken:               cfg.AdminToken,
+		TrustedProxies:           cfg.TrustedProxies,
+	})
+	if err := serve(cfg, r); err != nil {
 		log.Fatalf("ошибка при запуске сервера: %v", err)
 	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTPShutdownTimeout)
+	defer cancel()
+	if err := tasks.Close(ctx); err != nil {
+		slog.Error("не все фоновые задачи выполнены", "error", err)
+	}
+	if err := mailer.Close(ctx); err != nil {
+		slog.Error("не все письма из очереди отправлены", "error", err)
+	}
+}
+
+// setupLogging направляет стандартный журнал в slog с уровнем и форматом из конфигурации.
+// Отладочный вывод gin (список маршрутов, предупреждения) остается только при уровне debug.
+func setupLogging(cfg *config.Config) {
+	options := &slog.HandlerOptions{Level: cfg.LogLevel}
+	var logHandler slog.Handler = slog.NewTextHandler(os.Stderr, options)
+	if cfg.LogFormat == "json" {
+		logHandler = slog.NewJSONHandler(os.Stderr, options)
+	}
+	slog.SetDefault(slog.New(logHandler))
+	// После SetDefault пакет log пишет через slog; по умолчанию с уровнем Info, и при LOG_LEVEL=warn
+	// или error его записи (log.Fatalf, ошибки библиотек) пропадали бы.
+	slog.SetLogLoggerLevel(slog.LevelError)
+
+	if cfg.LogLevel > slog.LevelDebug {
+		gin.SetMode(gin.ReleaseMode)
+	}
+}
+
+// serve запускает HTTP- или HTTPS-сервер и по SIGINT или SIGTERM дожидается завершения текущих запросов
+// не дольше HTTP_SHUTDOWN_TIMEOUT.
+func serve(cfg *config.Config, handler http.Handler) error {
+	server := &http.Server{
+		Addr:              cfg.HTTPAddr,
+		Handler:           handler,
+		ReadTimeout:       cfg.HTTPReadTimeout,
+		ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout,
+		WriteTimeout:      cfg.HTTPWriteTimeout,
+		IdleTimeout:       cfg.HTTPIdleTimeout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, 1)
+	go func() {
+		slog.Info("сервер запущен", "addr", cfg.HTTPAddr, "tls", cfg.TLSCertFile != "")
+		if cfg.TLSCertFile != "" {
+			errs <- server.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+		} else {
+			errs <- server.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("остановка сервера")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTPShutdownTimeout)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
 }
@@ -0,0 +1,5 @@
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMPTZ;
+
+ALTER TABLE users DROP CONSTRAINT users_email_key;
+CREATE UNIQUE INDEX users_email_active_key ON users (email) WHERE deleted_at IS NULL;
+CREATE INDEX users_deleted_at_idx ON users (deleted_at) WHERE deleted_at IS NOT NULL;
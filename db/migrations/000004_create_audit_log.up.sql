@@ -0,0 +1,16 @@
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    actor VARCHAR(255) NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    entity_type VARCHAR(64) NOT NULL,
+    entity_id BIGINT NOT NULL,
+    before JSONB,
+    after JSONB,
+    diff JSONB,
+    request_id VARCHAR(128),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX audit_log_entity_idx ON audit_log (entity_type, entity_id, created_at);
+CREATE INDEX audit_log_actor_idx ON audit_log (actor, created_at);
+CREATE INDEX audit_log_created_at_idx ON audit_log (created_at);
@@ -0,0 +1,16 @@
+CREATE TABLE roles (
+    name VARCHAR(32) PRIMARY KEY,
+    description TEXT NOT NULL
+);
+
+INSERT INTO roles (name, description) VALUES
+    ('admin', 'Полный доступ, включая окончательное удаление, роли и журнал аудита'),
+    ('operator', 'Создание, изменение, удаление и восстановление пользователей'),
+    ('viewer', 'Только чтение пользователей');
+
+CREATE TABLE user_roles (
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role VARCHAR(32) NOT NULL REFERENCES roles (name),
+    granted_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, role)
+);
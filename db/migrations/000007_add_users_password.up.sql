@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN password_hash TEXT;
+ALTER TABLE users ADD COLUMN password_changed_at TIMESTAMPTZ;
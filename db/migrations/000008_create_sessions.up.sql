@@ -0,0 +1,24 @@
+CREATE TABLE sessions (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    user_agent VARCHAR(512) NOT NULL DEFAULT '',
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ,
+    revoke_reason VARCHAR(32)
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions (user_id);
+
+-- Все выданные refresh-токены сессии. Использованные токены не удаляются:
+-- их повторное предъявление означает утечку, и тогда отзывается вся сессия.
+CREATE TABLE session_tokens (
+    token_hash BYTEA PRIMARY KEY,
+    session_id BIGINT NOT NULL REFERENCES sessions (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    used_at TIMESTAMPTZ
+);
+
+CREATE INDEX session_tokens_session_id_idx ON session_tokens (session_id);
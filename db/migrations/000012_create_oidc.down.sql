@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS oidc_signing_keys;
+DROP TABLE IF EXISTS oauth_tokens;
+DROP TABLE IF EXISTS oauth_authorization_codes;
+DROP TABLE IF EXISTS oauth_clients;
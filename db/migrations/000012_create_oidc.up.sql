@@ -0,0 +1,49 @@
+-- Клиенты OIDC (приложения, которые входят через этот сервис). У публичных клиентов
+-- (SPA, мобильные приложения) секрета нет, они защищены только PKCE.
+CREATE TABLE oauth_clients (
+    id VARCHAR(64) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    secret_hash BYTEA,
+    redirect_uris TEXT[] NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- grant_id объединяет код авторизации и все токены, выпущенные по нему, чтобы их можно было
+-- отозвать вместе: при повторном использовании кода или отзыве refresh-токена.
+CREATE TABLE oauth_authorization_codes (
+    id BIGSERIAL PRIMARY KEY,
+    code_hash BYTEA NOT NULL UNIQUE,
+    client_id VARCHAR(64) NOT NULL REFERENCES oauth_clients (id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    redirect_uri TEXT NOT NULL,
+    scope TEXT NOT NULL,
+    nonce TEXT NOT NULL DEFAULT '',
+    code_challenge VARCHAR(128) NOT NULL,
+    auth_time TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ
+);
+
+CREATE TABLE oauth_tokens (
+    token_hash BYTEA PRIMARY KEY,
+    kind VARCHAR(16) NOT NULL,
+    grant_id BIGINT NOT NULL REFERENCES oauth_authorization_codes (id) ON DELETE CASCADE,
+    client_id VARCHAR(64) NOT NULL REFERENCES oauth_clients (id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    scope TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX oauth_tokens_grant_id_idx ON oauth_tokens (grant_id);
+
+-- Ключи подписи ID-токенов. Новый ключ создается при ротации, старые остаются в JWKS,
+-- пока подписанные ими токены могут проверяться клиентами.
+CREATE TABLE oidc_signing_keys (
+    id VARCHAR(64) PRIMARY KEY,
+    algorithm VARCHAR(16) NOT NULL,
+    private_key TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,24 @@
+-- Внешние учетные записи (вход через провайдеров OpenID Connect). У одного пользователя может быть
+-- несколько учетных записей у разных провайдеров; учетная запись провайдера связана ровно с одним пользователем.
+CREATE TABLE user_identities (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    provider VARCHAR(64) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_login_at TIMESTAMPTZ,
+    UNIQUE (provider, subject)
+);
+
+CREATE INDEX user_identities_user_id_idx ON user_identities (user_id);
+
+-- Незавершенные входы через провайдера: state из адреса возврата, nonce для ID-токена и PKCE verifier.
+CREATE TABLE sso_login_states (
+    state_hash BYTEA PRIMARY KEY,
+    provider VARCHAR(64) NOT NULL,
+    nonce TEXT NOT NULL,
+    code_verifier TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
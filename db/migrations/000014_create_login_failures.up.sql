@@ -0,0 +1,12 @@
+-- Неудачные попытки входа по учетной записи (scope = 'account', key — email в нижнем регистре)
+-- и по адресу клиента (scope = 'ip'). Счетчик сбрасывается, если попыток не было дольше окна.
+CREATE TABLE login_failures (
+    scope VARCHAR(16) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    failures INTEGER NOT NULL,
+    last_failure_at TIMESTAMPTZ NOT NULL,
+    locked_until TIMESTAMPTZ,
+    PRIMARY KEY (scope, key)
+);
+
+CREATE INDEX login_failures_last_failure_at_idx ON login_failures (last_failure_at);
@@ -0,0 +1,10 @@
+-- Бакеты ограничения частоты запросов: tokens — сколько запросов осталось на момент updated_at,
+-- allowed — пропущен ли последний запрос. Ключ — клиент и, если у маршрута своя квота, маршрут.
+CREATE TABLE rate_limit_buckets (
+    key VARCHAR(512) PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    allowed BOOLEAN NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX rate_limit_buckets_updated_at_idx ON rate_limit_buckets (updated_at);
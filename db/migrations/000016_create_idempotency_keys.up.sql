@@ -0,0 +1,15 @@
+-- Ключи Idempotency-Key. client — кто прислал запрос (API-ключ, пользователь или адрес),
+-- fingerprint — SHA-256 метода, пути и тела. status = 0, пока первый запрос еще выполняется.
+CREATE TABLE idempotency_keys (
+    client VARCHAR(255) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    fingerprint BYTEA NOT NULL,
+    status INTEGER NOT NULL DEFAULT 0,
+    content_type VARCHAR(255) NOT NULL DEFAULT '',
+    body BYTEA,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (client, key)
+);
+
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
@@ -0,0 +1,2 @@
+ALTER TABLE IF EXISTS idempotency_keys DROP COLUMN IF EXISTS locked_at;
+ALTER TABLE IF EXISTS idempotency_keys DROP COLUMN IF EXISTS headers;
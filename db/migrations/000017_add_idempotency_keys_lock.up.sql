@@ -0,0 +1,5 @@
+-- headers — заголовки ответа (ETag, Location, Retry-After), которые повторяются вместе с телом.
+-- locked_at — когда ключ занял выполняющийся запрос: если он так и не завершился, ключ
+-- после IDEMPOTENCY_LOCK_TIMEOUT может занять повторный запрос.
+ALTER TABLE idempotency_keys ADD COLUMN headers JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE idempotency_keys ADD COLUMN locked_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
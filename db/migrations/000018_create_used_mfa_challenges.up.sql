@@ -0,0 +1,9 @@
+-- Токены входа (jti), по которым уже пройден второй фактор: повторно такой токен не принимается.
+-- Запись нужна только до истечения токена.
+CREATE TABLE used_mfa_challenges (
+    jti VARCHAR(64) PRIMARY KEY,
+    used_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX used_mfa_challenges_expires_at_idx ON used_mfa_challenges (expires_at);
@@ -10,6 +10,6 @@ echo "база готова, применяем миграции..."
   exit 1
 }
 echo "миграции успешно применены, запускаем приложение..."
-/root/main
+exec /root/main "$@"
 
 
@@ -0,0 +1,96 @@
+// Package apperr — общий для repository и service каталог ошибок. Каждая ошибка относится к одному
+// из видов (Kind), по которому транспорт выбирает статус ответа, и имеет стабильный код для клиентов.
+package apperr
+
+import "errors"
+
+type Kind string
+
+const (
+	KindInvalid              Kind = "invalid"
+	KindValidation           Kind = "validation"
+	KindUnauthorized         Kind = "unauthorized"
+	KindForbidden            Kind = "forbidden"
+	KindNotFound             Kind = "not_found"
+	KindConflict             Kind = "conflict"
+	KindPreconditionFailed   Kind = "precondition_failed"
+	KindPreconditionRequired Kind = "precondition_required"
+	KindTooLarge             Kind = "too_large"
+	KindTooManyRequests      Kind = "too_many_requests"
+	KindInternal             Kind = "internal"
+)
+
+// Общие ошибки для случаев, когда у хранилища или сервиса нет более точной.
+var (
+	ErrNotFound           = New(KindNotFound, "not_found", "объект не найден")
+	ErrConflict           = New(KindConflict, "conflict", "объект конфликтует с существующими данными")
+	ErrValidation         = New(KindValidation, "validation_failed", "данные не прошли проверку")
+	ErrPreconditionFailed = New(KindPreconditionFailed, "precondition_failed", "условие запроса не выполнено")
+)
+
+// FieldError описывает ошибку в одном поле запроса. Если заданы Args, Message служит форматом
+// для fmt.Sprintf и подставляет их после перевода.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Args    []any  `json:"-"`
+}
+
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Fields  []FieldError
+	Err     error
+}
+
+func New(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is сравнивает ошибки по коду, поэтому errors.Is находит ошибку каталога и после WithFields или Wrap,
+// а ошибка хранилища совпадает с ошибкой сервиса с тем же кодом.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// WithFields возвращает копию ошибки с ошибками отдельных полей.
+func (e *Error) WithFields(fields ...FieldError) *Error {
+	copied := *e
+	copied.Fields = append(append([]FieldError(nil), e.Fields...), fields...)
+	return &copied
+}
+
+// Wrap возвращает копию ошибки с исходной причиной, доступной через errors.Unwrap.
+func (e *Error) Wrap(err error) *Error {
+	copied := *e
+	copied.Err = err
+	return &copied
+}
+
+// As возвращает ошибку каталога из цепочки err.
+func As(err error) (*Error, bool) {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr, true
+	}
+	return nil, false
+}
+
+// KindOf возвращает вид ошибки; ошибки вне каталога считаются внутренними.
+func KindOf(err error) Kind {
+	if appErr, ok := As(err); ok {
+		return appErr.Kind
+	}
+	return KindInternal
+}
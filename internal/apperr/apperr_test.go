@@ -0,0 +1,36 @@
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestError_IsMatchesByCode(t *testing.T) {
+	repoErr := New(KindNotFound, "user_not_found", "пользователь не найден")
+	serviceErr := New(KindNotFound, "user_not_found", "пользователь не найден")
+
+	wrapped := fmt.Errorf("ошибка при обновлении: %w", repoErr)
+	assert.ErrorIs(t, wrapped, serviceErr)
+	assert.NotErrorIs(t, wrapped, ErrNotFound)
+	assert.Equal(t, KindNotFound, KindOf(wrapped))
+	assert.Equal(t, KindInternal, KindOf(errors.New("сбой")))
+}
+
+func TestError_WithFieldsKeepsOriginal(t *testing.T) {
+	err := ErrValidation.WithFields(FieldError{Field: "email", Code: "required", Message: "укажите email"})
+
+	assert.ErrorIs(t, err, ErrValidation)
+	assert.Empty(t, ErrValidation.Fields)
+	assert.Equal(t, []FieldError{{Field: "email", Code: "required", Message: "укажите email"}}, err.Fields)
+}
+
+func TestError_WrapKeepsCause(t *testing.T) {
+	cause := errors.New("duplicate key")
+	err := ErrConflict.Wrap(cause)
+
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.ErrorIs(t, err, cause)
+	assert.Equal(t, ErrConflict.Message, err.Error())
+}
@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"context"
+	"strconv"
+	"testovoe/internal/domain"
+)
+
+const APIKeyScheme = "ApiKey"
+
+type APIKeyVerifier interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (*domain.APIKey, error)
+}
+
+// APIKeyAuthenticator принимает заголовок Authorization: ApiKey <ключ>.
+type APIKeyAuthenticator struct {
+	keys APIKeyVerifier
+}
+
+func NewAPIKeyAuthenticator(keys APIKeyVerifier) *APIKeyAuthenticator {
+	return &APIKeyAuthenticator{keys: keys}
+}
+
+func (a *APIKeyAuthenticator) Scheme() string {
+	return APIKeyScheme
+}
+
+func (a *APIKeyAuthenticator) Authenticate(ctx context.Context, credentials string) (*Principal, error) {
+	key, err := a.keys.AuthenticateAPIKey(ctx, credentials)
+	if err != nil {
+		return nil, err
+	}
+	principal := NewPrincipal(strconv.FormatInt(key.UserID, 10), MethodAPIKey)
+	principal.APIKeyID = key.ID
+	return principal, nil
+}
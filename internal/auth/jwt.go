@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"os"
+	"strconv"
+	"testovoe/internal/config"
+	"time"
+)
+
+const BearerScheme = "Bearer"
+
+type JWTConfig struct {
+	HMACSecret     []byte
+	RSAPublicKey   *rsa.PublicKey
+	EdDSAPublicKey ed25519.PublicKey
+	Issuer         string
+	Audience       string
+	ClockSkew      time.Duration
+}
+
+// LoadJWTConfig собирает параметры проверки JWT из конфигурации, читая публичные ключи из PEM-файлов.
+func LoadJWTConfig(cfg *config.Config) (JWTConfig, error) {
+	jwtConfig := JWTConfig{
+		HMACSecret: []byte(cfg.JWTHMACSecret),
+		Issuer:     cfg.JWTIssuer,
+		Audience:   cfg.JWTAudience,
+		ClockSkew:  cfg.JWTClockSkew,
+	}
+
+	if cfg.JWTRSAPublicKeyFile != "" {
+		data, err := os.ReadFile(cfg.JWTRSAPublicKeyFile)
+		if err != nil {
+			return JWTConfig{}, fmt.Errorf("ошибка при чтении RSA ключа: %w", err)
+		}
+		if jwtConfig.RSAPublicKey, err = jwt.ParseRSAPublicKeyFromPEM(data); err != nil {
+			return JWTConfig{}, fmt.Errorf("некорректный RSA ключ: %w", err)
+		}
+	}
+
+	if cfg.JWTEdDSAPublicKeyFile != "" {
+		data, err := os.ReadFile(cfg.JWTEdDSAPublicKeyFile)
+		if err != nil {
+			return JWTConfig{}, fmt.Errorf("ошибка при чтении EdDSA ключа: %w", err)
+		}
+		key, err := jwt.ParseEdPublicKeyFromPEM(data)
+		if err != nil {
+			return JWTConfig{}, fmt.Errorf("некорректный EdDSA ключ: %w", err)
+		}
+		jwtConfig.EdDSAPublicKey = key.(ed25519.PublicKey)
+	}
+
+	return jwtConfig, nil
+}
+
+// SessionValidator сообщает, действует ли сессия, к которой привязан access-токен.
+type SessionValidator interface {
+	SessionActive(ctx context.Context, id int64) (bool, error)
+}
+
+// JWTVerifier проверяет токены HS256, RS256 и EdDSA: алгоритм определяется заголовком токена,
+// но принимаются только те, для которых настроен ключ.
+type JWTVerifier struct {
+	cfg      JWTConfig
+	parser   *jwt.Parser
+	sessions SessionValidator
+}
+
+// NewJWTVerifier создает проверку токенов. Если sessions задан, токены с claim sid принимаются
+// только пока их сессия не отозвана; токены без sid (например, выпущенные внешним сервисом)
+// проверяются только по подписи и срокам.
+func NewJWTVerifier(cfg JWTConfig, sessions SessionValidator) (*JWTVerifier, error) {
+	var methods []string
+	if len(cfg.HMACSecret) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if cfg.RSAPublicKey != nil {
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	if len(cfg.EdDSAPublicKey) > 0 {
+		methods = append(methods, jwt.SigningMethodEdDSA.Alg())
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("не задан ни один ключ проверки JWT")
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(cfg.ClockSkew),
+	}
+	if cfg.Issuer != "" {
+		options = append(options, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		options = append(options, jwt.WithAudience(cfg.Audience))
+	}
+
+	return &JWTVerifier{cfg: cfg, parser: jwt.NewParser(options...), sessions: sessions}, nil
+}
+
+func (v *JWTVerifier) Scheme() string {
+	return BearerScheme
+}
+
+func (v *JWTVerifier) Authenticate(ctx context.Context, credentials string) (*Principal, error) {
+	var claims Claims
+	if _, err := v.parser.ParseWithClaims(credentials, &claims, v.key); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+	if claims.TokenUse != "" && claims.TokenUse != TokenUseAccess {
+		return nil, fmt.Errorf("%w: токен не предназначен для доступа", ErrInvalidCredentials)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: в токене нет sub", ErrInvalidCredentials)
+	}
+	principal := NewPrincipal(claims.Subject, MethodJWT)
+
+	if claims.SessionID != "" {
+		sessionID, err := strconv.ParseInt(claims.SessionID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: некорректный sid", ErrInvalidCredentials)
+		}
+		principal.SessionID = sessionID
+		if v.sessions != nil {
+			active, err := v.sessions.SessionActive(ctx, sessionID)
+			if err != nil {
+				return nil, err
+			}
+			if !active {
+				return nil, fmt.Errorf("%w: сессия отозвана", ErrInvalidCredentials)
+			}
+		}
+	}
+	return principal, nil
+}
+
+func (v *JWTVerifier) key(token *jwt.Token) (any, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		return v.cfg.HMACSecret, nil
+	case *jwt.SigningMethodRSA:
+		return v.cfg.RSAPublicKey, nil
+	case *jwt.SigningMethodEd25519:
+		return v.cfg.EdDSAPublicKey, nil
+	}
+	return nil, fmt.Errorf("неподдерживаемый алгоритм %s", token.Method.Alg())
+}
@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func signToken(t *testing.T, method jwt.SigningMethod, key any, claims jwt.RegisteredClaims) string {
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	assert.NoError(t, err)
+	return token
+}
+
+func validClaims() jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Subject:   "42",
+		Issuer:    "testovoe",
+		Audience:  jwt.ClaimStrings{"users-api"},
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}
+}
+
+func TestJWTVerifier_Algorithms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	secret := []byte("hmac-secret")
+
+	verifier, err := NewJWTVerifier(JWTConfig{
+		HMACSecret:     secret,
+		RSAPublicKey:   &rsaKey.PublicKey,
+		EdDSAPublicKey: edPublic,
+		Issuer:         "testovoe",
+		Audience:       "users-api",
+	}, nil)
+	assert.NoError(t, err)
+
+	for name, token := range map[string]string{
+		"HS256": signToken(t, jwt.SigningMethodHS256, secret, validClaims()),
+		"RS256": signToken(t, jwt.SigningMethodRS256, rsaKey, validClaims()),
+		"EdDSA": signToken(t, jwt.SigningMethodEdDSA, edPrivate, validClaims()),
+	} {
+		t.Run(name, func(t *testing.T) {
+			principal, err := verifier.Authenticate(context.Background(), token)
+			assert.NoError(t, err)
+			assert.Equal(t, "42", principal.Subject)
+			assert.Equal(t, int64(42), principal.UserID)
+			assert.Equal(t, MethodJWT, principal.Method)
+		})
+	}
+}
+
+func TestJWTVerifier_Rejects(t *testing.T) {
+	secret := []byte("hmac-secret")
+	verifier, err := NewJWTVerifier(JWTConfig{
+		HMACSecret: secret,
+		Issuer:     "testovoe",
+		Audience:   "users-api",
+		ClockSkew:  10 * time.Second,
+	}, nil)
+	assert.NoError(t, err)
+
+	expired := validClaims()
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	wrongIssuer := validClaims()
+	wrongIssuer.Issuer = "other"
+	wrongAudience := validClaims()
+	wrongAudience.Audience = jwt.ClaimStrings{"other"}
+	noExpiry := validClaims()
+	noExpiry.ExpiresAt = nil
+	noSubject := validClaims()
+	noSubject.Subject = ""
+	_, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	for name, token := range map[string]string{
+		"expired":        signToken(t, jwt.SigningMethodHS256, secret, expired),
+		"wrong issuer":   signToken(t, jwt.SigningMethodHS256, secret, wrongIssuer),
+		"wrong audience": signToken(t, jwt.SigningMethodHS256, secret, wrongAudience),
+		"no expiry":      signToken(t, jwt.SigningMethodHS256, secret, noExpiry),
+		"no subject":     signToken(t, jwt.SigningMethodHS256, secret, noSubject),
+		"wrong secret":   signToken(t, jwt.SigningMethodHS256, []byte("other"), validClaims()),
+		"unconfigured":   signToken(t, jwt.SigningMethodEdDSA, edPrivate, validClaims()),
+		"garbage":        "not-a-token",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := verifier.Authenticate(context.Background(), token)
+			assert.ErrorIs(t, err, ErrInvalidCredentials)
+		})
+	}
+}
+
+func TestJWTVerifier_ClockSkew(t *testing.T) {
+	secret := []byte("hmac-secret")
+	verifier, err := NewJWTVerifier(JWTConfig{HMACSecret: secret, ClockSkew: time.Minute}, nil)
+	assert.NoError(t, err)
+
+	claims := validClaims()
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-30 * time.Second))
+	_, err = verifier.Authenticate(context.Background(), signToken(t, jwt.SigningMethodHS256, secret, claims))
+	assert.NoError(t, err)
+}
+
+func TestNewJWTVerifier_NoKeys(t *testing.T) {
+	_, err := NewJWTVerifier(JWTConfig{}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+var testArgon2Params = Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestPasswordHasher_HashAndVerify(t *testing.T) {
+	hasher := NewPasswordHasher(testArgon2Params)
+
+	hash, err := hasher.Hash("correct horse")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$"))
+
+	ok, needsRehash, err := hasher.Verify("correct horse", hash)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.False(t, needsRehash)
+
+	ok, _, err = hasher.Verify("wrong horse", hash)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	other, err := hasher.Hash("correct horse")
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, other)
+}
+
+func TestPasswordHasher_NeedsRehash(t *testing.T) {
+	hash, err := NewPasswordHasher(testArgon2Params).Hash("correct horse")
+	assert.NoError(t, err)
+
+	stronger := testArgon2Params
+	stronger.Iterations = 2
+	ok, needsRehash, err := NewPasswordHasher(stronger).Verify("correct horse", hash)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, needsRehash)
+}
+
+func TestPasswordHasher_InvalidHash(t *testing.T) {
+	hasher := NewPasswordHasher(testArgon2Params)
+
+	for _, hash := range []string{"", "plain", "$argon2i$v=19$m=1024,t=1,p=1$c2FsdA$a2V5", "$argon2id$v=16$m=1024,t=1,p=1$c2FsdA$a2V5"} {
+		_, _, err := hasher.Verify("password", hash)
+		assert.ErrorIs(t, err, ErrInvalidPasswordHash, hash)
+	}
+}
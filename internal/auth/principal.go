@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strconv"
+)
+
+var ErrInvalidCredentials = errors.New("неверные учетные данные")
+
+const (
+	MethodJWT    = "jwt"
+	MethodAPIKey = "api_key"
+)
+
+// Principal описывает аутентифицированного клиента, от имени которого выполняется запрос.
+type Principal struct {
+	Subject   string
+	UserID    int64
+	Method    string
+	APIKeyID  int64
+	SessionID int64
+}
+
+func NewPrincipal(subject, method string) *Principal {
+	principal := &Principal{Subject: subject, Method: method}
+	if id, err := strconv.ParseInt(subject, 10, 64); err == nil && id > 0 {
+		principal.UserID = id
+	}
+	return principal
+}
+
+// Actor возвращает идентификатор клиента для журнала аудита.
+func (p *Principal) Actor() string {
+	return "user:" + p.Subject
+}
+
+// Authenticator проверяет учетные данные из заголовка Authorization для одной схемы.
+type Authenticator interface {
+	Scheme() string
+	Authenticate(ctx context.Context, credentials string) (*Principal, error)
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// SecretBoxMinSecretLength — минимальная длина секрета, из которого выводится ключ шифрования.
+const SecretBoxMinSecretLength = 32
+
+const sealedPrefix = "sealed:v1:"
+
+var ErrSealedSecret = errors.New("не удалось расшифровать секрет: неверный ключ или данные повреждены")
+
+// SecretBox шифрует секреты, которые хранятся в базе, алгоритмом AES-256-GCM. Ключ выводится
+// из секрета конфигурации через SHA-256. Зашифрованное значение имеет вид sealed:v1:<base64>
+// и привязано к associatedData, поэтому его нельзя переставить в другую запись.
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+func NewSecretBox(secret string) (*SecretBox, error) {
+	if len(secret) < SecretBoxMinSecretLength {
+		return nil, errors.New("секрет шифрования слишком короткий")
+	}
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretBox{aead: aead}, nil
+}
+
+func (b *SecretBox) Seal(plaintext, associatedData []byte) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize(), b.aead.NonceSize()+len(plaintext)+b.aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, plaintext, associatedData)
+	return sealedPrefix + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+func (b *SecretBox) Open(value string, associatedData []byte) ([]byte, error) {
+	encoded, ok := strings.CutPrefix(value, sealedPrefix)
+	if !ok {
+		return nil, ErrSealedSecret
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < b.aead.NonceSize() {
+		return nil, ErrSealedSecret
+	}
+	nonce, ciphertext := sealed[:b.aead.NonceSize()], sealed[b.aead.NonceSize():]
+	plaintext, err := b.aead.Open(nil, nonce, ciphertext, associatedData)
+	if err != nil {
+		return nil, ErrSealedSecret
+	}
+	return plaintext, nil
+}
+
+// IsSealed сообщает, зашифровано ли значение SecretBox.
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, sealedPrefix)
+}
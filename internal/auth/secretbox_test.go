@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestSecretBox(t *testing.T) {
+	box, err := NewSecretBox(strings.Repeat("k", SecretBoxMinSecretLength))
+	assert.NoError(t, err)
+
+	sealed, err := box.Seal([]byte("private key"), []byte("kid-1"))
+	assert.NoError(t, err)
+	assert.True(t, IsSealed(sealed))
+	assert.NotContains(t, sealed, "private key")
+
+	opened, err := box.Open(sealed, []byte("kid-1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "private key", string(opened))
+
+	// Значение привязано к записи и к ключу.
+	_, err = box.Open(sealed, []byte("kid-2"))
+	assert.ErrorIs(t, err, ErrSealedSecret)
+	other, _ := NewSecretBox(strings.Repeat("x", SecretBoxMinSecretLength))
+	_, err = other.Open(sealed, []byte("kid-1"))
+	assert.ErrorIs(t, err, ErrSealedSecret)
+
+	_, err = NewSecretBox("short")
+	assert.Error(t, err)
+}
@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"os"
+	"strconv"
+	"testovoe/internal/config"
+	"time"
+)
+
+const (
+	TokenUseAccess            = "access"
+	TokenUseEmailVerification = "email_verification"
+	TokenUseMFA               = "mfa"
+)
+
+// Claims — зарегистрированные claims JWT, назначение токена, чтобы токен другого назначения нельзя
+// было предъявить вместо access-токена, и сессия, при отзыве которой токен перестает приниматься.
+type Claims struct {
+	jwt.RegisteredClaims
+	TokenUse  string `json:"token_use,omitempty"`
+	SessionID string `json:"sid,omitempty"`
+	Email     string `json:"email,omitempty"`
+}
+
+type TokenIssuerConfig struct {
+	Method     jwt.SigningMethod
+	Key        crypto.PrivateKey
+	Issuer     string
+	Audience   string
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	// EmailVerificationTTL — срок действия ссылки подтверждения email.
+	EmailVerificationTTL time.Duration
+	// MFAChallengeTTL — сколько времени после проверки пароля можно ввести второй фактор.
+	MFAChallengeTTL time.Duration
+}
+
+// LoadTokenIssuerConfig выбирает ключ подписи: закрытый ключ RSA или Ed25519 из JWT_SIGNING_KEY_FILE,
+// иначе общий секрет HS256 из JWT_HMAC_SECRET.
+func LoadTokenIssuerConfig(cfg *config.Config) (TokenIssuerConfig, error) {
+	issuerConfig := TokenIssuerConfig{
+		Issuer:     cfg.JWTIssuer,
+		Audience:   cfg.JWTAudience,
+		AccessTTL:  cfg.JWTAccessTTL,
+		RefreshTTL: cfg.JWTRefreshTTL,
+
+		EmailVerificationTTL: cfg.EmailVerificationTTL,
+		MFAChallengeTTL:      cfg.MFAChallengeTTL,
+	}
+
+	if cfg.JWTSigningKeyFile == "" {
+		if cfg.JWTHMACSecret == "" {
+			return TokenIssuerConfig{}, errors.New("не задан ключ подписи JWT")
+		}
+		issuerConfig.Method, issuerConfig.Key = jwt.SigningMethodHS256, []byte(cfg.JWTHMACSecret)
+		return issuerConfig, nil
+	}
+
+	data, err := os.ReadFile(cfg.JWTSigningKeyFile)
+	if err != nil {
+		return TokenIssuerConfig{}, fmt.Errorf("ошибка при чтении ключа подписи: %w", err)
+	}
+	if key, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+		issuerConfig.Method, issuerConfig.Key = jwt.SigningMethodRS256, key
+		return issuerConfig, nil
+	}
+	if key, err := jwt.ParseEdPrivateKeyFromPEM(data); err == nil {
+		issuerConfig.Method, issuerConfig.Key = jwt.SigningMethodEdDSA, key
+		return issuerConfig, nil
+	}
+	return TokenIssuerConfig{}, errors.New("ключ подписи должен быть закрытым ключом RSA или Ed25519 в PEM")
+}
+
+// PublicKeys дополняет параметры проверки открытым ключом подписи, чтобы сервис принимал
+// собственные токены без отдельной настройки.
+func (c TokenIssuerConfig) PublicKeys(verify JWTConfig) JWTConfig {
+	switch key := c.Key.(type) {
+	case *rsa.PrivateKey:
+		if verify.RSAPublicKey == nil {
+			verify.RSAPublicKey = &key.PublicKey
+		}
+	case ed25519.PrivateKey:
+		if len(verify.EdDSAPublicKey) == 0 {
+			verify.EdDSAPublicKey = key.Public().(ed25519.PublicKey)
+		}
+	}
+	return verify
+}
+
+type TokenIssuer struct {
+	cfg TokenIssuerConfig
+	now func() time.Time
+}
+
+func NewTokenIssuer(cfg TokenIssuerConfig) *TokenIssuer {
+	return &TokenIssuer{cfg: cfg, now: time.Now}
+}
+
+func (i *TokenIssuer) AccessTTL() time.Duration {
+	return i.cfg.AccessTTL
+}
+
+func (i *TokenIssuer) RefreshTTL() time.Duration {
+	return i.cfg.RefreshTTL
+}
+
+func (i *TokenIssuer) EmailVerificationTTL() time.Duration {
+	return i.cfg.EmailVerificationTTL
+}
+
+// IssueAccessToken выпускает access-токен сессии sessionID; 0 означает токен без сессии.
+func (i *TokenIssuer) IssueAccessToken(subject string, sessionID int64) (string, error) {
+	claims := i.claims(subject, TokenUseAccess, i.cfg.AccessTTL)
+	if sessionID != 0 {
+		claims.SessionID = strconv.FormatInt(sessionID, 10)
+	}
+	return jwt.NewWithClaims(i.cfg.Method, claims).SignedString(i.cfg.Key)
+}
+
+// IssueEmailVerificationToken выпускает токен подтверждения адреса email. Адрес входит в токен,
+// поэтому после смены email старые ссылки подтверждения перестают действовать.
+func (i *TokenIssuer) IssueEmailVerificationToken(subject, email string) (string, error) {
+	claims := i.claims(subject, TokenUseEmailVerification, i.cfg.EmailVerificationTTL)
+	claims.Email = email
+	return jwt.NewWithClaims(i.cfg.Method, claims).SignedString(i.cfg.Key)
+}
+
+// ParseEmailVerificationToken проверяет подпись, срок и назначение токена и возвращает sub и email.
+func (i *TokenIssuer) ParseEmailVerificationToken(token string) (string, string, error) {
+	claims, err := i.parse(token, TokenUseEmailVerification)
+	if err != nil || claims.Email == "" {
+		return "", "", ErrInvalidCredentials
+	}
+	return claims.Subject, claims.Email, nil
+}
+
+// IssueMFAToken выпускает токен, подтверждающий, что пароль проверен и осталось пройти второй фактор.
+func (i *TokenIssuer) IssueMFAToken(subject string) (string, error) {
+	claims := i.claims(subject, TokenUseMFA, i.cfg.MFAChallengeTTL)
+	// Случайный jti делает каждый токен уникальным: неверные коды считаются отдельно по каждому токену.
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	claims.ID = base64.RawURLEncoding.EncodeToString(id)
+	return jwt.NewWithClaims(i.cfg.Method, claims).SignedString(i.cfg.Key)
+}
+
+// MFAChallenge — проверенный токен второго фактора.
+type MFAChallenge struct {
+	Subject   string
+	ID        string
+	ExpiresAt time.Time
+}
+
+// ParseMFAToken проверяет токен второго фактора и возвращает sub, jti и срок действия.
+func (i *TokenIssuer) ParseMFAToken(token string) (*MFAChallenge, error) {
+	claims, err := i.parse(token, TokenUseMFA)
+	if err != nil || claims.ID == "" {
+		return nil, ErrInvalidCredentials
+	}
+	return &MFAChallenge{Subject: claims.Subject, ID: claims.ID, ExpiresAt: claims.ExpiresAt.Time}, nil
+}
+
+func (i *TokenIssuer) claims(subject, use string, ttl time.Duration) Claims {
+	now := i.now()
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			Issuer:    i.cfg.Issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+		TokenUse: use,
+	}
+	if i.cfg.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{i.cfg.Audience}
+	}
+	return claims
+}
+
+// parse принимает только токены, подписанные ключом этого сервиса, с указанным назначением.
+func (i *TokenIssuer) parse(token, use string) (*Claims, error) {
+	var claims Claims
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{i.cfg.Method.Alg()}), jwt.WithExpirationRequired(), jwt.WithTimeFunc(i.now))
+	_, err := parser.ParseWithClaims(token, &claims, func(*jwt.Token) (any, error) {
+		return i.verificationKey(), nil
+	})
+	if err != nil || claims.TokenUse != use || claims.Subject == "" {
+		return nil, ErrInvalidCredentials
+	}
+	return &claims, nil
+}
+
+func (i *TokenIssuer) verificationKey() any {
+	switch key := i.cfg.Key.(type) {
+	case *rsa.PrivateKey:
+		return &key.PublicKey
+	case ed25519.PrivateKey:
+		return key.Public()
+	}
+	return i.cfg.Key
+}
@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type staticSessions map[int64]bool
+
+func (s staticSessions) SessionActive(_ context.Context, id int64) (bool, error) {
+	return s[id], nil
+}
+
+func TestTokenIssuer_RoundTrip(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	cfg := TokenIssuerConfig{
+		Method:     jwt.SigningMethodEdDSA,
+		Key:        private,
+		Issuer:     "testovoe",
+		Audience:   "users-api",
+		AccessTTL:  time.Minute,
+		RefreshTTL: time.Hour,
+	}
+	issuer := NewTokenIssuer(cfg)
+	verifier, err := NewJWTVerifier(cfg.PublicKeys(JWTConfig{Issuer: "testovoe", Audience: "users-api"}), staticSessions{5: true})
+	assert.NoError(t, err)
+
+	access, err := issuer.IssueAccessToken("42", 0)
+	assert.NoError(t, err)
+	principal, err := verifier.Authenticate(context.Background(), access)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), principal.UserID)
+	assert.Zero(t, principal.SessionID)
+
+	access, err = issuer.IssueAccessToken("42", 5)
+	assert.NoError(t, err)
+	principal, err = verifier.Authenticate(context.Background(), access)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), principal.SessionID)
+
+	revoked, err := issuer.IssueAccessToken("42", 6)
+	assert.NoError(t, err)
+	_, err = verifier.Authenticate(context.Background(), revoked)
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"encoding/base32"
+	"github.com/stretchr/testify/assert"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret — ключ SHA-1 из тестовых векторов RFC 6238.
+var rfc6238Secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCode_RFC6238(t *testing.T) {
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for unix, expected := range vectors {
+		code, err := TOTPCode(rfc6238Secret, TOTPStep(time.Unix(unix, 0)))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, code, "время %d", unix)
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	code, err := TOTPCode(rfc6238Secret, TOTPStep(now)-1)
+	assert.NoError(t, err)
+
+	step, ok := ValidateTOTP(rfc6238Secret, code, now)
+	assert.True(t, ok)
+	assert.Equal(t, TOTPStep(now)-1, step)
+
+	_, ok = ValidateTOTP(rfc6238Secret, code, now.Add(3*TOTPPeriod))
+	assert.False(t, ok)
+	_, ok = ValidateTOTP(rfc6238Secret, "12345", now)
+	assert.False(t, ok)
+}
+
+func TestTOTPURI(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	assert.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	link, err := url.Parse(TOTPURI("testovoe", "ivan@example.com", secret))
+	assert.NoError(t, err)
+	assert.Equal(t, "otpauth", link.Scheme)
+	assert.Equal(t, "totp", link.Host)
+	assert.Equal(t, "/testovoe:ivan@example.com", link.Path)
+	assert.Equal(t, secret, link.Query().Get("secret"))
+	assert.Equal(t, "testovoe", link.Query().Get("issuer"))
+}
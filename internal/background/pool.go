@@ -0,0 +1,104 @@
+package background
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	ErrFull   = errors.New("очередь фоновых задач переполнена")
+	ErrClosed = errors.New("очередь фоновых задач закрыта")
+)
+
+type task struct {
+	ctx context.Context
+	fn  func(ctx context.Context)
+}
+
+// Pool выполняет задачи вне запроса ограниченным числом горутин. Очередь тоже ограничена:
+// когда она заполнена, Go сразу возвращает ErrFull, поэтому поток запросов не может породить
+// неограниченное число горутин.
+type Pool struct {
+	tasks chan task
+	wg    sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+
+	// ctx отменяется, если Close не дождался выполнения оставшихся задач.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewPool(size, workers int) *Pool {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Pool{tasks: make(chan task, max(size, 1)), ctx: ctx, cancel: cancel}
+	workers = max(workers, 1)
+	p.wg.Add(workers)
+	for range workers {
+		go p.work()
+	}
+	return p
+}
+
+// Go ставит задачу в очередь. Задача получает контекст со значениями ctx (язык, автор, идентификатор
+// запроса), но не отменяется вместе с запросом; он отменяется, только если Close не дождался задачи.
+func (p *Pool) Go(ctx context.Context, fn func(ctx context.Context)) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrClosed
+	}
+	select {
+	case p.tasks <- task{ctx: context.WithoutCancel(ctx), fn: fn}:
+		return nil
+	default:
+		return ErrFull
+	}
+}
+
+// Pending возвращает число задач, ожидающих в очереди.
+func (p *Pool) Pending() int {
+	return len(p.tasks)
+}
+
+// Close перестает принимать задачи и ждет выполнения уже поставленных. Если ctx завершится раньше,
+// контексты задач отменяются, и Close дожидается, пока они на это отреагируют.
+func (p *Pool) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.tasks)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+	for t := range p.tasks {
+		p.run(t)
+	}
+}
+
+func (p *Pool) run(t task) {
+	ctx, cancel := context.WithCancel(t.ctx)
+	defer cancel()
+	stop := context.AfterFunc(p.ctx, cancel)
+	defer stop()
+	t.fn(ctx)
+}
@@ -1,29 +1,296 @@
 package config
 
 import (
+	"cmp"
+	"errors"
+	"fmt"
 	"github.com/joho/godotenv"
-	"log"
+	"golang.org/x/text/language"
+	"io/fs"
+	"log/slog"
 	"os"
+	"testovoe/internal/domain"
+	"time"
 )
 
 type Config struct {
-	DBUser     string
-	DBPassword string
-	DBHost     string
-	DBPort     string
-	DBName     string
+	HTTPAddr              string
+	HTTPReadTimeout       time.Duration
+	HTTPReadHeaderTimeout time.Duration
+	HTTPWriteTimeout      time.Duration
+	HTTPIdleTimeout       time.Duration
+	HTTPShutdownTimeout   time.Duration
+	TLSCertFile           string
+	TLSKeyFile            string
+	// TrustedProxies — адреса и подсети прокси, чьему X-Forwarded-For можно верить при определении
+	// адреса клиента. По умолчанию список пуст и адресом клиента считается адрес соединения.
+	TrustedProxies []string
+
+	LogLevel  slog.Level
+	LogFormat string
+
+	DBUser            string
+	DBPassword        string
+	DBHost            string
+	DBPort            string
+	DBName            string
+	DBMaxConns        int32
+	DBMinConns        int32
+	DBMaxConnLifetime time.Duration
+	DBMaxConnIdleTime time.Duration
+	DBConnectTimeout  time.Duration
+
+	CursorSecret        string
+	AdminToken          string
+	BootstrapAdminEmail string
+
+	JWTHMACSecret         string
+	JWTRSAPublicKeyFile   string
+	JWTEdDSAPublicKeyFile string
+	JWTIssuer             string
+	JWTAudience           string
+	JWTClockSkew          time.Duration
+	JWTSigningKeyFile     string
+	JWTAccessTTL          time.Duration
+	JWTRefreshTTL         time.Duration
+
+	Argon2Memory      uint32
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+
+	MailDriver           string
+	MailFrom             string
+	MailDir              string
+	SMTPHost             string
+	SMTPPort             string
+	SMTPUsername         string
+	SMTPPassword         string
+	SMTPTimeout          time.Duration
+	MailQueueSize        int
+	MailWorkers          int
+	MailMaxAttempts      int
+	EmailVerificationURL string
+	EmailVerificationTTL time.Duration
+
+	PasswordResetURL     string
+	PasswordResetTTL     time.Duration
+	PasswordResetPerHour uint64
+
+	MFAIssuer       string
+	MFAChallengeTTL time.Duration
+
+	OIDCIssuer       string
+	OIDCLoginURL     string
+	OIDCCodeTTL      time.Duration
+	OIDCAccessTTL    time.Duration
+	OIDCRefreshTTL   time.Duration
+	OIDCIDTokenTTL   time.Duration
+	OIDCKeyRotation  time.Duration
+	OIDCKeyRetention time.Duration
+	// OIDCKeyEncryptionSecret шифрует закрытые ключи подписи в базе; без него ключи хранятся открыто.
+	OIDCKeyEncryptionSecret string
+
+	SSOProviders       []SSOProvider
+	SSORedirectBaseURL string
+	SSOStateTTL        time.Duration
+
+	LockoutThreshold   uint64
+	LockoutIPThreshold uint64
+	LockoutDuration    time.Duration
+	LockoutMaxDuration time.Duration
+	LockoutResetAfter  time.Duration
+
+	RateLimitBackend string
+	RateLimitDefault domain.RateLimit
+	RateLimitRoutes  map[string]domain.RateLimit
+
+	IdempotencyTTL         time.Duration
+	IdempotencyLockTimeout time.Duration
+
+	DefaultLanguage language.Tag
+
+	// Help и PrintConfig — режимы запуска с флагами --help и --print-config.
+	Help        bool
+	PrintConfig bool
+
+	settings []setting
 }
 
-func LoadEnv() *Config {
-	if err := godotenv.Load("./.env"); err != nil {
-		log.Fatalf("ошибка при загрузке .env файла: %v", err)
+// SSOProvider — внешний провайдер OpenID Connect. Провайдеры перечисляются в SSO_PROVIDERS,
+// параметры каждого задаются переменными SSO_<ИМЯ>_*.
+type SSOProvider struct {
+	Name         string
+	DisplayName  string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+}
+
+// dotenvPath — файл с переменными для локального запуска.
+var dotenvPath = ".env"
+
+// Load собирает конфигурацию из значений по умолчанию, файла YAML или TOML, файла .env, переменных
+// окружения и флагов командной строки; каждый следующий источник важнее предыдущего. Файл .env
+// необязателен и в окружение процесса не попадает. Файл конфигурации задается флагом --config
+// или переменной CONFIG_FILE.
+//
+// Ошибки в командной строке и в файле возвращаются без конфигурации. Ошибки в значениях параметров
+// возвращаются все сразу вместе с конфигурацией, чтобы ее можно было вывести с --print-config.
+func Load(args []string) (*Config, error) {
+	dotenv, err := godotenv.Read(dotenvPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("ошибка при загрузке .env файла: %w", err)
 	}
 
+	cli, err := parseArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	file := map[string]string{}
+	if path := cmp.Or(cli.configFile, os.Getenv("CONFIG_FILE"), dotenv["CONFIG_FILE"]); path != "" {
+		if file, err = readFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	l := newLoader(file, dotenv, cli.flags)
+	cfg := l.config()
+	cfg.Help, cfg.PrintConfig = cli.help, cli.printConfig
+	cfg.settings = l.sorted()
+
+	errs := append(l.errs, l.unknown()...)
+	errs = append(errs, cfg.validate()...)
+	return cfg, errors.Join(errs...)
+}
+
+func (l *loader) config() *Config {
 	return &Config{
-		DBUser:     os.Getenv("DB_USER"),
-		DBPassword: os.Getenv("DB_PASSWORD"),
-		DBHost:     os.Getenv("DB_HOST"),
-		DBPort:     os.Getenv("DB_PORT"),
-		DBName:     os.Getenv("DB_NAME"),
+		HTTPAddr:              l.string("HTTP_ADDR", ":8080"),
+		HTTPReadTimeout:       l.duration("HTTP_READ_TIMEOUT", 30*time.Second),
+		HTTPReadHeaderTimeout: l.duration("HTTP_READ_HEADER_TIMEOUT", 5*time.Second),
+		HTTPWriteTimeout:      l.duration("HTTP_WRITE_TIMEOUT", 0),
+		HTTPIdleTimeout:       l.duration("HTTP_IDLE_TIMEOUT", 2*time.Minute),
+		HTTPShutdownTimeout:   l.duration("HTTP_SHUTDOWN_TIMEOUT", 15*time.Second),
+		TLSCertFile:           l.string("TLS_CERT_FILE", ""),
+		TLSKeyFile:            l.string("TLS_KEY_FILE", ""),
+		TrustedProxies:        l.proxies("TRUSTED_PROXIES"),
+
+		LogLevel:  l.logLevel("LOG_LEVEL", slog.LevelInfo),
+		LogFormat: l.string("LOG_FORMAT", "text"),
+
+		DBUser:            l.string("DB_USER", ""),
+		DBPassword:        l.string("DB_PASSWORD", ""),
+		DBHost:            l.string("DB_HOST", "localhost"),
+		DBPort:            l.string("DB_PORT", "5432"),
+		DBName:            l.string("DB_NAME", ""),
+		DBMaxConns:        int32(l.uint("DB_MAX_CONNS", 10, 31)),
+		DBMinConns:        l.int32("DB_MIN_CONNS", 0),
+		DBMaxConnLifetime: l.duration("DB_MAX_CONN_LIFETIME", time.Hour),
+		DBMaxConnIdleTime: l.duration("DB_MAX_CONN_IDLE_TIME", 30*time.Minute),
+		DBConnectTimeout:  l.duration("DB_CONNECT_TIMEOUT", 5*time.Second),
+
+		CursorSecret:        l.string("CURSOR_SECRET", ""),
+		AdminToken:          l.string("ADMIN_TOKEN", ""),
+		BootstrapAdminEmail: l.string("BOOTSTRAP_ADMIN_EMAIL", ""),
+
+		JWTHMACSecret:         l.string("JWT_HMAC_SECRET", ""),
+		JWTRSAPublicKeyFile:   l.string("JWT_RSA_PUBLIC_KEY_FILE", ""),
+		JWTEdDSAPublicKeyFile: l.string("JWT_EDDSA_PUBLIC_KEY_FILE", ""),
+		JWTIssuer:             l.string("JWT_ISSUER", ""),
+		JWTAudience:           l.string("JWT_AUDIENCE", ""),
+		JWTClockSkew:          l.duration("JWT_CLOCK_SKEW", 30*time.Second),
+		JWTSigningKeyFile:     l.string("JWT_SIGNING_KEY_FILE", ""),
+		JWTAccessTTL:          l.duration("JWT_ACCESS_TTL", 15*time.Minute),
+		JWTRefreshTTL:         l.duration("JWT_REFRESH_TTL", 30*24*time.Hour),
+
+		Argon2Memory:      uint32(l.uint("ARGON2_MEMORY_KIB", 64*1024, 32)),
+		Argon2Iterations:  uint32(l.uint("ARGON2_ITERATIONS", 3, 32)),
+		Argon2Parallelism: uint8(l.uint("ARGON2_PARALLELISM", 2, 8)),
+
+		MailDriver:           l.string("MAIL_DRIVER", "log"),
+		MailFrom:             l.string("MAIL_FROM", "no-reply@localhost"),
+		MailDir:              l.string("MAIL_DIR", ""),
+		SMTPHost:             l.string("SMTP_HOST", ""),
+		SMTPPort:             l.string("SMTP_PORT", "587"),
+		SMTPUsername:         l.string("SMTP_USERNAME", ""),
+		SMTPPassword:         l.string("SMTP_PASSWORD", ""),
+		SMTPTimeout:          l.duration("SMTP_TIMEOUT", 30*time.Second),
+		MailQueueSize:        int(l.uint("MAIL_QUEUE_SIZE", 1000, 31)),
+		MailWorkers:          int(l.uint("MAIL_WORKERS", 2, 31)),
+		MailMaxAttempts:      int(l.uint("MAIL_MAX_ATTEMPTS", 5, 31)),
+		EmailVerificationURL: l.string("EMAIL_VERIFICATION_URL", ""),
+		EmailVerificationTTL: l.duration("EMAIL_VERIFICATION_TTL", 48*time.Hour),
+
+		PasswordResetURL:     l.string("PASSWORD_RESET_URL", ""),
+		PasswordResetTTL:     l.duration("PASSWORD_RESET_TTL", time.Hour),
+		PasswordResetPerHour: l.uint("PASSWORD_RESET_PER_HOUR", 3, 32),
+
+		MFAIssuer:       l.string("MFA_ISSUER", "testovoe"),
+		MFAChallengeTTL: l.duration("MFA_CHALLENGE_TTL", 5*time.Minute),
+
+		OIDCIssuer:              l.string("OIDC_ISSUER", "http://localhost:8080"),
+		OIDCLoginURL:            l.string("OIDC_LOGIN_URL", ""),
+		OIDCCodeTTL:             l.duration("OIDC_CODE_TTL", time.Minute),
+		OIDCAccessTTL:           l.duration("OIDC_ACCESS_TTL", time.Hour),
+		OIDCRefreshTTL:          l.duration("OIDC_REFRESH_TTL", 720*time.Hour),
+		OIDCIDTokenTTL:          l.duration("OIDC_ID_TOKEN_TTL", time.Hour),
+		OIDCKeyRotation:         l.duration("OIDC_KEY_ROTATION", 720*time.Hour),
+		OIDCKeyRetention:        l.duration("OIDC_KEY_RETENTION", 24*time.Hour),
+		OIDCKeyEncryptionSecret: l.string("OIDC_KEY_ENCRYPTION_SECRET", ""),
+
+		SSOProviders:       l.ssoProviders(),
+		SSORedirectBaseURL: l.string("SSO_REDIRECT_BASE_URL", "http://localhost:8080"),
+		SSOStateTTL:        l.duration("SSO_STATE_TTL", 10*time.Minute),
+
+		LockoutThreshold:   l.uint("LOCKOUT_THRESHOLD", 5, 32),
+		LockoutIPThreshold: l.uint("LOCKOUT_IP_THRESHOLD", 20, 32),
+		LockoutDuration:    l.duration("LOCKOUT_DURATION", time.Minute),
+		LockoutMaxDuration: l.duration("LOCKOUT_MAX_DURATION", time.Hour),
+		LockoutResetAfter:  l.duration("LOCKOUT_RESET_AFTER", time.Hour),
+
+		RateLimitBackend: l.string("RATE_LIMIT_BACKEND", "memory"),
+		RateLimitDefault: l.rateLimit("RATE_LIMIT_DEFAULT", "600/1m"),
+		RateLimitRoutes:  l.rateLimitRoutes("RATE_LIMIT_ROUTES"),
+
+		IdempotencyTTL:         l.duration("IDEMPOTENCY_TTL", 24*time.Hour),
+		IdempotencyLockTimeout: l.duration("IDEMPOTENCY_LOCK_TIMEOUT", time.Minute),
+
+		DefaultLanguage: l.language("DEFAULT_LANGUAGE", "ru"),
+	}
+}
+
+// validate проверяет связи между параметрами, которые нельзя проверить по одному значению.
+func (c *Config) validate() []error {
+	var errs []error
+	if c.HTTPAddr == "" {
+		errs = append(errs, errors.New("HTTP_ADDR не может быть пустым"))
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		errs = append(errs, errors.New("TLS_CERT_FILE и TLS_KEY_FILE задаются только вместе"))
+	}
+	for _, file := range []struct{ key, path string }{{"TLS_CERT_FILE", c.TLSCertFile}, {"TLS_KEY_FILE", c.TLSKeyFile}} {
+		if file.path == "" {
+			continue
+		}
+		if _, err := os.Stat(file.path); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", file.key, err))
+		}
+	}
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		errs = append(errs, fmt.Errorf("LOG_FORMAT должен быть text или json, получено %q", c.LogFormat))
+	}
+	if c.DBUser == "" || c.DBName == "" {
+		errs = append(errs, errors.New("нужно задать DB_USER и DB_NAME"))
+	}
+	if c.DBMinConns > c.DBMaxConns {
+		errs = append(errs, fmt.Errorf("DB_MIN_CONNS (%d) больше DB_MAX_CONNS (%d)", c.DBMinConns, c.DBMaxConns))
+	}
+	if c.RateLimitBackend != "memory" && c.RateLimitBackend != "postgres" {
+		errs = append(errs, fmt.Errorf("RATE_LIMIT_BACKEND должен быть memory или postgres, получено %q", c.RateLimitBackend))
+	}
+	if c.LockoutDuration > c.LockoutMaxDuration {
+		errs = append(errs, errors.New("LOCKOUT_DURATION не может быть больше LOCKOUT_MAX_DURATION"))
 	}
+	return errs
 }
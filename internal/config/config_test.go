@@ -0,0 +1,191 @@
+package config
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func setRequired(t *testing.T) {
+	t.Setenv("DB_USER", "postgres")
+	t.Setenv("DB_NAME", "testovoedb")
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	setRequired(t)
+
+	cfg, err := Load(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, ":8080", cfg.HTTPAddr)
+	assert.Equal(t, 5*time.Second, cfg.HTTPReadHeaderTimeout)
+	assert.Equal(t, time.Duration(0), cfg.HTTPWriteTimeout)
+	assert.Equal(t, int32(10), cfg.DBMaxConns)
+	assert.Equal(t, slog.LevelInfo, cfg.LogLevel)
+	assert.Equal(t, "text", cfg.LogFormat)
+	assert.Empty(t, cfg.TrustedProxies)
+	assert.False(t, cfg.Help)
+	assert.False(t, cfg.PrintConfig)
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	setRequired(t)
+	path := writeFile(t, "config.yaml", `
+http:
+  addr: ":7000"
+  read_timeout: 10s
+  idle_timeout: 1m
+db_max_conns: 20
+log:
+  level: debug
+sso:
+  providers: [corp]
+  corp:
+    issuer: https://sso.example.com
+    client_id: testovoe
+    scopes: [openid, email]
+`)
+	t.Setenv("HTTP_READ_TIMEOUT", "20s")
+	t.Setenv("HTTP_IDLE_TIMEOUT", "3m")
+	// Пустая переменная окружения не перекрывает файл.
+	t.Setenv("DB_MAX_CONNS", "")
+
+	cfg, err := Load([]string{"--config", path, "--http-read-timeout=40s", "--log-format", "json"})
+	assert.NoError(t, err)
+	assert.Equal(t, ":7000", cfg.HTTPAddr)
+	assert.Equal(t, 40*time.Second, cfg.HTTPReadTimeout)
+	assert.Equal(t, 3*time.Minute, cfg.HTTPIdleTimeout)
+	assert.Equal(t, int32(20), cfg.DBMaxConns)
+	assert.Equal(t, slog.LevelDebug, cfg.LogLevel)
+	assert.Equal(t, "json", cfg.LogFormat)
+	assert.Equal(t, []SSOProvider{{
+		Name:        "corp",
+		DisplayName: "corp",
+		Issuer:      "https://sso.example.com",
+		ClientID:    "testovoe",
+		Scopes:      []string{"openid", "email"},
+	}}, cfg.SSOProviders)
+
+	var out bytes.Buffer
+	cfg.Print(&out)
+	assert.Contains(t, out.String(), `HTTP_ADDR=":7000" # file`)
+	assert.Contains(t, out.String(), `HTTP_IDLE_TIMEOUT="3m" # env`)
+	assert.Contains(t, out.String(), `HTTP_READ_TIMEOUT="40s" # flag`)
+	assert.Contains(t, out.String(), `HTTP_SHUTDOWN_TIMEOUT="15s" # default`)
+}
+
+func TestLoad_Dotenv(t *testing.T) {
+	setRequired(t)
+	path := writeFile(t, "config.yaml", "http:\n  addr: \":7000\"\n")
+	dotenvPath = writeFile(t, ".env", "HTTP_ADDR=:6000\nHTTP_IDLE_TIMEOUT=1m\nDB_MAX_CONNS=30\n")
+	t.Cleanup(func() { dotenvPath = ".env" })
+	t.Setenv("HTTP_IDLE_TIMEOUT", "3m")
+
+	// .env важнее файла конфигурации, но уступает настоящему окружению и в него не попадает.
+	cfg, err := Load([]string{"--config", path})
+	assert.NoError(t, err)
+	assert.Equal(t, ":6000", cfg.HTTPAddr)
+	assert.Equal(t, 3*time.Minute, cfg.HTTPIdleTimeout)
+	assert.Equal(t, int32(30), cfg.DBMaxConns)
+	assert.Empty(t, os.Getenv("DB_MAX_CONNS"))
+
+	var out bytes.Buffer
+	cfg.Print(&out)
+	assert.Contains(t, out.String(), `HTTP_ADDR=":6000" # dotenv`)
+}
+
+func TestLoad_TOML(t *testing.T) {
+	setRequired(t)
+	path := writeFile(t, "config.toml", `
+rate_limit_backend = "postgres"
+trusted_proxies = "10.0.0.0/8, 192.168.1.10"
+
+[db]
+min_conns = 2
+max_conn_lifetime = "2h"
+`)
+	t.Setenv("CONFIG_FILE", path)
+
+	cfg, err := Load(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "postgres", cfg.RateLimitBackend)
+	assert.Equal(t, []string{"10.0.0.0/8", "192.168.1.10"}, cfg.TrustedProxies)
+	assert.Equal(t, int32(2), cfg.DBMinConns)
+	assert.Equal(t, 2*time.Hour, cfg.DBMaxConnLifetime)
+}
+
+func TestLoad_ReportsAllErrors(t *testing.T) {
+	t.Setenv("DB_USER", "")
+	t.Setenv("DB_NAME", "")
+	path := writeFile(t, "config.yaml", "http:\n  adress: \":9090\"\n")
+	t.Setenv("HTTP_READ_TIMEOUT", "soon")
+	t.Setenv("DB_MIN_CONNS", "20")
+	t.Setenv("TLS_CERT_FILE", "cert.pem")
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.1,proxy.local")
+
+	cfg, err := Load([]string{"--config=" + path, "--log-format=xml", "--http-port=9090"})
+	// Конфигурация возвращается и с ошибками, чтобы ее можно было вывести.
+	assert.NotNil(t, cfg)
+	if assert.Error(t, err) {
+		for _, message := range []string{
+			`HTTP_READ_TIMEOUT="soon"`,
+			"параметр HTTP_ADRESS в файле конфигурации",
+			"флаг --http-port",
+			"TLS_CERT_FILE и TLS_KEY_FILE",
+			"LOG_FORMAT",
+			"DB_USER и DB_NAME",
+			"DB_MIN_CONNS (20) больше DB_MAX_CONNS (10)",
+		} {
+			assert.Contains(t, err.Error(), message)
+		}
+	}
+}
+
+func TestLoad_InvalidArgs(t *testing.T) {
+	for _, args := range [][]string{{"serve"}, {"--http-addr"}, {"--config", "config.ini"}, {"--print-config=maybe"}} {
+		cfg, err := Load(args)
+		assert.Nil(t, cfg, args)
+		assert.Error(t, err, args)
+	}
+}
+
+func TestLoad_Modes(t *testing.T) {
+	cfg, _ := Load([]string{"-h"})
+	assert.True(t, cfg.Help)
+
+	cfg, _ = Load([]string{"--print-config"})
+	assert.True(t, cfg.PrintConfig)
+}
+
+func TestPrint_RedactsSecrets(t *testing.T) {
+	setRequired(t)
+	t.Setenv("DB_PASSWORD", "hunter2")
+	t.Setenv("ADMIN_TOKEN", "")
+	t.Setenv("SSO_PROVIDERS", "corp")
+	t.Setenv("SSO_CORP_ISSUER", "https://sso.example.com")
+	t.Setenv("SSO_CORP_CLIENT_ID", "testovoe")
+	t.Setenv("SSO_CORP_CLIENT_SECRET", "s3cret")
+
+	cfg, err := Load(nil)
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	cfg.Print(&out)
+	assert.NotContains(t, out.String(), "hunter2")
+	assert.NotContains(t, out.String(), "s3cret")
+	assert.Contains(t, out.String(), `DB_PASSWORD="<скрыто>" # env`)
+	assert.Contains(t, out.String(), `SSO_CORP_CLIENT_SECRET="<скрыто>" # env`)
+	assert.Contains(t, out.String(), `ADMIN_TOKEN="" # default`)
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// commandLine — разобранные аргументы командной строки.
+type commandLine struct {
+	configFile  string
+	help        bool
+	printConfig bool
+	// flags — значения параметров по ключам: --http-addr=:9090 задает HTTP_ADDR.
+	flags map[string]string
+}
+
+// parseArgs разбирает аргументы вида --name=value, --name value и -name. Имя флага — ключ параметра
+// в нижнем регистре с дефисами; неизвестные имена обнаруживаются позже, при сборке конфигурации.
+func parseArgs(args []string) (commandLine, error) {
+	cli := commandLine{flags: make(map[string]string)}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		name := strings.TrimLeft(arg, "-")
+		if name == arg || name == "" {
+			return cli, fmt.Errorf("неожиданный аргумент %q", arg)
+		}
+		name, value, hasValue := strings.Cut(name, "=")
+
+		switch name {
+		case "h", "help", "print-config":
+			enabled := true
+			if hasValue {
+				var err error
+				if enabled, err = strconv.ParseBool(value); err != nil {
+					return cli, fmt.Errorf("некорректное значение флага --%s: %q", name, value)
+				}
+			}
+			if name == "print-config" {
+				cli.printConfig = enabled
+			} else {
+				cli.help = enabled
+			}
+			continue
+		}
+
+		if !hasValue {
+			if i+1 >= len(args) {
+				return cli, fmt.Errorf("для флага --%s нужно значение", name)
+			}
+			i++
+			value = args[i]
+		}
+		if name == "config" {
+			cli.configFile = value
+			continue
+		}
+		cli.flags[keyName(name)] = value
+	}
+	return cli, nil
+}
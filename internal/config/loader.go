@@ -0,0 +1,329 @@
+package config
+
+import (
+	"fmt"
+	"github.com/pelletier/go-toml/v2"
+	"golang.org/x/text/language"
+	"gopkg.in/yaml.v3"
+	"log/slog"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testovoe/internal/domain"
+	"testovoe/internal/i18n"
+	"testovoe/internal/ratelimit"
+	"time"
+	"unicode"
+)
+
+// Source — источник значения параметра. Источники перечислены по возрастанию приоритета.
+type Source int
+
+const (
+	SourceDefault Source = iota
+	SourceFile
+	SourceDotenv
+	SourceEnv
+	SourceFlag
+)
+
+func (s Source) String() string {
+	switch s {
+	case SourceFile:
+		return "file"
+	case SourceDotenv:
+		return "dotenv"
+	case SourceEnv:
+		return "env"
+	case SourceFlag:
+		return "flag"
+	default:
+		return "default"
+	}
+}
+
+// setting — параметр, прочитанный при загрузке: ключ в виде имени переменной окружения,
+// значение по умолчанию и итоговое значение с его источником.
+type setting struct {
+	Key      string
+	Default  string
+	Value    string
+	Source   Source
+	Redacted bool
+}
+
+// loader собирает значения параметров из файла, .env, окружения и флагов и копит ошибки разбора,
+// чтобы сообщить обо всех сразу.
+type loader struct {
+	file     map[string]string
+	dotenv   map[string]string
+	flags    map[string]string
+	settings map[string]*setting
+	errs     []error
+}
+
+func newLoader(file, dotenv, flags map[string]string) *loader {
+	return &loader{file: file, dotenv: dotenv, flags: flags, settings: make(map[string]*setting)}
+}
+
+func (l *loader) lookup(key, fallback string) string {
+	s := &setting{Key: key, Default: fallback, Value: fallback, Source: SourceDefault, Redacted: secretKey(key)}
+	if value, ok := l.file[key]; ok {
+		s.Value, s.Source = value, SourceFile
+	}
+	if value := l.dotenv[key]; value != "" {
+		s.Value, s.Source = value, SourceDotenv
+	}
+	if value := os.Getenv(key); value != "" {
+		s.Value, s.Source = value, SourceEnv
+	}
+	if value, ok := l.flags[key]; ok {
+		s.Value, s.Source = value, SourceFlag
+	}
+	l.settings[key] = s
+	return s.Value
+}
+
+func (l *loader) fail(key, value string, err error) {
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("некорректное значение %s=%q: %w", key, value, err))
+		return
+	}
+	l.errs = append(l.errs, fmt.Errorf("некорректное значение %s=%q", key, value))
+}
+
+func (l *loader) string(key, fallback string) string {
+	return l.lookup(key, fallback)
+}
+
+func (l *loader) duration(key string, fallback time.Duration) time.Duration {
+	value := l.lookup(key, fallback.String())
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		l.fail(key, value, err)
+		return fallback
+	}
+	return d
+}
+
+// uint разбирает положительное целое не длиннее bits бит.
+func (l *loader) uint(key string, fallback uint64, bits int) uint64 {
+	value := l.lookup(key, strconv.FormatUint(fallback, 10))
+	n, err := strconv.ParseUint(value, 10, bits)
+	if err != nil || n == 0 {
+		l.fail(key, value, nil)
+		return fallback
+	}
+	return n
+}
+
+// int32 разбирает неотрицательное целое.
+func (l *loader) int32(key string, fallback int32) int32 {
+	value := l.lookup(key, strconv.FormatInt(int64(fallback), 10))
+	n, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || n < 0 {
+		l.fail(key, value, nil)
+		return fallback
+	}
+	return int32(n)
+}
+
+func (l *loader) logLevel(key string, fallback slog.Level) slog.Level {
+	value := l.lookup(key, strings.ToLower(fallback.String()))
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		l.fail(key, value, nil)
+		return fallback
+	}
+	return level
+}
+
+func (l *loader) language(key, fallback string) language.Tag {
+	value := l.lookup(key, fallback)
+	tag, err := i18n.Parse(value)
+	if err != nil {
+		l.fail(key, value, err)
+		return i18n.Russian
+	}
+	return tag
+}
+
+func (l *loader) rateLimit(key, fallback string) domain.RateLimit {
+	value := l.lookup(key, fallback)
+	limit, err := ratelimit.ParseLimit(value)
+	if err != nil {
+		l.fail(key, value, err)
+	}
+	return limit
+}
+
+func (l *loader) rateLimitRoutes(key string) map[string]domain.RateLimit {
+	value := l.lookup(key, "")
+	routes, err := ratelimit.ParseRoutes(value)
+	if err != nil {
+		l.fail(key, value, err)
+	}
+	return routes
+}
+
+// proxies разбирает список адресов и подсетей через запятую, например "10.0.0.0/8, 192.168.1.10".
+func (l *loader) proxies(key string) []string {
+	value := l.lookup(key, "")
+	proxies := listValue(value)
+	for _, proxy := range proxies {
+		if _, err := netip.ParsePrefix(proxy); err == nil {
+			continue
+		}
+		if _, err := netip.ParseAddr(proxy); err != nil {
+			l.fail(key, value, fmt.Errorf("%q не является адресом или подсетью", proxy))
+			return nil
+		}
+	}
+	return proxies
+}
+
+func (l *loader) ssoProviders() []SSOProvider {
+	providers := make([]SSOProvider, 0)
+	for _, name := range strings.Split(l.lookup("SSO_PROVIDERS", ""), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		prefix := "SSO_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		provider := SSOProvider{
+			Name:         name,
+			DisplayName:  l.string(prefix+"DISPLAY_NAME", name),
+			Issuer:       l.string(prefix+"ISSUER", ""),
+			ClientID:     l.string(prefix+"CLIENT_ID", ""),
+			ClientSecret: l.string(prefix+"CLIENT_SECRET", ""),
+			Scopes:       listValue(l.string(prefix+"SCOPES", "openid email profile")),
+		}
+		if provider.Issuer == "" || provider.ClientID == "" {
+			l.errs = append(l.errs, fmt.Errorf("для провайдера %s нужно задать %sISSUER и %sCLIENT_ID", name, prefix, prefix))
+		}
+		providers = append(providers, provider)
+	}
+	return providers
+}
+
+// unknown возвращает ошибки для ключей из файла и флагов, которые не относятся ни к одному параметру.
+func (l *loader) unknown() []error {
+	var errs []error
+	for _, source := range []struct {
+		values map[string]string
+		name   func(string) string
+	}{
+		{l.file, func(key string) string { return "параметр " + key + " в файле конфигурации" }},
+		{l.flags, func(key string) string { return "флаг --" + flagName(key) }},
+	} {
+		keys := make([]string, 0, len(source.values))
+		for key := range source.values {
+			if _, ok := l.settings[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			errs = append(errs, fmt.Errorf("неизвестный %s", source.name(key)))
+		}
+	}
+	return errs
+}
+
+// sorted возвращает прочитанные параметры по алфавиту.
+func (l *loader) sorted() []setting {
+	settings := make([]setting, 0, len(l.settings))
+	for _, s := range l.settings {
+		settings = append(settings, *s)
+	}
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Key < settings[j].Key })
+	return settings
+}
+
+// secretKey сообщает, нужно ли скрывать значение параметра при выводе конфигурации.
+func secretKey(key string) bool {
+	for _, suffix := range []string{"_SECRET", "_PASSWORD", "_TOKEN"} {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// listValue разбирает список, разделенный запятыми или пробелами.
+func listValue(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
+}
+
+// flagName переводит ключ параметра в имя флага: JWT_ACCESS_TTL — --jwt-access-ttl.
+func flagName(key string) string {
+	return strings.ToLower(strings.ReplaceAll(key, "_", "-"))
+}
+
+func keyName(name string) string {
+	return strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// readFile читает файл конфигурации YAML или TOML. Вложенные ключи склеиваются через "_" и приводятся
+// к верхнему регистру, поэтому http: {addr: ":8080"} и http_addr: ":8080" задают HTTP_ADDR.
+// Списки склеиваются через запятую.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при чтении файла конфигурации: %w", err)
+	}
+
+	var tree map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return nil, fmt.Errorf("неизвестный формат файла конфигурации %s, ожидается .yaml, .yml или .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при разборе файла конфигурации %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	if err := flatten("", tree, values); err != nil {
+		return nil, fmt.Errorf("ошибка в файле конфигурации %s: %w", path, err)
+	}
+	return values, nil
+}
+
+func flatten(prefix string, value any, out map[string]string) error {
+	switch v := value.(type) {
+	case map[string]any:
+		for name, child := range v {
+			key := keyName(name)
+			if prefix != "" {
+				key = prefix + "_" + key
+			}
+			if err := flatten(key, child, out); err != nil {
+				return err
+			}
+		}
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			switch item.(type) {
+			case map[string]any, []any:
+				return fmt.Errorf("%s: ожидается список значений", prefix)
+			}
+			items[i] = fmt.Sprint(item)
+		}
+		out[prefix] = strings.Join(items, ",")
+	case nil:
+		if prefix != "" {
+			out[prefix] = ""
+		}
+	default:
+		out[prefix] = fmt.Sprint(v)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const redacted = "<скрыто>"
+
+// Print выводит итоговые значения параметров в формате .env с источником каждого значения.
+// Секреты (ключи с окончанием _SECRET, _PASSWORD и _TOKEN) скрываются, если заданы.
+func (c *Config) Print(w io.Writer) {
+	for _, s := range c.settings {
+		value := s.Value
+		if s.Redacted && value != "" {
+			value = redacted
+		}
+		fmt.Fprintf(w, "%s=%s # %s\n", s.Key, strconv.Quote(value), s.Source)
+	}
+}
+
+// PrintUsage выводит справку по флагам и параметрам.
+func (c *Config) PrintUsage(w io.Writer) {
+	fmt.Fprintln(w, "Параметры задаются флагами, переменными окружения или в файле конфигурации")
+	fmt.Fprintln(w, "(по убыванию приоритета). Флаг --http-addr соответствует переменной HTTP_ADDR")
+	fmt.Fprintln(w, "и ключу http.addr или http_addr в файле.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "  --config <файл>   файл конфигурации .yaml, .yml или .toml (или CONFIG_FILE)")
+	fmt.Fprintln(w, "  --print-config    вывести итоговую конфигурацию со скрытыми секретами и выйти")
+	fmt.Fprintln(w, "  --help            вывести эту справку")
+	fmt.Fprintln(w)
+	for _, s := range c.settings {
+		fmt.Fprintf(w, "  --%s (%s), по умолчанию %s\n", flagName(s.Key), s.Key, strconv.Quote(s.Default))
+	}
+}
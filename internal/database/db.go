@@ -10,11 +10,20 @@ import (
 
 var DB *pgxpool.Pool
 
-func ConnectDB() {
-	cfg := config.LoadEnv()
+func ConnectDB(cfg *config.Config) {
 	dsn := fmt.Sprintf("postgres://%s:%s@%s:%s/%s", cfg.DBUser, cfg.DBPassword, cfg.DBHost, cfg.DBPort, cfg.DBName)
 
-	pool, err := pgxpool.New(context.Background(), dsn)
+	poolConfig, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		log.Fatalf("ошибка в параметрах подключения к базе данных: %v", err)
+	}
+	poolConfig.MaxConns = cfg.DBMaxConns
+	poolConfig.MinConns = cfg.DBMinConns
+	poolConfig.MaxConnLifetime = cfg.DBMaxConnLifetime
+	poolConfig.MaxConnIdleTime = cfg.DBMaxConnIdleTime
+	poolConfig.ConnConfig.ConnectTimeout = cfg.DBConnectTimeout
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
 
 	if err != nil {
 		log.Fatalf("ошибка при подключении к базе данных: %v", err)
@@ -0,0 +1,21 @@
+package domain
+
+import "time"
+
+// APIKey хранит только хеш ключа; сам ключ показывается клиенту один раз при создании или ротации.
+type APIKey struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    []byte     `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RotatedAt  *time.Time `json:"rotated_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+type IssuedAPIKey struct {
+	APIKey APIKey `json:"api_key"`
+	Key    string `json:"key"`
+}
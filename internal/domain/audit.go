@@ -0,0 +1,94 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditEntityUser    = "user"
+	AuditEntityAPIKey  = "api_key"
+	AuditEntitySession = "session"
+	// AuditEntityMFAPolicy — политика обязательного второго фактора; она одна, поэтому entity_id у записей 0.
+	AuditEntityMFAPolicy = "mfa_policy"
+	// У клиентов OIDC и ключей подписи строковые идентификаторы, поэтому они пишутся в before/after, а entity_id равен 0.
+	AuditEntityOAuthClient = "oauth_client"
+	AuditEntitySigningKey  = "oidc_signing_key"
+	// AuditEntityLogin — блокировка входа, не связанная с известным пользователем (адрес клиента или
+	// несуществующий email); ключ пишется в after, entity_id равен 0.
+	AuditEntityLogin = "login"
+)
+
+const (
+	AuditActionUserCreated  = "user.created"
+	AuditActionUserUpdated  = "user.updated"
+	AuditActionUserDeleted  = "user.deleted"
+	AuditActionUserRestored = "user.restored"
+	AuditActionUserPurged   = "user.purged"
+
+	AuditActionUserRolesChanged    = "user.roles_changed"
+	AuditActionUserPasswordChanged = "user.password_changed"
+	// AuditActionUserPasswordChangeFailed — при смене пароля указан неверный текущий пароль.
+	AuditActionUserPasswordChangeFailed = "user.password_change_failed"
+	AuditActionUserEmailVerified        = "user.email_verified"
+	// AuditActionPasswordRehashed — хеш пароля пересчитан при входе с новыми параметрами Argon2.
+	AuditActionPasswordRehashed = "password.rehashed"
+
+	AuditActionUserPasswordResetRequested = "user.password_reset_requested"
+	AuditActionUserPasswordReset          = "user.password_reset"
+
+	AuditActionUserMFAEnabled                = "user.mfa_enabled"
+	AuditActionUserMFADisabled               = "user.mfa_disabled"
+	AuditActionUserMFARecoveryCodesGenerated = "user.mfa_recovery_codes_generated"
+	AuditActionUserMFARecoveryCodeUsed       = "user.mfa_recovery_code_used"
+	AuditActionMFAPolicyChanged              = "mfa.policy_changed"
+
+	AuditActionUserIdentityLinked   = "user.identity_linked"
+	AuditActionUserIdentityUnlinked = "user.identity_unlinked"
+
+	AuditActionLoginLocked   = "login.locked"
+	AuditActionLoginUnlocked = "login.unlocked"
+
+	AuditActionAPIKeyCreated = "api_key.created"
+	AuditActionAPIKeyRotated = "api_key.rotated"
+	AuditActionAPIKeyRevoked = "api_key.revoked"
+
+	AuditActionSessionRevoked       = "session.revoked"
+	AuditActionSessionReuseDetected = "session.reuse_detected"
+
+	AuditActionOAuthClientCreated     = "oauth_client.created"
+	AuditActionOAuthClientDeleted     = "oauth_client.deleted"
+	AuditActionOAuthCodeReuseDetected = "oauth_client.code_reuse_detected"
+	AuditActionOIDCSigningKeyRotated  = "oidc_signing_key.rotated"
+)
+
+type AuditRecord struct {
+	ID         int64           `json:"id"`
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   int64           `json:"entity_id"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	Diff       json.RawMessage `json:"diff,omitempty"`
+	RequestID  string          `json:"request_id,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+type AuditFilter struct {
+	EntityType string
+	EntityID   int64
+	Actor      string
+	Action     string
+	From       *time.Time
+	To         *time.Time
+	Limit      int
+	Offset     int
+}
+
+type AuditList struct {
+	Records []AuditRecord `json:"records"`
+	Total   int64         `json:"total"`
+	Limit   int           `json:"limit"`
+	Offset  int           `json:"offset"`
+}
@@ -0,0 +1,22 @@
+package domain
+
+import "time"
+
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	// RecoveryCodes возвращаются только при подключении второго фактора во время входа.
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+// PasswordResetToken хранит только хеш токена сброса пароля; сам токен уходит пользователю в письме.
+type PasswordResetToken struct {
+	ID        int64
+	UserID    int64
+	TokenHash []byte
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
@@ -0,0 +1,24 @@
+package domain
+
+import "time"
+
+// IdempotencyKey — сохраненный результат запроса с заголовком Idempotency-Key.
+// Status равен 0, пока первый запрос с этим ключом еще выполняется; LockedAt — когда
+// его начал выполнять последний занявший ключ запрос. Headers — заголовки ответа, которые
+// повторяются вместе с телом.
+type IdempotencyKey struct {
+	Client      string
+	Key         string
+	Fingerprint []byte
+	Status      int
+	ContentType string
+	Headers     map[string]string
+	Body        []byte
+	CreatedAt   time.Time
+	LockedAt    time.Time
+	ExpiresAt   time.Time
+}
+
+func (k *IdempotencyKey) Completed() bool {
+	return k.Status != 0
+}
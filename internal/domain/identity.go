@@ -0,0 +1,37 @@
+package domain
+
+import "time"
+
+// Identity — учетная запись пользователя у внешнего провайдера OpenID Connect.
+type Identity struct {
+	ID          int64      `json:"id"`
+	UserID      int64      `json:"user_id"`
+	Provider    string     `json:"provider"`
+	Subject     string     `json:"subject"`
+	Email       string     `json:"email,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
+
+// SSOState — незавершенный вход через внешний провайдер.
+type SSOState struct {
+	StateHash    []byte
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+}
+
+// SSOProvider описывает внешний провайдер для страницы входа.
+type SSOProvider struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	LoginURL    string `json:"login_url"`
+}
+
+// SSOLogin — адрес провайдера, на который отправляется браузер, и state, который нужно сохранить в cookie.
+type SSOLogin struct {
+	URL       string
+	State     string
+	ExpiresAt time.Time
+}
@@ -0,0 +1,32 @@
+package domain
+
+import "time"
+
+// Области, в которых считаются неудачные попытки входа. Неверные коды второго фактора считаются
+// по пользователю (LockScopeMFA, ключ — ID пользователя) и по токену входа (LockScopeMFAChallenge).
+const (
+	LockScopeAccount      = "account"
+	LockScopeIP           = "ip"
+	LockScopeMFA          = "mfa"
+	LockScopeMFAChallenge = "mfa_challenge"
+)
+
+// LoginFailure — счетчик неудачных попыток входа по учетной записи или адресу клиента.
+type LoginFailure struct {
+	Scope         string     `json:"scope"`
+	Key           string     `json:"key"`
+	Failures      int        `json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+}
+
+// Locked сообщает, действует ли блокировка в момент at.
+func (f *LoginFailure) Locked(at time.Time) bool {
+	return f != nil && f.LockedUntil != nil && f.LockedUntil.After(at)
+}
+
+type LockoutStatus struct {
+	Locked      bool       `json:"locked"`
+	Failures    int        `json:"failures"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+}
@@ -0,0 +1,39 @@
+package domain
+
+import "time"
+
+// MFA — настройка TOTP пользователя. Пока ConfirmedAt не задан, второй фактор не действует.
+type MFA struct {
+	UserID       int64
+	Secret       string
+	CreatedAt    time.Time
+	ConfirmedAt  *time.Time
+	LastUsedStep *int64
+}
+
+func (m *MFA) Enabled() bool {
+	return m != nil && m.ConfirmedAt != nil
+}
+
+type MFAStatus struct {
+	Enabled           bool       `json:"enabled"`
+	EnabledAt         *time.Time `json:"enabled_at,omitempty"`
+	Required          bool       `json:"required"`
+	RecoveryCodesLeft int        `json:"recovery_codes_left"`
+}
+
+// MFAEnrollment возвращается один раз при настройке: секрет больше нигде не показывается.
+type MFAEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// MFAProof — код из приложения-аутентификатора или один из кодов восстановления.
+type MFAProof struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+func (p MFAProof) Empty() bool {
+	return p.Code == "" && p.RecoveryCode == ""
+}
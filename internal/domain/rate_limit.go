@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"math"
+	"time"
+)
+
+// RateLimit — квота токен-бакета: не больше Requests запросов подряд, израсходованные запросы
+// восстанавливаются равномерно за Period. Нулевая квота означает, что ограничения нет.
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+}
+
+func (l RateLimit) Enabled() bool {
+	return l.Requests > 0 && l.Period > 0
+}
+
+// Rate возвращает скорость пополнения бакета в запросах в секунду.
+func (l RateLimit) Rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// Result описывает состояние бакета, в котором после запроса осталось tokens запросов.
+func (l RateLimit) Result(tokens float64, allowed bool) *RateLimitResult {
+	result := &RateLimitResult{
+		Allowed:   allowed,
+		Limit:     l.Requests,
+		Remaining: int(math.Max(0, math.Floor(tokens))),
+		Reset:     l.wait(float64(l.Requests) - tokens),
+	}
+	if !allowed {
+		result.RetryAfter = l.wait(1 - tokens)
+	}
+	return result
+}
+
+func (l RateLimit) wait(tokens float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	return time.Duration(tokens / l.Rate() * float64(time.Second))
+}
+
+// RateLimitResult — решение по одному запросу. Reset — через сколько бакет снова будет полным,
+// RetryAfter — через сколько можно повторить отклоненный запрос.
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
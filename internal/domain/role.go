@@ -0,0 +1,85 @@
+package domain
+
+type Role string
+
+const (
+	RoleAdmin    Role = "admin"
+	RoleOperator Role = "operator"
+	RoleViewer   Role = "viewer"
+	// RoleSelf не хранится в базе: его получает любой аутентифицированный пользователь
+	// по отношению к собственной учетной записи.
+	RoleSelf Role = "self"
+)
+
+type Permission string
+
+const (
+	PermissionUsersRead    Permission = "users:read"
+	PermissionUsersCreate  Permission = "users:create"
+	PermissionUsersUpdate  Permission = "users:update"
+	PermissionUsersDelete  Permission = "users:delete"
+	PermissionUsersRestore Permission = "users:restore"
+	PermissionUsersPurge   Permission = "users:purge"
+	PermissionUsersExport  Permission = "users:export"
+	PermissionUsersImport  Permission = "users:import"
+	PermissionRolesManage  Permission = "roles:manage"
+	PermissionAuditRead    Permission = "audit:read"
+	PermissionAPIKeys      Permission = "api_keys:manage"
+	PermissionSessions     Permission = "sessions:manage"
+	PermissionPasswordSet  Permission = "passwords:set"
+	// PermissionPasswordReset позволяет отправить пользователю письмо для сброса пароля.
+	PermissionPasswordReset Permission = "passwords:reset"
+	// PermissionPasswordChange требует знать текущий пароль, поэтому выдается только самому пользователю.
+	PermissionPasswordChange Permission = "passwords:change"
+	// PermissionMFA — настройка собственного второго фактора; требует доступа к приложению-аутентификатору.
+	PermissionMFA Permission = "mfa:manage"
+	// PermissionMFAReset позволяет отключить второй фактор пользователю, потерявшему устройство.
+	PermissionMFAReset Permission = "mfa:reset"
+	// PermissionOIDCClients — регистрация клиентов OIDC и ротация ключей подписи.
+	PermissionOIDCClients Permission = "oidc:manage"
+	// PermissionOIDCAuthorize — вход в стороннее приложение от имени собственной учетной записи.
+	PermissionOIDCAuthorize Permission = "oidc:authorize"
+	// PermissionIdentities — отвязка внешних учетных записей (вход через провайдеров OIDC).
+	PermissionIdentities Permission = "identities:manage"
+	// PermissionLockouts — просмотр и снятие блокировок входа после неудачных попыток.
+	PermissionLockouts Permission = "lockouts:manage"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleAdmin: {
+		PermissionUsersRead, PermissionUsersCreate, PermissionUsersUpdate, PermissionUsersDelete,
+		PermissionUsersRestore, PermissionUsersPurge, PermissionUsersExport, PermissionUsersImport,
+		PermissionRolesManage, PermissionAuditRead, PermissionAPIKeys, PermissionSessions, PermissionPasswordSet,
+		PermissionPasswordReset, PermissionMFAReset, PermissionOIDCClients, PermissionIdentities,
+		PermissionLockouts,
+	},
+	RoleOperator: {
+		PermissionUsersRead, PermissionUsersCreate, PermissionUsersUpdate, PermissionUsersDelete,
+		PermissionUsersRestore, PermissionUsersExport, PermissionUsersImport, PermissionPasswordReset,
+	},
+	RoleViewer: {
+		PermissionUsersRead,
+	},
+	RoleSelf: {
+		PermissionUsersRead, PermissionUsersUpdate, PermissionAPIKeys, PermissionSessions, PermissionPasswordChange,
+		PermissionMFA, PermissionOIDCAuthorize, PermissionIdentities,
+	},
+}
+
+func (r Role) Has(permission Permission) bool {
+	for _, p := range rolePermissions[r] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Assignable сообщает, можно ли выдать роль пользователю явно.
+func (r Role) Assignable() bool {
+	switch r {
+	case RoleAdmin, RoleOperator, RoleViewer:
+		return true
+	}
+	return false
+}
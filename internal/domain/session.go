@@ -0,0 +1,37 @@
+package domain
+
+import "time"
+
+// Причины отзыва сессии.
+const (
+	SessionRevokedLogout = "logout"
+	SessionRevokedByUser = "revoked"
+	SessionRevokedReuse  = "token_reuse"
+	// SessionRevokedPasswordReset — все сессии отзываются после сброса пароля.
+	SessionRevokedPasswordReset = "password_reset"
+	// SessionRevokedPasswordChanged — после смены пароля отзываются все сессии, кроме той, из которой его сменили.
+	SessionRevokedPasswordChanged = "password_changed"
+	// SessionRevokedUserDeleted — все сессии отзываются при удалении пользователя.
+	SessionRevokedUserDeleted = "user_deleted"
+)
+
+// Session — вход пользователя с одного устройства. Refresh-токены сессии меняются при каждом
+// обновлении, а сама сессия живет, пока ее не отзовут или она не истечет.
+type Session struct {
+	ID           int64      `json:"id"`
+	UserID       int64      `json:"user_id"`
+	UserAgent    string     `json:"user_agent"`
+	IP           string     `json:"ip"`
+	CreatedAt    time.Time  `json:"created_at"`
+	LastUsedAt   time.Time  `json:"last_used_at"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	RevokeReason string     `json:"revoke_reason,omitempty"`
+	Current      bool       `json:"current"`
+}
+
+// SessionMeta — сведения о клиенте, которые обновляются при входе и каждом обновлении токенов.
+type SessionMeta struct {
+	UserAgent string
+	IP        string
+}
@@ -1,7 +1,81 @@
 package domain
 
+import "time"
+
+// Ограничения длины полей пользователя, как в схеме таблицы users.
+const (
+	UserNameMaxLength  = 100
+	UserEmailMaxLength = 100
+)
+
 type User struct {
-	ID    int64  `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	ID              int64      `json:"id"`
+	Name            string     `json:"name"`
+	Email           string     `json:"email"`
+	Version         int64      `json:"version"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	DeletedAt       *time.Time `json:"deleted_at,omitempty"`
+}
+
+type UserPatch struct {
+	Name  *string
+	Email *string
+}
+
+func (p UserPatch) IsEmpty() bool {
+	return p.Name == nil && p.Email == nil
+}
+
+const (
+	UserSortByID    = "id"
+	UserSortByName  = "name"
+	UserSortByEmail = "email"
+)
+
+type SortOrder string
+
+const (
+	SortAsc  SortOrder = "asc"
+	SortDesc SortOrder = "desc"
+)
+
+type DeletedFilter string
+
+const (
+	DeletedExclude DeletedFilter = "exclude"
+	DeletedOnly    DeletedFilter = "only"
+	DeletedInclude DeletedFilter = "include"
+)
+
+// AnyVersion вместо версии пользователя означает «любая текущая версия» (If-Match: *).
+const AnyVersion int64 = 0
+
+type UserFilter struct {
+	Name      string
+	Email     string
+	SortBy    string
+	SortOrder SortOrder
+	Deleted   DeletedFilter
+	Limit     int
+	Offset    int
+	Cursor    string
+	After     *UserCursor
+}
+
+type UserCursor struct {
+	SortBy    string    `json:"s"`
+	SortOrder SortOrder `json:"o"`
+	// Filter — хеш условий отбора, для которых выдан курсор.
+	Filter string `json:"f"`
+	Value  string `json:"v,omitempty"`
+	ID     int64  `json:"id"`
+}
+
+type UserList struct {
+	Users      []User `json:"users"`
+	Total      *int64 `json:"total,omitempty"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"`
 }
@@ -0,0 +1,28 @@
+package domain
+
+type UserImportMode string
+
+const (
+	ImportAtomic      UserImportMode = "atomic"
+	ImportSkipInvalid UserImportMode = "skip_invalid"
+)
+
+type UserImportRow struct {
+	Line       int
+	User       User
+	ParseError string
+}
+
+type UserImportRowError struct {
+	Line  int    `json:"line"`
+	Email string `json:"email,omitempty"`
+	Error string `json:"error"`
+}
+
+type UserImportResult struct {
+	Mode     UserImportMode       `json:"mode"`
+	Total    int                  `json:"total"`
+	Imported int                  `json:"imported"`
+	Skipped  int                  `json:"skipped"`
+	Errors   []UserImportRowError `json:"errors"`
+}
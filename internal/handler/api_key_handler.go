@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"testovoe/internal/service"
+)
+
+type APIKeyHandler struct {
+	service service.APIKeyServiceInterface
+}
+
+func NewAPIKeyHandler(service service.APIKeyServiceInterface) *APIKeyHandler {
+	return &APIKeyHandler{service: service}
+}
+
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	userID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		writeError(c, errInvalidBody, "")
+		return
+	}
+
+	issued, err := h.service.CreateAPIKey(c.Request.Context(), userID, request.Name)
+	if err != nil {
+		writeError(c, err, "ошибка при создании API-ключа")
+		return
+	}
+	c.JSON(http.StatusCreated, issued)
+}
+
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	userID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	keys, err := h.service.ListAPIKeys(c.Request.Context(), userID)
+	if err != nil {
+		writeError(c, err, "ошибка при получении API-ключей")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+func (h *APIKeyHandler) RotateAPIKey(c *gin.Context) {
+	userID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	keyID, ok := parseIDParam(c, "key_id")
+	if !ok {
+		return
+	}
+
+	issued, err := h.service.RotateAPIKey(c.Request.Context(), userID, keyID)
+	if err != nil {
+		writeError(c, err, "ошибка при ротации API-ключа")
+		return
+	}
+	c.JSON(http.StatusOK, issued)
+}
+
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	userID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	keyID, ok := parseIDParam(c, "key_id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.RevokeAPIKey(c.Request.Context(), userID, keyID); err != nil {
+		writeError(c, err, "ошибка при отзыве API-ключа")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func parseIDParam(c *gin.Context, name string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil {
+		writeError(c, errInvalidID, "")
+		return 0, false
+	}
+	return id, true
+}
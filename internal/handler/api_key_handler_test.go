@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testovoe/internal/domain"
+	"testovoe/internal/service"
+)
+
+type MockAPIKeyService struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyService) CreateAPIKey(ctx context.Context, userID int64, name string) (*domain.IssuedAPIKey, error) {
+	args := m.Called(ctx, userID, name)
+	return args.Get(0).(*domain.IssuedAPIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyService) ListAPIKeys(ctx context.Context, userID int64) ([]domain.APIKey, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]domain.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyService) RotateAPIKey(ctx context.Context, userID, id int64) (*domain.IssuedAPIKey, error) {
+	args := m.Called(ctx, userID, id)
+	return args.Get(0).(*domain.IssuedAPIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyService) RevokeAPIKey(ctx context.Context, userID, id int64) error {
+	args := m.Called(ctx, userID, id)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyService) AuthenticateAPIKey(ctx context.Context, key string) (*domain.APIKey, error) {
+	args := m.Called(ctx, key)
+	return args.Get(0).(*domain.APIKey), args.Error(1)
+}
+
+func setupAPIKeyRouter(h *APIKeyHandler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.GET("/users/:id/api-keys", h.ListAPIKeys)
+	r.POST("/users/:id/api-keys", h.CreateAPIKey)
+	r.POST("/users/:id/api-keys/:key_id/rotate", h.RotateAPIKey)
+	r.DELETE("/users/:id/api-keys/:key_id", h.RevokeAPIKey)
+	return r
+}
+
+func TestCreateAPIKey(t *testing.T) {
+	mockService := new(MockAPIKeyService)
+	router := setupAPIKeyRouter(NewAPIKeyHandler(mockService))
+
+	issued := &domain.IssuedAPIKey{
+		APIKey: domain.APIKey{ID: 5, UserID: 1, Name: "ci", Prefix: "tvk_abc", KeyHash: []byte("hash")},
+		Key:    "tvk_abc.secret",
+	}
+	mockService.On("CreateAPIKey", mock.Anything, int64(1), "ci").Return(issued, nil)
+
+	req, _ := http.NewRequest("POST", "/users/1/api-keys", bytes.NewBufferString(`{"name":"ci"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"key":"tvk_abc.secret"`)
+	assert.NotContains(t, w.Body.String(), "hash")
+	mockService.AssertExpectations(t)
+}
+
+func TestListAPIKeys_UserNotFound(t *testing.T) {
+	mockService := new(MockAPIKeyService)
+	router := setupAPIKeyRouter(NewAPIKeyHandler(mockService))
+
+	mockService.On("ListAPIKeys", mock.Anything, int64(9)).Return([]domain.APIKey(nil), service.ErrUserNotFound)
+
+	req, _ := http.NewRequest("GET", "/users/9/api-keys", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	mockService := new(MockAPIKeyService)
+	router := setupAPIKeyRouter(NewAPIKeyHandler(mockService))
+
+	mockService.On("RevokeAPIKey", mock.Anything, int64(1), int64(5)).Return(nil)
+	mockService.On("RevokeAPIKey", mock.Anything, int64(1), int64(6)).Return(service.ErrAPIKeyNotFound)
+
+	req, _ := http.NewRequest("DELETE", "/users/1/api-keys/5", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	req, _ = http.NewRequest("DELETE", "/users/1/api-keys/6", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"testovoe/internal/domain"
+	"testovoe/internal/service"
+	"time"
+)
+
+type AuditHandler struct {
+	service service.AuditServiceInterface
+}
+
+func NewAuditHandler(service service.AuditServiceInterface) *AuditHandler {
+	return &AuditHandler{service: service}
+}
+
+func (h *AuditHandler) ListAuditRecords(c *gin.Context) {
+	filter, ok := parseAuditFilter(c)
+	if !ok {
+		return
+	}
+	filter.Actor = c.Query("actor")
+	filter.Action = c.Query("action")
+	filter.EntityType = c.Query("entity_type")
+	if entityID := c.Query("entity_id"); entityID != "" {
+		var err error
+		if filter.EntityID, err = strconv.ParseInt(entityID, 10, 64); err != nil {
+			writeError(c, errInvalidEntityID, "")
+			return
+		}
+	}
+
+	list, err := h.service.ListAuditRecords(c.Request.Context(), filter)
+	if err != nil {
+		writeError(c, err, "ошибка при получении журнала аудита")
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+func (h *AuditHandler) GetUserHistory(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		writeError(c, errInvalidID, "")
+		return
+	}
+
+	filter, ok := parseAuditFilter(c)
+	if !ok {
+		return
+	}
+	filter.Actor = c.Query("actor")
+
+	list, err := h.service.GetUserHistory(c.Request.Context(), id, filter)
+	if err != nil {
+		writeError(c, err, "ошибка при получении журнала аудита")
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+func parseAuditFilter(c *gin.Context) (domain.AuditFilter, bool) {
+	var filter domain.AuditFilter
+	var err error
+
+	if from := c.Query("from"); from != "" {
+		t, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			writeError(c, errInvalidFrom, "")
+			return filter, false
+		}
+		filter.From = &t
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			writeError(c, errInvalidTo, "")
+			return filter, false
+		}
+		filter.To = &t
+	}
+	if limit := c.Query("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil {
+			writeError(c, errInvalidLimit, "")
+			return filter, false
+		}
+	}
+	if offset := c.Query("offset"); offset != "" {
+		if filter.Offset, err = strconv.Atoi(offset); err != nil {
+			writeError(c, errInvalidOffset, "")
+			return filter, false
+		}
+	}
+	return filter, true
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testovoe/internal/domain"
+	"testovoe/internal/service"
+	"time"
+)
+
+type MockAuditService struct {
+	mock.Mock
+}
+
+func (m *MockAuditService) ListAuditRecords(ctx context.Context, filter domain.AuditFilter) (*domain.AuditList, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(*domain.AuditList), args.Error(1)
+}
+
+func (m *MockAuditService) GetUserHistory(ctx context.Context, userID int64, filter domain.AuditFilter) (*domain.AuditList, error) {
+	args := m.Called(ctx, userID, filter)
+	return args.Get(0).(*domain.AuditList), args.Error(1)
+}
+
+func setupAuditRouter(h *AuditHandler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.GET("/audit", h.ListAuditRecords)
+	r.GET("/users/:id/history", h.GetUserHistory)
+	return r
+}
+
+func TestListAuditRecords(t *testing.T) {
+	mockService := new(MockAuditService)
+	handler := NewAuditHandler(mockService)
+	router := setupAuditRouter(handler)
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	filter := domain.AuditFilter{Actor: "admin", From: &from, To: &to}
+	list := &domain.AuditList{
+		Records: []domain.AuditRecord{{ID: 1, Actor: "admin", Action: domain.AuditActionUserUpdated, EntityType: domain.AuditEntityUser, EntityID: 3}},
+		Total:   1,
+	}
+	mockService.On("ListAuditRecords", mock.Anything, filter).Return(list, nil)
+
+	req, _ := http.NewRequest("GET", "/audit?actor=admin&from=2024-01-01T00:00:00Z&to=2024-02-01T00:00:00Z", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"action":"user.updated"`)
+	assert.Contains(t, w.Body.String(), `"total":1`)
+	mockService.AssertExpectations(t)
+}
+
+func TestListAuditRecords_BadTime(t *testing.T) {
+	mockService := new(MockAuditService)
+	handler := NewAuditHandler(mockService)
+	router := setupAuditRouter(handler)
+
+	req, _ := http.NewRequest("GET", "/audit?from=yesterday", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertNotCalled(t, "ListAuditRecords")
+}
+
+func TestGetUserHistory(t *testing.T) {
+	mockService := new(MockAuditService)
+	handler := NewAuditHandler(mockService)
+	router := setupAuditRouter(handler)
+
+	list := &domain.AuditList{Records: []domain.AuditRecord{{ID: 2, EntityID: 5}}, Total: 1}
+	mockService.On("GetUserHistory", mock.Anything, int64(5), domain.AuditFilter{Limit: 10}).Return(list, nil)
+
+	req, _ := http.NewRequest("GET", "/users/5/history?limit=10", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"entity_id":5`)
+	mockService.AssertExpectations(t)
+}
+
+func TestGetUserHistory_InvalidTimeRange(t *testing.T) {
+	mockService := new(MockAuditService)
+	handler := NewAuditHandler(mockService)
+	router := setupAuditRouter(handler)
+
+	mockService.On("GetUserHistory", mock.Anything, int64(5), mock.Anything).Return((*domain.AuditList)(nil), service.ErrInvalidTimeRange)
+
+	req, _ := http.NewRequest("GET", "/users/5/history?from=2024-02-01T00:00:00Z&to=2024-01-01T00:00:00Z", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertExpectations(t)
+}
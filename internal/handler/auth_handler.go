@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"math"
+	"net/http"
+	"strconv"
+	"testovoe/internal/domain"
+	"testovoe/internal/problem"
+	"testovoe/internal/service"
+)
+
+type AuthHandler struct {
+	service service.AuthServiceInterface
+}
+
+func NewAuthHandler(service service.AuthServiceInterface) *AuthHandler {
+	return &AuthHandler{service: service}
+}
+
+func (h *AuthHandler) Login(c *gin.Context) {
+	var request struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		writeError(c, errInvalidBody, "")
+		return
+	}
+
+	tokens, err := h.service.Login(c.Request.Context(), request.Email, request.Password, sessionMeta(c))
+	if err != nil {
+		if writeMFAChallenge(c, err) {
+			return
+		}
+		writeError(c, err, "ошибка при входе")
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var request struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		writeError(c, errInvalidBody, "")
+		return
+	}
+
+	tokens, err := h.service.Refresh(c.Request.Context(), request.RefreshToken, sessionMeta(c))
+	if err != nil {
+		writeError(c, err, "ошибка при обновлении токенов")
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var request struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		writeError(c, errInvalidBody, "")
+		return
+	}
+
+	if err := h.service.Logout(c.Request.Context(), request.RefreshToken); err != nil {
+		writeError(c, err, "ошибка при выходе")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *AuthHandler) SetPassword(c *gin.Context) {
+	userID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Password string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		writeError(c, errInvalidBody, "")
+		return
+	}
+
+	if err := h.service.SetPassword(c.Request.Context(), userID, request.Password); err != nil {
+		writeError(c, err, "ошибка при сохранении пароля")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	userID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var request struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		writeError(c, errInvalidBody, "")
+		return
+	}
+
+	if err := h.service.ChangePassword(c.Request.Context(), userID, request.CurrentPassword, request.NewPassword); err != nil {
+		writeError(c, err, "ошибка при сохранении пароля")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// writeMFAChallenge отвечает 401 с токеном для POST /auth/mfa/verify, если для входа нужен второй фактор.
+func writeMFAChallenge(c *gin.Context, err error) bool {
+	var challenge *service.MFAChallengeError
+	if !errors.As(err, &challenge) {
+		return false
+	}
+	c.Header("Cache-Control", "no-store")
+	problem.Write(c, problem.FromError(c, service.ErrMFARequired, "").
+		With("mfa_required", true).
+		With("mfa_token", challenge.Token).
+		With("enrollment_required", challenge.EnrollmentRequired))
+	return true
+}
+
+// writeLoginLocked отвечает 429 с заголовком Retry-After, пока вход заблокирован после неудачных попыток.
+func writeLoginLocked(c *gin.Context, err error) bool {
+	var locked *service.LoginLockedError
+	if !errors.As(err, &locked) {
+		return false
+	}
+	seconds := int64(math.Ceil(locked.RetryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.FormatInt(seconds, 10))
+	problem.Write(c, problem.FromError(c, service.ErrLoginLocked, ""))
+	return true
+}
+
+// sessionMeta собирает сведения об устройстве и адресе клиента для списка сессий.
+func sessionMeta(c *gin.Context) domain.SessionMeta {
+	return domain.SessionMeta{UserAgent: c.Request.UserAgent(), IP: c.ClientIP()}
+}
@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testovoe/internal/domain"
+	"testovoe/internal/service"
+	"time"
+)
+
+type MockAuthService struct {
+	mock.Mock
+}
+
+func (m *MockAuthService) Login(ctx context.Context, email, password string, meta domain.SessionMeta) (*domain.TokenPair, error) {
+	args := m.Called(ctx, email, password, meta)
+	return args.Get(0).(*domain.TokenPair), args.Error(1)
+}
+
+func (m *MockAuthService) Refresh(ctx context.Context, refreshToken string, meta domain.SessionMeta) (*domain.TokenPair, error) {
+	args := m.Called(ctx, refreshToken, meta)
+	return args.Get(0).(*domain.TokenPair), args.Error(1)
+}
+
+func (m *MockAuthService) Logout(ctx context.Context, refreshToken string) error {
+	args := m.Called(ctx, refreshToken)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) SetPassword(ctx context.Context, userID int64, password string) error {
+	args := m.Called(ctx, userID, password)
+	return args.Error(0)
+}
+
+func (m *MockAuthService) ChangePassword(ctx context.Context, userID int64, current, next string) error {
+	args := m.Called(ctx, userID, current, next)
+	return args.Error(0)
+}
+
+func setupAuthRouter(h *AuthHandler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.POST("/auth/login", h.Login)
+	r.POST("/auth/refresh", h.Refresh)
+	r.POST("/auth/logout", h.Logout)
+	r.PUT("/users/:id/password", h.SetPassword)
+	r.POST("/users/:id/password/change", h.ChangePassword)
+	return r
+}
+
+func TestLogin(t *testing.T) {
+	mockService := new(MockAuthService)
+	router := setupAuthRouter(NewAuthHandler(mockService))
+
+	tokens := &domain.TokenPair{AccessToken: "access", RefreshToken: "refresh", TokenType: "Bearer", ExpiresIn: 900}
+	meta := domain.SessionMeta{UserAgent: "curl/8.0", IP: "192.0.2.1"}
+	mockService.On("Login", mock.Anything, "ivan@example.com", "secret-password", meta).Return(tokens, nil)
+	mockService.On("Login", mock.Anything, "ivan@example.com", "wrong", mock.Anything).Return((*domain.TokenPair)(nil), service.ErrInvalidLogin)
+
+	req, _ := http.NewRequest("POST", "/auth/login", bytes.NewBufferString(`{"email":"ivan@example.com","password":"secret-password"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "curl/8.0")
+	req.RemoteAddr = "192.0.2.1:54321"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	assert.JSONEq(t, `{"access_token":"access","refresh_token":"refresh","token_type":"Bearer","expires_in":900}`, w.Body.String())
+
+	req, _ = http.NewRequest("POST", "/auth/login", bytes.NewBufferString(`{"email":"ivan@example.com","password":"wrong"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestLogin_MFARequired(t *testing.T) {
+	mockService := new(MockAuthService)
+	router := setupAuthRouter(NewAuthHandler(mockService))
+
+	mockService.On("Login", mock.Anything, "ivan@example.com", "secret-password", mock.Anything).
+		Return((*domain.TokenPair)(nil), &service.MFAChallengeError{Token: "mfa-token", EnrollmentRequired: true})
+
+	req, _ := http.NewRequest("POST", "/auth/login", bytes.NewBufferString(`{"email":"ivan@example.com","password":"secret-password"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), `"mfa_token":"mfa-token"`)
+	assert.Contains(t, w.Body.String(), `"enrollment_required":true`)
+}
+
+func TestLogin_Locked(t *testing.T) {
+	mockService := new(MockAuthService)
+	router := setupAuthRouter(NewAuthHandler(mockService))
+
+	mockService.On("Login", mock.Anything, "ivan@example.com", "secret-password", mock.Anything).
+		Return((*domain.TokenPair)(nil), &service.LoginLockedError{RetryAfter: 90*time.Second + time.Millisecond})
+
+	req, _ := http.NewRequest("POST", "/auth/login", bytes.NewBufferString(`{"email":"ivan@example.com","password":"secret-password"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "91", w.Header().Get("Retry-After"))
+}
+
+func TestLogout(t *testing.T) {
+	mockService := new(MockAuthService)
+	router := setupAuthRouter(NewAuthHandler(mockService))
+
+	mockService.On("Logout", mock.Anything, "tvr_valid").Return(nil)
+	mockService.On("Logout", mock.Anything, "tvr_reused").Return(service.ErrInvalidRefreshToken)
+
+	req, _ := http.NewRequest("POST", "/auth/logout", bytes.NewBufferString(`{"refresh_token":"tvr_valid"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	req, _ = http.NewRequest("POST", "/auth/logout", bytes.NewBufferString(`{"refresh_token":"tvr_reused"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestChangePassword_Errors(t *testing.T) {
+	mockService := new(MockAuthService)
+	router := setupAuthRouter(NewAuthHandler(mockService))
+
+	mockService.On("ChangePassword", mock.Anything, int64(1), "old", "new-password").Return(service.ErrWrongPassword)
+	mockService.On("ChangePassword", mock.Anything, int64(2), "old", "new-password").
+		Return(&service.AccessDeniedError{Permission: domain.PermissionPasswordChange, Reason: service.DenyReasonMissingPermission})
+
+	body := `{"current_password":"old","new_password":"new-password"}`
+	req, _ := http.NewRequest("POST", "/users/1/password/change", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req, _ = http.NewRequest("POST", "/users/2/password/change", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
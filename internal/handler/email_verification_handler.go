@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"testovoe/internal/service"
+)
+
+type EmailVerificationHandler struct {
+	service service.EmailVerificationServiceInterface
+}
+
+func NewEmailVerificationHandler(service service.EmailVerificationServiceInterface) *EmailVerificationHandler {
+	return &EmailVerificationHandler{service: service}
+}
+
+func (h *EmailVerificationHandler) VerifyEmail(c *gin.Context) {
+	var request struct {
+		Token string `json:"token"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || request.Token == "" {
+		writeError(c, errInvalidBody, "")
+		return
+	}
+
+	user, err := h.service.VerifyEmail(c.Request.Context(), request.Token)
+	if err != nil {
+		writeError(c, err, "ошибка при подтверждении email")
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *EmailVerificationHandler) ResendVerification(c *gin.Context) {
+	userID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.ResendVerification(c.Request.Context(), userID); err != nil {
+		writeError(c, err, "ошибка при отправке письма")
+		return
+	}
+	c.Status(http.StatusAccepted)
+}
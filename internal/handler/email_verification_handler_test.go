@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testovoe/internal/domain"
+	"testovoe/internal/service"
+	"time"
+)
+
+type MockEmailVerificationService struct {
+	mock.Mock
+}
+
+func (m *MockEmailVerificationService) VerifyEmail(ctx context.Context, token string) (*domain.User, error) {
+	args := m.Called(ctx, token)
+	return args.Get(0).(*domain.User), args.Error(1)
+}
+
+func (m *MockEmailVerificationService) ResendVerification(ctx context.Context, userID int64) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func setupEmailVerificationRouter(h *EmailVerificationHandler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.POST("/users/verify-email", h.VerifyEmail)
+	r.POST("/users/:id/verify-email/resend", h.ResendVerification)
+	return r
+}
+
+func TestVerifyEmail(t *testing.T) {
+	mockService := new(MockEmailVerificationService)
+	router := setupEmailVerificationRouter(NewEmailVerificationHandler(mockService))
+
+	verifiedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	mockService.On("VerifyEmail", mock.Anything, "valid").
+		Return(&domain.User{ID: 1, Name: "Иван", Email: "ivan@example.com", Version: 2, EmailVerifiedAt: &verifiedAt}, nil)
+	mockService.On("VerifyEmail", mock.Anything, "expired").Return((*domain.User)(nil), service.ErrInvalidVerificationToken)
+
+	for body, status := range map[string]int{
+		`{"token":"valid"}`:   http.StatusOK,
+		`{"token":"expired"}`: http.StatusBadRequest,
+		`{}`:                  http.StatusBadRequest,
+	} {
+		req, _ := http.NewRequest("POST", "/users/verify-email", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, status, w.Code, body)
+		if status == http.StatusOK {
+			assert.Contains(t, w.Body.String(), `"email_verified_at":"2024-01-02T03:04:05Z"`)
+		}
+	}
+}
+
+func TestResendVerification(t *testing.T) {
+	mockService := new(MockEmailVerificationService)
+	router := setupEmailVerificationRouter(NewEmailVerificationHandler(mockService))
+
+	mockService.On("ResendVerification", mock.Anything, int64(1)).Return(nil)
+	mockService.On("ResendVerification", mock.Anything, int64(2)).Return(service.ErrEmailAlreadyVerified)
+
+	for path, status := range map[string]int{
+		"/users/1/verify-email/resend": http.StatusAccepted,
+		"/users/2/verify-email/resend": http.StatusConflict,
+	} {
+		req, _ := http.NewRequest("POST", path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, status, w.Code, path)
+	}
+}
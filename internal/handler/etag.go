@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"strconv"
+	"strings"
+	"testovoe/internal/apperr"
+	"testovoe/internal/domain"
+)
+
+var (
+	errIfMatchRequired = apperr.New(apperr.KindPreconditionRequired, "if_match_required", "требуется заголовок If-Match")
+	errInvalidIfMatch  = apperr.New(apperr.KindInvalid, "invalid_if_match", "некорректный заголовок If-Match")
+	errVersionMismatch = apperr.New(apperr.KindPreconditionFailed, "version_conflict", "версия пользователя не совпадает")
+)
+
+func setETag(c *gin.Context, version int64) {
+	c.Header("ETag", strconv.Quote(strconv.FormatInt(version, 10)))
+}
+
+// requireIfMatch разбирает заголовок If-Match. "*" означает любую текущую версию (domain.AnyVersion).
+// Слабые теги (W/"3") по RFC 9110 при проверке If-Match не совпадают ни с одной версией, поэтому
+// запрос с одними слабыми тегами получает 412. Из нескольких сильных тегов поддерживается только один.
+func requireIfMatch(c *gin.Context) (int64, bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		writeError(c, errIfMatchRequired, "")
+		return 0, false
+	}
+	if header == "*" {
+		return domain.AnyVersion, true
+	}
+
+	versions := make([]int64, 0, 1)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		opaque, weak := strings.CutPrefix(tag, "W/")
+		unquoted, err := strconv.Unquote(opaque)
+		if err != nil || !strings.HasPrefix(opaque, `"`) {
+			writeError(c, errInvalidIfMatch, "")
+			return 0, false
+		}
+		version, err := strconv.ParseInt(unquoted, 10, 64)
+		if weak || err != nil || version <= 0 {
+			continue
+		}
+		versions = append(versions, version)
+	}
+
+	switch len(versions) {
+	case 0:
+		writeError(c, errVersionMismatch, "")
+		return 0, false
+	case 1:
+		return versions[0], true
+	default:
+		writeError(c, errInvalidIfMatch, "")
+		return 0, false
+	}
+}
@@ -0,0 +1,21 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"testovoe/internal/problem"
+	"testovoe/internal/service"
+)
+
+// writeForbidden отвечает 403 с машиночитаемой причиной, если сервис отказал в доступе.
+func writeForbidden(c *gin.Context, err error) bool {
+	var denied *service.AccessDeniedError
+	if !errors.As(err, &denied) {
+		return false
+	}
+	problem.Write(c, problem.New(c, http.StatusForbidden, service.ErrForbidden.Code, service.ErrForbidden.Error()).
+		With("reason", denied.Reason).
+		With("permission", denied.Permission))
+	return true
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"testovoe/internal/service"
+)
+
+type LockoutHandler struct {
+	service service.LockoutServiceInterface
+}
+
+func NewLockoutHandler(service service.LockoutServiceInterface) *LockoutHandler {
+	return &LockoutHandler{service: service}
+}
+
+func (h *LockoutHandler) GetUserLockout(c *gin.Context) {
+	userID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	status, err := h.service.GetUserLockout(c.Request.Context(), userID)
+	if err != nil {
+		writeError(c, err, "ошибка при получении блокировки входа")
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+func (h *LockoutHandler) UnlockUser(c *gin.Context) {
+	userID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.UnlockUser(c.Request.Context(), userID); err != nil {
+		writeError(c, err, "ошибка при снятии блокировки входа")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *LockoutHandler) ListLockouts(c *gin.Context) {
+	lockouts, err := h.service.ListLockouts(c.Request.Context())
+	if err != nil {
+		writeError(c, err, "ошибка при получении блокировок входа")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"lockouts": lockouts})
+}
+
+func (h *LockoutHandler) UnlockIP(c *gin.Context) {
+	if err := h.service.UnlockIP(c.Request.Context(), c.Param("ip")); err != nil {
+		writeError(c, err, "ошибка при снятии блокировки входа")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
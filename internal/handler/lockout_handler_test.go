@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testovoe/internal/domain"
+	"testovoe/internal/service"
+)
+
+type MockLockoutService struct {
+	mock.Mock
+}
+
+func (m *MockLockoutService) GetUserLockout(ctx context.Context, userID int64) (*domain.LockoutStatus, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).(*domain.LockoutStatus), args.Error(1)
+}
+
+func (m *MockLockoutService) UnlockUser(ctx context.Context, userID int64) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockLockoutService) ListLockouts(ctx context.Context) ([]domain.LoginFailure, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]domain.LoginFailure), args.Error(1)
+}
+
+func (m *MockLockoutService) UnlockIP(ctx context.Context, ip string) error {
+	args := m.Called(ctx, ip)
+	return args.Error(0)
+}
+
+func setupLockoutRouter(h *LockoutHandler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.GET("/users/:id/lockout", h.GetUserLockout)
+	r.DELETE("/users/:id/lockout", h.UnlockUser)
+	r.GET("/lockouts", h.ListLockouts)
+	r.DELETE("/lockouts/ip/:ip", h.UnlockIP)
+	return r
+}
+
+func TestGetUserLockout(t *testing.T) {
+	mockService := new(MockLockoutService)
+	router := setupLockoutRouter(NewLockoutHandler(mockService))
+
+	mockService.On("GetUserLockout", mock.Anything, int64(7)).Return(&domain.LockoutStatus{Failures: 2}, nil)
+	mockService.On("GetUserLockout", mock.Anything, int64(8)).Return((*domain.LockoutStatus)(nil),
+		&service.AccessDeniedError{Permission: domain.PermissionLockouts, Reason: service.DenyReasonMissingPermission})
+
+	req, _ := http.NewRequest("GET", "/users/7/lockout", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"locked":false,"failures":2}`, w.Body.String())
+
+	req, _ = http.NewRequest("GET", "/users/8/lockout", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestUnlockIP(t *testing.T) {
+	mockService := new(MockLockoutService)
+	router := setupLockoutRouter(NewLockoutHandler(mockService))
+
+	mockService.On("UnlockIP", mock.Anything, "2001:db8::1").Return(nil)
+	mockService.On("UnlockIP", mock.Anything, "10.0.0.1").Return(service.ErrLockNotFound)
+
+	req, _ := http.NewRequest("DELETE", "/lockouts/ip/2001:db8::1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	req, _ = http.NewRequest("DELETE", "/lockouts/ip/10.0.0.1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,195 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"testovoe/internal/domain"
+	"testovoe/internal/problem"
+	"testovoe/internal/service"
+)
+
+type MFAHandler struct {
+	service service.MFAServiceInterface
+}
+
+func NewMFAHandler(service service.MFAServiceInterface) *MFAHandler {
+	return &MFAHandler{service: service}
+}
+
+func (h *MFAHandler) GetMFAStatus(c *gin.Context) {
+	userID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	status, err := h.service.GetMFAStatus(c.Request.Context(), userID)
+	if err != nil {
+		writeError(c, err, "ошибка при получении настроек второго фактора")
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+func (h *MFAHandler) StartMFAEnrollment(c *gin.Context) {
+	userID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	enrollment, err := h.service.StartMFAEnrollment(c.Request.Context(), userID)
+	if err != nil {
+		writeError(c, err, "ошибка при настройке второго фактора")
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, enrollment)
+}
+
+func (h *MFAHandler) ConfirmMFAEnrollment(c *gin.Context) {
+	userID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	var request struct {
+		Code string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || request.Code == "" {
+		writeError(c, errInvalidBody, "")
+		return
+	}
+
+	codes, err := h.service.ConfirmMFAEnrollment(c.Request.Context(), userID, request.Code)
+	if err != nil {
+		writeError(c, err, "ошибка при подключении второго фактора")
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	userID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	proof, ok := bindMFAProof(c)
+	if !ok {
+		return
+	}
+
+	codes, err := h.service.RegenerateRecoveryCodes(c.Request.Context(), userID, proof)
+	if err != nil {
+		writeError(c, err, "ошибка при выпуске кодов восстановления")
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+func (h *MFAHandler) DisableMFA(c *gin.Context) {
+	userID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	proof, ok := bindMFAProof(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DisableMFA(c.Request.Context(), userID, proof); err != nil {
+		writeError(c, err, "ошибка при отключении второго фактора")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *MFAHandler) ResetMFA(c *gin.Context) {
+	userID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.ResetMFA(c.Request.Context(), userID); err != nil {
+		writeError(c, err, "ошибка при сбросе второго фактора")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *MFAHandler) GetMFAPolicy(c *gin.Context) {
+	roles, err := h.service.GetMFAPolicy(c.Request.Context())
+	if err != nil {
+		writeError(c, err, "ошибка при получении политики второго фактора")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+func (h *MFAHandler) SetMFAPolicy(c *gin.Context) {
+	var request struct {
+		Roles []domain.Role `json:"roles"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || request.Roles == nil {
+		writeError(c, errInvalidBody, "")
+		return
+	}
+
+	roles, err := h.service.SetMFAPolicy(c.Request.Context(), request.Roles)
+	if err != nil {
+		writeError(c, err, "ошибка при изменении политики второго фактора")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+func (h *MFAHandler) EnrollMFAChallenge(c *gin.Context) {
+	var request struct {
+		MFAToken string `json:"mfa_token"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || request.MFAToken == "" {
+		writeError(c, errInvalidBody, "")
+		return
+	}
+
+	enrollment, err := h.service.EnrollMFAChallenge(c.Request.Context(), request.MFAToken)
+	if err != nil {
+		writeError(c, err, "ошибка при настройке второго фактора")
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, enrollment)
+}
+
+func (h *MFAHandler) VerifyMFAChallenge(c *gin.Context) {
+	var request struct {
+		MFAToken string `json:"mfa_token"`
+		domain.MFAProof
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || request.MFAToken == "" || request.MFAProof.Empty() {
+		writeError(c, errInvalidBody, "")
+		return
+	}
+
+	tokens, err := h.service.VerifyMFAChallenge(c.Request.Context(), request.MFAToken, request.MFAProof, sessionMeta(c))
+	if err != nil {
+		// Неверный код при входе — такая же ошибка аутентификации, как неверный пароль.
+		if errors.Is(err, service.ErrInvalidMFACode) {
+			problem.Write(c, problem.FromError(c, err, "").WithStatus(http.StatusUnauthorized))
+			return
+		}
+		writeError(c, err, "ошибка при входе")
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, tokens)
+}
+
+func bindMFAProof(c *gin.Context) (domain.MFAProof, bool) {
+	var proof domain.MFAProof
+	if err := c.ShouldBindJSON(&proof); err != nil || proof.Empty() {
+		writeError(c, errMFAProofRequired, "")
+		return proof, false
+	}
+	return proof, true
+}
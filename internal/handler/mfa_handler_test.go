@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testovoe/internal/domain"
+	"testovoe/internal/service"
+)
+
+type MockMFAService struct {
+	mock.Mock
+}
+
+func (m *MockMFAService) GetMFAStatus(ctx context.Context, userID int64) (*domain.MFAStatus, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).(*domain.MFAStatus), args.Error(1)
+}
+
+func (m *MockMFAService) StartMFAEnrollment(ctx context.Context, userID int64) (*domain.MFAEnrollment, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).(*domain.MFAEnrollment), args.Error(1)
+}
+
+func (m *MockMFAService) ConfirmMFAEnrollment(ctx context.Context, userID int64, code string) ([]string, error) {
+	args := m.Called(ctx, userID, code)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockMFAService) RegenerateRecoveryCodes(ctx context.Context, userID int64, proof domain.MFAProof) ([]string, error) {
+	args := m.Called(ctx, userID, proof)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockMFAService) DisableMFA(ctx context.Context, userID int64, proof domain.MFAProof) error {
+	args := m.Called(ctx, userID, proof)
+	return args.Error(0)
+}
+
+func (m *MockMFAService) ResetMFA(ctx context.Context, userID int64) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockMFAService) GetMFAPolicy(ctx context.Context) ([]domain.Role, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]domain.Role), args.Error(1)
+}
+
+func (m *MockMFAService) SetMFAPolicy(ctx context.Context, roles []domain.Role) ([]domain.Role, error) {
+	args := m.Called(ctx, roles)
+	return args.Get(0).([]domain.Role), args.Error(1)
+}
+
+func (m *MockMFAService) EnrollMFAChallenge(ctx context.Context, mfaToken string) (*domain.MFAEnrollment, error) {
+	args := m.Called(ctx, mfaToken)
+	return args.Get(0).(*domain.MFAEnrollment), args.Error(1)
+}
+
+func (m *MockMFAService) VerifyMFAChallenge(ctx context.Context, mfaToken string, proof domain.MFAProof, meta domain.SessionMeta) (*domain.TokenPair, error) {
+	args := m.Called(ctx, mfaToken, proof, meta)
+	return args.Get(0).(*domain.TokenPair), args.Error(1)
+}
+
+func setupMFARouter(h *MFAHandler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.POST("/users/:id/mfa/confirm", h.ConfirmMFAEnrollment)
+	r.DELETE("/users/:id/mfa", h.DisableMFA)
+	r.PUT("/mfa/policy", h.SetMFAPolicy)
+	r.POST("/auth/mfa/verify", h.VerifyMFAChallenge)
+	return r
+}
+
+func TestConfirmMFAEnrollment(t *testing.T) {
+	mockService := new(MockMFAService)
+	router := setupMFARouter(NewMFAHandler(mockService))
+
+	mockService.On("ConfirmMFAEnrollment", mock.Anything, int64(7), "123456").Return([]string{"aaaa-bbbb-cccc-dddd"}, nil)
+	mockService.On("ConfirmMFAEnrollment", mock.Anything, int64(7), "000000").Return([]string(nil), service.ErrInvalidMFACode)
+
+	req, _ := http.NewRequest("POST", "/users/7/mfa/confirm", bytes.NewBufferString(`{"code":"123456"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"recovery_codes":["aaaa-bbbb-cccc-dddd"]}`, w.Body.String())
+
+	req, _ = http.NewRequest("POST", "/users/7/mfa/confirm", bytes.NewBufferString(`{"code":"000000"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestDisableMFA_Enforced(t *testing.T) {
+	mockService := new(MockMFAService)
+	router := setupMFARouter(NewMFAHandler(mockService))
+
+	mockService.On("DisableMFA", mock.Anything, int64(7), domain.MFAProof{Code: "123456"}).Return(service.ErrMFAEnforced)
+
+	req, _ := http.NewRequest("DELETE", "/users/7/mfa", bytes.NewBufferString(`{"code":"123456"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	req, _ = http.NewRequest("DELETE", "/users/7/mfa", bytes.NewBufferString(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestSetMFAPolicy_Forbidden(t *testing.T) {
+	mockService := new(MockMFAService)
+	router := setupMFARouter(NewMFAHandler(mockService))
+
+	mockService.On("SetMFAPolicy", mock.Anything, []domain.Role{domain.RoleAdmin}).
+		Return([]domain.Role(nil), &service.AccessDeniedError{Permission: domain.PermissionRolesManage, Reason: service.DenyReasonMissingPermission})
+
+	req, _ := http.NewRequest("PUT", "/mfa/policy", bytes.NewBufferString(`{"roles":["admin"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestVerifyMFAChallenge(t *testing.T) {
+	mockService := new(MockMFAService)
+	router := setupMFARouter(NewMFAHandler(mockService))
+
+	tokens := &domain.TokenPair{AccessToken: "access", RefreshToken: "refresh", TokenType: "Bearer", ExpiresIn: 900}
+	mockService.On("VerifyMFAChallenge", mock.Anything, "mfa-token", domain.MFAProof{Code: "123456"}, mock.Anything).Return(tokens, nil)
+	mockService.On("VerifyMFAChallenge", mock.Anything, "mfa-token", domain.MFAProof{RecoveryCode: "used"}, mock.Anything).
+		Return((*domain.TokenPair)(nil), service.ErrInvalidMFACode)
+
+	req, _ := http.NewRequest("POST", "/auth/mfa/verify", bytes.NewBufferString(`{"mfa_token":"mfa-token","code":"123456"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	assert.Contains(t, w.Body.String(), `"access_token":"access"`)
+
+	req, _ = http.NewRequest("POST", "/auth/mfa/verify", bytes.NewBufferString(`{"mfa_token":"mfa-token","recovery_code":"used"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"testovoe/internal/service"
+)
+
+type OAuthClientHandler struct {
+	service service.OAuthClientServiceInterface
+}
+
+func NewOAuthClientHandler(service service.OAuthClientServiceInterface) *OAuthClientHandler {
+	return &OAuthClientHandler{service: service}
+}
+
+func (h *OAuthClientHandler) CreateOAuthClient(c *gin.Context) {
+	var request struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Confidential bool     `json:"confidential"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		writeError(c, errInvalidBody, "")
+		return
+	}
+
+	client, err := h.service.CreateOAuthClient(c.Request.Context(), request.Name, request.RedirectURIs, request.Confidential)
+	if err != nil {
+		writeError(c, err, "ошибка при регистрации клиента")
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, client)
+}
+
+func (h *OAuthClientHandler) ListOAuthClients(c *gin.Context) {
+	clients, err := h.service.ListOAuthClients(c.Request.Context())
+	if err != nil {
+		writeError(c, err, "ошибка при получении клиентов")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"clients": clients})
+}
+
+func (h *OAuthClientHandler) GetOAuthClient(c *gin.Context) {
+	client, err := h.service.GetOAuthClient(c.Request.Context(), c.Param("client_id"))
+	if err != nil {
+		writeError(c, err, "ошибка при получении клиента")
+		return
+	}
+	c.JSON(http.StatusOK, client)
+}
+
+func (h *OAuthClientHandler) DeleteOAuthClient(c *gin.Context) {
+	if err := h.service.DeleteOAuthClient(c.Request.Context(), c.Param("client_id")); err != nil {
+		writeError(c, err, "ошибка при удалении клиента")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
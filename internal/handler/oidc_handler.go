@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+	"testovoe/internal/auth"
+	"testovoe/internal/domain"
+	"testovoe/internal/i18n"
+	"testovoe/internal/service"
+)
+
+// OIDCHandler реализует конечные точки OpenID Connect. Ошибки протокола возвращаются
+// в формате RFC 6749: {"error": код, "error_description": пояснение}.
+type OIDCHandler struct {
+	service service.OIDCServiceInterface
+}
+
+func NewOIDCHandler(service service.OIDCServiceInterface) *OIDCHandler {
+	return &OIDCHandler{service: service}
+}
+
+func (h *OIDCHandler) Discovery(c *gin.Context) {
+	c.JSON(http.StatusOK, h.service.Discovery())
+}
+
+func (h *OIDCHandler) JWKS(c *gin.Context) {
+	keys, err := h.service.PublishedKeys(c.Request.Context())
+	if err != nil {
+		writeError(c, err, "ошибка при получении ключей подписи")
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+func (h *OIDCHandler) RotateSigningKey(c *gin.Context) {
+	key, err := h.service.RotateSigningKey(c.Request.Context())
+	if err != nil {
+		writeError(c, err, "ошибка при ротации ключа подписи")
+		return
+	}
+	c.JSON(http.StatusOK, key)
+}
+
+// StartAuthorization отправляет браузер на страницу входа с параметрами запроса авторизации.
+func (h *OIDCHandler) StartAuthorization(c *gin.Context) {
+	var request domain.AuthorizeRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		writeOAuthError(c, &service.OAuthError{Code: service.OAuthInvalidRequest, Description: "некорректные параметры"})
+		return
+	}
+
+	location, err := h.service.LoginRedirect(c.Request.Context(), request)
+	if err != nil {
+		writeOAuthError(c, err)
+		return
+	}
+	c.Redirect(http.StatusFound, location)
+}
+
+// Authorize выдает код авторизации вошедшему пользователю. Страница входа получает адрес
+// возврата клиента в redirect_to и сама перенаправляет на него браузер.
+func (h *OIDCHandler) Authorize(c *gin.Context) {
+	var request domain.AuthorizeRequest
+	if err := c.ShouldBind(&request); err != nil {
+		writeOAuthError(c, &service.OAuthError{Code: service.OAuthInvalidRequest, Description: "некорректные параметры"})
+		return
+	}
+	var userID int64
+	if principal, ok := auth.PrincipalFromContext(c.Request.Context()); ok {
+		userID = principal.UserID
+	}
+
+	location, err := h.service.Authorize(c.Request.Context(), userID, request)
+	if err != nil {
+		// Ошибки протокола OAuth отдаются в формате RFC 6749, остальные — как problem+json.
+		var oauthErr *service.OAuthError
+		if !errors.As(err, &oauthErr) {
+			writeError(c, err, "ошибка при авторизации")
+			return
+		}
+		writeOAuthError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"redirect_to": location})
+}
+
+func (h *OIDCHandler) Token(c *gin.Context) {
+	var request domain.TokenRequest
+	if err := c.ShouldBind(&request); err != nil {
+		writeOAuthError(c, &service.OAuthError{Code: service.OAuthInvalidRequest, Description: "некорректные параметры"})
+		return
+	}
+	if id, secret, ok := c.Request.BasicAuth(); ok {
+		request.ClientID, request.ClientSecret = id, secret
+	}
+
+	response, err := h.service.Token(c.Request.Context(), request)
+	if err != nil {
+		writeOAuthError(c, err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *OIDCHandler) UserInfo(c *gin.Context) {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		c.Header("WWW-Authenticate", `Bearer`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": service.OAuthInvalidToken, "error_description": i18n.T(c.Request.Context(), "требуется access-токен")})
+		return
+	}
+
+	info, err := h.service.UserInfo(c.Request.Context(), token)
+	if err != nil {
+		var oauthErr *service.OAuthError
+		if errors.As(err, &oauthErr) {
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": oauthErr.Code, "error_description": oauthErr.Localize(c.Request.Context())})
+			return
+		}
+		writeError(c, err, "ошибка при получении данных пользователя")
+		return
+	}
+	c.JSON(http.StatusOK, info)
+}
+
+func (h *OIDCHandler) Introspect(c *gin.Context) {
+	clientID, clientSecret, token, ok := bindClientTokenRequest(c)
+	if !ok {
+		return
+	}
+
+	introspection, err := h.service.Introspect(c.Request.Context(), clientID, clientSecret, token)
+	if err != nil {
+		writeOAuthError(c, err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, introspection)
+}
+
+func (h *OIDCHandler) Revoke(c *gin.Context) {
+	clientID, clientSecret, token, ok := bindClientTokenRequest(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Revoke(c.Request.Context(), clientID, clientSecret, token); err != nil {
+		writeOAuthError(c, err)
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// bindClientTokenRequest читает токен и учетные данные клиента из формы или заголовка Basic.
+func bindClientTokenRequest(c *gin.Context) (string, string, string, bool) {
+	var request struct {
+		Token        string `form:"token"`
+		ClientID     string `form:"client_id"`
+		ClientSecret string `form:"client_secret"`
+	}
+	if err := c.ShouldBind(&request); err != nil || request.Token == "" {
+		writeOAuthError(c, &service.OAuthError{Code: service.OAuthInvalidRequest, Description: "не указан token"})
+		return "", "", "", false
+	}
+	if id, secret, ok := c.Request.BasicAuth(); ok {
+		request.ClientID, request.ClientSecret = id, secret
+	}
+	return request.ClientID, request.ClientSecret, request.Token, true
+}
+
+func writeOAuthError(c *gin.Context, err error) {
+	var oauthErr *service.OAuthError
+	if !errors.As(err, &oauthErr) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+	status := http.StatusBadRequest
+	if oauthErr.Code == service.OAuthInvalidClient {
+		status = http.StatusUnauthorized
+		c.Header("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, gin.H{"error": oauthErr.Code, "error_description": oauthErr.Localize(c.Request.Context())})
+}
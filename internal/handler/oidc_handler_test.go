@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"testovoe/internal/auth"
+	"testovoe/internal/domain"
+	"testovoe/internal/service"
+)
+
+type MockOIDCService struct {
+	mock.Mock
+}
+
+func (m *MockOIDCService) Discovery() *domain.OIDCDiscovery {
+	return m.Called().Get(0).(*domain.OIDCDiscovery)
+}
+
+func (m *MockOIDCService) PublishedKeys(ctx context.Context) (*domain.JSONWebKeySet, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(*domain.JSONWebKeySet), args.Error(1)
+}
+
+func (m *MockOIDCService) RotateSigningKey(ctx context.Context) (*domain.JSONWebKey, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(*domain.JSONWebKey), args.Error(1)
+}
+
+func (m *MockOIDCService) LoginRedirect(ctx context.Context, request domain.AuthorizeRequest) (string, error) {
+	args := m.Called(ctx, request)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockOIDCService) Authorize(ctx context.Context, userID int64, request domain.AuthorizeRequest) (string, error) {
+	args := m.Called(ctx, userID, request)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockOIDCService) Token(ctx context.Context, request domain.TokenRequest) (*domain.OAuthTokenResponse, error) {
+	args := m.Called(ctx, request)
+	return args.Get(0).(*domain.OAuthTokenResponse), args.Error(1)
+}
+
+func (m *MockOIDCService) UserInfo(ctx context.Context, accessToken string) (*domain.UserInfo, error) {
+	args := m.Called(ctx, accessToken)
+	return args.Get(0).(*domain.UserInfo), args.Error(1)
+}
+
+func (m *MockOIDCService) Introspect(ctx context.Context, clientID, clientSecret, token string) (*domain.TokenIntrospection, error) {
+	args := m.Called(ctx, clientID, clientSecret, token)
+	return args.Get(0).(*domain.TokenIntrospection), args.Error(1)
+}
+
+func (m *MockOIDCService) Revoke(ctx context.Context, clientID, clientSecret, token string) error {
+	return m.Called(ctx, clientID, clientSecret, token).Error(0)
+}
+
+func setupOIDCRouter(h *OIDCHandler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.Use(func(c *gin.Context) {
+		if subject := c.GetHeader("X-Test-Subject"); subject != "" {
+			c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), auth.NewPrincipal(subject, auth.MethodJWT)))
+		}
+	})
+	r.GET("/oauth/authorize", h.StartAuthorization)
+	r.POST("/oauth/authorize", h.Authorize)
+	r.POST("/oauth/token", h.Token)
+	r.GET("/oauth/userinfo", h.UserInfo)
+	r.POST("/oauth/revoke", h.Revoke)
+	return r
+}
+
+func TestStartAuthorization(t *testing.T) {
+	mockService := new(MockOIDCService)
+	router := setupOIDCRouter(NewOIDCHandler(mockService))
+
+	request := domain.AuthorizeRequest{ResponseType: "code", ClientID: "tvc_web", RedirectURI: "https://app.example.com/cb", Scope: "openid"}
+	mockService.On("LoginRedirect", mock.Anything, request).Return("https://login.example.com/?client_id=tvc_web", nil)
+	mockService.On("LoginRedirect", mock.Anything, mock.Anything).
+		Return("", &service.OAuthError{Code: service.OAuthInvalidClient, Description: "клиент не зарегистрирован"})
+
+	req, _ := http.NewRequest("GET", "/oauth/authorize?response_type=code&client_id=tvc_web&redirect_uri=https%3A%2F%2Fapp.example.com%2Fcb&scope=openid", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://login.example.com/?client_id=tvc_web", w.Header().Get("Location"))
+
+	req, _ = http.NewRequest("GET", "/oauth/authorize?client_id=unknown", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Empty(t, w.Header().Get("Location"))
+	assert.Contains(t, w.Body.String(), `"error":"invalid_client"`)
+}
+
+func TestAuthorize_UsesPrincipal(t *testing.T) {
+	mockService := new(MockOIDCService)
+	router := setupOIDCRouter(NewOIDCHandler(mockService))
+
+	mockService.On("Authorize", mock.Anything, int64(7), mock.Anything).Return("https://app.example.com/cb?code=tvg_x&state=s", nil)
+
+	req, _ := http.NewRequest("POST", "/oauth/authorize", bytes.NewBufferString(`{"client_id":"tvc_web","state":"s"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-Subject", "7")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"redirect_to":"https://app.example.com/cb?code=tvg_x&state=s"}`, w.Body.String())
+	mockService.AssertCalled(t, "Authorize", mock.Anything, int64(7), mock.MatchedBy(func(r domain.AuthorizeRequest) bool {
+		return r.ClientID == "tvc_web" && r.State == "s"
+	}))
+}
+
+func TestToken_BasicAuthAndErrors(t *testing.T) {
+	mockService := new(MockOIDCService)
+	router := setupOIDCRouter(NewOIDCHandler(mockService))
+
+	mockService.On("Token", mock.Anything, domain.TokenRequest{GrantType: "authorization_code", Code: "tvg_ok", ClientID: "tvc_web", ClientSecret: "secret"}).
+		Return(&domain.OAuthTokenResponse{AccessToken: "tvo_a", TokenType: "Bearer", ExpiresIn: 3600}, nil)
+	mockService.On("Token", mock.Anything, mock.Anything).
+		Return((*domain.OAuthTokenResponse)(nil), &service.OAuthError{Code: service.OAuthInvalidGrant, Description: "код недействителен"})
+
+	form := url.Values{"grant_type": {"authorization_code"}, "code": {"tvg_ok"}}
+	req, _ := http.NewRequest("POST", "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("tvc_web", "secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	assert.Contains(t, w.Body.String(), `"access_token":"tvo_a"`)
+
+	form.Set("code", "tvg_used")
+	req, _ = http.NewRequest("POST", "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error":"invalid_grant","error_description":"код недействителен"}`, w.Body.String())
+}
+
+func TestUserInfo(t *testing.T) {
+	mockService := new(MockOIDCService)
+	router := setupOIDCRouter(NewOIDCHandler(mockService))
+
+	mockService.On("UserInfo", mock.Anything, "tvo_valid").Return(&domain.UserInfo{Subject: "7", Email: "ivan@example.com"}, nil)
+	mockService.On("UserInfo", mock.Anything, "tvo_revoked").
+		Return((*domain.UserInfo)(nil), &service.OAuthError{Code: service.OAuthInvalidToken, Description: "access-токен недействителен"})
+
+	req, _ := http.NewRequest("GET", "/oauth/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer tvo_valid")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"sub":"7","email":"ivan@example.com"}`, w.Body.String())
+
+	req, _ = http.NewRequest("GET", "/oauth/userinfo", nil)
+	req.Header.Set("Authorization", "Bearer tvo_revoked")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `Bearer error="invalid_token"`, w.Header().Get("WWW-Authenticate"))
+
+	req, _ = http.NewRequest("GET", "/oauth/userinfo", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestRevoke_RequiresToken(t *testing.T) {
+	mockService := new(MockOIDCService)
+	router := setupOIDCRouter(NewOIDCHandler(mockService))
+
+	mockService.On("Revoke", mock.Anything, "tvc_spa", "", "tvo_a").Return(nil)
+
+	req, _ := http.NewRequest("POST", "/oauth/revoke", strings.NewReader("client_id=tvc_spa&token=tvo_a"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req, _ = http.NewRequest("POST", "/oauth/revoke", strings.NewReader("client_id=tvc_spa"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"invalid_request"`)
+}
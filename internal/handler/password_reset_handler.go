@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+	"testovoe/internal/service"
+)
+
+type PasswordResetHandler struct {
+	service service.PasswordResetServiceInterface
+}
+
+func NewPasswordResetHandler(service service.PasswordResetServiceInterface) *PasswordResetHandler {
+	return &PasswordResetHandler{service: service}
+}
+
+// RequestPasswordReset всегда отвечает 202, чтобы по ответу нельзя было узнать, зарегистрирован ли адрес.
+func (h *PasswordResetHandler) RequestPasswordReset(c *gin.Context) {
+	var request struct {
+		Email string `json:"email"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || request.Email == "" {
+		writeError(c, errInvalidBody, "")
+		return
+	}
+
+	if err := h.service.RequestPasswordReset(c.Request.Context(), request.Email); err != nil {
+		slog.ErrorContext(c.Request.Context(), "ошибка при запросе сброса пароля", "error", err)
+	}
+	c.Status(http.StatusAccepted)
+}
+
+func (h *PasswordResetHandler) ConfirmPasswordReset(c *gin.Context) {
+	var request struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || request.Token == "" {
+		writeError(c, errInvalidBody, "")
+		return
+	}
+
+	if err := h.service.ConfirmPasswordReset(c.Request.Context(), request.Token, request.Password); err != nil {
+		writeError(c, err, "ошибка при сбросе пароля")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *PasswordResetHandler) SendPasswordReset(c *gin.Context) {
+	userID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.SendPasswordReset(c.Request.Context(), userID); err != nil {
+		writeError(c, err, "ошибка при отправке письма")
+		return
+	}
+	c.Status(http.StatusAccepted)
+}
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testovoe/internal/domain"
+	"testovoe/internal/service"
+)
+
+type MockPasswordResetService struct {
+	mock.Mock
+}
+
+func (m *MockPasswordResetService) RequestPasswordReset(ctx context.Context, email string) error {
+	args := m.Called(ctx, email)
+	return args.Error(0)
+}
+
+func (m *MockPasswordResetService) SendPasswordReset(ctx context.Context, userID int64) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *MockPasswordResetService) ConfirmPasswordReset(ctx context.Context, token, password string) error {
+	args := m.Called(ctx, token, password)
+	return args.Error(0)
+}
+
+func setupPasswordResetRouter(h *PasswordResetHandler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.POST("/auth/password-reset/request", h.RequestPasswordReset)
+	r.POST("/auth/password-reset/confirm", h.ConfirmPasswordReset)
+	r.POST("/users/:id/password-reset", h.SendPasswordReset)
+	return r
+}
+
+func TestRequestPasswordReset_AlwaysAccepted(t *testing.T) {
+	mockService := new(MockPasswordResetService)
+	router := setupPasswordResetRouter(NewPasswordResetHandler(mockService))
+
+	mockService.On("RequestPasswordReset", mock.Anything, "ivan@example.com").Return(nil)
+	mockService.On("RequestPasswordReset", mock.Anything, "broken@example.com").Return(errors.New("db down"))
+
+	for _, email := range []string{"ivan@example.com", "broken@example.com"} {
+		req, _ := http.NewRequest("POST", "/auth/password-reset/request", bytes.NewBufferString(`{"email":"`+email+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusAccepted, w.Code)
+	}
+	mockService.AssertExpectations(t)
+}
+
+func TestConfirmPasswordReset(t *testing.T) {
+	mockService := new(MockPasswordResetService)
+	router := setupPasswordResetRouter(NewPasswordResetHandler(mockService))
+
+	mockService.On("ConfirmPasswordReset", mock.Anything, "tvp_good", "new-secret-password").Return(nil)
+	mockService.On("ConfirmPasswordReset", mock.Anything, "tvp_used", "new-secret-password").Return(service.ErrInvalidResetToken)
+
+	req, _ := http.NewRequest("POST", "/auth/password-reset/confirm", bytes.NewBufferString(`{"token":"tvp_good","password":"new-secret-password"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	req, _ = http.NewRequest("POST", "/auth/password-reset/confirm", bytes.NewBufferString(`{"token":"tvp_used","password":"new-secret-password"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestSendPasswordReset_Forbidden(t *testing.T) {
+	mockService := new(MockPasswordResetService)
+	router := setupPasswordResetRouter(NewPasswordResetHandler(mockService))
+
+	mockService.On("SendPasswordReset", mock.Anything, int64(7)).
+		Return(&service.AccessDeniedError{Permission: domain.PermissionPasswordReset, Reason: service.DenyReasonMissingPermission})
+
+	req, _ := http.NewRequest("POST", "/users/7/password-reset", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
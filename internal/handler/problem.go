@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"testovoe/internal/apperr"
+	"testovoe/internal/problem"
+)
+
+// Ошибки разбора запроса, общие для обработчиков.
+var (
+	errInvalidBody   = apperr.New(apperr.KindInvalid, "invalid_body", "некорректные данные")
+	errInvalidID     = apperr.New(apperr.KindInvalid, "invalid_id", "неверный формат ID")
+	errInvalidLimit  = apperr.New(apperr.KindInvalid, "invalid_limit", "неверный формат limit")
+	errInvalidOffset = apperr.New(apperr.KindInvalid, "invalid_offset", "неверный формат offset")
+	errInvalidFrom   = apperr.New(apperr.KindInvalid, "invalid_from", "неверный формат from, ожидается RFC 3339")
+	errInvalidTo     = apperr.New(apperr.KindInvalid, "invalid_to", "неверный формат to, ожидается RFC 3339")
+
+	errInvalidEntityID  = apperr.New(apperr.KindInvalid, "invalid_entity_id", "неверный формат entity_id")
+	errMFAProofRequired = apperr.New(apperr.KindInvalid, "mfa_proof_required", "укажите code или recovery_code")
+)
+
+// writeError отвечает ошибкой в формате application/problem+json. Статус и код берутся из каталога
+// apperr; ошибки вне каталога отдаются как 500 с текстом fallback, без подробностей.
+func writeError(c *gin.Context, err error, fallback string) {
+	if writeForbidden(c, err) || writeLoginLocked(c, err) {
+		return
+	}
+	problem.Write(c, problem.FromError(c, err, fallback))
+}
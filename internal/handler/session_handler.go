@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"testovoe/internal/service"
+)
+
+type SessionHandler struct {
+	service service.SessionServiceInterface
+}
+
+func NewSessionHandler(service service.SessionServiceInterface) *SessionHandler {
+	return &SessionHandler{service: service}
+}
+
+func (h *SessionHandler) ListSessions(c *gin.Context) {
+	userID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	sessions, err := h.service.ListSessions(c.Request.Context(), userID)
+	if err != nil {
+		writeError(c, err, "ошибка при получении сессий")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	userID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	sessionID, ok := parseIDParam(c, "session_id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.RevokeSession(c.Request.Context(), userID, sessionID); err != nil {
+		writeError(c, err, "ошибка при отзыве сессии")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *SessionHandler) RevokeAllSessions(c *gin.Context) {
+	userID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.RevokeAllSessions(c.Request.Context(), userID); err != nil {
+		writeError(c, err, "ошибка при отзыве сессий")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
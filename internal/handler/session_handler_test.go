@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testovoe/internal/domain"
+	"testovoe/internal/service"
+)
+
+type MockSessionService struct {
+	mock.Mock
+}
+
+func (m *MockSessionService) ListSessions(ctx context.Context, userID int64) ([]domain.Session, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]domain.Session), args.Error(1)
+}
+
+func (m *MockSessionService) RevokeSession(ctx context.Context, userID, id int64) error {
+	args := m.Called(ctx, userID, id)
+	return args.Error(0)
+}
+
+func (m *MockSessionService) RevokeAllSessions(ctx context.Context, userID int64) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func setupSessionRouter(h *SessionHandler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.GET("/users/:id/sessions", h.ListSessions)
+	r.DELETE("/users/:id/sessions", h.RevokeAllSessions)
+	r.DELETE("/users/:id/sessions/:session_id", h.RevokeSession)
+	return r
+}
+
+func TestListSessions(t *testing.T) {
+	mockService := new(MockSessionService)
+	router := setupSessionRouter(NewSessionHandler(mockService))
+
+	sessions := []domain.Session{{ID: 3, UserID: 1, UserAgent: "curl/8.0", IP: "10.0.0.1", Current: true}}
+	mockService.On("ListSessions", mock.Anything, int64(1)).Return(sessions, nil)
+
+	req, _ := http.NewRequest("GET", "/users/1/sessions", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"user_agent":"curl/8.0"`)
+	assert.Contains(t, w.Body.String(), `"current":true`)
+}
+
+func TestRevokeSession(t *testing.T) {
+	mockService := new(MockSessionService)
+	router := setupSessionRouter(NewSessionHandler(mockService))
+
+	mockService.On("RevokeSession", mock.Anything, int64(1), int64(3)).Return(nil)
+	mockService.On("RevokeSession", mock.Anything, int64(1), int64(4)).Return(service.ErrSessionNotFound)
+	mockService.On("RevokeAllSessions", mock.Anything, int64(2)).
+		Return(&service.AccessDeniedError{Permission: domain.PermissionSessions, Reason: service.DenyReasonMissingPermission})
+
+	for path, status := range map[string]int{
+		"/users/1/sessions/3":   http.StatusNoContent,
+		"/users/1/sessions/4":   http.StatusNotFound,
+		"/users/1/sessions/abc": http.StatusBadRequest,
+		"/users/2/sessions":     http.StatusForbidden,
+	} {
+		req, _ := http.NewRequest("DELETE", path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, status, w.Code, path)
+	}
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"testovoe/internal/problem"
+	"testovoe/internal/service"
+	"time"
+)
+
+// ssoStateCookie связывает вход через провайдера с браузером, который его начал: без нее
+// злоумышленник мог бы подсунуть жертве адрес возврата со своим кодом и войти ее браузером в свою учетную запись.
+const ssoStateCookie = "sso_state"
+
+type SSOHandler struct {
+	service service.SSOServiceInterface
+}
+
+func NewSSOHandler(service service.SSOServiceInterface) *SSOHandler {
+	return &SSOHandler{service: service}
+}
+
+func (h *SSOHandler) ListSSOProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": h.service.ListSSOProviders()})
+}
+
+func (h *SSOHandler) StartSSOLogin(c *gin.Context) {
+	login, err := h.service.StartSSOLogin(c.Request.Context(), c.Param("provider"))
+	if err != nil {
+		writeError(c, err, "ошибка при входе через провайдера")
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(ssoStateCookie, login.State, int(time.Until(login.ExpiresAt).Seconds()), "/auth/sso", "", secureRequest(c), true)
+	c.Redirect(http.StatusFound, login.URL)
+}
+
+func (h *SSOHandler) CompleteSSOLogin(c *gin.Context) {
+	state := c.Query("state")
+	cookie, _ := c.Cookie(ssoStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(ssoStateCookie, "", -1, "/auth/sso", "", secureRequest(c), true)
+
+	if providerError := c.Query("error"); providerError != "" {
+		problem.Write(c, problem.FromError(c, service.ErrSSOLoginFailed, "").With("provider_error", providerError))
+		return
+	}
+	if state == "" || cookie != state {
+		writeError(c, service.ErrInvalidSSOState, "")
+		return
+	}
+
+	tokens, err := h.service.CompleteSSOLogin(c.Request.Context(), c.Param("provider"), c.Query("code"), state, sessionMeta(c))
+	if err != nil {
+		if writeMFAChallenge(c, err) {
+			return
+		}
+		writeError(c, err, "ошибка при входе через провайдера")
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (h *SSOHandler) ListIdentities(c *gin.Context) {
+	userID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	identities, err := h.service.ListIdentities(c.Request.Context(), userID)
+	if err != nil {
+		writeError(c, err, "ошибка при получении внешних учетных записей")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"identities": identities})
+}
+
+func (h *SSOHandler) UnlinkIdentity(c *gin.Context) {
+	userID, ok := parseIDParam(c, "id")
+	if !ok {
+		return
+	}
+	identityID, ok := parseIDParam(c, "identity_id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.UnlinkIdentity(c.Request.Context(), userID, identityID); err != nil {
+		writeError(c, err, "ошибка при отвязке внешней учетной записи")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// secureRequest сообщает, пришел ли запрос по https, в том числе через прокси.
+func secureRequest(c *gin.Context) bool {
+	return c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+}
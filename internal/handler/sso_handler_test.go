@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testovoe/internal/domain"
+	"testovoe/internal/service"
+	"time"
+)
+
+type MockSSOService struct {
+	mock.Mock
+}
+
+func (m *MockSSOService) ListSSOProviders() []domain.SSOProvider {
+	return m.Called().Get(0).([]domain.SSOProvider)
+}
+
+func (m *MockSSOService) StartSSOLogin(ctx context.Context, provider string) (*domain.SSOLogin, error) {
+	args := m.Called(ctx, provider)
+	return args.Get(0).(*domain.SSOLogin), args.Error(1)
+}
+
+func (m *MockSSOService) CompleteSSOLogin(ctx context.Context, provider, code, state string, meta domain.SessionMeta) (*domain.TokenPair, error) {
+	args := m.Called(ctx, provider, code, state, meta)
+	return args.Get(0).(*domain.TokenPair), args.Error(1)
+}
+
+func (m *MockSSOService) ListIdentities(ctx context.Context, userID int64) ([]domain.Identity, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]domain.Identity), args.Error(1)
+}
+
+func (m *MockSSOService) UnlinkIdentity(ctx context.Context, userID, identityID int64) error {
+	return m.Called(ctx, userID, identityID).Error(0)
+}
+
+func setupSSORouter(h *SSOHandler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.GET("/auth/sso/:provider", h.StartSSOLogin)
+	r.GET("/auth/sso/:provider/callback", h.CompleteSSOLogin)
+	r.DELETE("/users/:id/identities/:identity_id", h.UnlinkIdentity)
+	return r
+}
+
+func TestStartSSOLogin(t *testing.T) {
+	mockService := new(MockSSOService)
+	router := setupSSORouter(NewSSOHandler(mockService))
+
+	mockService.On("StartSSOLogin", mock.Anything, "corp").
+		Return(&domain.SSOLogin{URL: "https://sso.example.com/authorize?state=abc", State: "abc", ExpiresAt: time.Now().Add(10 * time.Minute)}, nil)
+	mockService.On("StartSSOLogin", mock.Anything, "unknown").Return((*domain.SSOLogin)(nil), service.ErrSSOProviderNotFound)
+
+	req, _ := http.NewRequest("GET", "/auth/sso/corp", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://sso.example.com/authorize?state=abc", w.Header().Get("Location"))
+	assert.Contains(t, w.Header().Get("Set-Cookie"), "sso_state=abc")
+	assert.Contains(t, w.Header().Get("Set-Cookie"), "HttpOnly")
+
+	req, _ = http.NewRequest("GET", "/auth/sso/unknown", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestCompleteSSOLogin(t *testing.T) {
+	mockService := new(MockSSOService)
+	router := setupSSORouter(NewSSOHandler(mockService))
+
+	tokens := &domain.TokenPair{AccessToken: "access", RefreshToken: "refresh", TokenType: "Bearer", ExpiresIn: 900}
+	mockService.On("CompleteSSOLogin", mock.Anything, "corp", "code-1", "abc", mock.Anything).Return(tokens, nil)
+	mockService.On("CompleteSSOLogin", mock.Anything, "corp", "code-2", "abc", mock.Anything).
+		Return((*domain.TokenPair)(nil), service.ErrSSOAccountNotFound)
+
+	req, _ := http.NewRequest("GET", "/auth/sso/corp/callback?code=code-1&state=abc", nil)
+	req.AddCookie(&http.Cookie{Name: "sso_state", Value: "abc"})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	assert.Contains(t, w.Body.String(), `"access_token":"access"`)
+
+	req, _ = http.NewRequest("GET", "/auth/sso/corp/callback?code=code-2&state=abc", nil)
+	req.AddCookie(&http.Cookie{Name: "sso_state", Value: "abc"})
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestCompleteSSOLogin_StateMismatch(t *testing.T) {
+	mockService := new(MockSSOService)
+	router := setupSSORouter(NewSSOHandler(mockService))
+
+	// Без cookie браузера, начавшего вход, код не обменивается.
+	req, _ := http.NewRequest("GET", "/auth/sso/corp/callback?code=code-1&state=abc", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	req, _ = http.NewRequest("GET", "/auth/sso/corp/callback?error=access_denied&state=abc", nil)
+	req.AddCookie(&http.Cookie{Name: "sso_state", Value: "abc"})
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), `"provider_error":"access_denied"`)
+
+	mockService.AssertNotCalled(t, "CompleteSSOLogin", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUnlinkIdentity(t *testing.T) {
+	mockService := new(MockSSOService)
+	router := setupSSORouter(NewSSOHandler(mockService))
+
+	mockService.On("UnlinkIdentity", mock.Anything, int64(7), int64(3)).Return(nil)
+	mockService.On("UnlinkIdentity", mock.Anything, int64(7), int64(4)).Return(service.ErrIdentityNotFound)
+
+	req, _ := http.NewRequest("DELETE", "/users/7/identities/3", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	req, _ = http.NewRequest("DELETE", "/users/7/identities/4", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
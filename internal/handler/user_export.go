@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"strconv"
+	"testovoe/internal/domain"
+	"testovoe/internal/problem"
+	"time"
+)
+
+const exportFlushEvery = 500
+
+var exportFormats = []string{"application/json", "text/csv", "application/x-ndjson"}
+
+type userExporter interface {
+	Begin() error
+	Write(user *domain.User) error
+	End() error
+}
+
+func (h *UserHandler) ExportUsers(c *gin.Context) {
+	format := c.NegotiateFormat(exportFormats...)
+	if format == "" {
+		problem.Write(c, problem.New(c, http.StatusNotAcceptable, "not_acceptable", "поддерживаются application/json, text/csv и application/x-ndjson"))
+		return
+	}
+
+	filter := domain.UserFilter{
+		Name:      c.Query("name"),
+		Email:     c.Query("email"),
+		SortBy:    c.Query("sort_by"),
+		SortOrder: domain.SortOrder(c.Query("order")),
+		Deleted:   domain.DeletedFilter(c.Query("deleted")),
+	}
+
+	exporter := newUserExporter(format, c.Writer)
+	started, written := false, 0
+	start := func() error {
+		started = true
+		c.Header("Content-Type", format+"; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="users`+exportExtension(format)+`"`)
+		c.Header("Cache-Control", "no-store")
+		c.Status(http.StatusOK)
+		return exporter.Begin()
+	}
+
+	err := h.service.ExportUsers(c.Request.Context(), filter, func(user *domain.User) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		if err := exporter.Write(user); err != nil {
+			return err
+		}
+		if written++; written%exportFlushEvery == 0 {
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err == nil && !started {
+		err = start()
+	}
+	if err != nil {
+		if started {
+			// Статус уже отправлен: обрываем выгрузку, не дописывая завершение,
+			// чтобы клиент не принял неполные данные за корректный ответ.
+			_ = c.Error(err)
+			return
+		}
+		writeError(c, err, "ошибка при выгрузке пользователей")
+		return
+	}
+
+	if err := exporter.End(); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.Writer.Flush()
+}
+
+func newUserExporter(format string, w io.Writer) userExporter {
+	switch format {
+	case "text/csv":
+		return &csvUserExporter{w: csv.NewWriter(w)}
+	case "application/x-ndjson":
+		return &ndjsonUserExporter{enc: json.NewEncoder(w)}
+	default:
+		return &jsonUserExporter{w: w}
+	}
+}
+
+func exportExtension(format string) string {
+	switch format {
+	case "text/csv":
+		return ".csv"
+	case "application/x-ndjson":
+		return ".ndjson"
+	default:
+		return ".json"
+	}
+}
+
+type csvUserExporter struct {
+	w *csv.Writer
+}
+
+func (e *csvUserExporter) Begin() error {
+	if err := e.w.Write([]string{"id", "name", "email", "version", "email_verified_at", "deleted_at"}); err != nil {
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvUserExporter) Write(user *domain.User) error {
+	err := e.w.Write([]string{
+		strconv.FormatInt(user.ID, 10),
+		user.Name,
+		user.Email,
+		strconv.FormatInt(user.Version, 10),
+		formatCSVTime(user.EmailVerifiedAt),
+		formatCSVTime(user.DeletedAt),
+	})
+	if err != nil {
+		return err
+	}
+	// csv.Writer буферизует сам; сбрасываем строку сразу, буферизацию оставляем http.ResponseWriter.
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func formatCSVTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func (e *csvUserExporter) End() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type ndjsonUserExporter struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonUserExporter) Begin() error {
+	return nil
+}
+
+func (e *ndjsonUserExporter) Write(user *domain.User) error {
+	return e.enc.Encode(user)
+}
+
+func (e *ndjsonUserExporter) End() error {
+	return nil
+}
+
+type jsonUserExporter struct {
+	w     io.Writer
+	count int
+}
+
+func (e *jsonUserExporter) Begin() error {
+	_, err := io.WriteString(e.w, "[")
+	return err
+}
+
+func (e *jsonUserExporter) Write(user *domain.User) error {
+	data, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+	separator := "\n"
+	if e.count > 0 {
+		separator = ",\n"
+	}
+	e.count++
+	if _, err := io.WriteString(e.w, separator); err != nil {
+		return err
+	}
+	_, err = e.w.Write(data)
+	return err
+}
+
+func (e *jsonUserExporter) End() error {
+	if e.count > 0 {
+		_, err := io.WriteString(e.w, "\n]\n")
+		return err
+	}
+	_, err := io.WriteString(e.w, "]\n")
+	return err
+}
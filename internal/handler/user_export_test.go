@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testovoe/internal/domain"
+	"testovoe/internal/service"
+)
+
+func exportedUsers() []domain.User {
+	return []domain.User{
+		{ID: 1, Name: "Иван", Email: "ivan@example.com", Version: 1},
+		{ID: 2, Name: "Петр, младший", Email: "petr@example.com", Version: 3},
+	}
+}
+
+func TestExportUsers_Formats(t *testing.T) {
+	tests := []struct {
+		accept      string
+		contentType string
+		body        string
+	}{
+		{
+			accept:      "",
+			contentType: "application/json; charset=utf-8",
+			body:        "[\n{\"id\":1,\"name\":\"Иван\",\"email\":\"ivan@example.com\",\"version\":1},\n{\"id\":2,\"name\":\"Петр, младший\",\"email\":\"petr@example.com\",\"version\":3}\n]\n",
+		},
+		{
+			accept:      "text/csv",
+			contentType: "text/csv; charset=utf-8",
+			body:        "id,name,email,version,email_verified_at,deleted_at\n1,Иван,ivan@example.com,1,,\n2,\"Петр, младший\",petr@example.com,3,,\n",
+		},
+		{
+			accept:      "application/x-ndjson",
+			contentType: "application/x-ndjson; charset=utf-8",
+			body:        "{\"id\":1,\"name\":\"Иван\",\"email\":\"ivan@example.com\",\"version\":1}\n{\"id\":2,\"name\":\"Петр, младший\",\"email\":\"petr@example.com\",\"version\":3}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			mockService := new(MockUserService)
+			handler := NewUserHandler(mockService)
+			router := setupRouter(handler)
+
+			filter := domain.UserFilter{Name: "ив", SortBy: "name"}
+			mockService.On("ExportUsers", mock.Anything, filter, mock.Anything).Return(exportedUsers(), nil)
+
+			req, _ := http.NewRequest("GET", "/users/export?name=ив&sort_by=name&limit=1", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, tt.body, w.Body.String())
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestExportUsers_EmptyJSON(t *testing.T) {
+	mockService := new(MockUserService)
+	handler := NewUserHandler(mockService)
+	router := setupRouter(handler)
+
+	mockService.On("ExportUsers", mock.Anything, domain.UserFilter{}, mock.Anything).Return(nil, nil)
+
+	req, _ := http.NewRequest("GET", "/users/export", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "[]\n", w.Body.String())
+}
+
+func TestExportUsers_InvalidSort(t *testing.T) {
+	mockService := new(MockUserService)
+	handler := NewUserHandler(mockService)
+	router := setupRouter(handler)
+
+	mockService.On("ExportUsers", mock.Anything, domain.UserFilter{SortBy: "password"}, mock.Anything).Return(nil, service.ErrInvalidSort)
+
+	req, _ := http.NewRequest("GET", "/users/export?sort_by=password", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestExportUsers_NotAcceptable(t *testing.T) {
+	mockService := new(MockUserService)
+	handler := NewUserHandler(mockService)
+	router := setupRouter(handler)
+
+	req, _ := http.NewRequest("GET", "/users/export", nil)
+	req.Header.Set("Accept", "application/xml")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+	mockService.AssertNotCalled(t, "ExportUsers")
+}
@@ -1,11 +1,13 @@
 package handler
 
 import (
-	"context"
 	"github.com/gin-gonic/gin"
+	"io"
 	"net/http"
 	"strconv"
 	"testovoe/internal/domain"
+	"testovoe/internal/i18n"
+	"testovoe/internal/problem"
 	"testovoe/internal/service"
 )
 
@@ -21,65 +23,222 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 	var user domain.User
 
 	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректные данные"})
+		writeError(c, errInvalidBody, "")
 		return
 	}
 
-	if err := h.service.CreateUser(context.Background(), &user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка при создании пользователя"})
+	if err := h.service.CreateUser(c.Request.Context(), &user); err != nil {
+		writeError(c, err, "ошибка при создании пользователя")
 		return
 	}
+	setETag(c, user.Version)
 	c.JSON(http.StatusCreated, gin.H{"user": user})
 }
 
 func (h *UserHandler) GetUserByID(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный формат ID"})
+		writeError(c, errInvalidID, "")
 		return
 	}
 
-	user, err := h.service.GetUserByID(context.Background(), id)
+	user, err := h.service.GetUserByID(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "пользователь не найден"})
+		writeError(c, err, "ошибка при получении пользователя")
 		return
 	}
 
+	setETag(c, user.Version)
 	c.JSON(http.StatusOK, gin.H{"user": user})
 }
 
 func (h *UserHandler) UpdateUserByID(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный формат ID"})
+		writeError(c, errInvalidID, "")
+		return
+	}
+
+	version, ok := requireIfMatch(c)
+	if !ok {
 		return
 	}
 
 	var updateUser domain.User
 	if err := c.ShouldBindJSON(&updateUser); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "некорректные данные"})
+		writeError(c, errInvalidBody, "")
 		return
 	}
+	updateUser.Version = version
 
-	if err := h.service.UpdateUserByID(context.Background(), id, &updateUser); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка при обновлении пользователя"})
+	if err := h.service.UpdateUserByID(c.Request.Context(), id, &updateUser); err != nil {
+		writeError(c, err, "ошибка при обновлении пользователя")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "пользователь успешно обновлен"})
+	setETag(c, updateUser.Version)
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(c.Request.Context(), "пользователь успешно обновлен")})
+}
+
+func (h *UserHandler) PatchUserByID(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		writeError(c, errInvalidID, "")
+		return
+	}
+
+	version, ok := requireIfMatch(c)
+	if !ok {
+		return
+	}
+
+	var format service.PatchFormat
+	switch c.ContentType() {
+	case "application/merge-patch+json":
+		format = service.MergePatch
+	case "application/json-patch+json":
+		format = service.JSONPatch
+	default:
+		c.Header("Accept-Patch", "application/merge-patch+json, application/json-patch+json")
+		problem.Write(c, problem.New(c, http.StatusUnsupportedMediaType, "unsupported_patch_format", "неподдерживаемый формат патча"))
+		return
+	}
+
+	patch, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		writeError(c, errInvalidBody, "")
+		return
+	}
+
+	user, err := h.service.PatchUserByID(c.Request.Context(), id, version, format, patch)
+	if err != nil {
+		writeError(c, err, "ошибка при обновлении пользователя")
+		return
+	}
+
+	setETag(c, user.Version)
+	c.JSON(http.StatusOK, gin.H{"user": user})
 }
 
 func (h *UserHandler) DeleteUserByID(c *gin.Context) {
 	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "неверный формат ID"})
+		writeError(c, errInvalidID, "")
+		return
+	}
+
+	version, ok := requireIfMatch(c)
+	if !ok {
 		return
 	}
 
-	if err := h.service.DeleteUserByID(context.Background(), id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка при удалении пользователя"})
+	if err := h.service.DeleteUserByID(c.Request.Context(), id, version); err != nil {
+		writeError(c, err, "ошибка при удалении пользователя")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(c.Request.Context(), "пользователь успешно удален")})
+}
+
+func (h *UserHandler) RestoreUserByID(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		writeError(c, errInvalidID, "")
+		return
+	}
+
+	if err := h.service.RestoreUserByID(c.Request.Context(), id); err != nil {
+		writeError(c, err, "ошибка при восстановлении пользователя")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(c.Request.Context(), "пользователь успешно восстановлен")})
+}
+
+func (h *UserHandler) PurgeUserByID(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		writeError(c, errInvalidID, "")
+		return
+	}
+
+	if err := h.service.PurgeUserByID(c.Request.Context(), id); err != nil {
+		writeError(c, err, "ошибка при удалении пользователя")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.T(c.Request.Context(), "пользователь удален безвозвратно")})
+}
+
+func (h *UserHandler) ListUsers(c *gin.Context) {
+	filter := domain.UserFilter{
+		Name:      c.Query("name"),
+		Email:     c.Query("email"),
+		SortBy:    c.Query("sort_by"),
+		SortOrder: domain.SortOrder(c.Query("order")),
+		Deleted:   domain.DeletedFilter(c.Query("deleted")),
+		Cursor:    c.Query("cursor"),
+	}
+
+	var err error
+	if limit := c.Query("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil {
+			writeError(c, errInvalidLimit, "")
+			return
+		}
+	}
+	if offset := c.Query("offset"); offset != "" {
+		if filter.Offset, err = strconv.Atoi(offset); err != nil {
+			writeError(c, errInvalidOffset, "")
+			return
+		}
+	}
+
+	list, err := h.service.ListUsers(c.Request.Context(), filter)
+	if err != nil {
+		writeError(c, err, "ошибка при получении списка пользователей")
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+func (h *UserHandler) GetUserRoles(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		writeError(c, errInvalidID, "")
+		return
+	}
+
+	roles, err := h.service.GetUserRoles(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, err, "ошибка при получении ролей пользователя")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+func (h *UserHandler) SetUserRoles(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		writeError(c, errInvalidID, "")
+		return
+	}
+
+	var request struct {
+		Roles []domain.Role `json:"roles"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || request.Roles == nil {
+		writeError(c, errInvalidBody, "")
+		return
+	}
+
+	roles, err := h.service.SetUserRoles(c.Request.Context(), id, request.Roles)
+	if err != nil {
+		writeError(c, err, "ошибка при изменении ролей пользователя")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "пользователь успешно удален"})
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
 }
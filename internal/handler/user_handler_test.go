@@ -10,7 +10,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"testovoe/internal/apperr"
 	"testovoe/internal/domain"
+	"testovoe/internal/problem"
 	"testovoe/internal/service"
 )
 
@@ -33,17 +35,73 @@ func (m *MockUserService) UpdateUserByID(ctx context.Context, id int64, user *do
 	return args.Error(0)
 }
 
-func (m *MockUserService) DeleteUserByID(ctx context.Context, id int64) error {
+func (m *MockUserService) PatchUserByID(ctx context.Context, id int64, version int64, format service.PatchFormat, patch []byte) (*domain.User, error) {
+	args := m.Called(ctx, id, version, format, patch)
+	return args.Get(0).(*domain.User), args.Error(1)
+}
+
+func (m *MockUserService) DeleteUserByID(ctx context.Context, id int64, version int64) error {
+	args := m.Called(ctx, id, version)
+	return args.Error(0)
+}
+
+func (m *MockUserService) RestoreUserByID(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockUserService) PurgeUserByID(ctx context.Context, id int64) error {
 	args := m.Called(ctx, id)
 	return args.Error(0)
 }
 
+func (m *MockUserService) ListUsers(ctx context.Context, filter domain.UserFilter) (*domain.UserList, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(*domain.UserList), args.Error(1)
+}
+
+func (m *MockUserService) ExportUsers(ctx context.Context, filter domain.UserFilter, fn func(user *domain.User) error) error {
+	args := m.Called(ctx, filter, fn)
+	if users, ok := args.Get(0).([]domain.User); ok {
+		for i := range users {
+			if err := fn(&users[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockUserService) ImportUsers(ctx context.Context, rows []domain.UserImportRow, mode domain.UserImportMode) (*domain.UserImportResult, error) {
+	args := m.Called(ctx, rows, mode)
+	return args.Get(0).(*domain.UserImportResult), args.Error(1)
+}
+
+func (m *MockUserService) GetUserRoles(ctx context.Context, id int64) ([]domain.Role, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).([]domain.Role), args.Error(1)
+}
+
+func (m *MockUserService) SetUserRoles(ctx context.Context, id int64, roles []domain.Role) ([]domain.Role, error) {
+	args := m.Called(ctx, id, roles)
+	return args.Get(0).([]domain.Role), args.Error(1)
+}
+
 func setupRouter(h *UserHandler) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	r := gin.Default()
+	r.GET("/users", h.ListUsers)
 	r.POST("/users", h.CreateUser)
+	r.POST("/users/import", h.ImportUsers)
+	r.GET("/users/export", h.ExportUsers)
 	r.GET("/users/:id", h.GetUserByID)
 	r.PUT("/users/:id", h.UpdateUserByID)
+	r.PATCH("/users/:id", h.PatchUserByID)
+	r.DELETE("/users/:id", h.DeleteUserByID)
+	r.POST("/users/:id/restore", h.RestoreUserByID)
+	r.GET("/users/:id/roles", h.GetUserRoles)
+	r.PUT("/users/:id/roles", h.SetUserRoles)
+	r.DELETE("/admin/users/:id", h.PurgeUserByID)
 	return r
 }
 func TestCreateUser(t *testing.T) {
@@ -67,6 +125,45 @@ func TestCreateUser(t *testing.T) {
 	mockService.AssertExpectations(t)
 }
 
+func TestCreateUser_EmailTaken(t *testing.T) {
+	mockService := new(MockUserService)
+	handler := NewUserHandler(mockService)
+	router := setupRouter(handler)
+
+	mockService.On("CreateUser", mock.Anything, mock.Anything).Return(service.ErrEmailTaken)
+
+	req, _ := http.NewRequest("POST", "/users", bytes.NewBufferString(`{"name":"test","email":"test@example.com"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestCreateUser_InvalidFields(t *testing.T) {
+	mockService := new(MockUserService)
+	handler := NewUserHandler(mockService)
+	router := setupRouter(handler)
+
+	invalid := service.ErrInvalidUser.WithFields(
+		apperr.FieldError{Field: "name", Code: service.FieldRequired, Message: "укажите имя"},
+		apperr.FieldError{Field: "email", Code: service.FieldInvalidEmail, Message: "некорректный email"},
+	)
+	mockService.On("CreateUser", mock.Anything, mock.Anything).Return(invalid)
+
+	req, _ := http.NewRequest("POST", "/users", bytes.NewBufferString(`{"name":"","email":"not-an-email"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	var response problem.Problem
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "invalid_user", response.Code)
+	assert.Len(t, response.Errors, 2)
+	assert.Equal(t, "email", response.Errors[1].Field)
+}
+
 func TestCreateUser_BadRequest(t *testing.T) {
 	mockService := new(MockUserService)
 	handler := NewUserHandler(mockService)
@@ -88,7 +185,7 @@ func TestGetUserByID(t *testing.T) {
 	handler := NewUserHandler(mockService)
 	router := setupRouter(handler)
 
-	user := &domain.User{ID: 1, Name: "test", Email: "test@example.com"}
+	user := &domain.User{ID: 1, Name: "test", Email: "test@example.com", Version: 4}
 	mockService.On("GetUserByID", mock.Anything, int64(1)).Return(user, nil)
 
 	req, _ := http.NewRequest("GET", "/users/1", nil)
@@ -97,6 +194,7 @@ func TestGetUserByID(t *testing.T) {
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"4"`, w.Header().Get("ETag"))
 	assert.Contains(t, w.Body.String(), `"id":1`)
 	assert.Contains(t, w.Body.String(), `"name":"test"`)
 	mockService.AssertExpectations(t)
@@ -124,12 +222,13 @@ func TestUpdateUserByID(t *testing.T) {
 	handler := NewUserHandler(mockService)
 	router := setupRouter(handler)
 
-	user := domain.User{Name: "updated", Email: "updated@example.com"}
+	user := domain.User{Name: "updated", Email: "updated@example.com", Version: 1}
 	mockService.On("UpdateUserByID", mock.Anything, int64(1), &user).Return(nil)
 
 	body, _ := json.Marshal(user)
 	req, _ := http.NewRequest("PUT", "/users/1", bytes.NewBuffer(body))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
 	w := httptest.NewRecorder()
 
 	router.ServeHTTP(w, req)
@@ -139,6 +238,31 @@ func TestUpdateUserByID(t *testing.T) {
 	mockService.AssertExpectations(t)
 }
 
+func TestUpdateUserByID_NotFound(t *testing.T) {
+	mockService := new(MockUserService)
+	handler := NewUserHandler(mockService)
+	router := setupRouter(handler)
+
+	user := domain.User{Name: "updated", Email: "updated@example.com", Version: 1}
+	mockService.On("UpdateUserByID", mock.Anything, int64(1), &user).Return(service.ErrUserNotFound)
+
+	body, _ := json.Marshal(user)
+	req, _ := http.NewRequest("PUT", "/users/1", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+	var response problem.Problem
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "user_not_found", response.Code)
+	assert.Equal(t, "/users/1", response.Instance)
+	mockService.AssertExpectations(t)
+}
+
 func TestUpdateUserByID_BadID(t *testing.T) {
 	mockService := new(MockUserService)
 	handler := NewUserHandler(mockService)
@@ -154,3 +278,367 @@ func TestUpdateUserByID_BadID(t *testing.T) {
 	assert.Contains(t, w.Body.String(), "неверный формат ID")
 	mockService.AssertNotCalled(t, "UpdateUserByID")
 }
+
+func TestListUsers(t *testing.T) {
+	mockService := new(MockUserService)
+	handler := NewUserHandler(mockService)
+	router := setupRouter(handler)
+
+	filter := domain.UserFilter{Name: "te", SortBy: "name", SortOrder: domain.SortDesc, Limit: 10, Offset: 20}
+	total := int64(21)
+	list := &domain.UserList{
+		Users:  []domain.User{{ID: 1, Name: "test", Email: "test@example.com"}},
+		Total:  &total,
+		Limit:  10,
+		Offset: 20,
+	}
+	mockService.On("ListUsers", mock.Anything, filter).Return(list, nil)
+
+	req, _ := http.NewRequest("GET", "/users?name=te&sort_by=name&order=desc&limit=10&offset=20", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"total":21`)
+	assert.Contains(t, w.Body.String(), `"limit":10`)
+	assert.Contains(t, w.Body.String(), `"offset":20`)
+	assert.Contains(t, w.Body.String(), `"name":"test"`)
+	mockService.AssertExpectations(t)
+}
+
+func TestListUsers_BadLimit(t *testing.T) {
+	mockService := new(MockUserService)
+	handler := NewUserHandler(mockService)
+	router := setupRouter(handler)
+
+	req, _ := http.NewRequest("GET", "/users?limit=abc", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "неверный формат limit")
+	mockService.AssertNotCalled(t, "ListUsers")
+}
+
+func TestListUsers_InvalidSort(t *testing.T) {
+	mockService := new(MockUserService)
+	handler := NewUserHandler(mockService)
+	router := setupRouter(handler)
+
+	filter := domain.UserFilter{SortBy: "password"}
+	mockService.On("ListUsers", mock.Anything, filter).Return((*domain.UserList)(nil), service.ErrInvalidSort)
+
+	req, _ := http.NewRequest("GET", "/users?sort_by=password", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), service.ErrInvalidSort.Error())
+	mockService.AssertExpectations(t)
+}
+
+func TestListUsers_Cursor(t *testing.T) {
+	mockService := new(MockUserService)
+	handler := NewUserHandler(mockService)
+	router := setupRouter(handler)
+
+	filter := domain.UserFilter{Cursor: "abc.def", Limit: 2}
+	list := &domain.UserList{
+		Users:      []domain.User{{ID: 5, Name: "test", Email: "test@example.com"}},
+		Limit:      2,
+		HasMore:    true,
+		NextCursor: "next.cursor",
+	}
+	mockService.On("ListUsers", mock.Anything, filter).Return(list, nil)
+
+	req, _ := http.NewRequest("GET", "/users?cursor=abc.def&limit=2", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"next_cursor":"next.cursor"`)
+	assert.NotContains(t, w.Body.String(), `"total"`)
+	mockService.AssertExpectations(t)
+}
+
+func TestPatchUserByID_MergePatch(t *testing.T) {
+	mockService := new(MockUserService)
+	handler := NewUserHandler(mockService)
+	router := setupRouter(handler)
+
+	patch := []byte(`{"name":"patched"}`)
+	user := &domain.User{ID: 1, Name: "patched", Email: "test@example.com", Version: 3}
+	mockService.On("PatchUserByID", mock.Anything, int64(1), int64(2), service.MergePatch, patch).Return(user, nil)
+
+	req, _ := http.NewRequest("PATCH", "/users/1", bytes.NewBuffer(patch))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req.Header.Set("If-Match", `"2"`)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+	assert.Contains(t, w.Body.String(), `"name":"patched"`)
+	mockService.AssertExpectations(t)
+}
+
+func TestPatchUserByID_JSONPatchTestFailed(t *testing.T) {
+	mockService := new(MockUserService)
+	handler := NewUserHandler(mockService)
+	router := setupRouter(handler)
+
+	patch := []byte(`[{"op":"test","path":"/name","value":"old"}]`)
+	mockService.On("PatchUserByID", mock.Anything, int64(1), int64(1), service.JSONPatch, patch).Return((*domain.User)(nil), service.ErrPatchTestFailed)
+
+	req, _ := http.NewRequest("PATCH", "/users/1", bytes.NewBuffer(patch))
+	req.Header.Set("Content-Type", "application/json-patch+json")
+	req.Header.Set("If-Match", `"1"`)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestPatchUserByID_UnsupportedMediaType(t *testing.T) {
+	mockService := new(MockUserService)
+	handler := NewUserHandler(mockService)
+	router := setupRouter(handler)
+
+	req, _ := http.NewRequest("PATCH", "/users/1", bytes.NewBuffer([]byte(`{"name":"patched"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	assert.Contains(t, w.Header().Get("Accept-Patch"), "application/merge-patch+json")
+	mockService.AssertNotCalled(t, "PatchUserByID")
+}
+
+func TestListUsers_DeletedOnly(t *testing.T) {
+	mockService := new(MockUserService)
+	handler := NewUserHandler(mockService)
+	router := setupRouter(handler)
+
+	filter := domain.UserFilter{Deleted: domain.DeletedOnly}
+	mockService.On("ListUsers", mock.Anything, filter).Return(&domain.UserList{Users: []domain.User{}}, nil)
+
+	req, _ := http.NewRequest("GET", "/users?deleted=only", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestRestoreUserByID(t *testing.T) {
+	mockService := new(MockUserService)
+	handler := NewUserHandler(mockService)
+	router := setupRouter(handler)
+
+	mockService.On("RestoreUserByID", mock.Anything, int64(1)).Return(nil)
+
+	req, _ := http.NewRequest("POST", "/users/1/restore", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "пользователь успешно восстановлен")
+	mockService.AssertExpectations(t)
+}
+
+func TestRestoreUserByID_EmailTaken(t *testing.T) {
+	mockService := new(MockUserService)
+	handler := NewUserHandler(mockService)
+	router := setupRouter(handler)
+
+	mockService.On("RestoreUserByID", mock.Anything, int64(1)).Return(service.ErrEmailTaken)
+
+	req, _ := http.NewRequest("POST", "/users/1/restore", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestPurgeUserByID_NotFound(t *testing.T) {
+	mockService := new(MockUserService)
+	handler := NewUserHandler(mockService)
+	router := setupRouter(handler)
+
+	mockService.On("PurgeUserByID", mock.Anything, int64(999)).Return(service.ErrUserNotFound)
+
+	req, _ := http.NewRequest("DELETE", "/admin/users/999", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestUpdateUserByID_MissingIfMatch(t *testing.T) {
+	mockService := new(MockUserService)
+	handler := NewUserHandler(mockService)
+	router := setupRouter(handler)
+
+	req, _ := http.NewRequest("PUT", "/users/1", bytes.NewBuffer([]byte(`{"name": "updated", "email": "updated@example.com"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+	mockService.AssertNotCalled(t, "UpdateUserByID")
+}
+
+func TestUpdateUserByID_VersionConflict(t *testing.T) {
+	mockService := new(MockUserService)
+	handler := NewUserHandler(mockService)
+	router := setupRouter(handler)
+
+	user := domain.User{Name: "updated", Email: "updated@example.com", Version: 1}
+	mockService.On("UpdateUserByID", mock.Anything, int64(1), &user).Return(service.ErrVersionConflict)
+
+	body, _ := json.Marshal(user)
+	req, _ := http.NewRequest("PUT", "/users/1", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", `"1"`)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestDeleteUserByID(t *testing.T) {
+	mockService := new(MockUserService)
+	handler := NewUserHandler(mockService)
+	router := setupRouter(handler)
+
+	mockService.On("DeleteUserByID", mock.Anything, int64(1), int64(5)).Return(nil)
+
+	req, _ := http.NewRequest("DELETE", "/users/1", nil)
+	req.Header.Set("If-Match", `"5"`)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "пользователь успешно удален")
+	mockService.AssertExpectations(t)
+}
+
+func TestDeleteUserByID_IfMatchAny(t *testing.T) {
+	mockService := new(MockUserService)
+	handler := NewUserHandler(mockService)
+	router := setupRouter(handler)
+
+	mockService.On("DeleteUserByID", mock.Anything, int64(1), domain.AnyVersion).Return(nil)
+
+	req, _ := http.NewRequest("DELETE", "/users/1", nil)
+	req.Header.Set("If-Match", "*")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestDeleteUserByID_IfMatchTags(t *testing.T) {
+	mockService := new(MockUserService)
+	handler := NewUserHandler(mockService)
+	router := setupRouter(handler)
+
+	mockService.On("DeleteUserByID", mock.Anything, int64(1), int64(5)).Return(nil)
+
+	for header, status := range map[string]int{
+		`W/"5"`:        http.StatusPreconditionFailed,
+		`W/"4", "5"`:   http.StatusOK,
+		`"0"`:          http.StatusPreconditionFailed,
+		`"4", "5"`:     http.StatusBadRequest,
+		`5`:            http.StatusBadRequest,
+		`W/5`:          http.StatusBadRequest,
+		"`5`":          http.StatusBadRequest,
+		`"5", garbage`: http.StatusBadRequest,
+	} {
+		req, _ := http.NewRequest("DELETE", "/users/1", nil)
+		req.Header.Set("If-Match", header)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, status, w.Code, header)
+	}
+	mockService.AssertNumberOfCalls(t, "DeleteUserByID", 1)
+}
+
+func TestDeleteUserByID_MissingIfMatch(t *testing.T) {
+	mockService := new(MockUserService)
+	handler := NewUserHandler(mockService)
+	router := setupRouter(handler)
+
+	req, _ := http.NewRequest("DELETE", "/users/1", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+	mockService.AssertNotCalled(t, "DeleteUserByID")
+}
+
+func TestSetUserRoles(t *testing.T) {
+	mockService := new(MockUserService)
+	handler := NewUserHandler(mockService)
+	router := setupRouter(handler)
+
+	roles := []domain.Role{domain.RoleOperator}
+	mockService.On("SetUserRoles", mock.Anything, int64(1), roles).Return(roles, nil)
+	mockService.On("SetUserRoles", mock.Anything, int64(1), []domain.Role{"root"}).Return([]domain.Role(nil), service.ErrInvalidRole)
+
+	req, _ := http.NewRequest("PUT", "/users/1/roles", bytes.NewBufferString(`{"roles":["operator"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"roles":["operator"]}`, w.Body.String())
+
+	req, _ = http.NewRequest("PUT", "/users/1/roles", bytes.NewBufferString(`{"roles":["root"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestDeleteUserByID_Forbidden(t *testing.T) {
+	mockService := new(MockUserService)
+	handler := NewUserHandler(mockService)
+	router := setupRouter(handler)
+
+	denied := &service.AccessDeniedError{Permission: domain.PermissionUsersDelete, Reason: service.DenyReasonMissingPermission}
+	mockService.On("DeleteUserByID", mock.Anything, int64(1), int64(2)).Return(denied)
+
+	req, _ := http.NewRequest("DELETE", "/users/1", nil)
+	req.Header.Set("If-Match", `"2"`)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"type":"urn:testovoe:problem:forbidden","title":"Forbidden","status":403,"detail":"доступ запрещен",
+		"instance":"/users/1","code":"forbidden","reason":"missing_permission","permission":"users:delete"}`, w.Body.String())
+}
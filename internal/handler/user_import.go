@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"strings"
+	"testovoe/internal/apperr"
+	"testovoe/internal/domain"
+	"testovoe/internal/problem"
+	"testovoe/internal/service"
+)
+
+const maxImportBodySize = 64 << 20
+
+// Ошибки разбора файла импорта.
+var (
+	errInvalidImportFile = apperr.New(apperr.KindInvalid, "invalid_import_file", "некорректный файл импорта")
+	errEmptyCSV          = apperr.New(apperr.KindInvalid, "empty_import_file", "пустой CSV файл")
+	errInvalidCSVHeader  = apperr.New(apperr.KindInvalid, "invalid_csv_header", "некорректный заголовок CSV")
+	errMissingCSVColumns = apperr.New(apperr.KindInvalid, "missing_csv_columns", "в заголовке CSV должны быть колонки name и email")
+)
+
+func (h *UserHandler) ImportUsers(c *gin.Context) {
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBodySize)
+
+	var rows []domain.UserImportRow
+	var err error
+	switch c.ContentType() {
+	case "text/csv":
+		rows, err = parseCSVImport(body)
+	case "application/x-ndjson":
+		rows, err = parseNDJSONImport(body)
+	default:
+		problem.Write(c, problem.New(c, http.StatusUnsupportedMediaType, "unsupported_import_format", "ожидается text/csv или application/x-ndjson"))
+		return
+	}
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			problem.Write(c, problem.New(c, http.StatusRequestEntityTooLarge, "import_too_large", "слишком большой файл импорта"))
+			return
+		}
+		if _, ok := apperr.As(err); !ok {
+			err = errInvalidImportFile.Wrap(err)
+		}
+		writeError(c, err, "")
+		return
+	}
+
+	result, err := h.service.ImportUsers(c.Request.Context(), rows, domain.UserImportMode(c.Query("mode")))
+	if err != nil {
+		// Отчет по строкам помогает исправить файл, поэтому отдается вместе с ошибкой.
+		if errors.Is(err, service.ErrImportRejected) {
+			problem.Write(c, problem.FromError(c, err, "").With("result", result))
+			return
+		}
+		writeError(c, err, "ошибка при импорте пользователей")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"result": result})
+}
+
+func parseCSVImport(r io.Reader) ([]domain.UserImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errEmptyCSV
+		}
+		return nil, errInvalidCSVHeader.Wrap(err)
+	}
+
+	nameColumn, emailColumn := -1, -1
+	for i, column := range header {
+		switch strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\uFEFF"))) {
+		case "name":
+			nameColumn = i
+		case "email":
+			emailColumn = i
+		}
+	}
+	if nameColumn < 0 || emailColumn < 0 {
+		return nil, errMissingCSVColumns
+	}
+
+	var rows []domain.UserImportRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				rows = append(rows, domain.UserImportRow{Line: parseErr.StartLine, ParseError: parseErr.Err.Error()})
+				continue
+			}
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if len(record) <= nameColumn || len(record) <= emailColumn {
+			rows = append(rows, domain.UserImportRow{Line: line, ParseError: "недостаточно колонок"})
+			continue
+		}
+
+		rows = append(rows, domain.UserImportRow{
+			Line: line,
+			User: domain.User{Name: record[nameColumn], Email: record[emailColumn]},
+		})
+	}
+	return rows, nil
+}
+
+func parseNDJSONImport(r io.Reader) ([]domain.UserImportRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var rows []domain.UserImportRow
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		var payload struct {
+			Name  string `json:"name"`
+			Email string `json:"email"`
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&payload); err != nil {
+			rows = append(rows, domain.UserImportRow{Line: line, ParseError: "некорректный JSON"})
+			continue
+		}
+
+		rows = append(rows, domain.UserImportRow{
+			Line: line,
+			User: domain.User{Name: payload.Name, Email: payload.Email},
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
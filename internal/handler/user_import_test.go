@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testovoe/internal/domain"
+	"testovoe/internal/service"
+)
+
+func TestParseCSVImport(t *testing.T) {
+	rows, err := parseCSVImport(strings.NewReader("email,name\nivan@example.com,Иван\nbad\"quote,x\n\npetr@example.com,Петр\nonly-one-column\n"))
+	assert.NoError(t, err)
+	assert.Len(t, rows, 4)
+
+	assert.Equal(t, domain.UserImportRow{Line: 2, User: domain.User{Name: "Иван", Email: "ivan@example.com"}}, rows[0])
+	assert.Equal(t, 3, rows[1].Line)
+	assert.NotEmpty(t, rows[1].ParseError)
+	assert.Equal(t, domain.UserImportRow{Line: 5, User: domain.User{Name: "Петр", Email: "petr@example.com"}}, rows[2])
+	assert.Equal(t, domain.UserImportRow{Line: 6, ParseError: "недостаточно колонок"}, rows[3])
+}
+
+func TestParseCSVImport_MissingColumns(t *testing.T) {
+	_, err := parseCSVImport(strings.NewReader("name,phone\nИван,123\n"))
+	assert.Error(t, err)
+}
+
+func TestParseNDJSONImport(t *testing.T) {
+	rows, err := parseNDJSONImport(strings.NewReader("{\"name\":\"Иван\",\"email\":\"ivan@example.com\"}\n\n{broken\n{\"name\":\"Петр\",\"email\":\"petr@example.com\"}\n"))
+	assert.NoError(t, err)
+	assert.Len(t, rows, 3)
+
+	assert.Equal(t, domain.UserImportRow{Line: 1, User: domain.User{Name: "Иван", Email: "ivan@example.com"}}, rows[0])
+	assert.Equal(t, domain.UserImportRow{Line: 3, ParseError: "некорректный JSON"}, rows[1])
+	assert.Equal(t, 4, rows[2].Line)
+}
+
+func TestImportUsers_CSV(t *testing.T) {
+	mockService := new(MockUserService)
+	handler := NewUserHandler(mockService)
+	router := setupRouter(handler)
+
+	rows := []domain.UserImportRow{{Line: 2, User: domain.User{Name: "Иван", Email: "ivan@example.com"}}}
+	result := &domain.UserImportResult{Mode: domain.ImportSkipInvalid, Total: 1, Imported: 1, Errors: []domain.UserImportRowError{}}
+	mockService.On("ImportUsers", mock.Anything, rows, domain.ImportSkipInvalid).Return(result, nil)
+
+	req, _ := http.NewRequest("POST", "/users/import?mode=skip_invalid", bytes.NewBufferString("name,email\nИван,ivan@example.com\n"))
+	req.Header.Set("Content-Type", "text/csv")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"imported":1`)
+	mockService.AssertExpectations(t)
+}
+
+func TestImportUsers_Rejected(t *testing.T) {
+	mockService := new(MockUserService)
+	handler := NewUserHandler(mockService)
+	router := setupRouter(handler)
+
+	result := &domain.UserImportResult{
+		Mode:    domain.ImportAtomic,
+		Total:   1,
+		Skipped: 1,
+		Errors:  []domain.UserImportRowError{{Line: 1, Error: "укажите имя"}},
+	}
+	mockService.On("ImportUsers", mock.Anything, mock.Anything, domain.UserImportMode("")).Return(result, service.ErrImportRejected)
+
+	req, _ := http.NewRequest("POST", "/users/import", bytes.NewBufferString("{\"name\":\"\",\"email\":\"x@example.com\"}\n"))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), `"line":1`)
+	mockService.AssertExpectations(t)
+}
+
+func TestImportUsers_UnsupportedMediaType(t *testing.T) {
+	mockService := new(MockUserService)
+	handler := NewUserHandler(mockService)
+	router := setupRouter(handler)
+
+	req, _ := http.NewRequest("POST", "/users/import", bytes.NewBufferString(`[]`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	mockService.AssertNotCalled(t, "ImportUsers")
+}
@@ -0,0 +1,205 @@
+package i18n
+
+// english — переводы сообщений API на английский язык.
+var english = map[string]string{
+	// Общие ошибки и разбор запроса.
+	"объект не найден": "resource not found",
+	"объект конфликтует с существующими данными": "resource conflicts with existing data",
+	"данные не прошли проверку":                  "validation failed",
+	"условие запроса не выполнено":               "precondition failed",
+	"недопустимое значение":                      "invalid value",
+	"слишком длинное значение":                   "value is too long",
+	"некорректные данные":                        "malformed request body",
+	"неверный формат ID":                         "malformed ID",
+	"неверный формат limit":                      "malformed limit",
+	"неверный формат offset":                     "malformed offset",
+	"неверный формат from, ожидается RFC 3339":   "malformed from, RFC 3339 expected",
+	"неверный формат to, ожидается RFC 3339":     "malformed to, RFC 3339 expected",
+	"неверный формат entity_id":                  "malformed entity_id",
+	"требуется заголовок If-Match":               "If-Match header is required",
+	"некорректный заголовок If-Match":            "malformed If-Match header",
+	"доступ запрещен":                            "access denied",
+
+	// Аутентификация и ограничения.
+	"требуется аутентификация":                                "authentication required",
+	"неверные учетные данные":                                 "invalid credentials",
+	"неподдерживаемая схема аутентификации":                   "unsupported authentication scheme",
+	"ошибка при проверке учетных данных":                      "failed to verify credentials",
+	"слишком много запросов, попробуйте позже":                "too many requests, try again later",
+	"слишком длинный Idempotency-Key":                         "Idempotency-Key is too long",
+	"ошибка при обработке Idempotency-Key":                    "failed to process Idempotency-Key",
+	"Idempotency-Key уже использован с другим запросом":       "Idempotency-Key has already been used with a different request",
+	"запрос с этим Idempotency-Key еще выполняется":           "a request with this Idempotency-Key is still in progress",
+	"слишком большой запрос":                                  "request is too large",
+	"внутренняя ошибка сервера":                               "internal server error",
+	"неверный email или пароль":                               "invalid email or password",
+	"недействительный refresh-токен":                          "invalid refresh token",
+	"слишком много неудачных попыток входа, попробуйте позже": "too many failed login attempts, try again later",
+	"блокировка входа не найдена":                             "login lock not found",
+	"неудачных попыток входа нет":                             "no failed login attempts",
+
+	// Пользователи.
+	"пользователь не найден":                                           "user not found",
+	"пользователь был изменен другим запросом":                         "user was modified by another request",
+	"версия пользователя не совпадает":                                 "user version does not match",
+	"версия пользователя устарела":                                     "user version is outdated",
+	"email уже используется":                                           "email is already in use",
+	"данные пользователя не прошли проверку":                           "user data failed validation",
+	"поле не может быть изменено":                                      "field cannot be changed",
+	"проверка в патче не прошла":                                       "patch test operation failed",
+	"некорректный патч":                                                "malformed patch",
+	"неподдерживаемый формат патча":                                    "unsupported patch format",
+	"недопустимые параметры сортировки":                                "invalid sort parameters",
+	"недопустимые параметры пагинации":                                 "invalid pagination parameters",
+	"недопустимые параметры фильтрации":                                "invalid filter parameters",
+	"недействительный курсор":                                          "invalid cursor",
+	"недопустимая роль":                                                "invalid role",
+	"пользователь успешно обновлен":                                    "user updated",
+	"пользователь успешно удален":                                      "user deleted",
+	"пользователь успешно восстановлен":                                "user restored",
+	"пользователь удален безвозвратно":                                 "user permanently deleted",
+	"ошибка при создании пользователя":                                 "failed to create user",
+	"ошибка при получении пользователя":                                "failed to get user",
+	"ошибка при обновлении пользователя":                               "failed to update user",
+	"ошибка при удалении пользователя":                                 "failed to delete user",
+	"ошибка при восстановлении пользователя":                           "failed to restore user",
+	"ошибка при получении списка пользователей":                        "failed to list users",
+	"ошибка при получении ролей пользователя":                          "failed to get user roles",
+	"ошибка при изменении ролей пользователя":                          "failed to change user roles",
+	"поддерживаются application/json, text/csv и application/x-ndjson": "supported formats are application/json, text/csv and application/x-ndjson",
+	"ошибка при выгрузке пользователей":                                "failed to export users",
+
+	// Проверка полей пользователя.
+	"укажите имя":                       "name is required",
+	"имя длиннее %d символов":           "name is longer than %d characters",
+	"имя содержит недопустимые символы": "name contains invalid characters",
+	"укажите email":                     "email is required",
+	"некорректный email":                "invalid email",
+	"имя ящика в email длиннее %d байт": "email local part is longer than %d bytes",
+	"некорректный домен email":          "invalid email domain",
+	"email длиннее %d символов":         "email is longer than %d characters",
+
+	// Импорт.
+	"ожидается text/csv или application/x-ndjson":      "text/csv or application/x-ndjson expected",
+	"слишком большой файл импорта":                     "import file is too large",
+	"слишком много строк для импорта":                  "too many rows to import",
+	"недопустимый режим импорта":                       "invalid import mode",
+	"импорт отклонен из-за ошибок в данных":            "import rejected due to invalid data",
+	"ошибка при импорте пользователей":                 "failed to import users",
+	"некорректный файл импорта":                        "malformed import file",
+	"пустой CSV файл":                                  "empty CSV file",
+	"некорректный заголовок CSV":                       "malformed CSV header",
+	"в заголовке CSV должны быть колонки name и email": "CSV header must contain name and email columns",
+	"недостаточно колонок":                             "not enough columns",
+	"некорректный JSON":                                "malformed JSON",
+	"email повторяется в строке %d":                    "email duplicates line %d",
+
+	// Журнал аудита.
+	"недопустимый временной интервал":     "invalid time range",
+	"ошибка при получении журнала аудита": "failed to get audit log",
+
+	// Пароли.
+	"пароль должен содержать от 8 до 256 символов":           "password must be 8 to 256 characters long",
+	"текущий пароль указан неверно":                          "current password is incorrect",
+	"ошибка при входе":                                       "failed to log in",
+	"ошибка при обновлении токенов":                          "failed to refresh tokens",
+	"ошибка при выходе":                                      "failed to log out",
+	"ошибка при сохранении пароля":                           "failed to save password",
+	"недействительная или просроченная ссылка сброса пароля": "invalid or expired password reset link",
+	"токен сброса пароля не найден":                          "password reset token not found",
+	"ошибка при сбросе пароля":                               "failed to reset password",
+	"ошибка при отправке письма":                             "failed to send email",
+
+	// Подтверждение email.
+	"недействительная или просроченная ссылка подтверждения email": "invalid or expired email verification link",
+	"email уже подтвержден":          "email is already verified",
+	"ошибка при подтверждении email": "failed to verify email",
+
+	// API-ключи и сессии.
+	"API-ключ не найден": "API key not found",
+	"название ключа должно быть непустым и не длиннее 255 символов": "key name must be non-empty and at most 255 characters long",
+	"ошибка при создании API-ключа":                                 "failed to create API key",
+	"ошибка при получении API-ключей":                               "failed to list API keys",
+	"ошибка при ротации API-ключа":                                  "failed to rotate API key",
+	"ошибка при отзыве API-ключа":                                   "failed to revoke API key",
+	"сессия не найдена":                                             "session not found",
+	"ошибка при получении сессий":                                   "failed to list sessions",
+	"ошибка при отзыве сессии":                                      "failed to revoke session",
+	"ошибка при отзыве сессий":                                      "failed to revoke sessions",
+	"токен не найден":                                               "token not found",
+
+	// Второй фактор.
+	"требуется подтверждение вторым фактором":                 "second factor confirmation required",
+	"неверный код второго фактора":                            "invalid second factor code",
+	"недействительный или просроченный токен второго фактора": "invalid or expired second factor token",
+	"второй фактор уже подключен":                             "second factor is already enabled",
+	"второй фактор не настроен":                               "second factor is not configured",
+	"второй фактор обязателен для роли пользователя":          "second factor is required for the user's role",
+	"укажите code или recovery_code":                          "code or recovery_code is required",
+	"код восстановления не найден":                            "recovery code not found",
+	"ошибка при получении настроек второго фактора":           "failed to get second factor settings",
+	"ошибка при настройке второго фактора":                    "failed to set up second factor",
+	"ошибка при подключении второго фактора":                  "failed to enable second factor",
+	"ошибка при выпуске кодов восстановления":                 "failed to issue recovery codes",
+	"ошибка при отключении второго фактора":                   "failed to disable second factor",
+	"ошибка при сбросе второго фактора":                       "failed to reset second factor",
+	"ошибка при получении политики второго фактора":           "failed to get second factor policy",
+	"ошибка при изменении политики второго фактора":           "failed to change second factor policy",
+
+	// Блокировки входа.
+	"ошибка при получении блокировки входа": "failed to get login lock",
+	"ошибка при снятии блокировки входа":    "failed to remove login lock",
+	"ошибка при получении блокировок входа": "failed to list login locks",
+
+	// OpenID Connect.
+	"клиент OIDC не найден":                                  "OIDC client not found",
+	"некорректные данные клиента OIDC":                       "invalid OIDC client data",
+	"код авторизации не найден":                              "authorization code not found",
+	"ошибка при регистрации клиента":                         "failed to register client",
+	"ошибка при получении клиентов":                          "failed to list clients",
+	"ошибка при получении клиента":                           "failed to get client",
+	"ошибка при удалении клиента":                            "failed to delete client",
+	"ошибка при получении ключей подписи":                    "failed to get signing keys",
+	"ошибка при ротации ключа подписи":                       "failed to rotate signing key",
+	"ошибка при авторизации":                                 "authorization failed",
+	"ошибка при получении данных пользователя":               "failed to get user info",
+	"некорректные параметры":                                 "invalid parameters",
+	"требуется access-токен":                                 "access token required",
+	"не указан token":                                        "token is missing",
+	"страница входа не настроена":                            "login page is not configured",
+	"не указан grant_type":                                   "grant_type is missing",
+	"поддерживаются authorization_code и refresh_token":      "authorization_code and refresh_token are supported",
+	"access-токен недействителен":                            "access token is invalid",
+	"интроспекция доступна только конфиденциальным клиентам": "introspection is available to confidential clients only",
+	"код авторизации недействителен":                         "authorization code is invalid",
+	"код авторизации выдан другому клиенту":                  "authorization code was issued to another client",
+	"срок действия кода авторизации истек":                   "authorization code has expired",
+	"redirect_uri не совпадает с указанным при авторизации":  "redirect_uri does not match the one used for authorization",
+	"code_verifier не соответствует code_challenge":          "code_verifier does not match code_challenge",
+	"код авторизации уже использован":                        "authorization code has already been used",
+	"refresh-токен недействителен":                           "refresh token is invalid",
+	"не указаны client_id или redirect_uri":                  "client_id or redirect_uri is missing",
+	"клиент не зарегистрирован":                              "client is not registered",
+	"redirect_uri не зарегистрирован для клиента":            "redirect_uri is not registered for the client",
+	"не указан client_id":                                    "client_id is missing",
+	"неверные учетные данные клиента":                        "invalid client credentials",
+	"у публичного клиента нет секрета":                       "public clients have no secret",
+	"поддерживается только response_type=code":               "only response_type=code is supported",
+	"scope должен содержать openid":                          "scope must contain openid",
+	"неподдерживаемый scope %s":                              "unsupported scope %s",
+	"требуется PKCE с code_challenge_method=S256":            "PKCE with code_challenge_method=S256 is required",
+	"некорректный code_challenge":                            "invalid code_challenge",
+
+	// Вход через внешних провайдеров.
+	"провайдер входа не найден":                          "login provider not found",
+	"вход через провайдера устарел или уже завершен":     "provider login has expired or has already completed",
+	"провайдер не подтвердил вход":                       "provider did not confirm the login",
+	"провайдер не подтвердил email":                      "provider did not confirm the email",
+	"пользователь с таким email не найден":               "no user with this email",
+	"внешняя учетная запись не найдена":                  "external account not found",
+	"внешняя учетная запись уже связана с пользователем": "external account is already linked to a user",
+	"вход через провайдера не найден":                    "provider login not found",
+	"ошибка при входе через провайдера":                  "failed to log in via provider",
+	"ошибка при получении внешних учетных записей":       "failed to list external accounts",
+	"ошибка при отвязке внешней учетной записи":          "failed to unlink external account",
+}
@@ -0,0 +1,97 @@
+// Package i18n переводит сообщения API на язык клиента. Ключ сообщения — его русский текст, поэтому
+// для русского языка каталог не нужен, а сообщения без перевода отдаются по-русски. Коды ошибок
+// не переводятся.
+package i18n
+
+import (
+	"context"
+	"fmt"
+	"golang.org/x/text/language"
+	"strings"
+)
+
+var (
+	Russian = language.Russian
+	English = language.English
+)
+
+// Supported перечисляет языки, на которые переведены сообщения.
+var Supported = []language.Tag{Russian, English}
+
+var catalogs = map[language.Tag]map[string]string{
+	English: english,
+}
+
+type languageKey struct{}
+
+func WithLanguage(ctx context.Context, tag language.Tag) context.Context {
+	return context.WithValue(ctx, languageKey{}, tag)
+}
+
+// Language возвращает язык запроса; без выбранного языка сообщения отдаются по-русски.
+func Language(ctx context.Context) language.Tag {
+	if tag, ok := ctx.Value(languageKey{}).(language.Tag); ok {
+		return tag
+	}
+	return Russian
+}
+
+// Parse возвращает поддерживаемый язык по его коду, например "en" или "ru".
+func Parse(code string) (language.Tag, error) {
+	tag, err := language.Parse(strings.TrimSpace(code))
+	if err != nil {
+		return language.Und, fmt.Errorf("некорректный код языка %q: %w", code, err)
+	}
+	base, _ := tag.Base()
+	for _, supported := range Supported {
+		if supportedBase, _ := supported.Base(); supportedBase == base {
+			return supported, nil
+		}
+	}
+	return language.Und, fmt.Errorf("язык %q не поддерживается", code)
+}
+
+// Matcher выбирает язык ответа по заголовку Accept-Language.
+type Matcher struct {
+	tags    []language.Tag
+	matcher language.Matcher
+}
+
+// NewMatcher создает Matcher, который выбирает fallback, если клиент не принимает ни один из
+// поддерживаемых языков.
+func NewMatcher(fallback language.Tag) *Matcher {
+	tags := []language.Tag{fallback}
+	for _, tag := range Supported {
+		if tag != fallback {
+			tags = append(tags, tag)
+		}
+	}
+	return &Matcher{tags: tags, matcher: language.NewMatcher(tags)}
+}
+
+func (m *Matcher) Match(acceptLanguage string) language.Tag {
+	accepted, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(accepted) == 0 {
+		return m.tags[0]
+	}
+	_, index, confidence := m.matcher.Match(accepted...)
+	if confidence == language.No {
+		return m.tags[0]
+	}
+	return m.tags[index]
+}
+
+// T переводит сообщение на язык запроса. С аргументами сообщение служит форматом для fmt.Sprintf.
+func T(ctx context.Context, message string, args ...any) string {
+	return Translate(Language(ctx), message, args...)
+}
+
+func Translate(tag language.Tag, message string, args ...any) string {
+	if translated, ok := catalogs[tag][message]; ok {
+		message = translated
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(message, args...)
+	}
+	return message
+}
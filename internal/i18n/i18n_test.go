@@ -0,0 +1,126 @@
+package i18n
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestT(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, "пользователь не найден", T(ctx, "пользователь не найден"))
+	assert.Equal(t, "email повторяется в строке 2", T(ctx, "email повторяется в строке %d", 2))
+
+	ctx = WithLanguage(ctx, English)
+	assert.Equal(t, "user not found", T(ctx, "пользователь не найден"))
+	assert.Equal(t, "email duplicates line 2", T(ctx, "email повторяется в строке %d", 2))
+	// Сообщения без перевода отдаются как есть, без разбора как формата.
+	assert.Equal(t, "100% не переведено", T(ctx, "100% не переведено"))
+}
+
+func TestParse(t *testing.T) {
+	tag, err := Parse("en-GB")
+	assert.NoError(t, err)
+	assert.Equal(t, English, tag)
+
+	tag, err = Parse(" ru ")
+	assert.NoError(t, err)
+	assert.Equal(t, Russian, tag)
+
+	_, err = Parse("de")
+	assert.Error(t, err)
+	_, err = Parse("not a language")
+	assert.Error(t, err)
+}
+
+func TestMatcher_Fallback(t *testing.T) {
+	matcher := NewMatcher(English)
+	assert.Equal(t, English, matcher.Match(""))
+	assert.Equal(t, English, matcher.Match("fr-FR"))
+	assert.Equal(t, Russian, matcher.Match("ru;q=0.9, fr"))
+}
+
+// messageArguments — функции, которые принимают текст ошибки для клиента, и номер этого аргумента.
+// Вызовы New внутри пакетов apperr и problem записаны без имени пакета.
+var messageArguments = map[string]int{
+	"apperr.New":   2,
+	"apperr/New":   2,
+	"problem.New":  3,
+	"problem/New":  3,
+	"oauthError":   1,
+	"unauthorized": 1,
+	"invalid":      1,
+	"rejectRow":    1,
+	"writeError":   2,
+	"i18n.T":       1,
+}
+
+// messageFields — поля структур (FieldError, OAuthError, строка импорта) с текстом для клиента.
+var messageFields = map[string]bool{"Message": true, "Description": true, "ParseError": true}
+
+// TestEnglishCatalogComplete ищет в исходниках сообщения для клиента и проверяет, что у каждого
+// есть английский перевод: иначе ответ на английском незаметно вернулся бы по-русски.
+func TestEnglishCatalogComplete(t *testing.T) {
+	cyrillic := regexp.MustCompile(`\p{Cyrillic}`)
+	fset := token.NewFileSet()
+	var missing []string
+	check := func(expr ast.Expr) {
+		literal, ok := expr.(*ast.BasicLit)
+		if !ok || literal.Kind != token.STRING {
+			return
+		}
+		message, err := strconv.Unquote(literal.Value)
+		if err != nil || !cyrillic.MatchString(message) {
+			return
+		}
+		if _, ok := english[message]; !ok {
+			missing = append(missing, fset.Position(literal.Pos()).String()+": "+message)
+		}
+	}
+
+	err := filepath.WalkDir("..", func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return err
+		}
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return err
+		}
+		ast.Inspect(file, func(node ast.Node) bool {
+			switch node := node.(type) {
+			case *ast.CallExpr:
+				var name string
+				switch fun := node.Fun.(type) {
+				case *ast.Ident:
+					name = fun.Name
+					if name == "New" {
+						name = file.Name.Name + "/New"
+					}
+				case *ast.SelectorExpr:
+					if pkg, ok := fun.X.(*ast.Ident); ok {
+						name = pkg.Name + "." + fun.Sel.Name
+					}
+				}
+				if index, ok := messageArguments[name]; ok && index < len(node.Args) {
+					check(node.Args[index])
+				}
+			case *ast.KeyValueExpr:
+				if key, ok := node.Key.(*ast.Ident); ok && messageFields[key.Name] {
+					check(node.Value)
+				}
+			}
+			return true
+		})
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, missing, "нет английского перевода")
+}
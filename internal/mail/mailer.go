@@ -0,0 +1,62 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testovoe/internal/config"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer отправляет письма пользователям. Реализации: SMTPMailer для рабочего окружения,
+// FileMailer и LogMailer для локальной разработки, CaptureMailer для тестов. Queue отправляет
+// письма любой реализации в фоне.
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// NewMailer выбирает реализацию по MAIL_DRIVER: smtp, file или log.
+func NewMailer(cfg *config.Config) (Mailer, error) {
+	switch cfg.MailDriver {
+	case "smtp":
+		if cfg.SMTPHost == "" {
+			return nil, fmt.Errorf("для MAIL_DRIVER=smtp нужно задать SMTP_HOST")
+		}
+		return NewSMTPMailer(SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.MailFrom,
+			Timeout:  cfg.SMTPTimeout,
+		}), nil
+	case "file":
+		if cfg.MailDir == "" {
+			return nil, fmt.Errorf("для MAIL_DRIVER=file нужно задать MAIL_DIR")
+		}
+		return NewFileMailer(cfg.MailDir, cfg.MailFrom), nil
+	case "log":
+		return NewLogMailer(cfg.MailFrom), nil
+	}
+	return nil, fmt.Errorf("неизвестный MAIL_DRIVER %q", cfg.MailDriver)
+}
+
+// format собирает письмо в формате RFC 5322 с телом в UTF-8.
+func format(from string, message Message, now time.Time) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", message.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", encodeHeader(message.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
@@ -0,0 +1,148 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testovoe/internal/background"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	data := string(format("no-reply@example.com", Message{To: "ivan@example.com", Subject: "Подтверждение", Body: "строка 1\nстрока 2"},
+		time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)))
+
+	assert.Contains(t, data, "To: ivan@example.com\r\n")
+	assert.Contains(t, data, "Subject: =?utf-8?q?")
+	assert.True(t, strings.HasSuffix(data, "\r\n\r\nстрока 1\r\nстрока 2"))
+}
+
+func TestASCIIAddress(t *testing.T) {
+	address, err := asciiAddress("ivan@пример.рф")
+	assert.NoError(t, err)
+	assert.Equal(t, "ivan@xn--e1afmkfd.xn--p1ai", address)
+
+	address, err = asciiAddress("ivan@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "ivan@example.com", address)
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	mailer := NewFileMailer(dir, "no-reply@example.com")
+
+	assert.NoError(t, mailer.Send(context.Background(), Message{To: "ivan@example.com", Subject: "s", Body: "b"}))
+	assert.Error(t, mailer.Send(context.Background(), Message{To: "ivan@example.com\r\nBcc: x@example.com"}))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	data, err := os.ReadFile(files[0])
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "To: ivan@example.com")
+}
+
+func TestCaptureMailer(t *testing.T) {
+	mailer := NewCaptureMailer()
+	assert.NoError(t, mailer.Send(context.Background(), Message{To: "a@example.com", Body: "1"}))
+	assert.NoError(t, mailer.Send(context.Background(), Message{To: "a@example.com", Body: "2"}))
+
+	last, ok := mailer.Last("a@example.com")
+	assert.True(t, ok)
+	assert.Equal(t, "2", last.Body)
+	_, ok = mailer.Last("b@example.com")
+	assert.False(t, ok)
+	assert.Len(t, mailer.Messages(), 2)
+}
+
+// flakyMailer отказывает failures раз, а затем передает письма в CaptureMailer.
+type flakyMailer struct {
+	*CaptureMailer
+	mu       sync.Mutex
+	failures int
+	attempts int
+}
+
+func (m *flakyMailer) Send(ctx context.Context, message Message) error {
+	m.mu.Lock()
+	m.attempts++
+	fail := m.attempts <= m.failures
+	m.mu.Unlock()
+	if fail {
+		return errors.New("сервер недоступен")
+	}
+	return m.CaptureMailer.Send(ctx, message)
+}
+
+func TestQueue_Retries(t *testing.T) {
+	mailer := &flakyMailer{CaptureMailer: NewCaptureMailer(), failures: 2}
+	queue := NewQueue(mailer, QueueConfig{Size: 10, MaxAttempts: 3, Backoff: time.Millisecond})
+
+	assert.NoError(t, queue.Send(context.Background(), Message{To: "ivan@example.com", Body: "1"}))
+	assert.Error(t, queue.Send(context.Background(), Message{To: "ivan@example.com\r\nBcc: x@example.com"}))
+	assert.NoError(t, queue.Close(context.Background()))
+
+	assert.Equal(t, 3, mailer.attempts)
+	assert.Len(t, mailer.Messages(), 1)
+	assert.ErrorIs(t, queue.Send(context.Background(), Message{To: "ivan@example.com"}), background.ErrClosed)
+}
+
+func TestQueue_GivesUp(t *testing.T) {
+	mailer := &flakyMailer{CaptureMailer: NewCaptureMailer(), failures: 10}
+	queue := NewQueue(mailer, QueueConfig{MaxAttempts: 2, Backoff: time.Millisecond})
+
+	assert.NoError(t, queue.Send(context.Background(), Message{To: "ivan@example.com"}))
+	assert.NoError(t, queue.Close(context.Background()))
+	assert.Equal(t, 2, mailer.attempts)
+	assert.Empty(t, mailer.Messages())
+}
+
+// blockingMailer не возвращается, пока не отменят ctx.
+type blockingMailer struct{}
+
+func (blockingMailer) Send(ctx context.Context, message Message) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestQueue_Full(t *testing.T) {
+	queue := NewQueue(blockingMailer{}, QueueConfig{Size: 1, Workers: 1, MaxAttempts: 1})
+
+	// Первое письмо забирает отправитель, второе занимает очередь, третьему места нет.
+	assert.NoError(t, queue.Send(context.Background(), Message{To: "a@example.com"}))
+	assert.Eventually(t, func() bool { return queue.pool.Pending() == 0 }, time.Second, time.Millisecond)
+	assert.NoError(t, queue.Send(context.Background(), Message{To: "b@example.com"}))
+	assert.ErrorIs(t, queue.Send(context.Background(), Message{To: "c@example.com"}), background.ErrFull)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, queue.Close(ctx), context.DeadlineExceeded)
+}
+
+func TestSMTPMailer_Timeout(t *testing.T) {
+	// Сервер принимает соединение, но не отвечает.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			defer conn.Close()
+			time.Sleep(time.Second)
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	mailer := NewSMTPMailer(SMTPConfig{Host: host, Port: port, From: "no-reply@example.com", Timeout: 50 * time.Millisecond})
+
+	started := time.Now()
+	err = mailer.Send(context.Background(), Message{To: "ivan@example.com"})
+	assert.Error(t, err)
+	assert.Less(t, time.Since(started), 500*time.Millisecond)
+}
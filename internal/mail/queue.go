@@ -0,0 +1,68 @@
+package mail
+
+import (
+	"context"
+	"log/slog"
+	"testovoe/internal/background"
+	"time"
+)
+
+// QueueConfig задает размер очереди, число отправителей и повторы. Пауза перед повтором начинается
+// с Backoff и удваивается после каждой неудачной попытки.
+type QueueConfig struct {
+	Size        int
+	Workers     int
+	MaxAttempts int
+	Backoff     time.Duration
+}
+
+// Queue отправляет письма в фоне, чтобы медленный или недоступный почтовый сервер не задерживал
+// запросы. Send только проверяет адрес и ставит письмо в очередь; ошибки отправки записываются в журнал.
+// Переполненная очередь возвращает background.ErrFull, закрытая — background.ErrClosed.
+type Queue struct {
+	mailer Mailer
+	cfg    QueueConfig
+	pool   *background.Pool
+}
+
+func NewQueue(mailer Mailer, cfg QueueConfig) *Queue {
+	cfg.MaxAttempts = max(cfg.MaxAttempts, 1)
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = time.Second
+	}
+	return &Queue{mailer: mailer, cfg: cfg, pool: background.NewPool(cfg.Size, cfg.Workers)}
+}
+
+func (q *Queue) Send(ctx context.Context, message Message) error {
+	if err := validateAddress(message.To); err != nil {
+		return err
+	}
+	return q.pool.Go(ctx, func(ctx context.Context) { q.deliver(ctx, message) })
+}
+
+// Close перестает принимать письма и ждет отправки уже поставленных в очередь. Если ctx
+// завершится раньше, повторы прекращаются, а неотправленные письма теряются.
+func (q *Queue) Close(ctx context.Context) error {
+	return q.pool.Close(ctx)
+}
+
+func (q *Queue) deliver(ctx context.Context, message Message) {
+	backoff := q.cfg.Backoff
+	for attempt := 1; ; attempt++ {
+		err := q.mailer.Send(ctx, message)
+		if err == nil {
+			return
+		}
+		if attempt == q.cfg.MaxAttempts || ctx.Err() != nil {
+			slog.Error("письмо не отправлено", "to", message.To, "subject", message.Subject, "attempts", attempt, "error", err)
+			return
+		}
+		slog.Warn("ошибка при отправке письма, повторим", "to", message.To, "attempt", attempt, "retry_in", backoff, "error", err)
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+		}
+	}
+}
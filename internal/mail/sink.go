@@ -0,0 +1,99 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileMailer сохраняет каждое письмо в отдельный .eml файл в каталоге dir.
+type FileMailer struct {
+	dir  string
+	from string
+
+	mu  sync.Mutex
+	seq int
+}
+
+func NewFileMailer(dir, from string) *FileMailer {
+	return &FileMailer{dir: dir, from: from}
+}
+
+func (m *FileMailer) Send(ctx context.Context, message Message) error {
+	if err := validateAddress(message.To); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return fmt.Errorf("ошибка при создании каталога писем: %w", err)
+	}
+
+	now := time.Now()
+	m.mu.Lock()
+	m.seq++
+	name := fmt.Sprintf("%s-%04d.eml", now.UTC().Format("20060102T150405.000000000"), m.seq)
+	m.mu.Unlock()
+
+	if err := os.WriteFile(filepath.Join(m.dir, name), format(m.from, message, now), 0o600); err != nil {
+		return fmt.Errorf("ошибка при сохранении письма: %w", err)
+	}
+	return nil
+}
+
+// LogMailer выводит письма в журнал приложения.
+type LogMailer struct {
+	from string
+}
+
+func NewLogMailer(from string) *LogMailer {
+	return &LogMailer{from: from}
+}
+
+func (m *LogMailer) Send(ctx context.Context, message Message) error {
+	if err := validateAddress(message.To); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "письмо", "to", message.To, "from", m.from, "subject", message.Subject, "body", message.Body)
+	return nil
+}
+
+// CaptureMailer запоминает отправленные письма, чтобы тесты могли их проверить.
+type CaptureMailer struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewCaptureMailer() *CaptureMailer {
+	return &CaptureMailer{}
+}
+
+func (m *CaptureMailer) Send(ctx context.Context, message Message) error {
+	if err := validateAddress(message.To); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+func (m *CaptureMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.messages...)
+}
+
+// Last возвращает последнее письмо, отправленное на адрес to.
+func (m *CaptureMailer) Last(to string) (Message, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].To == to {
+			return m.messages[i], true
+		}
+	}
+	return Message{}, false
+}
@@ -0,0 +1,143 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"golang.org/x/net/idna"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	// Timeout ограничивает всю отправку письма: соединение, TLS, авторизацию и передачу.
+	Timeout time.Duration
+}
+
+// DefaultSMTPTimeout используется, если SMTPConfig.Timeout не задан.
+const DefaultSMTPTimeout = 30 * time.Second
+
+// SMTPMailer отправляет письма через SMTP-сервер; если сервер поддерживает STARTTLS,
+// соединение шифруется. Отправка прерывается по отмене ctx или по истечении Timeout.
+type SMTPMailer struct {
+	cfg SMTPConfig
+}
+
+func NewSMTPMailer(cfg SMTPConfig) *SMTPMailer {
+	return &SMTPMailer{cfg: cfg}
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, message Message) error {
+	if err := validateAddress(message.To); err != nil {
+		return err
+	}
+	to, err := asciiAddress(message.To)
+	if err != nil {
+		return err
+	}
+	message.To = to
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	timeout := m.cfg.Timeout
+	if timeout <= 0 {
+		timeout = DefaultSMTPTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := m.send(ctx, message); err != nil {
+		return fmt.Errorf("ошибка при отправке письма: %w", err)
+	}
+	return nil
+}
+
+func (m *SMTPMailer) send(ctx context.Context, message Message) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(m.cfg.Host, m.cfg.Port))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Срок ctx распространяется на все чтения и записи, а отмена ctx прерывает их сразу.
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Unix(1, 0)) })
+	defer stop()
+
+	client, err := smtp.NewClient(conn, m.cfg.Host)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.cfg.Host}); err != nil {
+			return err
+		}
+	}
+	if m.cfg.Username != "" {
+		if ok, _ := client.Extension("AUTH"); ok {
+			if err := client.Auth(smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := client.Mail(m.cfg.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(message.To); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(format(m.cfg.From, message, time.Now())); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	// Письмо уже принято сервером: ошибка при завершении сеанса не повод отправлять его заново.
+	_ = client.Quit()
+	return nil
+}
+
+// validateAddress не дает внедрить дополнительные заголовки или получателей через адрес.
+func validateAddress(address string) error {
+	if address == "" || strings.ContainsAny(address, "\r\n,") {
+		return fmt.Errorf("некорректный адрес получателя %q", address)
+	}
+	return nil
+}
+
+// asciiAddress переводит домен адреса в ASCII-форму IDN (A-label). Адреса хранятся с доменом
+// в Unicode, а сервер без расширения SMTPUTF8 не примет такой домен ни в RCPT TO, ни в заголовке To.
+func asciiAddress(address string) (string, error) {
+	at := strings.LastIndexByte(address, '@')
+	if at < 0 {
+		return address, nil
+	}
+	host, err := idna.Lookup.ToASCII(address[at+1:])
+	if err != nil {
+		return "", fmt.Errorf("некорректный домен получателя %q: %w", address, err)
+	}
+	return address[:at+1] + host, nil
+}
+
+func encodeHeader(value string) string {
+	return mime.QEncoding.Encode("utf-8", value)
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"testovoe/internal/problem"
+)
+
+const AdminTokenHeader = "X-Admin-Token"
+
+func RequireAdminToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provided := c.GetHeader(AdminTokenHeader)
+		if token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			problem.Abort(c, problem.New(c, http.StatusForbidden, "forbidden", "доступ запрещен"))
+			return
+		}
+		c.Next()
+	}
+}
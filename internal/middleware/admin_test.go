@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func setupAdminRouter(token string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.DELETE("/admin", RequireAdminToken(token), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	return r
+}
+
+func TestRequireAdminToken(t *testing.T) {
+	router := setupAdminRouter("secret")
+
+	req, _ := http.NewRequest("DELETE", "/admin", nil)
+	req.Header.Set(AdminTokenHeader, "secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	req, _ = http.NewRequest("DELETE", "/admin", nil)
+	req.Header.Set(AdminTokenHeader, "wrong")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestRequireAdminToken_Disabled(t *testing.T) {
+	router := setupAdminRouter("")
+
+	req, _ := http.NewRequest("DELETE", "/admin", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+	"testovoe/internal/auth"
+	"testovoe/internal/problem"
+	"testovoe/internal/reqctx"
+)
+
+// Authenticate требует учетные данные в заголовке Authorization для всех маршрутов,
+// кроме перечисленных в public в виде "METHOD /путь" (шаблон маршрута gin, например "GET /users/:id").
+func Authenticate(authenticators []auth.Authenticator, public ...string) gin.HandlerFunc {
+	publicRoutes := make(map[string]bool, len(public))
+	for _, route := range public {
+		publicRoutes[route] = true
+	}
+
+	schemes := make([]string, len(authenticators))
+	for i, authenticator := range authenticators {
+		schemes[i] = authenticator.Scheme()
+	}
+	challenge := strings.Join(schemes, ", ")
+
+	return func(c *gin.Context) {
+		if c.FullPath() == "" || publicRoutes[c.Request.Method+" "+c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		unauthorized := func(code, message string) {
+			c.Header("WWW-Authenticate", challenge)
+			problem.Abort(c, problem.New(c, http.StatusUnauthorized, code, message))
+		}
+
+		scheme, credentials, found := strings.Cut(c.GetHeader("Authorization"), " ")
+		credentials = strings.TrimSpace(credentials)
+		if !found || credentials == "" {
+			unauthorized("authentication_required", "требуется аутентификация")
+			return
+		}
+
+		for _, authenticator := range authenticators {
+			if !strings.EqualFold(authenticator.Scheme(), scheme) {
+				continue
+			}
+			principal, err := authenticator.Authenticate(c.Request.Context(), credentials)
+			if err != nil {
+				if errors.Is(err, auth.ErrInvalidCredentials) {
+					unauthorized("invalid_credentials", "неверные учетные данные")
+					return
+				}
+				problem.Abort(c, problem.New(c, http.StatusInternalServerError, problem.CodeInternal, "ошибка при проверке учетных данных"))
+				return
+			}
+
+			ctx := auth.WithPrincipal(c.Request.Context(), principal)
+			ctx = reqctx.WithActor(ctx, principal.Actor())
+			c.Request = c.Request.WithContext(ctx)
+			c.Next()
+			return
+		}
+		unauthorized("unsupported_auth_scheme", "неподдерживаемая схема аутентификации")
+	}
+}
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testovoe/internal/auth"
+	"testovoe/internal/reqctx"
+)
+
+type staticAuthenticator struct {
+	scheme string
+	tokens map[string]string
+}
+
+func (a staticAuthenticator) Scheme() string {
+	return a.scheme
+}
+
+func (a staticAuthenticator) Authenticate(ctx context.Context, credentials string) (*auth.Principal, error) {
+	if credentials == "broken" {
+		return nil, errors.New("хранилище недоступно")
+	}
+	subject, ok := a.tokens[credentials]
+	if !ok {
+		return nil, auth.ErrInvalidCredentials
+	}
+	return auth.NewPrincipal(subject, auth.MethodJWT), nil
+}
+
+func setupAuthRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	authenticator := staticAuthenticator{scheme: auth.BearerScheme, tokens: map[string]string{"good": "7"}}
+	r.Use(Authenticate([]auth.Authenticator{authenticator}, "GET /public"))
+	r.GET("/private", func(c *gin.Context) {
+		principal, _ := auth.PrincipalFromContext(c.Request.Context())
+		c.JSON(http.StatusOK, gin.H{"user_id": principal.UserID, "actor": reqctx.Actor(c.Request.Context())})
+	})
+	r.GET("/public", func(c *gin.Context) {
+		_, ok := auth.PrincipalFromContext(c.Request.Context())
+		c.JSON(http.StatusOK, gin.H{"authenticated": ok})
+	})
+	return r
+}
+
+func TestAuthenticate(t *testing.T) {
+	router := setupAuthRouter()
+
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		code          int
+		body          string
+	}{
+		{"valid token", "/private", "Bearer good", http.StatusOK, `{"actor":"user:7","user_id":7}`},
+		{"case insensitive scheme", "/private", "bearer good", http.StatusOK, `{"actor":"user:7","user_id":7}`},
+		{"missing header", "/private", "", http.StatusUnauthorized, ""},
+		{"invalid token", "/private", "Bearer bad", http.StatusUnauthorized, ""},
+		{"unknown scheme", "/private", "Basic good", http.StatusUnauthorized, ""},
+		{"authenticator failure", "/private", "Bearer broken", http.StatusInternalServerError, ""},
+		{"public route", "/public", "", http.StatusOK, `{"authenticated":false}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.code, w.Code)
+			if tt.body != "" {
+				assert.JSONEq(t, tt.body, w.Body.String())
+			}
+			if tt.code == http.StatusUnauthorized {
+				assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"testovoe/internal/domain"
+	"testovoe/internal/problem"
+	"time"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+)
+
+const maxIdempotencyKeyLength = 255
+
+// maxIdempotentBodySize ограничивает тело, которое читается целиком ради отпечатка запроса.
+// Оно не меньше, чем принимает импорт пользователей.
+const maxIdempotentBodySize = 64 << 20
+
+// idempotencyPruneInterval — как часто удаляются истекшие ключи.
+const idempotencyPruneInterval = time.Minute
+
+// replayedHeaders — заголовки ответа, которые сохраняются и повторяются вместе с телом. Content-Language
+// повторяется, потому что сохраненное тело написано на языке первого запроса, а не повторного.
+var replayedHeaders = []string{"ETag", "Location", "Retry-After", "Content-Language"}
+
+type IdempotencyStore interface {
+	ReserveIdempotencyKey(ctx context.Context, key *domain.IdempotencyKey, lockTimeout time.Duration) (*domain.IdempotencyKey, bool, error)
+	CompleteIdempotencyKey(ctx context.Context, key *domain.IdempotencyKey) error
+	ReleaseIdempotencyKey(ctx context.Context, key *domain.IdempotencyKey) error
+	PruneIdempotencyKeys(ctx context.Context, before time.Time) error
+}
+
+// IdempotencyConfig задает, сколько хранятся ответы (TTL) и через сколько ключ, запрос с которым
+// так и не завершился, например из-за остановки экземпляра, может занять повторный запрос (LockTimeout).
+type IdempotencyConfig struct {
+	TTL         time.Duration
+	LockTimeout time.Duration
+}
+
+// Idempotency сохраняет ответы на запросы с заголовком Idempotency-Key к маршрутам из routes
+// ("METHOD /путь", как в Authenticate) и повторяет их на повторные запросы с тем же ключом в течение TTL.
+// Ключ принадлежит клиенту: у разных клиентов одинаковые ключи не пересекаются. Ответы 5xx
+// не сохраняются, чтобы запрос можно было повторить.
+func Idempotency(store IdempotencyStore, cfg IdempotencyConfig, routes ...string) gin.HandlerFunc {
+	idempotentRoutes := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		idempotentRoutes[route] = true
+	}
+	pruner := &idempotencyPruner{store: store}
+
+	return func(c *gin.Context) {
+		value := c.GetHeader(IdempotencyKeyHeader)
+		if value == "" || !idempotentRoutes[c.Request.Method+" "+c.FullPath()] {
+			c.Next()
+			return
+		}
+		if len(value) > maxIdempotencyKeyLength {
+			problem.Abort(c, problem.New(c, http.StatusBadRequest, "idempotency_key_too_long", "слишком длинный Idempotency-Key"))
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIdempotentBodySize))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				problem.Abort(c, problem.New(c, http.StatusRequestEntityTooLarge, "request_too_large", "слишком большой запрос"))
+				return
+			}
+			problem.Abort(c, problem.New(c, http.StatusBadRequest, "invalid_body", "некорректные данные"))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := c.Request.Context()
+		pruner.prune(ctx)
+		key := &domain.IdempotencyKey{
+			Client:      clientKey(c),
+			Key:         value,
+			Fingerprint: requestFingerprint(c.Request, body),
+			ExpiresAt:   time.Now().Add(cfg.TTL),
+		}
+		stored, reserved, err := store.ReserveIdempotencyKey(ctx, key, cfg.LockTimeout)
+		if err != nil {
+			slog.ErrorContext(ctx, "ошибка при проверке Idempotency-Key", "error", err)
+			problem.Abort(c, problem.New(c, http.StatusInternalServerError, problem.CodeInternal, "ошибка при обработке Idempotency-Key"))
+			return
+		}
+		if !reserved {
+			switch {
+			case subtle.ConstantTimeCompare(stored.Fingerprint, key.Fingerprint) != 1:
+				problem.Abort(c, problem.New(c, http.StatusUnprocessableEntity, "idempotency_key_reused", "Idempotency-Key уже использован с другим запросом"))
+			case !stored.Completed():
+				c.Header("Retry-After", "1")
+				problem.Abort(c, problem.New(c, http.StatusConflict, "idempotency_key_in_progress", "запрос с этим Idempotency-Key еще выполняется"))
+			default:
+				for name, value := range stored.Headers {
+					c.Header(name, value)
+				}
+				c.Header(IdempotencyReplayedHeader, "true")
+				c.Data(stored.Status, stored.ContentType, stored.Body)
+				c.Abort()
+			}
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		defer func() {
+			recovered := recover()
+			if recovered != nil || recorder.Status() >= http.StatusInternalServerError {
+				if err := store.ReleaseIdempotencyKey(context.WithoutCancel(ctx), key); err != nil {
+					slog.ErrorContext(ctx, "ошибка при освобождении Idempotency-Key", "error", err)
+				}
+				if recovered != nil {
+					panic(recovered)
+				}
+				return
+			}
+			key.Status = recorder.Status()
+			key.ContentType = recorder.Header().Get("Content-Type")
+			key.Headers = make(map[string]string)
+			for _, name := range replayedHeaders {
+				if value := recorder.Header().Get(name); value != "" {
+					key.Headers[name] = value
+				}
+			}
+			key.Body = recorder.body.Bytes()
+			if err := store.CompleteIdempotencyKey(context.WithoutCancel(ctx), key); err != nil {
+				slog.ErrorContext(ctx, "ошибка при сохранении ответа для Idempotency-Key", "error", err)
+			}
+		}()
+		c.Next()
+	}
+}
+
+// requestFingerprint отличает запросы с одним ключом по методу, пути с параметрами и телу.
+func requestFingerprint(r *http.Request, body []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n" + strconv.Itoa(len(body)) + "\n"))
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+// responseRecorder копирует тело ответа, чтобы сохранить его для повторных запросов.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// idempotencyPruner удаляет истекшие ключи не чаще раза в idempotencyPruneInterval, чтобы не нагружать
+// таблицу удалением при каждом запросе. Ошибка удаления не мешает обработке запроса.
+type idempotencyPruner struct {
+	store IdempotencyStore
+
+	mu        sync.Mutex
+	lastPrune time.Time
+}
+
+func (p *idempotencyPruner) prune(ctx context.Context) {
+	now := time.Now()
+	p.mu.Lock()
+	if now.Sub(p.lastPrune) < idempotencyPruneInterval {
+		p.mu.Unlock()
+		return
+	}
+	p.lastPrune = now
+	p.mu.Unlock()
+	if err := p.store.PruneIdempotencyKeys(ctx, now); err != nil {
+		slog.WarnContext(ctx, "ошибка при удалении устаревших ключей идемпотентности", "error", err)
+	}
+}
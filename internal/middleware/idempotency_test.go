@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"testovoe/internal/domain"
+	"testovoe/internal/i18n"
+	"time"
+)
+
+type memoryIdempotencyStore struct {
+	keys   map[[2]string]domain.IdempotencyKey
+	prunes int
+}
+
+func (s *memoryIdempotencyStore) ReserveIdempotencyKey(ctx context.Context, key *domain.IdempotencyKey, lockTimeout time.Duration) (*domain.IdempotencyKey, bool, error) {
+	existing, ok := s.keys[[2]string{key.Client, key.Key}]
+	stale := ok && !existing.Completed() && bytes.Equal(existing.Fingerprint, key.Fingerprint) && time.Since(existing.LockedAt) > lockTimeout
+	if ok && !stale && existing.ExpiresAt.After(time.Now()) {
+		return &existing, false, nil
+	}
+	key.LockedAt = time.Now()
+	s.keys[[2]string{key.Client, key.Key}] = *key
+	return key, true, nil
+}
+
+func (s *memoryIdempotencyStore) CompleteIdempotencyKey(ctx context.Context, key *domain.IdempotencyKey) error {
+	if s.keys[[2]string{key.Client, key.Key}].LockedAt.Equal(key.LockedAt) {
+		s.keys[[2]string{key.Client, key.Key}] = *key
+	}
+	return nil
+}
+
+func (s *memoryIdempotencyStore) ReleaseIdempotencyKey(ctx context.Context, key *domain.IdempotencyKey) error {
+	if existing := s.keys[[2]string{key.Client, key.Key}]; existing.LockedAt.Equal(key.LockedAt) && !existing.Completed() {
+		delete(s.keys, [2]string{key.Client, key.Key})
+	}
+	return nil
+}
+
+func (s *memoryIdempotencyStore) PruneIdempotencyKeys(ctx context.Context, before time.Time) error {
+	s.prunes++
+	for id, key := range s.keys {
+		if key.ExpiresAt.Before(before) {
+			delete(s.keys, id)
+		}
+	}
+	return nil
+}
+
+func setupIdempotencyRouter(store IdempotencyStore) (*gin.Engine, *int) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Locale(i18n.NewMatcher(i18n.Russian)))
+	r.Use(Idempotency(store, IdempotencyConfig{TTL: time.Hour, LockTimeout: time.Minute}, "POST /users", "POST /fail"))
+	calls := 0
+	r.POST("/users", func(c *gin.Context) {
+		calls++
+		c.Header("Location", "/users/"+strconv.Itoa(calls))
+		c.Header("ETag", `W/"1"`)
+		c.JSON(http.StatusCreated, gin.H{"id": calls})
+	})
+	r.POST("/fail", func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "ошибка"})
+	})
+	return r, &calls
+}
+
+func postWithKey(router *gin.Engine, path, key, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(IdempotencyKeyHeader, key)
+	req.RemoteAddr = "192.0.2.1:1234"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotency_Replay(t *testing.T) {
+	store := &memoryIdempotencyStore{keys: make(map[[2]string]domain.IdempotencyKey)}
+	router, calls := setupIdempotencyRouter(store)
+
+	w := postWithKey(router, "/users", "key-1", `{"name":"Иван"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"id":1}`, w.Body.String())
+
+	w = postWithKey(router, "/users", "key-1", `{"name":"Иван"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"id":1}`, w.Body.String())
+	assert.Equal(t, "true", w.Header().Get(IdempotencyReplayedHeader))
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "/users/1", w.Header().Get("Location"))
+	assert.Equal(t, `W/"1"`, w.Header().Get("ETag"))
+	assert.Equal(t, 1, *calls)
+
+	w = postWithKey(router, "/users", "key-1", `{"name":"Петр"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w = postWithKey(router, "/users", "key-2", `{"name":"Иван"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, 2, *calls)
+}
+
+func TestIdempotency_ReplaysLanguage(t *testing.T) {
+	store := &memoryIdempotencyStore{keys: make(map[[2]string]domain.IdempotencyKey)}
+	router, calls := setupIdempotencyRouter(store)
+	post := func(acceptLanguage string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/users", bytes.NewBufferString(`{}`))
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
+		req.Header.Set("Accept-Language", acceptLanguage)
+		req.RemoteAddr = "192.0.2.1:1234"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, "en", post("en").Header().Get("Content-Language"))
+	// Сохраненный ответ повторяется на языке первого запроса, и заголовок говорит об этом.
+	w := post("ru")
+	assert.Equal(t, "true", w.Header().Get(IdempotencyReplayedHeader))
+	assert.Equal(t, "en", w.Header().Get("Content-Language"))
+	assert.Equal(t, 1, *calls)
+}
+
+func TestIdempotency_InProgressAndServerErrors(t *testing.T) {
+	store := &memoryIdempotencyStore{keys: make(map[[2]string]domain.IdempotencyKey)}
+	router, calls := setupIdempotencyRouter(store)
+
+	// Ответ 5xx не сохраняется: повтор снова доходит до обработчика.
+	postWithKey(router, "/fail", "key-1", `{}`)
+	w := postWithKey(router, "/fail", "key-1", `{}`)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, 2, *calls)
+
+	// Первый запрос с ключом еще не завершился.
+	store.keys[[2]string{"ip:192.0.2.1", "key-2"}] = domain.IdempotencyKey{
+		Client: "ip:192.0.2.1", Key: "key-2", Fingerprint: requestFingerprint(httptest.NewRequest("POST", "/users", nil), []byte(`{}`)),
+		LockedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour),
+	}
+	w = postWithKey(router, "/users", "key-2", `{}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, 2, *calls)
+
+	// Запрос, который так и не завершился, уступает ключ повтору.
+	stale := store.keys[[2]string{"ip:192.0.2.1", "key-2"}]
+	stale.LockedAt = time.Now().Add(-2 * time.Minute)
+	store.keys[[2]string{"ip:192.0.2.1", "key-2"}] = stale
+	w = postWithKey(router, "/users", "key-2", `{}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, 3, *calls)
+}
+
+func TestIdempotency_ExpiredKeys(t *testing.T) {
+	store := &memoryIdempotencyStore{keys: make(map[[2]string]domain.IdempotencyKey)}
+	router, calls := setupIdempotencyRouter(store)
+
+	postWithKey(router, "/users", "key-1", `{"name":"Иван"}`)
+	postWithKey(router, "/users", "key-2", `{"name":"Иван"}`)
+	assert.Equal(t, 1, store.prunes, "очистка выполняется не чаще раза в минуту")
+
+	// Истекший ключ не воспроизводится, даже если его еще не удалили.
+	expired := store.keys[[2]string{"ip:192.0.2.1", "key-1"}]
+	expired.ExpiresAt = time.Now().Add(-time.Second)
+	store.keys[[2]string{"ip:192.0.2.1", "key-1"}] = expired
+	w := postWithKey(router, "/users", "key-1", `{"name":"Петр"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Empty(t, w.Header().Get(IdempotencyReplayedHeader))
+	assert.Equal(t, 3, *calls)
+}
+
+func TestIdempotency_BodyTooLarge(t *testing.T) {
+	store := &memoryIdempotencyStore{keys: make(map[[2]string]domain.IdempotencyKey)}
+	router, calls := setupIdempotencyRouter(store)
+
+	w := postWithKey(router, "/users", "key-1", strings.Repeat("x", maxIdempotentBodySize+1))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Equal(t, 0, *calls)
+	assert.Empty(t, store.keys)
+}
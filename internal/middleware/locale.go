@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"testovoe/internal/i18n"
+)
+
+// Locale выбирает язык ответа по заголовку Accept-Language и сохраняет его в контексте запроса.
+func Locale(languages *i18n.Matcher) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tag := languages.Match(c.GetHeader("Accept-Language"))
+		c.Header("Content-Language", tag.String())
+		c.Writer.Header().Add("Vary", "Accept-Language")
+		c.Request = c.Request.WithContext(i18n.WithLanguage(c.Request.Context(), tag))
+		c.Next()
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testovoe/internal/i18n"
+	"testovoe/internal/problem"
+)
+
+func TestLocale(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Locale(i18n.NewMatcher(i18n.Russian)))
+	r.GET("/users/:id", func(c *gin.Context) {
+		problem.Write(c, problem.New(c, http.StatusNotFound, "user_not_found", "пользователь не найден"))
+	})
+
+	tests := []struct {
+		acceptLanguage string
+		language       string
+		detail         string
+	}{
+		{"", "ru", "пользователь не найден"},
+		{"en-US,en;q=0.9", "en", "user not found"},
+		{"de-DE, en;q=0.5", "en", "user not found"},
+		{"ru-RU, en;q=0.8", "ru", "пользователь не найден"},
+		{"fr", "ru", "пользователь не найден"},
+		{"not a language", "ru", "пользователь не найден"},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", "/users/1", nil)
+		req.Header.Set("Accept-Language", tt.acceptLanguage)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, tt.language, w.Header().Get("Content-Language"), tt.acceptLanguage)
+		assert.Contains(t, w.Body.String(), `"detail":"`+tt.detail+`"`, tt.acceptLanguage)
+		assert.Contains(t, w.Body.String(), `"code":"user_not_found"`)
+	}
+}
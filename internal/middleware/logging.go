@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"testovoe/internal/problem"
+	"testovoe/internal/reqctx"
+	"time"
+)
+
+// Logger записывает каждый запрос в журнал slog: ответы 5xx с уровнем Error, остальные — Info.
+// Ставится после RequestID, чтобы в записи был идентификатор запроса.
+func Logger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+		c.Next()
+
+		level := slog.LevelInfo
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", path),
+			slog.Int("status", c.Writer.Status()),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if requestID := reqctx.RequestID(c.Request.Context()); requestID != "" {
+			attrs = append(attrs, slog.String("request_id", requestID))
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.String()))
+		}
+		slog.LogAttrs(c.Request.Context(), level, "запрос обработан", attrs...)
+	}
+}
+
+// Recovery перехватывает панику в обработчике, записывает ее в журнал со стеком и отвечает 500,
+// если ответ еще не начат. http.ErrAbortHandler пробрасывается дальше: им обработчик сам прерывает ответ.
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if err, ok := recovered.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(recovered)
+			}
+			slog.ErrorContext(c.Request.Context(), "паника при обработке запроса",
+				"panic", recovered, "method", c.Request.Method, "path", c.Request.URL.Path,
+				"request_id", reqctx.RequestID(c.Request.Context()), "stack", string(debug.Stack()))
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			problem.Abort(c, problem.New(c, http.StatusInternalServerError, problem.CodeInternal, "внутренняя ошибка сервера"))
+		}()
+		c.Next()
+	}
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func captureLog(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]any
+		assert.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestLoggerAndRecovery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestID(), Logger(), Recovery())
+	r.GET("/ok", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	r.GET("/panic", func(c *gin.Context) { panic("сбой") })
+
+	buf := captureLog(t)
+	req, _ := http.NewRequest("GET", "/ok", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	records := logRecords(t, buf)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "INFO", records[0]["level"])
+		assert.Equal(t, "/ok", records[0]["path"])
+		assert.EqualValues(t, http.StatusNoContent, records[0]["status"])
+		assert.Equal(t, "req-1", records[0]["request_id"])
+	}
+
+	// Паника превращается в 500 с телом problem+json, а в журнал попадают стек и запрос с уровнем Error.
+	buf.Reset()
+	req, _ = http.NewRequest("GET", "/panic", nil)
+	req.Header.Set(RequestIDHeader, "req-2")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"internal_error"`)
+	assert.Contains(t, w.Body.String(), `"request_id":"req-2"`)
+	records = logRecords(t, buf)
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, "ERROR", records[0]["level"])
+		assert.Equal(t, "сбой", records[0]["panic"])
+		assert.Contains(t, records[0]["stack"], "runtime/debug.Stack")
+		assert.Equal(t, "req-2", records[0]["request_id"])
+		assert.Equal(t, "ERROR", records[1]["level"])
+		assert.EqualValues(t, http.StatusInternalServerError, records[1]["status"])
+	}
+}
+
+func TestRecovery_AbortHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Recovery())
+	r.GET("/", func(c *gin.Context) { panic(http.ErrAbortHandler) })
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() { r.ServeHTTP(httptest.NewRecorder(), req) })
+}
@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"testovoe/internal/auth"
+	"testovoe/internal/problem"
+	"testovoe/internal/ratelimit"
+	"time"
+)
+
+// rateLimitReserved — ключ контекста gin, под которым RateLimit запоминает бакет, из которого
+// расходован запрос с непроверенными учетными данными.
+const rateLimitReserved = "rate_limit_reserved"
+
+// RateLimit ограничивает частоту запросов до аутентификации, поэтому квоту расходуют и запросы
+// с неверными учетными данными. Запросы без учетных данных считаются по адресу клиента. Запросы
+// с учетными данными до проверки расходуют отдельный бакет адреса; если аутентификация прошла,
+// RateLimitAuthenticated возвращает запрос в этот бакет и считает его по API-ключу или пользователю.
+// Так клиент с верными учетными данными не делит квоту с соседями по адресу, а подбор учетных данных
+// ограничен квотой адреса. Если хранилище квот недоступно, запрос пропускается: сбой лимитера
+// не должен останавливать API.
+func RateLimit(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.FullPath() == "" {
+			c.Next()
+			return
+		}
+		client := "ip:" + c.ClientIP()
+		if c.GetHeader("Authorization") != "" {
+			client = "credentials_ip:" + c.ClientIP()
+			c.Set(rateLimitReserved, client)
+		}
+		if allowRequest(c, limiter, client) {
+			c.Next()
+		}
+	}
+}
+
+// RateLimitAuthenticated ставится после Authenticate и считает аутентифицированные запросы
+// по API-ключу или пользователю.
+func RateLimitAuthenticated(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := auth.PrincipalFromContext(c.Request.Context())
+		if c.FullPath() == "" || !ok {
+			c.Next()
+			return
+		}
+		if reserved := c.GetString(rateLimitReserved); reserved != "" {
+			if err := limiter.Refund(c.Request.Context(), routeKey(c), reserved); err != nil {
+				slog.WarnContext(c.Request.Context(), "ошибка при возврате запроса в квоту", "error", err)
+			}
+		}
+		if allowRequest(c, limiter, principalKey(principal)) {
+			c.Next()
+		}
+	}
+}
+
+// allowRequest расходует запрос клиента и выставляет заголовки квоты. Если квота исчерпана,
+// запрос прерывается с 429 и возвращается false.
+func allowRequest(c *gin.Context, limiter *ratelimit.Limiter, client string) bool {
+	result, limit, err := limiter.Allow(c.Request.Context(), routeKey(c), client)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "ошибка при проверке ограничения частоты запросов, запрос пропущен", "error", err)
+		return true
+	}
+	if result == nil {
+		return true
+	}
+
+	c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+	c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Header("RateLimit-Reset", strconv.FormatInt(ceilSeconds(result.Reset), 10))
+	c.Header("RateLimit-Policy", strconv.Itoa(limit.Requests)+";w="+strconv.FormatInt(ceilSeconds(limit.Period), 10))
+	if !result.Allowed {
+		c.Header("Retry-After", strconv.FormatInt(max(ceilSeconds(result.RetryAfter), 1), 10))
+		problem.Abort(c, problem.New(c, http.StatusTooManyRequests, "rate_limited", "слишком много запросов, попробуйте позже"))
+		return false
+	}
+	return true
+}
+
+func routeKey(c *gin.Context) string {
+	return c.Request.Method + " " + c.FullPath()
+}
+
+// clientKey определяет клиента по API-ключу, пользователю или, без аутентификации, по адресу.
+func clientKey(c *gin.Context) string {
+	if principal, ok := auth.PrincipalFromContext(c.Request.Context()); ok {
+		return principalKey(principal)
+	}
+	return "ip:" + c.ClientIP()
+}
+
+func principalKey(principal *auth.Principal) string {
+	if principal.Method == auth.MethodAPIKey && principal.APIKeyID != 0 {
+		return "api_key:" + strconv.FormatInt(principal.APIKeyID, 10)
+	}
+	return "user:" + principal.Subject
+}
+
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
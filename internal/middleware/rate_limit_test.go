@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testovoe/internal/auth"
+	"testovoe/internal/domain"
+	"testovoe/internal/ratelimit"
+	"time"
+)
+
+func setupRateLimitRouter(limiter *ratelimit.Limiter) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RateLimit(limiter))
+	r.Use(func(c *gin.Context) {
+		switch c.GetHeader("Authorization") {
+		case "":
+		case "Test valid":
+			principal := auth.NewPrincipal("7", auth.MethodAPIKey)
+			principal.APIKeyID = 3
+			c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), principal))
+		default:
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	})
+	r.Use(RateLimitAuthenticated(limiter))
+	r.GET("/users", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return r
+}
+
+func TestRateLimit(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), domain.RateLimit{Requests: 1, Period: time.Minute}, nil)
+	router := setupRateLimitRouter(limiter)
+
+	req, _ := http.NewRequest("GET", "/users", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "60", w.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, "1;w=60", w.Header().Get("RateLimit-Policy"))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	// Клиент с API-ключом получает собственную квоту, даже с того же адреса.
+	req.Header.Set("Authorization", "Test valid")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+}
+
+func TestRateLimit_InvalidCredentials(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), domain.RateLimit{Requests: 1, Period: time.Minute}, nil)
+	router := setupRateLimitRouter(limiter)
+	serve := func(authorization string) int {
+		req, _ := http.NewRequest("GET", "/users", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("Authorization", authorization)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Запрос с верными учетными данными возвращается в квоту адреса, неверные ее расходуют.
+	assert.Equal(t, http.StatusOK, serve("Test valid"))
+	assert.Equal(t, http.StatusUnauthorized, serve("Test guess-1"))
+	assert.Equal(t, http.StatusTooManyRequests, serve("Test guess-2"))
+}
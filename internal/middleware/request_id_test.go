@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testovoe/internal/reqctx"
+)
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestID())
+	r.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, reqctx.RequestID(c.Request.Context()))
+	})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set(RequestIDHeader, "req-42")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, "req-42", w.Body.String())
+	assert.Equal(t, "req-42", w.Header().Get(RequestIDHeader))
+
+	req, _ = http.NewRequest("GET", "/", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Len(t, w.Body.String(), 32)
+	assert.Equal(t, w.Body.String(), w.Header().Get(RequestIDHeader))
+}
@@ -0,0 +1,38 @@
+package pagination
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type testCursor struct {
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+}
+
+func TestCursorCodec_RoundTrip(t *testing.T) {
+	codec := NewCursorCodec([]byte("secret"))
+
+	token, err := codec.Encode(testCursor{Value: "Bob", ID: 42})
+	assert.NoError(t, err)
+
+	var decoded testCursor
+	err = codec.Decode(token, &decoded)
+	assert.NoError(t, err)
+	assert.Equal(t, testCursor{Value: "Bob", ID: 42}, decoded)
+}
+
+func TestCursorCodec_Tampered(t *testing.T) {
+	codec := NewCursorCodec([]byte("secret"))
+
+	token, err := codec.Encode(testCursor{Value: "Bob", ID: 42})
+	assert.NoError(t, err)
+
+	forged, err := NewCursorCodec([]byte("other")).Encode(testCursor{Value: "Bob", ID: 1})
+	assert.NoError(t, err)
+
+	var decoded testCursor
+	assert.Equal(t, ErrInvalidCursor, codec.Decode(forged, &decoded))
+	assert.Equal(t, ErrInvalidCursor, codec.Decode(token[:len(token)-2], &decoded))
+	assert.Equal(t, ErrInvalidCursor, codec.Decode("garbage", &decoded))
+}
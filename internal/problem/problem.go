@@ -0,0 +1,134 @@
+// Package problem отдает ошибки API в формате application/problem+json (RFC 7807).
+package problem
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"testovoe/internal/apperr"
+	"testovoe/internal/i18n"
+	"testovoe/internal/reqctx"
+)
+
+const ContentType = "application/problem+json"
+
+// TypePrefix — префикс поля type. Полный тип — префикс и стабильный код ошибки.
+const TypePrefix = "urn:testovoe:problem:"
+
+const CodeInternal = "internal_error"
+
+// Problem — тело ответа об ошибке. Extensions добавляются в тело как дополнительные поля.
+type Problem struct {
+	Type       string              `json:"type"`
+	Title      string              `json:"title"`
+	Status     int                 `json:"status"`
+	Detail     string              `json:"detail,omitempty"`
+	Instance   string              `json:"instance,omitempty"`
+	Code       string              `json:"code"`
+	RequestID  string              `json:"request_id,omitempty"`
+	Errors     []apperr.FieldError `json:"errors,omitempty"`
+	Extensions map[string]any      `json:"-"`
+}
+
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	type plain Problem
+	body, err := json.Marshal((*plain)(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return body, err
+	}
+	fields := make(map[string]any, len(p.Extensions)+8)
+	for key, value := range p.Extensions {
+		fields[key] = value
+	}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+// With добавляет к ответу дополнительное поле.
+func (p *Problem) With(key string, value any) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = make(map[string]any)
+	}
+	p.Extensions[key] = value
+	return p
+}
+
+// WithStatus меняет статус ответа, когда он зависит от места, где произошла ошибка.
+func (p *Problem) WithStatus(status int) *Problem {
+	p.Status = status
+	p.Title = http.StatusText(status)
+	return p
+}
+
+// New строит ответ с кодом code. detail переводится на язык запроса.
+func New(c *gin.Context, status int, code, detail string) *Problem {
+	return &Problem{
+		Type:      TypePrefix + code,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    i18n.T(c.Request.Context(), detail),
+		Instance:  c.Request.URL.Path,
+		Code:      code,
+		RequestID: reqctx.RequestID(c.Request.Context()),
+	}
+}
+
+// FromError строит ответ по ошибке каталога. Остальные ошибки считаются внутренними: клиент
+// получает 500 с текстом fallback, а подробности не раскрываются.
+func FromError(c *gin.Context, err error, fallback string) *Problem {
+	appErr, ok := apperr.As(err)
+	if !ok {
+		return New(c, http.StatusInternalServerError, CodeInternal, fallback)
+	}
+	p := New(c, Status(appErr.Kind), appErr.Code, appErr.Message)
+	for _, field := range appErr.Fields {
+		field.Message = i18n.T(c.Request.Context(), field.Message, field.Args...)
+		p.Errors = append(p.Errors, field)
+	}
+	return p
+}
+
+// Status возвращает HTTP-статус для вида ошибки.
+func Status(kind apperr.Kind) int {
+	switch kind {
+	case apperr.KindInvalid:
+		return http.StatusBadRequest
+	case apperr.KindValidation:
+		return http.StatusUnprocessableEntity
+	case apperr.KindUnauthorized:
+		return http.StatusUnauthorized
+	case apperr.KindForbidden:
+		return http.StatusForbidden
+	case apperr.KindNotFound:
+		return http.StatusNotFound
+	case apperr.KindConflict:
+		return http.StatusConflict
+	case apperr.KindPreconditionFailed:
+		return http.StatusPreconditionFailed
+	case apperr.KindPreconditionRequired:
+		return http.StatusPreconditionRequired
+	case apperr.KindTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case apperr.KindTooManyRequests:
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func Write(c *gin.Context, p *Problem) {
+	body, err := json.Marshal(p)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.Data(p.Status, ContentType, body)
+}
+
+// Abort отвечает ошибкой и прерывает цепочку обработчиков.
+func Abort(c *gin.Context, p *Problem) {
+	Write(c, p)
+	c.Abort()
+}
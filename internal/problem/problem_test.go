@@ -0,0 +1,66 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testovoe/internal/apperr"
+	"testovoe/internal/reqctx"
+)
+
+func serve(t *testing.T, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/users/:id", func(c *gin.Context) {
+		c.Request = c.Request.WithContext(reqctx.WithRequestID(c.Request.Context(), "req-1"))
+		handler(c)
+	})
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/users/7", nil)
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestFromError_CatalogError(t *testing.T) {
+	invalid := apperr.ErrValidation.WithFields(apperr.FieldError{Field: "email", Code: "required", Message: "укажите email"})
+	w := serve(t, func(c *gin.Context) {
+		Write(c, FromError(c, fmt.Errorf("сервис: %w", invalid), "ошибка"))
+	})
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"type":"urn:testovoe:problem:validation_failed","title":"Unprocessable Entity","status":422,
+		"detail":"данные не прошли проверку","instance":"/users/7","code":"validation_failed","request_id":"req-1",
+		"errors":[{"field":"email","code":"required","message":"укажите email"}]}`, w.Body.String())
+}
+
+func TestFromError_HidesInternalErrors(t *testing.T) {
+	w := serve(t, func(c *gin.Context) {
+		Write(c, FromError(c, errors.New("pq: connection refused"), "ошибка при получении пользователя"))
+	})
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	var p Problem
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	assert.Equal(t, CodeInternal, p.Code)
+	assert.Equal(t, "ошибка при получении пользователя", p.Detail)
+}
+
+func TestProblem_Extensions(t *testing.T) {
+	w := serve(t, func(c *gin.Context) {
+		Write(c, New(c, http.StatusTooManyRequests, "login_locked", "попробуйте позже").With("retry_after", 30).WithStatus(http.StatusServiceUnavailable))
+	})
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	var body map[string]any
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, float64(30), body["retry_after"])
+	assert.Equal(t, "Service Unavailable", body["title"])
+	assert.Equal(t, "login_locked", body["code"])
+}
@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"testovoe/internal/domain"
+	"time"
+)
+
+// Store хранит бакеты. Take расходует один запрос из бакета key, если он там есть, Refund
+// возвращает в бакет израсходованный запрос. Prune удаляет бакеты, которые не менялись с before:
+// к этому времени они уже полны.
+type Store interface {
+	Take(ctx context.Context, key string, limit domain.RateLimit) (*domain.RateLimitResult, error)
+	Refund(ctx context.Context, key string, limit domain.RateLimit) error
+	Prune(ctx context.Context, before time.Time) error
+}
+
+const pruneInterval = time.Minute
+
+// Limiter выбирает квоту для маршрута. Маршруты без собственной квоты делят между собой
+// общий бакет клиента с квотой по умолчанию.
+type Limiter struct {
+	store    Store
+	fallback domain.RateLimit
+	routes   map[string]domain.RateLimit
+	idle     time.Duration
+	now      func() time.Time
+
+	mu        sync.Mutex
+	lastPrune time.Time
+}
+
+func NewLimiter(store Store, fallback domain.RateLimit, routes map[string]domain.RateLimit) *Limiter {
+	idle := fallback.Period
+	for _, limit := range routes {
+		if limit.Period > idle {
+			idle = limit.Period
+		}
+	}
+	return &Limiter{store: store, fallback: fallback, routes: routes, idle: idle, now: time.Now}
+}
+
+// Allow расходует запрос клиента client на маршруте route ("METHOD /путь" в виде шаблона gin).
+// Для маршрутов без ограничения возвращает nil.
+func (l *Limiter) Allow(ctx context.Context, route, client string) (*domain.RateLimitResult, *domain.RateLimit, error) {
+	limit, key := l.bucket(route, client)
+	if !limit.Enabled() {
+		return nil, nil, nil
+	}
+
+	if err := l.prune(ctx); err != nil {
+		return nil, nil, err
+	}
+	result, err := l.store.Take(ctx, key, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result, &limit, nil
+}
+
+// Refund возвращает запрос, израсходованный Allow с теми же route и client.
+func (l *Limiter) Refund(ctx context.Context, route, client string) error {
+	limit, key := l.bucket(route, client)
+	if !limit.Enabled() {
+		return nil
+	}
+	return l.store.Refund(ctx, key, limit)
+}
+
+func (l *Limiter) bucket(route, client string) (domain.RateLimit, string) {
+	if limit, ok := l.routes[route]; ok {
+		return limit, client + " " + route
+	}
+	return l.fallback, client
+}
+
+func (l *Limiter) prune(ctx context.Context) error {
+	now := l.now()
+	l.mu.Lock()
+	if now.Sub(l.lastPrune) < pruneInterval {
+		l.mu.Unlock()
+		return nil
+	}
+	l.lastPrune = now
+	l.mu.Unlock()
+	return l.store.Prune(ctx, now.Add(-l.idle))
+}
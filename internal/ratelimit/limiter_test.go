@@ -0,0 +1,94 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testovoe/internal/domain"
+	"time"
+)
+
+func TestMemoryStore_TokenBucket(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	limit := domain.RateLimit{Requests: 3, Period: 3 * time.Second}
+
+	for remaining := 2; remaining >= 0; remaining-- {
+		result, err := store.Take(context.Background(), "ip:10.0.0.1", limit)
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, remaining, result.Remaining)
+	}
+
+	result, err := store.Take(context.Background(), "ip:10.0.0.1", limit)
+	assert.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, time.Second, result.RetryAfter)
+	assert.Equal(t, 3*time.Second, result.Reset)
+
+	// Другой клиент расходует свой бакет.
+	result, err = store.Take(context.Background(), "ip:10.0.0.2", limit)
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	now = now.Add(1500 * time.Millisecond)
+	result, err = store.Take(context.Background(), "ip:10.0.0.1", limit)
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+
+	// Возвращенный запрос снова доступен, но бакет не переполняется.
+	assert.NoError(t, store.Refund(context.Background(), "ip:10.0.0.1", limit))
+	assert.NoError(t, store.Refund(context.Background(), "ip:10.0.0.2", limit))
+	assert.Equal(t, 1.5, store.buckets["ip:10.0.0.1"].tokens)
+	assert.Equal(t, 3.0, store.buckets["ip:10.0.0.2"].tokens)
+
+	assert.NoError(t, store.Prune(context.Background(), now))
+	assert.Len(t, store.buckets, 1)
+}
+
+func TestLimiter_RouteLimits(t *testing.T) {
+	store := NewMemoryStore()
+	limiter := NewLimiter(store, domain.RateLimit{Requests: 2, Period: time.Minute}, map[string]domain.RateLimit{
+		"POST /auth/login":           {Requests: 1, Period: time.Minute},
+		"GET /.well-known/jwks.json": {},
+	})
+	ctx := context.Background()
+
+	result, limit, err := limiter.Allow(ctx, "POST /auth/login", "ip:10.0.0.1")
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 1, limit.Requests)
+	result, _, err = limiter.Allow(ctx, "POST /auth/login", "ip:10.0.0.1")
+	assert.NoError(t, err)
+	assert.False(t, result.Allowed)
+
+	// Маршруты без своей квоты делят общий бакет клиента, отдельный от бакета входа.
+	result, _, _ = limiter.Allow(ctx, "GET /users/", "ip:10.0.0.1")
+	assert.True(t, result.Allowed)
+	result, _, _ = limiter.Allow(ctx, "GET /users/:id", "ip:10.0.0.1")
+	assert.True(t, result.Allowed)
+	result, _, _ = limiter.Allow(ctx, "GET /users/", "ip:10.0.0.1")
+	assert.False(t, result.Allowed)
+
+	result, limit, err = limiter.Allow(ctx, "GET /.well-known/jwks.json", "ip:10.0.0.1")
+	assert.NoError(t, err)
+	assert.Nil(t, result)
+	assert.Nil(t, limit)
+}
+
+func TestParseRoutes(t *testing.T) {
+	routes, err := ParseRoutes(" post  /auth/login=10/1m; GET /users/:id = 5/1h ;GET /.well-known/jwks.json=off;")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]domain.RateLimit{
+		"POST /auth/login":           {Requests: 10, Period: time.Minute},
+		"GET /users/:id":             {Requests: 5, Period: time.Hour},
+		"GET /.well-known/jwks.json": {},
+	}, routes)
+
+	for _, value := range []string{"POST /auth/login", "/auth/login=10/1m", "POST /auth/login=10", "POST /auth/login=0/1m", "POST /auth/login=10/0s"} {
+		_, err := ParseRoutes(value)
+		assert.Error(t, err, value)
+	}
+}
@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testovoe/internal/domain"
+	"time"
+)
+
+// ParseLimit разбирает квоту вида "100/1m" (100 запросов в минуту). "off" или пустая строка — без ограничения.
+func ParseLimit(value string) (domain.RateLimit, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "off" {
+		return domain.RateLimit{}, nil
+	}
+	requests, period, ok := strings.Cut(value, "/")
+	if !ok {
+		return domain.RateLimit{}, fmt.Errorf("квота %q должна иметь вид запросы/период", value)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(requests))
+	if err != nil || n <= 0 {
+		return domain.RateLimit{}, fmt.Errorf("некорректное число запросов в квоте %q", value)
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(period))
+	if err != nil || d <= 0 {
+		return domain.RateLimit{}, fmt.Errorf("некорректный период в квоте %q", value)
+	}
+	return domain.RateLimit{Requests: n, Period: d}, nil
+}
+
+// ParseRoutes разбирает квоты маршрутов, разделенные ";":
+// "POST /auth/login=10/1m; GET /users/export=5/1h; GET /.well-known/jwks.json=off".
+func ParseRoutes(value string) (map[string]domain.RateLimit, error) {
+	routes := make(map[string]domain.RateLimit)
+	for _, rule := range strings.Split(value, ";") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		index := strings.LastIndex(rule, "=")
+		if index < 0 {
+			return nil, fmt.Errorf("правило %q должно иметь вид \"METHOD /путь=квота\"", rule)
+		}
+		fields := strings.Fields(rule[:index])
+		if len(fields) != 2 || !strings.HasPrefix(fields[1], "/") {
+			return nil, fmt.Errorf("некорректный маршрут в правиле %q", rule)
+		}
+		limit, err := ParseLimit(rule[index+1:])
+		if err != nil {
+			return nil, err
+		}
+		routes[strings.ToUpper(fields[0])+" "+fields[1]] = limit
+	}
+	return routes, nil
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"testovoe/internal/apperr"
+	"testovoe/internal/domain"
+)
+
+var ErrAPIKeyNotFound = apperr.New(apperr.KindNotFound, "api_key_not_found", "API-ключ не найден")
+
+type APIKeyRepositoryInterface interface {
+	CreateAPIKey(ctx context.Context, key *domain.APIKey) error
+	GetAPIKeyByID(ctx context.Context, userID, id int64) (*domain.APIKey, error)
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error)
+	ListAPIKeysByUser(ctx context.Context, userID int64) ([]domain.APIKey, error)
+	RotateAPIKey(ctx context.Context, key *domain.APIKey) error
+	RevokeAPIKey(ctx context.Context, userID, id int64) (*domain.APIKey, error)
+	TouchAPIKey(ctx context.Context, id int64) error
+}
+
+type APIKeyRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewAPIKeyRepository(db *pgxpool.Pool) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+func (r *APIKeyRepository) conn(ctx context.Context) querier {
+	return conn(ctx, r.db)
+}
+
+const apiKeyColumns = "id, user_id, name, prefix, key_hash, created_at, rotated_at, last_used_at, revoked_at"
+
+func scanAPIKey(row pgx.Row) (*domain.APIKey, error) {
+	var key domain.APIKey
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.KeyHash,
+		&key.CreatedAt, &key.RotatedAt, &key.LastUsedAt, &key.RevokedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *APIKeyRepository) CreateAPIKey(ctx context.Context, key *domain.APIKey) error {
+	query := `INSERT INTO api_keys (user_id, name, prefix, key_hash) VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+	if err := r.conn(ctx).QueryRow(ctx, query, key.UserID, key.Name, key.Prefix, key.KeyHash).Scan(&key.ID, &key.CreatedAt); err != nil {
+		return fmt.Errorf("ошибка при создании API-ключа: %w", err)
+	}
+	return nil
+}
+
+func (r *APIKeyRepository) GetAPIKeyByID(ctx context.Context, userID, id int64) (*domain.APIKey, error) {
+	query := "SELECT " + apiKeyColumns + " FROM api_keys WHERE id = $1 AND user_id = $2"
+	key, err := scanAPIKey(r.conn(ctx).QueryRow(ctx, query, id, userID))
+	if err != nil && !errors.Is(err, ErrAPIKeyNotFound) {
+		return nil, fmt.Errorf("ошибка при получении API-ключа: %w", err)
+	}
+	return key, err
+}
+
+func (r *APIKeyRepository) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error) {
+	query := "SELECT " + apiKeyColumns + " FROM api_keys WHERE prefix = $1"
+	key, err := scanAPIKey(r.conn(ctx).QueryRow(ctx, query, prefix))
+	if err != nil && !errors.Is(err, ErrAPIKeyNotFound) {
+		return nil, fmt.Errorf("ошибка при получении API-ключа: %w", err)
+	}
+	return key, err
+}
+
+func (r *APIKeyRepository) ListAPIKeysByUser(ctx context.Context, userID int64) ([]domain.APIKey, error) {
+	query := "SELECT " + apiKeyColumns + " FROM api_keys WHERE user_id = $1 ORDER BY id"
+	rows, err := r.conn(ctx).Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении API-ключей: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make([]domain.APIKey, 0)
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при чтении API-ключа: %w", err)
+		}
+		keys = append(keys, *key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при получении API-ключей: %w", err)
+	}
+	return keys, nil
+}
+
+// RotateAPIKey заменяет префикс и хеш действующего ключа; старое значение ключа сразу перестает работать.
+func (r *APIKeyRepository) RotateAPIKey(ctx context.Context, key *domain.APIKey) error {
+	query := `UPDATE api_keys SET prefix = $1, key_hash = $2, rotated_at = NOW()
+		WHERE id = $3 AND user_id = $4 AND revoked_at IS NULL RETURNING rotated_at`
+	err := r.conn(ctx).QueryRow(ctx, query, key.Prefix, key.KeyHash, key.ID, key.UserID).Scan(&key.RotatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrAPIKeyNotFound
+		}
+		return fmt.Errorf("ошибка при ротации API-ключа: %w", err)
+	}
+	return nil
+}
+
+func (r *APIKeyRepository) RevokeAPIKey(ctx context.Context, userID, id int64) (*domain.APIKey, error) {
+	query := `UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+		RETURNING ` + apiKeyColumns
+	key, err := scanAPIKey(r.conn(ctx).QueryRow(ctx, query, id, userID))
+	if err != nil && !errors.Is(err, ErrAPIKeyNotFound) {
+		return nil, fmt.Errorf("ошибка при отзыве API-ключа: %w", err)
+	}
+	return key, err
+}
+
+// TouchAPIKey обновляет last_used_at не чаще раза в минуту, чтобы не писать в базу на каждый запрос.
+func (r *APIKeyRepository) TouchAPIKey(ctx context.Context, id int64) error {
+	query := `UPDATE api_keys SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')`
+	if _, err := r.conn(ctx).Exec(ctx, query, id); err != nil {
+		return fmt.Errorf("ошибка при обновлении API-ключа: %w", err)
+	}
+	return nil
+}
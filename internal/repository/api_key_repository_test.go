@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testovoe/internal/domain"
+)
+
+func TestAPIKeyRepository_Lifecycle(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	users := NewUserRepository(pool)
+	repo := NewAPIKeyRepository(pool)
+
+	user := &domain.User{Name: "Иван", Email: "ivan@example.com"}
+	assert.NoError(t, users.CreateUser(context.Background(), user))
+
+	key := &domain.APIKey{UserID: user.ID, Name: "ci", Prefix: "tvk_000000000001", KeyHash: []byte("hash-1")}
+	assert.NoError(t, repo.CreateAPIKey(context.Background(), key))
+	assert.NotZero(t, key.ID)
+
+	found, err := repo.GetAPIKeyByPrefix(context.Background(), "tvk_000000000001")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("hash-1"), found.KeyHash)
+	assert.Nil(t, found.LastUsedAt)
+
+	assert.NoError(t, repo.TouchAPIKey(context.Background(), key.ID))
+	found, err = repo.GetAPIKeyByID(context.Background(), user.ID, key.ID)
+	assert.NoError(t, err)
+	assert.NotNil(t, found.LastUsedAt)
+
+	key.Prefix, key.KeyHash = "tvk_000000000002", []byte("hash-2")
+	assert.NoError(t, repo.RotateAPIKey(context.Background(), key))
+	_, err = repo.GetAPIKeyByPrefix(context.Background(), "tvk_000000000001")
+	assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+
+	revoked, err := repo.RevokeAPIKey(context.Background(), user.ID, key.ID)
+	assert.NoError(t, err)
+	assert.NotNil(t, revoked.RevokedAt)
+
+	_, err = repo.RevokeAPIKey(context.Background(), user.ID, key.ID)
+	assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+
+	list, err := repo.ListAPIKeysByUser(context.Background(), user.ID)
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+
+	_, err = repo.GetAPIKeyByID(context.Background(), user.ID+1, key.ID)
+	assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+}
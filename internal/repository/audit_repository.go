@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"testovoe/internal/domain"
+)
+
+type AuditRepositoryInterface interface {
+	CreateAuditRecord(ctx context.Context, record *domain.AuditRecord) error
+	CreateAuditRecords(ctx context.Context, records []domain.AuditRecord) error
+	ListAuditRecords(ctx context.Context, filter domain.AuditFilter) (*domain.AuditList, error)
+}
+
+type AuditRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewAuditRepository(db *pgxpool.Pool) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+func (r *AuditRepository) conn(ctx context.Context) querier {
+	return conn(ctx, r.db)
+}
+
+func (r *AuditRepository) CreateAuditRecord(ctx context.Context, record *domain.AuditRecord) error {
+	query := `INSERT INTO audit_log (actor, action, entity_type, entity_id, before, after, diff, request_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, '')) RETURNING id, created_at`
+	err := r.conn(ctx).QueryRow(ctx, query,
+		record.Actor, record.Action, record.EntityType, record.EntityID,
+		nullableJSON(record.Before), nullableJSON(record.After), nullableJSON(record.Diff), record.RequestID,
+	).Scan(&record.ID, &record.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка при записи в журнал аудита: %w", err)
+	}
+	return nil
+}
+
+func (r *AuditRepository) CreateAuditRecords(ctx context.Context, records []domain.AuditRecord) error {
+	columns := []string{"actor", "action", "entity_type", "entity_id", "before", "after", "diff", "request_id"}
+	_, err := r.conn(ctx).CopyFrom(ctx, pgx.Identifier{"audit_log"}, columns,
+		pgx.CopyFromSlice(len(records), func(i int) ([]any, error) {
+			record := records[i]
+			var requestID any
+			if record.RequestID != "" {
+				requestID = record.RequestID
+			}
+			return []any{record.Actor, record.Action, record.EntityType, record.EntityID,
+				nullableJSON(record.Before), nullableJSON(record.After), nullableJSON(record.Diff), requestID}, nil
+		}))
+	if err != nil {
+		return fmt.Errorf("ошибка при записи в журнал аудита: %w", err)
+	}
+	return nil
+}
+
+func (r *AuditRepository) ListAuditRecords(ctx context.Context, filter domain.AuditFilter) (*domain.AuditList, error) {
+	var conditions []string
+	var args []any
+
+	if filter.EntityType != "" {
+		args = append(args, filter.EntityType)
+		conditions = append(conditions, fmt.Sprintf("entity_type = $%d", len(args)))
+	}
+	if filter.EntityID != 0 {
+		args = append(args, filter.EntityID)
+		conditions = append(conditions, fmt.Sprintf("entity_id = $%d", len(args)))
+	}
+	if filter.Actor != "" {
+		args = append(args, filter.Actor)
+		conditions = append(conditions, fmt.Sprintf("actor = $%d", len(args)))
+	}
+	if filter.Action != "" {
+		args = append(args, filter.Action)
+		conditions = append(conditions, fmt.Sprintf("action = $%d", len(args)))
+	}
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+
+	list := &domain.AuditList{Limit: filter.Limit, Offset: filter.Offset}
+	countQuery := "SELECT COUNT(*) FROM audit_log" + whereClause(conditions)
+	if err := r.conn(ctx).QueryRow(ctx, countQuery, args...).Scan(&list.Total); err != nil {
+		return nil, fmt.Errorf("ошибка при подсчете записей аудита: %w", err)
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	query := fmt.Sprintf(`SELECT id, actor, action, entity_type, entity_id, before, after, diff, COALESCE(request_id, ''), created_at
+		FROM audit_log%s ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d`,
+		whereClause(conditions), len(args)-1, len(args))
+	rows, err := r.conn(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении журнала аудита: %w", err)
+	}
+	defer rows.Close()
+
+	list.Records = make([]domain.AuditRecord, 0, filter.Limit)
+	for rows.Next() {
+		var record domain.AuditRecord
+		var before, after, diff []byte
+		if err := rows.Scan(&record.ID, &record.Actor, &record.Action, &record.EntityType, &record.EntityID,
+			&before, &after, &diff, &record.RequestID, &record.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении записи аудита: %w", err)
+		}
+		record.Before, record.After, record.Diff = before, after, diff
+		list.Records = append(list.Records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при получении журнала аудита: %w", err)
+	}
+	return list, nil
+}
+
+func nullableJSON(data []byte) any {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testovoe/internal/domain"
+	"time"
+)
+
+func TestAuditRepository_CreateAndList(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewAuditRepository(pool)
+	ctx := context.Background()
+
+	first := &domain.AuditRecord{
+		Actor:      "admin",
+		Action:     domain.AuditActionUserCreated,
+		EntityType: domain.AuditEntityUser,
+		EntityID:   1,
+		After:      []byte(`{"id":1,"name":"Test"}`),
+		Diff:       []byte(`{"name":{"from":null,"to":"Test"}}`),
+		RequestID:  "req-1",
+	}
+	assert.NoError(t, repo.CreateAuditRecord(ctx, first))
+	assert.NotZero(t, first.ID)
+	assert.False(t, first.CreatedAt.IsZero())
+
+	second := &domain.AuditRecord{Actor: "operator", Action: domain.AuditActionUserDeleted, EntityType: domain.AuditEntityUser, EntityID: 2}
+	assert.NoError(t, repo.CreateAuditRecord(ctx, second))
+
+	list, err := repo.ListAuditRecords(ctx, domain.AuditFilter{EntityType: domain.AuditEntityUser, EntityID: 1, Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), list.Total)
+	assert.Equal(t, "req-1", list.Records[0].RequestID)
+	assert.JSONEq(t, `{"id":1,"name":"Test"}`, string(list.Records[0].After))
+	assert.Nil(t, list.Records[0].Before)
+
+	list, err = repo.ListAuditRecords(ctx, domain.AuditFilter{Actor: "operator", Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), list.Total)
+	assert.Equal(t, domain.AuditActionUserDeleted, list.Records[0].Action)
+
+	future := time.Now().Add(time.Hour)
+	list, err = repo.ListAuditRecords(ctx, domain.AuditFilter{From: &future, Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), list.Total)
+}
+
+func TestTransactor_RollbackOnError(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	users := NewUserRepository(pool)
+	audit := NewAuditRepository(pool)
+	tx := NewTransactor(pool)
+	ctx := context.Background()
+
+	err := tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := users.CreateUser(ctx, &domain.User{Name: "Test User", Email: "test@example.com"}); err != nil {
+			return err
+		}
+		if err := audit.CreateAuditRecord(ctx, &domain.AuditRecord{Actor: "admin", Action: domain.AuditActionUserCreated, EntityType: domain.AuditEntityUser}); err != nil {
+			return err
+		}
+		return errors.New("откат")
+	})
+	assert.EqualError(t, err, "откат")
+
+	list, err := users.ListUsers(ctx, domain.UserFilter{Limit: 10})
+	assert.NoError(t, err)
+	assert.Empty(t, list.Users)
+
+	records, err := audit.ListAuditRecords(ctx, domain.AuditFilter{Limit: 10})
+	assert.NoError(t, err)
+	assert.Empty(t, records.Records)
+}
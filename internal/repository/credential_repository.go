@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"testovoe/internal/domain"
+)
+
+// CredentialRepositoryInterface работает с хешами паролей, которые намеренно не входят в domain.User.
+type CredentialRepositoryInterface interface {
+	GetCredentialsByEmail(ctx context.Context, email string) (*domain.User, string, error)
+	GetPasswordHash(ctx context.Context, userID int64) (string, error)
+	SetPasswordHash(ctx context.Context, userID int64, hash string) error
+}
+
+// GetCredentialsByEmail возвращает активного пользователя и хеш его пароля;
+// если пароль не задан, хеш пустой.
+func (r *UserRepository) GetCredentialsByEmail(ctx context.Context, email string) (*domain.User, string, error) {
+	query := "SELECT id, name, email, version, email_verified_at, COALESCE(password_hash, '') FROM users WHERE email = $1 AND deleted_at IS NULL"
+	var user domain.User
+	var hash string
+	err := r.conn(ctx).QueryRow(ctx, query, email).Scan(&user.ID, &user.Name, &user.Email, &user.Version, &user.EmailVerifiedAt, &hash)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, "", ErrUserNotFound
+		}
+		return nil, "", fmt.Errorf("ошибка при получении учетных данных: %w", err)
+	}
+	return &user, hash, nil
+}
+
+func (r *UserRepository) GetPasswordHash(ctx context.Context, userID int64) (string, error) {
+	query := "SELECT COALESCE(password_hash, '') FROM users WHERE id = $1 AND deleted_at IS NULL"
+	var hash string
+	if err := r.conn(ctx).QueryRow(ctx, query, userID).Scan(&hash); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrUserNotFound
+		}
+		return "", fmt.Errorf("ошибка при получении учетных данных: %w", err)
+	}
+	return hash, nil
+}
+
+func (r *UserRepository) SetPasswordHash(ctx context.Context, userID int64, hash string) error {
+	query := "UPDATE users SET password_hash = $1, password_changed_at = NOW() WHERE id = $2 AND deleted_at IS NULL"
+	tag, err := r.conn(ctx).Exec(ctx, query, hash, userID)
+	if err != nil {
+		return fmt.Errorf("ошибка при сохранении пароля: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testovoe/internal/domain"
+)
+
+func TestUserRepository_Credentials(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewUserRepository(pool)
+
+	user := &domain.User{Name: "Иван", Email: "ivan@example.com"}
+	assert.NoError(t, repo.CreateUser(context.Background(), user))
+
+	found, hash, err := repo.GetCredentialsByEmail(context.Background(), "ivan@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, found.ID)
+	assert.Empty(t, hash)
+
+	assert.NoError(t, repo.SetPasswordHash(context.Background(), user.ID, "$argon2id$hash"))
+	hash, err = repo.GetPasswordHash(context.Background(), user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "$argon2id$hash", hash)
+
+	assert.ErrorIs(t, repo.SetPasswordHash(context.Background(), user.ID+1, "x"), ErrUserNotFound)
+	_, _, err = repo.GetCredentialsByEmail(context.Background(), "nobody@example.com")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
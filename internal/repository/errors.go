@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"errors"
+	"github.com/jackc/pgx/v5/pgconn"
+	"testovoe/internal/apperr"
+)
+
+// Коды ошибок Postgres, которые означают неверные данные, а не сбой хранилища.
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+	pgNotNullViolation    = "23502"
+	pgCheckViolation      = "23514"
+	pgStringTooLong       = "22001"
+)
+
+// constraintError переводит нарушение ограничения Postgres в ошибку каталога: нарушение уникальности —
+// в conflict (или apperr.ErrConflict, если conflict не задан), ссылку на несуществующую запись — в конфликт,
+// а NOT NULL, CHECK и превышение длины — в ошибку проверки поля. Для остальных ошибок возвращает nil.
+func constraintError(err error, conflict *apperr.Error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return nil
+	}
+	switch pgErr.Code {
+	case pgUniqueViolation:
+		if conflict == nil {
+			conflict = apperr.ErrConflict
+		}
+		return conflict.Wrap(err)
+	case pgForeignKeyViolation:
+		return apperr.ErrConflict.Wrap(err)
+	case pgNotNullViolation, pgCheckViolation, pgStringTooLong:
+		field := apperr.FieldError{Field: pgErr.ColumnName, Code: "invalid", Message: "недопустимое значение"}
+		if pgErr.Code == pgStringTooLong {
+			field.Code, field.Message = "too_long", "слишком длинное значение"
+		}
+		return apperr.ErrValidation.WithFields(field).Wrap(err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"testovoe/internal/domain"
+	"time"
+)
+
+type IdempotencyRepositoryInterface interface {
+	ReserveIdempotencyKey(ctx context.Context, key *domain.IdempotencyKey, lockTimeout time.Duration) (*domain.IdempotencyKey, bool, error)
+	CompleteIdempotencyKey(ctx context.Context, key *domain.IdempotencyKey) error
+	ReleaseIdempotencyKey(ctx context.Context, key *domain.IdempotencyKey) error
+	PruneIdempotencyKeys(ctx context.Context, before time.Time) error
+}
+
+type IdempotencyRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewIdempotencyRepository(db *pgxpool.Pool) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+func (r *IdempotencyRepository) conn(ctx context.Context) querier {
+	return conn(ctx, r.db)
+}
+
+// ReserveIdempotencyKey занимает ключ под новый запрос. Ключ с истекшим сроком считается свободным
+// и занимается заново, даже если PruneIdempotencyKeys его еще не удалил. Ключ, запрос с которым
+// не завершился за lockTimeout, занимается заново, если повтор совпадает с ним по отпечатку.
+// Если ключ занят, возвращает сохраненную запись и false.
+func (r *IdempotencyRepository) ReserveIdempotencyKey(ctx context.Context, key *domain.IdempotencyKey, lockTimeout time.Duration) (*domain.IdempotencyKey, bool, error) {
+	query := `INSERT INTO idempotency_keys AS stored (client, key, fingerprint, expires_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (client, key) DO UPDATE SET fingerprint = EXCLUDED.fingerprint, status = 0, content_type = '',
+			headers = '{}', body = NULL, created_at = NOW(), locked_at = NOW(), expires_at = EXCLUDED.expires_at
+			WHERE stored.expires_at < NOW()
+				OR (stored.status = 0 AND stored.fingerprint = EXCLUDED.fingerprint
+					AND stored.locked_at < NOW() - $5::float8 * INTERVAL '1 second')
+		RETURNING created_at, locked_at`
+	err := r.conn(ctx).QueryRow(ctx, query, key.Client, key.Key, key.Fingerprint, key.ExpiresAt, lockTimeout.Seconds()).
+		Scan(&key.CreatedAt, &key.LockedAt)
+	if err == nil {
+		return key, true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, fmt.Errorf("ошибка при сохранении ключа идемпотентности: %w", err)
+	}
+
+	query = `SELECT client, key, fingerprint, status, content_type, headers, body, created_at, locked_at, expires_at
+		FROM idempotency_keys WHERE client = $1 AND key = $2 AND expires_at >= NOW()`
+	var existing domain.IdempotencyKey
+	err = r.conn(ctx).QueryRow(ctx, query, key.Client, key.Key).Scan(&existing.Client, &existing.Key, &existing.Fingerprint,
+		&existing.Status, &existing.ContentType, &existing.Headers, &existing.Body, &existing.CreatedAt, &existing.LockedAt, &existing.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// Ключ освободили или он истек между двумя запросами: занимаем его заново.
+			return r.ReserveIdempotencyKey(ctx, key, lockTimeout)
+		}
+		return nil, false, fmt.Errorf("ошибка при получении ключа идемпотентности: %w", err)
+	}
+	return &existing, false, nil
+}
+
+// CompleteIdempotencyKey сохраняет ответ, если ключ не занял другой запрос, пока этот выполнялся.
+func (r *IdempotencyRepository) CompleteIdempotencyKey(ctx context.Context, key *domain.IdempotencyKey) error {
+	query := `UPDATE idempotency_keys SET status = $4, content_type = $5, headers = $6, body = $7
+		WHERE client = $1 AND key = $2 AND locked_at = $3`
+	headers := key.Headers
+	if headers == nil {
+		headers = map[string]string{}
+	}
+	_, err := r.conn(ctx).Exec(ctx, query, key.Client, key.Key, key.LockedAt, key.Status, key.ContentType, headers, key.Body)
+	if err != nil {
+		return fmt.Errorf("ошибка при сохранении ответа для ключа идемпотентности: %w", err)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey освобождает ключ, чтобы запрос можно было повторить. Ключ, который
+// уже занял другой запрос, не освобождается.
+func (r *IdempotencyRepository) ReleaseIdempotencyKey(ctx context.Context, key *domain.IdempotencyKey) error {
+	query := "DELETE FROM idempotency_keys WHERE client = $1 AND key = $2 AND locked_at = $3 AND status = 0"
+	if _, err := r.conn(ctx).Exec(ctx, query, key.Client, key.Key, key.LockedAt); err != nil {
+		return fmt.Errorf("ошибка при освобождении ключа идемпотентности: %w", err)
+	}
+	return nil
+}
+
+// PruneIdempotencyKeys удаляет ключи, срок которых истек до before. Вызывается не чаще раза
+// в минуту, а не при каждом запросе: до удаления истекшие ключи просто не учитываются.
+func (r *IdempotencyRepository) PruneIdempotencyKeys(ctx context.Context, before time.Time) error {
+	if _, err := r.conn(ctx).Exec(ctx, "DELETE FROM idempotency_keys WHERE expires_at < $1", before); err != nil {
+		return fmt.Errorf("ошибка при удалении устаревших ключей идемпотентности: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testovoe/internal/domain"
+	"time"
+)
+
+func TestIdempotencyRepository_Lifecycle(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewIdempotencyRepository(pool)
+	ctx := context.Background()
+	key := &domain.IdempotencyKey{Client: "user:1", Key: "key-1", Fingerprint: []byte("fp"), ExpiresAt: time.Now().Add(time.Hour)}
+
+	_, reserved, err := repo.ReserveIdempotencyKey(ctx, key, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+
+	stored, reserved, err := repo.ReserveIdempotencyKey(ctx, &domain.IdempotencyKey{Client: "user:1", Key: "key-1", Fingerprint: []byte("fp"), ExpiresAt: time.Now().Add(time.Hour)}, time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, reserved)
+	assert.False(t, stored.Completed())
+
+	// Запрос, который не завершился за lockTimeout, уступает ключ повтору и уже не сохраняет ответ.
+	stale := *key
+	key = &domain.IdempotencyKey{Client: "user:1", Key: "key-1", Fingerprint: []byte("fp"), ExpiresAt: time.Now().Add(time.Hour)}
+	_, reserved, err = repo.ReserveIdempotencyKey(ctx, key, 0)
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+	stale.Status = 500
+	assert.NoError(t, repo.CompleteIdempotencyKey(ctx, &stale))
+	assert.NoError(t, repo.ReleaseIdempotencyKey(ctx, &stale))
+
+	key.Status, key.ContentType, key.Body = 201, "application/json", []byte(`{"id":1}`)
+	key.Headers = map[string]string{"Location": "/users/1"}
+	assert.NoError(t, repo.CompleteIdempotencyKey(ctx, key))
+	stored, _, err = repo.ReserveIdempotencyKey(ctx, &domain.IdempotencyKey{Client: "user:1", Key: "key-1", Fingerprint: []byte("fp"), ExpiresAt: time.Now().Add(time.Hour)}, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 201, stored.Status)
+	assert.Equal(t, []byte(`{"id":1}`), stored.Body)
+	assert.Equal(t, "/users/1", stored.Headers["Location"])
+
+	// Ключ другого клиента не пересекается с ключом первого.
+	_, reserved, err = repo.ReserveIdempotencyKey(ctx, &domain.IdempotencyKey{Client: "user:2", Key: "key-1", Fingerprint: []byte("fp"), ExpiresAt: time.Now().Add(time.Hour)}, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+
+	// Завершенный ключ не освобождается.
+	assert.NoError(t, repo.ReleaseIdempotencyKey(ctx, key))
+	_, reserved, err = repo.ReserveIdempotencyKey(ctx, &domain.IdempotencyKey{Client: "user:1", Key: "key-1", Fingerprint: []byte("fp"), ExpiresAt: time.Now().Add(time.Hour)}, time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, reserved)
+
+	other := &domain.IdempotencyKey{Client: "user:3", Key: "key-1", Fingerprint: []byte("fp"), ExpiresAt: time.Now().Add(time.Hour)}
+	_, reserved, err = repo.ReserveIdempotencyKey(ctx, other, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+	assert.NoError(t, repo.ReleaseIdempotencyKey(ctx, other))
+	_, reserved, err = repo.ReserveIdempotencyKey(ctx, &domain.IdempotencyKey{Client: "user:3", Key: "key-1", Fingerprint: []byte("other"), ExpiresAt: time.Now().Add(-time.Minute)}, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+
+	// Истекший ключ занимает следующий запрос, даже если очистка его еще не удалила.
+	_, reserved, err = repo.ReserveIdempotencyKey(ctx, &domain.IdempotencyKey{Client: "user:3", Key: "key-1", Fingerprint: []byte("fp"), ExpiresAt: time.Now().Add(-time.Minute)}, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+
+	assert.NoError(t, repo.PruneIdempotencyKeys(ctx, time.Now()))
+	_, reserved, err = repo.ReserveIdempotencyKey(ctx, &domain.IdempotencyKey{Client: "user:3", Key: "key-1", Fingerprint: []byte("other"), ExpiresAt: time.Now().Add(time.Hour)}, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+	stored, _, err = repo.ReserveIdempotencyKey(ctx, &domain.IdempotencyKey{Client: "user:1", Key: "key-1", Fingerprint: []byte("fp"), ExpiresAt: time.Now().Add(time.Hour)}, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, stored.Completed(), "непросроченные ключи очистка не трогает")
+}
@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"testovoe/internal/apperr"
+	"testovoe/internal/domain"
+)
+
+var ErrIdentityNotFound = apperr.New(apperr.KindNotFound, "identity_not_found", "внешняя учетная запись не найдена")
+var ErrIdentityTaken = apperr.New(apperr.KindConflict, "identity_taken", "внешняя учетная запись уже связана с пользователем")
+var ErrSSOStateNotFound = apperr.New(apperr.KindNotFound, "sso_state_not_found", "вход через провайдера не найден")
+
+type IdentityRepositoryInterface interface {
+	GetIdentity(ctx context.Context, provider, subject string) (*domain.Identity, error)
+	CreateIdentity(ctx context.Context, identity *domain.Identity) error
+	ListIdentities(ctx context.Context, userID int64) ([]domain.Identity, error)
+	DeleteIdentity(ctx context.Context, userID, id int64) (*domain.Identity, error)
+	TouchIdentity(ctx context.Context, id int64, email string) error
+	FindUserIDByEmail(ctx context.Context, email string) (int64, error)
+	CreateSSOState(ctx context.Context, state *domain.SSOState) error
+	ClaimSSOState(ctx context.Context, hash []byte) (*domain.SSOState, error)
+}
+
+type IdentityRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewIdentityRepository(db *pgxpool.Pool) *IdentityRepository {
+	return &IdentityRepository{db: db}
+}
+
+func (r *IdentityRepository) conn(ctx context.Context) querier {
+	return conn(ctx, r.db)
+}
+
+const identityColumns = "id, user_id, provider, subject, email, created_at, last_login_at"
+
+func scanIdentity(row pgx.Row) (*domain.Identity, error) {
+	var identity domain.Identity
+	err := row.Scan(&identity.ID, &identity.UserID, &identity.Provider, &identity.Subject, &identity.Email,
+		&identity.CreatedAt, &identity.LastLoginAt)
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// GetIdentity возвращает учетную запись провайдера; учетные записи удаленных пользователей не возвращаются.
+func (r *IdentityRepository) GetIdentity(ctx context.Context, provider, subject string) (*domain.Identity, error) {
+	query := `SELECT i.id, i.user_id, i.provider, i.subject, i.email, i.created_at, i.last_login_at
+		FROM user_identities i JOIN users u ON u.id = i.user_id
+		WHERE i.provider = $1 AND i.subject = $2 AND u.deleted_at IS NULL`
+	identity, err := scanIdentity(r.conn(ctx).QueryRow(ctx, query, provider, subject))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrIdentityNotFound
+		}
+		return nil, fmt.Errorf("ошибка при получении внешней учетной записи: %w", err)
+	}
+	return identity, nil
+}
+
+func (r *IdentityRepository) CreateIdentity(ctx context.Context, identity *domain.Identity) error {
+	query := `INSERT INTO user_identities (user_id, provider, subject, email) VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+	err := r.conn(ctx).QueryRow(ctx, query, identity.UserID, identity.Provider, identity.Subject, identity.Email).
+		Scan(&identity.ID, &identity.CreatedAt)
+	if err != nil {
+		if err := constraintError(err, ErrIdentityTaken); err != nil {
+			return err
+		}
+		return fmt.Errorf("ошибка при связывании внешней учетной записи: %w", err)
+	}
+	return nil
+}
+
+func (r *IdentityRepository) ListIdentities(ctx context.Context, userID int64) ([]domain.Identity, error) {
+	query := "SELECT " + identityColumns + " FROM user_identities WHERE user_id = $1 ORDER BY id"
+	rows, err := r.conn(ctx).Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении внешних учетных записей: %w", err)
+	}
+	defer rows.Close()
+
+	identities := make([]domain.Identity, 0)
+	for rows.Next() {
+		identity, err := scanIdentity(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при чтении внешней учетной записи: %w", err)
+		}
+		identities = append(identities, *identity)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при получении внешних учетных записей: %w", err)
+	}
+	return identities, nil
+}
+
+func (r *IdentityRepository) DeleteIdentity(ctx context.Context, userID, id int64) (*domain.Identity, error) {
+	query := "DELETE FROM user_identities WHERE id = $1 AND user_id = $2 RETURNING " + identityColumns
+	identity, err := scanIdentity(r.conn(ctx).QueryRow(ctx, query, id, userID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrIdentityNotFound
+		}
+		return nil, fmt.Errorf("ошибка при отвязке внешней учетной записи: %w", err)
+	}
+	return identity, nil
+}
+
+// TouchIdentity отмечает вход и сохраняет актуальный email из ID-токена.
+func (r *IdentityRepository) TouchIdentity(ctx context.Context, id int64, email string) error {
+	query := "UPDATE user_identities SET last_login_at = NOW(), email = $2 WHERE id = $1"
+	if _, err := r.conn(ctx).Exec(ctx, query, id, email); err != nil {
+		return fmt.Errorf("ошибка при обновлении внешней учетной записи: %w", err)
+	}
+	return nil
+}
+
+// FindUserIDByEmail ищет пользователя по email без учета регистра: провайдеры не обязаны
+// сохранять регистр, в котором адрес был введен у нас.
+func (r *IdentityRepository) FindUserIDByEmail(ctx context.Context, email string) (int64, error) {
+	query := "SELECT id FROM users WHERE lower(email) = lower($1) AND deleted_at IS NULL ORDER BY id LIMIT 1"
+	var id int64
+	if err := r.conn(ctx).QueryRow(ctx, query, email).Scan(&id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrUserNotFound
+		}
+		return 0, fmt.Errorf("ошибка при поиске пользователя по email: %w", err)
+	}
+	return id, nil
+}
+
+func (r *IdentityRepository) CreateSSOState(ctx context.Context, state *domain.SSOState) error {
+	query := `INSERT INTO sso_login_states (state_hash, provider, nonce, code_verifier, expires_at)
+		VALUES ($1, $2, $3, $4, $5)`
+	if _, err := r.conn(ctx).Exec(ctx, query, state.StateHash, state.Provider, state.Nonce, state.CodeVerifier, state.ExpiresAt); err != nil {
+		return fmt.Errorf("ошибка при сохранении входа через провайдера: %w", err)
+	}
+	return nil
+}
+
+// ClaimSSOState удаляет и возвращает незавершенный вход, поэтому state можно использовать один раз.
+// Заодно удаляются просроченные записи.
+func (r *IdentityRepository) ClaimSSOState(ctx context.Context, hash []byte) (*domain.SSOState, error) {
+	if _, err := r.conn(ctx).Exec(ctx, "DELETE FROM sso_login_states WHERE expires_at < NOW()"); err != nil {
+		return nil, fmt.Errorf("ошибка при удалении просроченных входов через провайдера: %w", err)
+	}
+	query := `DELETE FROM sso_login_states WHERE state_hash = $1
+		RETURNING state_hash, provider, nonce, code_verifier, expires_at`
+	var state domain.SSOState
+	err := r.conn(ctx).QueryRow(ctx, query, hash).Scan(&state.StateHash, &state.Provider, &state.Nonce, &state.CodeVerifier, &state.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrSSOStateNotFound
+		}
+		return nil, fmt.Errorf("ошибка при получении входа через провайдера: %w", err)
+	}
+	return &state, nil
+}
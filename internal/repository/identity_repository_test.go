@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testovoe/internal/domain"
+	"time"
+)
+
+func TestIdentityRepository_Lifecycle(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	users := NewUserRepository(pool)
+	repo := NewIdentityRepository(pool)
+
+	user := &domain.User{Name: "Иван", Email: "Ivan@example.com"}
+	assert.NoError(t, users.CreateUser(context.Background(), user))
+
+	id, err := repo.FindUserIDByEmail(context.Background(), "ivan@EXAMPLE.com")
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, id)
+	_, err = repo.FindUserIDByEmail(context.Background(), "other@example.com")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+
+	identity := &domain.Identity{UserID: user.ID, Provider: "corp", Subject: "corp-1", Email: "ivan@example.com"}
+	assert.NoError(t, repo.CreateIdentity(context.Background(), identity))
+	assert.ErrorIs(t, repo.CreateIdentity(context.Background(), &domain.Identity{UserID: user.ID, Provider: "corp", Subject: "corp-1"}), ErrIdentityTaken)
+	assert.NoError(t, repo.CreateIdentity(context.Background(), &domain.Identity{UserID: user.ID, Provider: "google", Subject: "g-1"}))
+
+	assert.NoError(t, repo.TouchIdentity(context.Background(), identity.ID, "ivan.petrov@example.com"))
+	stored, err := repo.GetIdentity(context.Background(), "corp", "corp-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "ivan.petrov@example.com", stored.Email)
+	assert.NotNil(t, stored.LastLoginAt)
+
+	identities, err := repo.ListIdentities(context.Background(), user.ID)
+	assert.NoError(t, err)
+	assert.Len(t, identities, 2)
+
+	_, err = repo.DeleteIdentity(context.Background(), user.ID+1, identity.ID)
+	assert.ErrorIs(t, err, ErrIdentityNotFound)
+	deleted, err := repo.DeleteIdentity(context.Background(), user.ID, identity.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "corp-1", deleted.Subject)
+	_, err = repo.GetIdentity(context.Background(), "corp", "corp-1")
+	assert.ErrorIs(t, err, ErrIdentityNotFound)
+}
+
+func TestIdentityRepository_SSOState(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewIdentityRepository(pool)
+
+	state := &domain.SSOState{StateHash: []byte("state"), Provider: "corp", Nonce: "nonce", CodeVerifier: "verifier", ExpiresAt: time.Now().Add(time.Minute)}
+	assert.NoError(t, repo.CreateSSOState(context.Background(), state))
+	expired := &domain.SSOState{StateHash: []byte("expired"), Provider: "corp", Nonce: "n", CodeVerifier: "v", ExpiresAt: time.Now().Add(-time.Minute)}
+	assert.NoError(t, repo.CreateSSOState(context.Background(), expired))
+
+	claimed, err := repo.ClaimSSOState(context.Background(), []byte("state"))
+	assert.NoError(t, err)
+	assert.Equal(t, "verifier", claimed.CodeVerifier)
+
+	_, err = repo.ClaimSSOState(context.Background(), []byte("state"))
+	assert.ErrorIs(t, err, ErrSSOStateNotFound)
+	_, err = repo.ClaimSSOState(context.Background(), []byte("expired"))
+	assert.ErrorIs(t, err, ErrSSOStateNotFound)
+}
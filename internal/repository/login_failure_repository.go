@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"testovoe/internal/apperr"
+	"testovoe/internal/domain"
+	"time"
+)
+
+var ErrLoginFailureNotFound = apperr.New(apperr.KindNotFound, "login_failure_not_found", "неудачных попыток входа нет")
+
+type LoginFailureRepositoryInterface interface {
+	GetLoginFailure(ctx context.Context, scope, key string) (*domain.LoginFailure, error)
+	RecordLoginFailure(ctx context.Context, scope, key string, at, resetBefore time.Time) (*domain.LoginFailure, error)
+	LockLogin(ctx context.Context, scope, key string, until time.Time) error
+	ReleaseLoginFailure(ctx context.Context, scope, key string, lockedUntil, previous *time.Time) error
+	ClearLoginFailures(ctx context.Context, scope, key string) (*domain.LoginFailure, error)
+	ListLoginLocks(ctx context.Context, at time.Time) ([]domain.LoginFailure, error)
+}
+
+type LoginFailureRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewLoginFailureRepository(db *pgxpool.Pool) *LoginFailureRepository {
+	return &LoginFailureRepository{db: db}
+}
+
+func (r *LoginFailureRepository) conn(ctx context.Context) querier {
+	return conn(ctx, r.db)
+}
+
+const loginFailureColumns = "scope, key, failures, last_failure_at, locked_until"
+
+func scanLoginFailure(row pgx.Row) (*domain.LoginFailure, error) {
+	var failure domain.LoginFailure
+	if err := row.Scan(&failure.Scope, &failure.Key, &failure.Failures, &failure.LastFailureAt, &failure.LockedUntil); err != nil {
+		return nil, err
+	}
+	return &failure, nil
+}
+
+func (r *LoginFailureRepository) GetLoginFailure(ctx context.Context, scope, key string) (*domain.LoginFailure, error) {
+	query := "SELECT " + loginFailureColumns + " FROM login_failures WHERE scope = $1 AND key = $2"
+	failure, err := scanLoginFailure(r.conn(ctx).QueryRow(ctx, query, scope, key))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrLoginFailureNotFound
+		}
+		return nil, fmt.Errorf("ошибка при получении неудачных попыток входа: %w", err)
+	}
+	return failure, nil
+}
+
+// RecordLoginFailure атомарно увеличивает счетчик. Если последняя попытка была раньше resetBefore,
+// счет начинается заново. Заодно удаляются забытые счетчики без действующей блокировки.
+func (r *LoginFailureRepository) RecordLoginFailure(ctx context.Context, scope, key string, at, resetBefore time.Time) (*domain.LoginFailure, error) {
+	cleanup := `DELETE FROM login_failures WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until < $2)`
+	if _, err := r.conn(ctx).Exec(ctx, cleanup, resetBefore, at); err != nil {
+		return nil, fmt.Errorf("ошибка при удалении устаревших попыток входа: %w", err)
+	}
+
+	query := `INSERT INTO login_failures (scope, key, failures, last_failure_at) VALUES ($1, $2, 1, $3)
+		ON CONFLICT (scope, key) DO UPDATE SET
+			failures = CASE WHEN login_failures.last_failure_at < $4 THEN 1 ELSE login_failures.failures + 1 END,
+			last_failure_at = EXCLUDED.last_failure_at
+		RETURNING ` + loginFailureColumns
+	failure, err := scanLoginFailure(r.conn(ctx).QueryRow(ctx, query, scope, key, at, resetBefore))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при сохранении неудачной попытки входа: %w", err)
+	}
+	return failure, nil
+}
+
+func (r *LoginFailureRepository) LockLogin(ctx context.Context, scope, key string, until time.Time) error {
+	query := "UPDATE login_failures SET locked_until = $3 WHERE scope = $1 AND key = $2"
+	if _, err := r.conn(ctx).Exec(ctx, query, scope, key, until); err != nil {
+		return fmt.Errorf("ошибка при блокировке входа: %w", err)
+	}
+	return nil
+}
+
+// ReleaseLoginFailure возвращает попытку, учтенную заранее: уменьшает счетчик и, если блокировка
+// до сих пор равна lockedUntil, восстанавливает прежний срок previous.
+func (r *LoginFailureRepository) ReleaseLoginFailure(ctx context.Context, scope, key string, lockedUntil, previous *time.Time) error {
+	query := `UPDATE login_failures SET failures = GREATEST(failures - 1, 0),
+			locked_until = CASE WHEN locked_until = $3 THEN $4 ELSE locked_until END
+		WHERE scope = $1 AND key = $2`
+	if _, err := r.conn(ctx).Exec(ctx, query, scope, key, lockedUntil, previous); err != nil {
+		return fmt.Errorf("ошибка при возврате попытки входа: %w", err)
+	}
+	return nil
+}
+
+// ClearLoginFailures снимает блокировку и обнуляет счетчик, возвращая удаленную запись.
+func (r *LoginFailureRepository) ClearLoginFailures(ctx context.Context, scope, key string) (*domain.LoginFailure, error) {
+	query := "DELETE FROM login_failures WHERE scope = $1 AND key = $2 RETURNING " + loginFailureColumns
+	failure, err := scanLoginFailure(r.conn(ctx).QueryRow(ctx, query, scope, key))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrLoginFailureNotFound
+		}
+		return nil, fmt.Errorf("ошибка при снятии блокировки входа: %w", err)
+	}
+	return failure, nil
+}
+
+// ListLoginLocks возвращает блокировки, действующие в момент at, начиная с самых долгих.
+func (r *LoginFailureRepository) ListLoginLocks(ctx context.Context, at time.Time) ([]domain.LoginFailure, error) {
+	query := "SELECT " + loginFailureColumns + " FROM login_failures WHERE locked_until > $1 ORDER BY locked_until DESC, scope, key"
+	rows, err := r.conn(ctx).Query(ctx, query, at)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении блокировок входа: %w", err)
+	}
+	defer rows.Close()
+
+	failures := make([]domain.LoginFailure, 0)
+	for rows.Next() {
+		failure, err := scanLoginFailure(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при чтении блокировки входа: %w", err)
+		}
+		failures = append(failures, *failure)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при получении блокировок входа: %w", err)
+	}
+	return failures, nil
+}
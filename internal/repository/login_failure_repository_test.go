@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testovoe/internal/domain"
+	"time"
+)
+
+func TestLoginFailureRepository_Lifecycle(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewLoginFailureRepository(pool)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	_, err := repo.GetLoginFailure(ctx, domain.LockScopeAccount, "ivan@example.com")
+	assert.ErrorIs(t, err, ErrLoginFailureNotFound)
+
+	failure, err := repo.RecordLoginFailure(ctx, domain.LockScopeAccount, "ivan@example.com", now, now.Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, failure.Failures)
+	failure, err = repo.RecordLoginFailure(ctx, domain.LockScopeAccount, "ivan@example.com", now.Add(time.Second), now.Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, failure.Failures)
+
+	// Счетчик начинается заново, если последняя ошибка старше resetBefore.
+	failure, err = repo.RecordLoginFailure(ctx, domain.LockScopeAccount, "ivan@example.com", now.Add(2*time.Hour), now.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, failure.Failures)
+
+	until := now.Add(3 * time.Hour)
+	assert.NoError(t, repo.LockLogin(ctx, domain.LockScopeAccount, "ivan@example.com", until))
+	locks, err := repo.ListLoginLocks(ctx, now)
+	assert.NoError(t, err)
+	assert.Len(t, locks, 1)
+	assert.True(t, locks[0].Locked(now))
+
+	// Возврат попытки снимает только ту блокировку, которую она поставила.
+	other := until.Add(time.Hour)
+	assert.NoError(t, repo.ReleaseLoginFailure(ctx, domain.LockScopeAccount, "ivan@example.com", &other, nil))
+	failure, err = repo.GetLoginFailure(ctx, domain.LockScopeAccount, "ivan@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, failure.Failures)
+	assert.True(t, failure.Locked(now))
+	assert.NoError(t, repo.ReleaseLoginFailure(ctx, domain.LockScopeAccount, "ivan@example.com", &until, nil))
+	failure, err = repo.GetLoginFailure(ctx, domain.LockScopeAccount, "ivan@example.com")
+	assert.NoError(t, err)
+	assert.False(t, failure.Locked(now))
+
+	cleared, err := repo.ClearLoginFailures(ctx, domain.LockScopeAccount, "ivan@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, cleared.Failures)
+	_, err = repo.ClearLoginFailures(ctx, domain.LockScopeAccount, "ivan@example.com")
+	assert.ErrorIs(t, err, ErrLoginFailureNotFound)
+}
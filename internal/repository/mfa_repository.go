@@ -0,0 +1,191 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"testovoe/internal/apperr"
+	"testovoe/internal/domain"
+	"time"
+)
+
+var ErrMFANotFound = apperr.New(apperr.KindNotFound, "mfa_not_found", "второй фактор не настроен")
+var ErrRecoveryCodeNotFound = apperr.New(apperr.KindNotFound, "recovery_code_not_found", "код восстановления не найден")
+
+type MFARepositoryInterface interface {
+	GetMFA(ctx context.Context, userID int64) (*domain.MFA, error)
+	SaveMFASecret(ctx context.Context, userID int64, secret string) error
+	ConfirmMFA(ctx context.Context, userID int64) error
+	DeleteMFA(ctx context.Context, userID int64) error
+	UseTOTPStep(ctx context.Context, userID, step int64) (bool, error)
+	UseMFAChallenge(ctx context.Context, jti string, expiresAt time.Time) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID int64, hashes [][]byte) error
+	UseRecoveryCode(ctx context.Context, userID int64, hash []byte) error
+	CountRecoveryCodes(ctx context.Context, userID int64) (int, error)
+	UserRequiresMFA(ctx context.Context, userID int64) (bool, error)
+	ListMFARequiredRoles(ctx context.Context) ([]domain.Role, error)
+	SetMFARequiredRoles(ctx context.Context, roles []domain.Role) error
+}
+
+type MFARepository struct {
+	db *pgxpool.Pool
+}
+
+func NewMFARepository(db *pgxpool.Pool) *MFARepository {
+	return &MFARepository{db: db}
+}
+
+func (r *MFARepository) conn(ctx context.Context) querier {
+	return conn(ctx, r.db)
+}
+
+func (r *MFARepository) GetMFA(ctx context.Context, userID int64) (*domain.MFA, error) {
+	query := "SELECT user_id, secret, created_at, confirmed_at, last_used_step FROM user_mfa WHERE user_id = $1"
+	var mfa domain.MFA
+	err := r.conn(ctx).QueryRow(ctx, query, userID).Scan(&mfa.UserID, &mfa.Secret, &mfa.CreatedAt, &mfa.ConfirmedAt, &mfa.LastUsedStep)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrMFANotFound
+		}
+		return nil, fmt.Errorf("ошибка при получении второго фактора пользователя с id %d: %w", userID, err)
+	}
+	return &mfa, nil
+}
+
+// SaveMFASecret начинает настройку заново: прежний неподтвержденный секрет заменяется.
+func (r *MFARepository) SaveMFASecret(ctx context.Context, userID int64, secret string) error {
+	query := `INSERT INTO user_mfa (user_id, secret) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, created_at = NOW(), last_used_step = NULL
+		WHERE user_mfa.confirmed_at IS NULL`
+	if _, err := r.conn(ctx).Exec(ctx, query, userID, secret); err != nil {
+		return fmt.Errorf("ошибка при сохранении секрета второго фактора: %w", err)
+	}
+	return nil
+}
+
+func (r *MFARepository) ConfirmMFA(ctx context.Context, userID int64) error {
+	tag, err := r.conn(ctx).Exec(ctx, "UPDATE user_mfa SET confirmed_at = NOW() WHERE user_id = $1 AND confirmed_at IS NULL", userID)
+	if err != nil {
+		return fmt.Errorf("ошибка при подключении второго фактора: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrMFANotFound
+	}
+	return nil
+}
+
+// DeleteMFA отключает второй фактор вместе с кодами восстановления.
+func (r *MFARepository) DeleteMFA(ctx context.Context, userID int64) error {
+	tag, err := r.conn(ctx).Exec(ctx, "DELETE FROM user_mfa WHERE user_id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("ошибка при отключении второго фактора: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrMFANotFound
+	}
+	return nil
+}
+
+// UseTOTPStep запоминает интервал принятого кода и возвращает false, если код этого или
+// более позднего интервала уже принимался.
+func (r *MFARepository) UseTOTPStep(ctx context.Context, userID, step int64) (bool, error) {
+	query := `UPDATE user_mfa SET last_used_step = $2
+		WHERE user_id = $1 AND (last_used_step IS NULL OR last_used_step < $2)`
+	tag, err := r.conn(ctx).Exec(ctx, query, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("ошибка при проверке кода второго фактора: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// UseMFAChallenge отмечает токен входа использованным и возвращает false, если он уже был использован.
+// Заодно удаляются записи об истекших токенах.
+func (r *MFARepository) UseMFAChallenge(ctx context.Context, jti string, expiresAt time.Time) (bool, error) {
+	if _, err := r.conn(ctx).Exec(ctx, "DELETE FROM used_mfa_challenges WHERE expires_at < NOW()"); err != nil {
+		return false, fmt.Errorf("ошибка при удалении истекших токенов входа: %w", err)
+	}
+	query := "INSERT INTO used_mfa_challenges (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING"
+	tag, err := r.conn(ctx).Exec(ctx, query, jti, expiresAt)
+	if err != nil {
+		return false, fmt.Errorf("ошибка при проверке токена входа: %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r *MFARepository) ReplaceRecoveryCodes(ctx context.Context, userID int64, hashes [][]byte) error {
+	if _, err := r.conn(ctx).Exec(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("ошибка при замене кодов восстановления: %w", err)
+	}
+	query := "INSERT INTO mfa_recovery_codes (user_id, code_hash) SELECT $1, unnest($2::bytea[])"
+	if _, err := r.conn(ctx).Exec(ctx, query, userID, hashes); err != nil {
+		return fmt.Errorf("ошибка при замене кодов восстановления: %w", err)
+	}
+	return nil
+}
+
+// UseRecoveryCode погашает код восстановления; каждый код действует один раз.
+func (r *MFARepository) UseRecoveryCode(ctx context.Context, userID int64, hash []byte) error {
+	query := "UPDATE mfa_recovery_codes SET used_at = NOW() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL"
+	tag, err := r.conn(ctx).Exec(ctx, query, userID, hash)
+	if err != nil {
+		return fmt.Errorf("ошибка при использовании кода восстановления: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrRecoveryCodeNotFound
+	}
+	return nil
+}
+
+func (r *MFARepository) CountRecoveryCodes(ctx context.Context, userID int64) (int, error) {
+	var count int
+	query := "SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL"
+	if err := r.conn(ctx).QueryRow(ctx, query, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("ошибка при подсчете кодов восстановления: %w", err)
+	}
+	return count, nil
+}
+
+// UserRequiresMFA сообщает, есть ли у пользователя роль, для которой второй фактор обязателен.
+func (r *MFARepository) UserRequiresMFA(ctx context.Context, userID int64) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM user_roles ur JOIN roles ro ON ro.name = ur.role
+		WHERE ur.user_id = $1 AND ro.mfa_required)`
+	var required bool
+	if err := r.conn(ctx).QueryRow(ctx, query, userID).Scan(&required); err != nil {
+		return false, fmt.Errorf("ошибка при проверке политики второго фактора: %w", err)
+	}
+	return required, nil
+}
+
+func (r *MFARepository) ListMFARequiredRoles(ctx context.Context) ([]domain.Role, error) {
+	rows, err := r.conn(ctx).Query(ctx, "SELECT name FROM roles WHERE mfa_required ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении политики второго фактора: %w", err)
+	}
+	defer rows.Close()
+
+	roles := make([]domain.Role, 0)
+	for rows.Next() {
+		var role domain.Role
+		if err := rows.Scan(&role); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении роли: %w", err)
+		}
+		roles = append(roles, role)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при получении политики второго фактора: %w", err)
+	}
+	return roles, nil
+}
+
+func (r *MFARepository) SetMFARequiredRoles(ctx context.Context, roles []domain.Role) error {
+	names := make([]string, len(roles))
+	for i, role := range roles {
+		names[i] = string(role)
+	}
+	if _, err := r.conn(ctx).Exec(ctx, "UPDATE roles SET mfa_required = (name = ANY($1))", names); err != nil {
+		return fmt.Errorf("ошибка при изменении политики второго фактора: %w", err)
+	}
+	return nil
+}
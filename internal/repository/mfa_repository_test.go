@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testovoe/internal/domain"
+	"time"
+)
+
+func TestMFARepository_Lifecycle(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	users := NewUserRepository(pool)
+	repo := NewMFARepository(pool)
+
+	user := &domain.User{Name: "Иван", Email: "ivan@example.com"}
+	assert.NoError(t, users.CreateUser(context.Background(), user))
+
+	_, err := repo.GetMFA(context.Background(), user.ID)
+	assert.ErrorIs(t, err, ErrMFANotFound)
+
+	assert.NoError(t, repo.SaveMFASecret(context.Background(), user.ID, "SECRET1"))
+	assert.NoError(t, repo.SaveMFASecret(context.Background(), user.ID, "SECRET2"))
+	assert.NoError(t, repo.ConfirmMFA(context.Background(), user.ID))
+	assert.ErrorIs(t, repo.ConfirmMFA(context.Background(), user.ID), ErrMFANotFound)
+
+	// Подтвержденный секрет не заменяется повторной настройкой.
+	assert.NoError(t, repo.SaveMFASecret(context.Background(), user.ID, "SECRET3"))
+	mfa, err := repo.GetMFA(context.Background(), user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "SECRET2", mfa.Secret)
+	assert.True(t, mfa.Enabled())
+
+	fresh, err := repo.UseTOTPStep(context.Background(), user.ID, 100)
+	assert.NoError(t, err)
+	assert.True(t, fresh)
+	fresh, err = repo.UseTOTPStep(context.Background(), user.ID, 100)
+	assert.NoError(t, err)
+	assert.False(t, fresh)
+
+	fresh, err = repo.UseMFAChallenge(context.Background(), "jti-1", time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, fresh)
+	fresh, err = repo.UseMFAChallenge(context.Background(), "jti-1", time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, fresh)
+
+	assert.NoError(t, repo.ReplaceRecoveryCodes(context.Background(), user.ID, [][]byte{[]byte("code-1"), []byte("code-2")}))
+	assert.NoError(t, repo.UseRecoveryCode(context.Background(), user.ID, []byte("code-1")))
+	assert.ErrorIs(t, repo.UseRecoveryCode(context.Background(), user.ID, []byte("code-1")), ErrRecoveryCodeNotFound)
+	count, err := repo.CountRecoveryCodes(context.Background(), user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	assert.NoError(t, repo.DeleteMFA(context.Background(), user.ID))
+	assert.ErrorIs(t, repo.DeleteMFA(context.Background(), user.ID), ErrMFANotFound)
+}
+
+func TestMFARepository_Policy(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	users := NewUserRepository(pool)
+	repo := NewMFARepository(pool)
+
+	user := &domain.User{Name: "Иван", Email: "ivan@example.com"}
+	assert.NoError(t, users.CreateUser(context.Background(), user))
+	assert.NoError(t, users.SetUserRoles(context.Background(), user.ID, []domain.Role{domain.RoleAdmin}))
+
+	required, err := repo.UserRequiresMFA(context.Background(), user.ID)
+	assert.NoError(t, err)
+	assert.False(t, required)
+
+	assert.NoError(t, repo.SetMFARequiredRoles(context.Background(), []domain.Role{domain.RoleAdmin, domain.RoleOperator}))
+	roles, err := repo.ListMFARequiredRoles(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Role{domain.RoleAdmin, domain.RoleOperator}, roles)
+
+	required, err = repo.UserRequiresMFA(context.Background(), user.ID)
+	assert.NoError(t, err)
+	assert.True(t, required)
+}
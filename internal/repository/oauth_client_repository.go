@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"testovoe/internal/apperr"
+	"testovoe/internal/domain"
+)
+
+var ErrOAuthClientNotFound = apperr.New(apperr.KindNotFound, "oauth_client_not_found", "клиент OIDC не найден")
+
+type OAuthClientRepositoryInterface interface {
+	CreateOAuthClient(ctx context.Context, client *domain.OAuthClient) error
+	GetOAuthClient(ctx context.Context, id string) (*domain.OAuthClient, error)
+	ListOAuthClients(ctx context.Context) ([]domain.OAuthClient, error)
+	DeleteOAuthClient(ctx context.Context, id string) (*domain.OAuthClient, error)
+}
+
+type OAuthClientRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewOAuthClientRepository(db *pgxpool.Pool) *OAuthClientRepository {
+	return &OAuthClientRepository{db: db}
+}
+
+func (r *OAuthClientRepository) conn(ctx context.Context) querier {
+	return conn(ctx, r.db)
+}
+
+const oauthClientColumns = "id, name, secret_hash, redirect_uris, created_at"
+
+func scanOAuthClient(row pgx.Row) (*domain.OAuthClient, error) {
+	var client domain.OAuthClient
+	if err := row.Scan(&client.ID, &client.Name, &client.SecretHash, &client.RedirectURIs, &client.CreatedAt); err != nil {
+		return nil, err
+	}
+	client.Confidential = len(client.SecretHash) > 0
+	return &client, nil
+}
+
+func (r *OAuthClientRepository) CreateOAuthClient(ctx context.Context, client *domain.OAuthClient) error {
+	query := "INSERT INTO oauth_clients (id, name, secret_hash, redirect_uris) VALUES ($1, $2, $3, $4) RETURNING created_at"
+	err := r.conn(ctx).QueryRow(ctx, query, client.ID, client.Name, client.SecretHash, client.RedirectURIs).Scan(&client.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка при создании клиента OIDC: %w", err)
+	}
+	client.Confidential = len(client.SecretHash) > 0
+	return nil
+}
+
+func (r *OAuthClientRepository) GetOAuthClient(ctx context.Context, id string) (*domain.OAuthClient, error) {
+	client, err := scanOAuthClient(r.conn(ctx).QueryRow(ctx, "SELECT "+oauthClientColumns+" FROM oauth_clients WHERE id = $1", id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrOAuthClientNotFound
+		}
+		return nil, fmt.Errorf("ошибка при получении клиента OIDC %q: %w", id, err)
+	}
+	return client, nil
+}
+
+func (r *OAuthClientRepository) ListOAuthClients(ctx context.Context) ([]domain.OAuthClient, error) {
+	rows, err := r.conn(ctx).Query(ctx, "SELECT "+oauthClientColumns+" FROM oauth_clients ORDER BY created_at, id")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении клиентов OIDC: %w", err)
+	}
+	defer rows.Close()
+
+	clients := make([]domain.OAuthClient, 0)
+	for rows.Next() {
+		client, err := scanOAuthClient(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при чтении клиента OIDC: %w", err)
+		}
+		clients = append(clients, *client)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при получении клиентов OIDC: %w", err)
+	}
+	return clients, nil
+}
+
+// DeleteOAuthClient удаляет клиента вместе с его кодами авторизации и токенами.
+func (r *OAuthClientRepository) DeleteOAuthClient(ctx context.Context, id string) (*domain.OAuthClient, error) {
+	client, err := scanOAuthClient(r.conn(ctx).QueryRow(ctx, "DELETE FROM oauth_clients WHERE id = $1 RETURNING "+oauthClientColumns, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrOAuthClientNotFound
+		}
+		return nil, fmt.Errorf("ошибка при удалении клиента OIDC %q: %w", id, err)
+	}
+	return client, nil
+}
@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"testovoe/internal/apperr"
+	"testovoe/internal/domain"
+)
+
+var ErrAuthorizationCodeNotFound = apperr.New(apperr.KindNotFound, "authorization_code_not_found", "код авторизации не найден")
+var ErrOAuthTokenNotFound = apperr.New(apperr.KindNotFound, "oauth_token_not_found", "токен не найден")
+
+type OIDCRepositoryInterface interface {
+	CreateAuthorizationCode(ctx context.Context, code *domain.AuthorizationCode) error
+	ClaimAuthorizationCode(ctx context.Context, hash []byte) (*domain.AuthorizationCode, error)
+	CreateOAuthToken(ctx context.Context, token *domain.OAuthToken) error
+	GetOAuthToken(ctx context.Context, hash []byte) (*domain.OAuthToken, error)
+	ClaimOAuthToken(ctx context.Context, hash []byte) (*domain.OAuthToken, error)
+	RevokeOAuthToken(ctx context.Context, hash []byte) error
+	RevokeOAuthGrant(ctx context.Context, grantID int64) error
+	ListSigningKeys(ctx context.Context) ([]domain.SigningKey, error)
+	CreateSigningKey(ctx context.Context, key *domain.SigningKey) error
+}
+
+type OIDCRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewOIDCRepository(db *pgxpool.Pool) *OIDCRepository {
+	return &OIDCRepository{db: db}
+}
+
+func (r *OIDCRepository) conn(ctx context.Context) querier {
+	return conn(ctx, r.db)
+}
+
+func (r *OIDCRepository) CreateAuthorizationCode(ctx context.Context, code *domain.AuthorizationCode) error {
+	query := `INSERT INTO oauth_authorization_codes
+		(code_hash, client_id, user_id, redirect_uri, scope, nonce, code_challenge, auth_time, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id, created_at`
+	err := r.conn(ctx).QueryRow(ctx, query, code.CodeHash, code.ClientID, code.UserID, code.RedirectURI, code.Scope,
+		code.Nonce, code.CodeChallenge, code.AuthTime, code.ExpiresAt).Scan(&code.ID, &code.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка при создании кода авторизации: %w", err)
+	}
+	return nil
+}
+
+// ClaimAuthorizationCode блокирует код и помечает его использованным. Возвращается и уже
+// использованный код: его повторное предъявление означает утечку, и вызывающий отзывает выданные по нему токены.
+func (r *OIDCRepository) ClaimAuthorizationCode(ctx context.Context, hash []byte) (*domain.AuthorizationCode, error) {
+	query := `SELECT id, code_hash, client_id, user_id, redirect_uri, scope, nonce, code_challenge, auth_time,
+		created_at, expires_at, used_at FROM oauth_authorization_codes WHERE code_hash = $1 FOR UPDATE`
+	var code domain.AuthorizationCode
+	err := r.conn(ctx).QueryRow(ctx, query, hash).Scan(&code.ID, &code.CodeHash, &code.ClientID, &code.UserID,
+		&code.RedirectURI, &code.Scope, &code.Nonce, &code.CodeChallenge, &code.AuthTime, &code.CreatedAt, &code.ExpiresAt, &code.UsedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrAuthorizationCodeNotFound
+		}
+		return nil, fmt.Errorf("ошибка при получении кода авторизации: %w", err)
+	}
+	if code.UsedAt == nil {
+		if _, err := r.conn(ctx).Exec(ctx, "UPDATE oauth_authorization_codes SET used_at = NOW() WHERE id = $1", code.ID); err != nil {
+			return nil, fmt.Errorf("ошибка при использовании кода авторизации: %w", err)
+		}
+	}
+	return &code, nil
+}
+
+func (r *OIDCRepository) CreateOAuthToken(ctx context.Context, token *domain.OAuthToken) error {
+	query := `INSERT INTO oauth_tokens (token_hash, kind, grant_id, client_id, user_id, scope, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING created_at`
+	err := r.conn(ctx).QueryRow(ctx, query, token.TokenHash, token.Kind, token.GrantID, token.ClientID, token.UserID,
+		token.Scope, token.ExpiresAt).Scan(&token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка при сохранении токена: %w", err)
+	}
+	return nil
+}
+
+func (r *OIDCRepository) GetOAuthToken(ctx context.Context, hash []byte) (*domain.OAuthToken, error) {
+	query := `SELECT token_hash, kind, grant_id, client_id, user_id, scope, created_at, expires_at, revoked_at
+		FROM oauth_tokens WHERE token_hash = $1`
+	var token domain.OAuthToken
+	err := r.conn(ctx).QueryRow(ctx, query, hash).Scan(&token.TokenHash, &token.Kind, &token.GrantID, &token.ClientID,
+		&token.UserID, &token.Scope, &token.CreatedAt, &token.ExpiresAt, &token.RevokedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrOAuthTokenNotFound
+		}
+		return nil, fmt.Errorf("ошибка при получении токена: %w", err)
+	}
+	return &token, nil
+}
+
+// ClaimOAuthToken блокирует токен и отзывает его; возвращается состояние до отзыва. Параллельный вызов
+// с тем же токеном ждет конца транзакции и получает уже отозванный токен, поэтому refresh-токен
+// можно обменять только один раз.
+func (r *OIDCRepository) ClaimOAuthToken(ctx context.Context, hash []byte) (*domain.OAuthToken, error) {
+	query := `SELECT token_hash, kind, grant_id, client_id, user_id, scope, created_at, expires_at, revoked_at
+		FROM oauth_tokens WHERE token_hash = $1 FOR UPDATE`
+	var token domain.OAuthToken
+	err := r.conn(ctx).QueryRow(ctx, query, hash).Scan(&token.TokenHash, &token.Kind, &token.GrantID, &token.ClientID,
+		&token.UserID, &token.Scope, &token.CreatedAt, &token.ExpiresAt, &token.RevokedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrOAuthTokenNotFound
+		}
+		return nil, fmt.Errorf("ошибка при получении токена: %w", err)
+	}
+	if token.RevokedAt == nil {
+		if err := r.RevokeOAuthToken(ctx, hash); err != nil {
+			return nil, err
+		}
+	}
+	return &token, nil
+}
+
+func (r *OIDCRepository) RevokeOAuthToken(ctx context.Context, hash []byte) error {
+	query := "UPDATE oauth_tokens SET revoked_at = NOW() WHERE token_hash = $1 AND revoked_at IS NULL"
+	if _, err := r.conn(ctx).Exec(ctx, query, hash); err != nil {
+		return fmt.Errorf("ошибка при отзыве токена: %w", err)
+	}
+	return nil
+}
+
+// RevokeOAuthGrant отзывает все токены, выпущенные по одному коду авторизации.
+func (r *OIDCRepository) RevokeOAuthGrant(ctx context.Context, grantID int64) error {
+	query := "UPDATE oauth_tokens SET revoked_at = NOW() WHERE grant_id = $1 AND revoked_at IS NULL"
+	if _, err := r.conn(ctx).Exec(ctx, query, grantID); err != nil {
+		return fmt.Errorf("ошибка при отзыве токенов: %w", err)
+	}
+	return nil
+}
+
+// ListSigningKeys возвращает ключи подписи от нового к старому.
+func (r *OIDCRepository) ListSigningKeys(ctx context.Context) ([]domain.SigningKey, error) {
+	rows, err := r.conn(ctx).Query(ctx, "SELECT id, algorithm, private_key, created_at FROM oidc_signing_keys ORDER BY created_at DESC, id")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении ключей подписи: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make([]domain.SigningKey, 0)
+	for rows.Next() {
+		var key domain.SigningKey
+		if err := rows.Scan(&key.ID, &key.Algorithm, &key.PrivateKey, &key.CreatedAt); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении ключа подписи: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при получении ключей подписи: %w", err)
+	}
+	return keys, nil
+}
+
+func (r *OIDCRepository) CreateSigningKey(ctx context.Context, key *domain.SigningKey) error {
+	query := "INSERT INTO oidc_signing_keys (id, algorithm, private_key) VALUES ($1, $2, $3) RETURNING created_at"
+	if err := r.conn(ctx).QueryRow(ctx, query, key.ID, key.Algorithm, key.PrivateKey).Scan(&key.CreatedAt); err != nil {
+		return fmt.Errorf("ошибка при сохранении ключа подписи: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testovoe/internal/domain"
+	"time"
+)
+
+func TestOAuthClientRepository_Lifecycle(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewOAuthClientRepository(pool)
+
+	client := &domain.OAuthClient{ID: "tvc_web", Name: "Web", SecretHash: []byte("hash"), RedirectURIs: []string{"https://app.example.com/cb"}}
+	assert.NoError(t, repo.CreateOAuthClient(context.Background(), client))
+	assert.NoError(t, repo.CreateOAuthClient(context.Background(), &domain.OAuthClient{ID: "tvc_spa", Name: "SPA", RedirectURIs: []string{"app://cb"}}))
+
+	stored, err := repo.GetOAuthClient(context.Background(), "tvc_web")
+	assert.NoError(t, err)
+	assert.True(t, stored.Confidential)
+	assert.Equal(t, []string{"https://app.example.com/cb"}, stored.RedirectURIs)
+
+	clients, err := repo.ListOAuthClients(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, clients, 2)
+
+	deleted, err := repo.DeleteOAuthClient(context.Background(), "tvc_spa")
+	assert.NoError(t, err)
+	assert.False(t, deleted.Confidential)
+	_, err = repo.GetOAuthClient(context.Background(), "tvc_spa")
+	assert.ErrorIs(t, err, ErrOAuthClientNotFound)
+	_, err = repo.DeleteOAuthClient(context.Background(), "tvc_spa")
+	assert.ErrorIs(t, err, ErrOAuthClientNotFound)
+}
+
+func TestOIDCRepository_CodesAndTokens(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	users := NewUserRepository(pool)
+	clients := NewOAuthClientRepository(pool)
+	repo := NewOIDCRepository(pool)
+
+	user := &domain.User{Name: "Иван", Email: "ivan@example.com"}
+	assert.NoError(t, users.CreateUser(context.Background(), user))
+	assert.NoError(t, clients.CreateOAuthClient(context.Background(), &domain.OAuthClient{ID: "tvc_web", Name: "Web", RedirectURIs: []string{"https://app.example.com/cb"}}))
+
+	code := &domain.AuthorizationCode{
+		CodeHash: []byte("code"), ClientID: "tvc_web", UserID: user.ID, RedirectURI: "https://app.example.com/cb",
+		Scope: "openid", CodeChallenge: "challenge", AuthTime: time.Now(), ExpiresAt: time.Now().Add(time.Minute),
+	}
+	assert.NoError(t, repo.CreateAuthorizationCode(context.Background(), code))
+
+	claimed, err := repo.ClaimAuthorizationCode(context.Background(), []byte("code"))
+	assert.NoError(t, err)
+	assert.Nil(t, claimed.UsedAt)
+	claimed, err = repo.ClaimAuthorizationCode(context.Background(), []byte("code"))
+	assert.NoError(t, err)
+	assert.NotNil(t, claimed.UsedAt)
+	_, err = repo.ClaimAuthorizationCode(context.Background(), []byte("missing"))
+	assert.ErrorIs(t, err, ErrAuthorizationCodeNotFound)
+
+	for _, hash := range []string{"access", "refresh"} {
+		assert.NoError(t, repo.CreateOAuthToken(context.Background(), &domain.OAuthToken{
+			TokenHash: []byte(hash), Kind: hash, GrantID: code.ID, ClientID: "tvc_web", UserID: user.ID,
+			Scope: "openid", ExpiresAt: time.Now().Add(time.Hour),
+		}))
+	}
+	assert.NoError(t, repo.RevokeOAuthToken(context.Background(), []byte("access")))
+	token, err := repo.GetOAuthToken(context.Background(), []byte("access"))
+	assert.NoError(t, err)
+	assert.NotNil(t, token.RevokedAt)
+
+	assert.NoError(t, repo.RevokeOAuthGrant(context.Background(), code.ID))
+	token, err = repo.GetOAuthToken(context.Background(), []byte("refresh"))
+	assert.NoError(t, err)
+	assert.NotNil(t, token.RevokedAt)
+
+	// Удаление клиента удаляет его коды и токены.
+	_, err = clients.DeleteOAuthClient(context.Background(), "tvc_web")
+	assert.NoError(t, err)
+	_, err = repo.GetOAuthToken(context.Background(), []byte("refresh"))
+	assert.ErrorIs(t, err, ErrOAuthTokenNotFound)
+}
+
+func TestOIDCRepository_ClaimOAuthTokenLocks(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	users := NewUserRepository(pool)
+	clients := NewOAuthClientRepository(pool)
+	repo := NewOIDCRepository(pool)
+	tx := NewTransactor(pool)
+
+	user := &domain.User{Name: "Иван", Email: "ivan@example.com"}
+	assert.NoError(t, users.CreateUser(context.Background(), user))
+	assert.NoError(t, clients.CreateOAuthClient(context.Background(), &domain.OAuthClient{ID: "tvc_web", Name: "Web", RedirectURIs: []string{"https://app.example.com/cb"}}))
+	code := &domain.AuthorizationCode{
+		CodeHash: []byte("code"), ClientID: "tvc_web", UserID: user.ID, RedirectURI: "https://app.example.com/cb",
+		Scope: "openid", CodeChallenge: "challenge", AuthTime: time.Now(), ExpiresAt: time.Now().Add(time.Minute),
+	}
+	assert.NoError(t, repo.CreateAuthorizationCode(context.Background(), code))
+	assert.NoError(t, repo.CreateOAuthToken(context.Background(), &domain.OAuthToken{
+		TokenHash: []byte("refresh"), Kind: domain.OAuthTokenRefresh, GrantID: code.ID, ClientID: "tvc_web", UserID: user.ID,
+		Scope: "openid", ExpiresAt: time.Now().Add(time.Hour),
+	}))
+
+	// Второй обмен того же токена ждет, пока первый завершит транзакцию, и видит токен отозванным.
+	claimed, release := make(chan struct{}), make(chan struct{})
+	first := make(chan error, 1)
+	go func() {
+		first <- tx.WithinTx(context.Background(), func(ctx context.Context) error {
+			token, err := repo.ClaimOAuthToken(ctx, []byte("refresh"))
+			if err == nil && token.RevokedAt != nil {
+				err = errors.New("токен уже отозван")
+			}
+			close(claimed)
+			<-release
+			return err
+		})
+	}()
+	<-claimed
+
+	second := make(chan *domain.OAuthToken, 1)
+	go func() {
+		_ = tx.WithinTx(context.Background(), func(ctx context.Context) error {
+			token, err := repo.ClaimOAuthToken(ctx, []byte("refresh"))
+			second <- token
+			return err
+		})
+	}()
+	select {
+	case <-second:
+		t.Fatal("второй обмен не ждал блокировки токена")
+	case <-time.After(200 * time.Millisecond):
+	}
+	close(release)
+	assert.NoError(t, <-first)
+	token := <-second
+	if assert.NotNil(t, token) {
+		assert.NotNil(t, token.RevokedAt)
+	}
+}
+
+func TestOIDCRepository_SigningKeys(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewOIDCRepository(pool)
+
+	first := &domain.SigningKey{ID: "kid-1", Algorithm: "RS256", PrivateKey: "pem-1"}
+	assert.NoError(t, repo.CreateSigningKey(context.Background(), first))
+	_, err := pool.Exec(context.Background(), "UPDATE oidc_signing_keys SET created_at = created_at - INTERVAL '1 day' WHERE id = 'kid-1'")
+	assert.NoError(t, err)
+	assert.NoError(t, repo.CreateSigningKey(context.Background(), &domain.SigningKey{ID: "kid-2", Algorithm: "RS256", PrivateKey: "pem-2"}))
+
+	keys, err := repo.ListSigningKeys(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+	assert.Equal(t, "kid-2", keys[0].ID)
+	assert.Equal(t, "pem-1", keys[1].PrivateKey)
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"testovoe/internal/apperr"
+	"testovoe/internal/domain"
+	"time"
+)
+
+var ErrPasswordResetTokenNotFound = apperr.New(apperr.KindNotFound, "password_reset_token_not_found", "токен сброса пароля не найден")
+
+type PasswordResetRepositoryInterface interface {
+	CreatePasswordResetToken(ctx context.Context, token *domain.PasswordResetToken) error
+	LockPasswordResets(ctx context.Context, userID int64) error
+	CountPasswordResetTokensSince(ctx context.Context, userID int64, since time.Time) (int, error)
+	ClaimPasswordResetToken(ctx context.Context, hash []byte) (*domain.PasswordResetToken, error)
+	InvalidatePasswordResetTokens(ctx context.Context, userID int64) error
+}
+
+type PasswordResetRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPasswordResetRepository(db *pgxpool.Pool) *PasswordResetRepository {
+	return &PasswordResetRepository{db: db}
+}
+
+func (r *PasswordResetRepository) conn(ctx context.Context) querier {
+	return conn(ctx, r.db)
+}
+
+func (r *PasswordResetRepository) CreatePasswordResetToken(ctx context.Context, token *domain.PasswordResetToken) error {
+	query := `INSERT INTO password_reset_tokens (user_id, token_hash, expires_at) VALUES ($1, $2, $3)
+		RETURNING id, created_at`
+	if err := r.conn(ctx).QueryRow(ctx, query, token.UserID, token.TokenHash, token.ExpiresAt).Scan(&token.ID, &token.CreatedAt); err != nil {
+		return fmt.Errorf("ошибка при создании токена сброса пароля: %w", err)
+	}
+	return nil
+}
+
+// LockPasswordResets блокирует строку пользователя до конца транзакции, чтобы параллельные запросы
+// сброса пароля подсчитывали и выпускали токены по очереди.
+func (r *PasswordResetRepository) LockPasswordResets(ctx context.Context, userID int64) error {
+	var id int64
+	err := r.conn(ctx).QueryRow(ctx, "SELECT id FROM users WHERE id = $1 FOR NO KEY UPDATE", userID).Scan(&id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("ошибка при блокировке пользователя: %w", err)
+	}
+	return nil
+}
+
+func (r *PasswordResetRepository) CountPasswordResetTokensSince(ctx context.Context, userID int64, since time.Time) (int, error) {
+	query := "SELECT COUNT(*) FROM password_reset_tokens WHERE user_id = $1 AND created_at >= $2"
+	var count int
+	if err := r.conn(ctx).QueryRow(ctx, query, userID, since).Scan(&count); err != nil {
+		return 0, fmt.Errorf("ошибка при подсчете токенов сброса пароля: %w", err)
+	}
+	return count, nil
+}
+
+// ClaimPasswordResetToken одним запросом проверяет, что токен не использован и не истек,
+// и помечает его использованным, поэтому токен нельзя применить дважды даже параллельно.
+func (r *PasswordResetRepository) ClaimPasswordResetToken(ctx context.Context, hash []byte) (*domain.PasswordResetToken, error) {
+	query := `UPDATE password_reset_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING id, user_id, token_hash, created_at, expires_at, used_at`
+	var token domain.PasswordResetToken
+	err := r.conn(ctx).QueryRow(ctx, query, hash).
+		Scan(&token.ID, &token.UserID, &token.TokenHash, &token.CreatedAt, &token.ExpiresAt, &token.UsedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrPasswordResetTokenNotFound
+		}
+		return nil, fmt.Errorf("ошибка при использовании токена сброса пароля: %w", err)
+	}
+	return &token, nil
+}
+
+// InvalidatePasswordResetTokens погашает все неиспользованные токены пользователя.
+func (r *PasswordResetRepository) InvalidatePasswordResetTokens(ctx context.Context, userID int64) error {
+	query := "UPDATE password_reset_tokens SET used_at = NOW() WHERE user_id = $1 AND used_at IS NULL"
+	if _, err := r.conn(ctx).Exec(ctx, query, userID); err != nil {
+		return fmt.Errorf("ошибка при отзыве токенов сброса пароля: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testovoe/internal/domain"
+	"time"
+)
+
+func TestPasswordResetRepository_Lifecycle(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	users := NewUserRepository(pool)
+	repo := NewPasswordResetRepository(pool)
+
+	user := &domain.User{Name: "Иван", Email: "ivan@example.com"}
+	assert.NoError(t, users.CreateUser(context.Background(), user))
+
+	first := &domain.PasswordResetToken{UserID: user.ID, TokenHash: []byte("hash-1"), ExpiresAt: time.Now().Add(time.Hour)}
+	second := &domain.PasswordResetToken{UserID: user.ID, TokenHash: []byte("hash-2"), ExpiresAt: time.Now().Add(time.Hour)}
+	expired := &domain.PasswordResetToken{UserID: user.ID, TokenHash: []byte("hash-3"), ExpiresAt: time.Now().Add(-time.Minute)}
+	for _, token := range []*domain.PasswordResetToken{first, second, expired} {
+		assert.NoError(t, repo.CreatePasswordResetToken(context.Background(), token))
+		assert.NotZero(t, token.ID)
+	}
+
+	assert.NoError(t, repo.LockPasswordResets(context.Background(), user.ID))
+	assert.ErrorIs(t, repo.LockPasswordResets(context.Background(), user.ID+1000), ErrUserNotFound)
+
+	count, err := repo.CountPasswordResetTokensSince(context.Background(), user.ID, time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	_, err = repo.ClaimPasswordResetToken(context.Background(), []byte("hash-3"))
+	assert.ErrorIs(t, err, ErrPasswordResetTokenNotFound)
+
+	claimed, err := repo.ClaimPasswordResetToken(context.Background(), []byte("hash-1"))
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, claimed.UserID)
+	assert.NotNil(t, claimed.UsedAt)
+
+	_, err = repo.ClaimPasswordResetToken(context.Background(), []byte("hash-1"))
+	assert.ErrorIs(t, err, ErrPasswordResetTokenNotFound)
+
+	assert.NoError(t, repo.InvalidatePasswordResetTokens(context.Background(), user.ID))
+	_, err = repo.ClaimPasswordResetToken(context.Background(), []byte("hash-2"))
+	assert.ErrorIs(t, err, ErrPasswordResetTokenNotFound)
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"testovoe/internal/domain"
+	"time"
+)
+
+// RateLimitRepository хранит бакеты ограничения частоты запросов в Postgres, чтобы квоты
+// соблюдались сразу на всех экземплярах приложения.
+type RateLimitRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewRateLimitRepository(db *pgxpool.Pool) *RateLimitRepository {
+	return &RateLimitRepository{db: db}
+}
+
+func (r *RateLimitRepository) conn(ctx context.Context) querier {
+	return conn(ctx, r.db)
+}
+
+// rateLimitAvailable — сколько запросов накопилось в бакете к текущему моменту. Время берется
+// из базы, чтобы расхождение часов между экземплярами не влияло на квоту.
+const rateLimitAvailable = `LEAST($2::float8, bucket.tokens +
+	GREATEST(EXTRACT(EPOCH FROM NOW() - bucket.updated_at)::float8, 0) * $3::float8)`
+
+// Take атомарно пополняет бакет и расходует из него один запрос, если он есть.
+func (r *RateLimitRepository) Take(ctx context.Context, key string, limit domain.RateLimit) (*domain.RateLimitResult, error) {
+	query := `INSERT INTO rate_limit_buckets AS bucket (key, tokens, allowed, updated_at) VALUES ($1, $2::float8 - 1, TRUE, NOW())
+		ON CONFLICT (key) DO UPDATE SET
+			tokens = CASE WHEN ` + rateLimitAvailable + ` >= 1 THEN ` + rateLimitAvailable + ` - 1 ELSE ` + rateLimitAvailable + ` END,
+			allowed = ` + rateLimitAvailable + ` >= 1,
+			updated_at = NOW()
+		RETURNING tokens, allowed`
+	var tokens float64
+	var allowed bool
+	err := r.conn(ctx).QueryRow(ctx, query, key, float64(limit.Requests), limit.Rate()).Scan(&tokens, &allowed)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при проверке ограничения частоты запросов: %w", err)
+	}
+	return limit.Result(tokens, allowed), nil
+}
+
+func (r *RateLimitRepository) Refund(ctx context.Context, key string, limit domain.RateLimit) error {
+	query := "UPDATE rate_limit_buckets SET tokens = LEAST($2::float8, tokens + 1) WHERE key = $1"
+	if _, err := r.conn(ctx).Exec(ctx, query, key, float64(limit.Requests)); err != nil {
+		return fmt.Errorf("ошибка при возврате запроса в квоту: %w", err)
+	}
+	return nil
+}
+
+func (r *RateLimitRepository) Prune(ctx context.Context, before time.Time) error {
+	if _, err := r.conn(ctx).Exec(ctx, "DELETE FROM rate_limit_buckets WHERE updated_at < $1", before); err != nil {
+		return fmt.Errorf("ошибка при удалении устаревших ограничений частоты запросов: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testovoe/internal/domain"
+	"time"
+)
+
+func TestRateLimitRepository_Take(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewRateLimitRepository(pool)
+	ctx := context.Background()
+	limit := domain.RateLimit{Requests: 2, Period: time.Hour}
+
+	result, err := repo.Take(ctx, "ip:10.0.0.1", limit)
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 1, result.Remaining)
+
+	result, err = repo.Take(ctx, "ip:10.0.0.1", limit)
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+
+	result, err = repo.Take(ctx, "ip:10.0.0.1", limit)
+	assert.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Greater(t, result.RetryAfter, time.Duration(0))
+
+	result, err = repo.Take(ctx, "ip:10.0.0.2", limit)
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	assert.NoError(t, repo.Refund(ctx, "ip:10.0.0.2", limit))
+	result, err = repo.Take(ctx, "ip:10.0.0.2", limit)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Remaining)
+
+	assert.NoError(t, repo.Prune(ctx, time.Now().Add(time.Hour)))
+	result, err = repo.Take(ctx, "ip:10.0.0.1", limit)
+	assert.NoError(t, err)
+	assert.True(t, result.Allowed)
+}
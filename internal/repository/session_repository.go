@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"testovoe/internal/apperr"
+	"testovoe/internal/domain"
+	"time"
+)
+
+var ErrSessionNotFound = apperr.New(apperr.KindNotFound, "session_not_found", "сессия не найдена")
+
+type SessionRepositoryInterface interface {
+	CreateSession(ctx context.Context, session *domain.Session) error
+	GetSession(ctx context.Context, userID, id int64) (*domain.Session, error)
+	ListActiveSessions(ctx context.Context, userID int64) ([]domain.Session, error)
+	IsSessionActive(ctx context.Context, id int64) (bool, error)
+	TouchSession(ctx context.Context, id int64, meta domain.SessionMeta, expiresAt time.Time) error
+	RevokeSession(ctx context.Context, userID, id int64, reason string) (*domain.Session, error)
+	CreateSessionToken(ctx context.Context, sessionID int64, hash []byte) error
+	ClaimSessionToken(ctx context.Context, hash []byte) (*domain.Session, bool, error)
+}
+
+type SessionRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewSessionRepository(db *pgxpool.Pool) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+func (r *SessionRepository) conn(ctx context.Context) querier {
+	return conn(ctx, r.db)
+}
+
+const sessionColumns = "id, user_id, user_agent, ip, created_at, last_used_at, expires_at, revoked_at, COALESCE(revoke_reason, '')"
+
+func scanSession(row pgx.Row) (*domain.Session, error) {
+	var session domain.Session
+	err := row.Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IP, &session.CreatedAt,
+		&session.LastUsedAt, &session.ExpiresAt, &session.RevokedAt, &session.RevokeReason)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *SessionRepository) CreateSession(ctx context.Context, session *domain.Session) error {
+	query := `INSERT INTO sessions (user_id, user_agent, ip, expires_at) VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, last_used_at`
+	err := r.conn(ctx).QueryRow(ctx, query, session.UserID, session.UserAgent, session.IP, session.ExpiresAt).
+		Scan(&session.ID, &session.CreatedAt, &session.LastUsedAt)
+	if err != nil {
+		return fmt.Errorf("ошибка при создании сессии: %w", err)
+	}
+	return nil
+}
+
+func (r *SessionRepository) GetSession(ctx context.Context, userID, id int64) (*domain.Session, error) {
+	query := "SELECT " + sessionColumns + " FROM sessions WHERE id = $1 AND user_id = $2"
+	session, err := scanSession(r.conn(ctx).QueryRow(ctx, query, id, userID))
+	if err != nil && !errors.Is(err, ErrSessionNotFound) {
+		return nil, fmt.Errorf("ошибка при получении сессии: %w", err)
+	}
+	return session, err
+}
+
+func (r *SessionRepository) ListActiveSessions(ctx context.Context, userID int64) ([]domain.Session, error) {
+	query := "SELECT " + sessionColumns + ` FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW() ORDER BY last_used_at DESC, id DESC`
+	rows, err := r.conn(ctx).Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении сессий: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := make([]domain.Session, 0)
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при чтении сессии: %w", err)
+		}
+		sessions = append(sessions, *session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при получении сессий: %w", err)
+	}
+	return sessions, nil
+}
+
+func (r *SessionRepository) IsSessionActive(ctx context.Context, id int64) (bool, error) {
+	query := "SELECT EXISTS (SELECT 1 FROM sessions WHERE id = $1 AND revoked_at IS NULL AND expires_at > NOW())"
+	var active bool
+	if err := r.conn(ctx).QueryRow(ctx, query, id).Scan(&active); err != nil {
+		return false, fmt.Errorf("ошибка при проверке сессии: %w", err)
+	}
+	return active, nil
+}
+
+// TouchSession продлевает сессию и запоминает, с какого устройства и адреса она использовалась последней.
+func (r *SessionRepository) TouchSession(ctx context.Context, id int64, meta domain.SessionMeta, expiresAt time.Time) error {
+	query := "UPDATE sessions SET user_agent = $1, ip = $2, last_used_at = NOW(), expires_at = $3 WHERE id = $4"
+	if _, err := r.conn(ctx).Exec(ctx, query, meta.UserAgent, meta.IP, expiresAt, id); err != nil {
+		return fmt.Errorf("ошибка при обновлении сессии: %w", err)
+	}
+	return nil
+}
+
+func (r *SessionRepository) RevokeSession(ctx context.Context, userID, id int64, reason string) (*domain.Session, error) {
+	query := `UPDATE sessions SET revoked_at = NOW(), revoke_reason = $1
+		WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL RETURNING ` + sessionColumns
+	session, err := scanSession(r.conn(ctx).QueryRow(ctx, query, reason, id, userID))
+	if err != nil && !errors.Is(err, ErrSessionNotFound) {
+		return nil, fmt.Errorf("ошибка при отзыве сессии: %w", err)
+	}
+	return session, err
+}
+
+func (r *SessionRepository) CreateSessionToken(ctx context.Context, sessionID int64, hash []byte) error {
+	query := "INSERT INTO session_tokens (token_hash, session_id) VALUES ($1, $2)"
+	if _, err := r.conn(ctx).Exec(ctx, query, hash, sessionID); err != nil {
+		return fmt.Errorf("ошибка при сохранении refresh-токена: %w", err)
+	}
+	return nil
+}
+
+// ClaimSessionToken помечает refresh-токен использованным и возвращает его сессию.
+// Второй результат сообщает, что токен уже был использован раньше. Строка токена блокируется
+// до конца транзакции, поэтому из двух одновременных обновлений одним токеном успешно только одно.
+func (r *SessionRepository) ClaimSessionToken(ctx context.Context, hash []byte) (*domain.Session, bool, error) {
+	var sessionID int64
+	var usedAt *time.Time
+	query := "SELECT session_id, used_at FROM session_tokens WHERE token_hash = $1 FOR UPDATE"
+	if err := r.conn(ctx).QueryRow(ctx, query, hash).Scan(&sessionID, &usedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, ErrSessionNotFound
+		}
+		return nil, false, fmt.Errorf("ошибка при получении refresh-токена: %w", err)
+	}
+
+	if usedAt == nil {
+		if _, err := r.conn(ctx).Exec(ctx, "UPDATE session_tokens SET used_at = NOW() WHERE token_hash = $1", hash); err != nil {
+			return nil, false, fmt.Errorf("ошибка при обновлении refresh-токена: %w", err)
+		}
+	}
+
+	session, err := scanSession(r.conn(ctx).QueryRow(ctx, "SELECT "+sessionColumns+" FROM sessions WHERE id = $1", sessionID))
+	if err != nil {
+		if errors.Is(err, ErrSessionNotFound) {
+			return nil, false, err
+		}
+		return nil, false, fmt.Errorf("ошибка при получении сессии: %w", err)
+	}
+	return session, usedAt != nil, nil
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testovoe/internal/domain"
+	"time"
+)
+
+func TestSessionRepository_TokenRotation(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	users := NewUserRepository(pool)
+	repo := NewSessionRepository(pool)
+
+	user := &domain.User{Name: "Иван", Email: "ivan@example.com"}
+	assert.NoError(t, users.CreateUser(context.Background(), user))
+
+	session := &domain.Session{UserID: user.ID, UserAgent: "curl/8.0", IP: "10.0.0.1", ExpiresAt: time.Now().Add(time.Hour)}
+	assert.NoError(t, repo.CreateSession(context.Background(), session))
+	assert.NotZero(t, session.ID)
+	assert.NoError(t, repo.CreateSessionToken(context.Background(), session.ID, []byte("hash-1")))
+
+	claimed, used, err := repo.ClaimSessionToken(context.Background(), []byte("hash-1"))
+	assert.NoError(t, err)
+	assert.False(t, used)
+	assert.Equal(t, session.ID, claimed.ID)
+
+	_, used, err = repo.ClaimSessionToken(context.Background(), []byte("hash-1"))
+	assert.NoError(t, err)
+	assert.True(t, used)
+
+	_, _, err = repo.ClaimSessionToken(context.Background(), []byte("unknown"))
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+
+	assert.NoError(t, repo.TouchSession(context.Background(), session.ID, domain.SessionMeta{UserAgent: "Firefox", IP: "10.0.0.2"}, time.Now().Add(2*time.Hour)))
+	list, err := repo.ListActiveSessions(context.Background(), user.ID)
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	assert.Equal(t, "Firefox", list[0].UserAgent)
+
+	active, err := repo.IsSessionActive(context.Background(), session.ID)
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	revoked, err := repo.RevokeSession(context.Background(), user.ID, session.ID, domain.SessionRevokedLogout)
+	assert.NoError(t, err)
+	assert.Equal(t, domain.SessionRevokedLogout, revoked.RevokeReason)
+	_, err = repo.RevokeSession(context.Background(), user.ID, session.ID, domain.SessionRevokedLogout)
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+
+	active, err = repo.IsSessionActive(context.Background(), session.ID)
+	assert.NoError(t, err)
+	assert.False(t, active)
+	list, err = repo.ListActiveSessions(context.Background(), user.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+}
@@ -6,16 +6,39 @@ import (
 	"fmt"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"strings"
+	"testovoe/internal/apperr"
 	"testovoe/internal/domain"
 )
 
-var ErrUserNotFound = errors.New("пользователь не найден")
+var ErrUserNotFound = apperr.New(apperr.KindNotFound, "user_not_found", "пользователь не найден")
+var ErrEmailTaken = apperr.New(apperr.KindConflict, "email_taken", "email уже используется")
+var ErrVersionConflict = apperr.New(apperr.KindPreconditionFailed, "version_conflict", "версия пользователя устарела")
 
 type UserRepositoryInterface interface {
 	CreateUser(ctx context.Context, user *domain.User) error
 	GetUserByID(ctx context.Context, id int64) (*domain.User, error)
 	UpdateUserByID(ctx context.Context, id int64, user *domain.User) error
-	DeleteUserByID(ctx context.Context, id int64) error
+	PatchUserByID(ctx context.Context, id int64, version int64, patch domain.UserPatch) (int64, error)
+	DeleteUserByID(ctx context.Context, id int64, version int64) error
+	RestoreUserByID(ctx context.Context, id int64) (*domain.User, error)
+	PurgeUserByID(ctx context.Context, id int64) (*domain.User, error)
+	ListUsers(ctx context.Context, filter domain.UserFilter) (*domain.UserList, error)
+	ExportUsers(ctx context.Context, filter domain.UserFilter, fn func(user *domain.User) error) error
+	FindExistingEmails(ctx context.Context, emails []string) (map[string]bool, error)
+	CopyUsers(ctx context.Context, users []domain.User) (int64, error)
+	GetUsersByEmails(ctx context.Context, emails []string) ([]domain.User, error)
+	GetUserRoles(ctx context.Context, userID int64) ([]domain.Role, error)
+	GetAssignedRoles(ctx context.Context, userID int64) ([]domain.Role, error)
+	SetUserRoles(ctx context.Context, userID int64, roles []domain.Role) error
+	HasUsersWithRole(ctx context.Context, role domain.Role) (bool, error)
+	MarkEmailVerified(ctx context.Context, id int64, email string) (*domain.User, error)
+}
+
+var userSortColumns = map[string]string{
+	domain.UserSortByID:    "id",
+	domain.UserSortByName:  "name",
+	domain.UserSortByEmail: "email",
 }
 
 type UserRepository struct {
@@ -26,19 +49,26 @@ func NewUserRepository(db *pgxpool.Pool) *UserRepository {
 	return &UserRepository{db: db}
 }
 
+func (r *UserRepository) conn(ctx context.Context) querier {
+	return conn(ctx, r.db)
+}
+
 func (r *UserRepository) CreateUser(ctx context.Context, user *domain.User) error {
-	query := "INSERT INTO users (name, email) VALUES ($1, $2) RETURNING id"
-	if err := r.db.QueryRow(ctx, query, user.Name, user.Email).Scan(&user.ID); err != nil {
+	query := "INSERT INTO users (name, email) VALUES ($1, $2) RETURNING id, version"
+	if err := r.conn(ctx).QueryRow(ctx, query, user.Name, user.Email).Scan(&user.ID, &user.Version); err != nil {
+		if err := constraintError(err, ErrEmailTaken); err != nil {
+			return err
+		}
 		return fmt.Errorf("ошибка при создании пользователя: %w", err)
 	}
 	return nil
 }
 
 func (r *UserRepository) GetUserByID(ctx context.Context, id int64) (*domain.User, error) {
-	query := "SELECT id, name, email FROM users WHERE id = $1"
+	query := "SELECT id, name, email, version, email_verified_at FROM users WHERE id = $1 AND deleted_at IS NULL"
 
 	var user domain.User
-	if err := r.db.QueryRow(ctx, query, id).Scan(&user.ID, &user.Name, &user.Email); err != nil {
+	if err := r.conn(ctx).QueryRow(ctx, query, id).Scan(&user.ID, &user.Name, &user.Email, &user.Version, &user.EmailVerifiedAt); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, ErrUserNotFound
 		}
@@ -48,25 +78,369 @@ func (r *UserRepository) GetUserByID(ctx context.Context, id int64) (*domain.Use
 }
 
 func (r *UserRepository) UpdateUserByID(ctx context.Context, id int64, user *domain.User) error {
-	query := "UPDATE users SET name = $1, email = $2 WHERE id = $3"
-	cmdTag, err := r.db.Exec(ctx, query, user.Name, user.Email, id)
-	if err != nil {
+	// При смене email подтверждение сбрасывается: новый адрес нужно подтвердить заново.
+	query := `UPDATE users SET name = $1, email = $2, version = version + 1,
+		email_verified_at = CASE WHEN email = $2 THEN email_verified_at END
+		WHERE id = $3 AND version = $4 AND deleted_at IS NULL RETURNING version, email_verified_at`
+	if err := r.conn(ctx).QueryRow(ctx, query, user.Name, user.Email, id, user.Version).Scan(&user.Version, &user.EmailVerifiedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return r.versionMismatch(ctx, id)
+		}
+		if err := constraintError(err, ErrEmailTaken); err != nil {
+			return err
+		}
 		return fmt.Errorf("ошибка при обновлении пользователя с id %d: %w", id, err)
 	}
-	if cmdTag.RowsAffected() == 0 {
-		return ErrUserNotFound
-	}
 	return nil
 }
 
-func (r *UserRepository) DeleteUserByID(ctx context.Context, id int64) error {
-	query := "DELETE FROM users WHERE id = $1"
-	cmdTag, err := r.db.Exec(ctx, query, id)
+func (r *UserRepository) PatchUserByID(ctx context.Context, id int64, version int64, patch domain.UserPatch) (int64, error) {
+	var assignments []string
+	var args []any
+
+	if patch.Name != nil {
+		args = append(args, *patch.Name)
+		assignments = append(assignments, fmt.Sprintf("name = $%d", len(args)))
+	}
+	if patch.Email != nil {
+		args = append(args, *patch.Email)
+		assignments = append(assignments, fmt.Sprintf("email = $%d", len(args)),
+			fmt.Sprintf("email_verified_at = CASE WHEN email = $%d THEN email_verified_at END", len(args)))
+	}
+	if len(assignments) == 0 {
+		return version, nil
+	}
+
+	args = append(args, id, version)
+	query := fmt.Sprintf("UPDATE users SET %s, version = version + 1 WHERE id = $%d AND version = $%d AND deleted_at IS NULL RETURNING version",
+		strings.Join(assignments, ", "), len(args)-1, len(args))
+	var newVersion int64
+	if err := r.conn(ctx).QueryRow(ctx, query, args...).Scan(&newVersion); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, r.versionMismatch(ctx, id)
+		}
+		if err := constraintError(err, ErrEmailTaken); err != nil {
+			return 0, err
+		}
+		return 0, fmt.Errorf("ошибка при обновлении пользователя с id %d: %w", id, err)
+	}
+	return newVersion, nil
+}
+
+func (r *UserRepository) DeleteUserByID(ctx context.Context, id int64, version int64) error {
+	query := "UPDATE users SET deleted_at = NOW(), version = version + 1 WHERE id = $1 AND version = $2 AND deleted_at IS NULL"
+	cmdTag, err := r.conn(ctx).Exec(ctx, query, id, version)
 	if err != nil {
 		return fmt.Errorf("ошибка при удалении пользователя с id %d: %w", id, err)
 	}
 	if cmdTag.RowsAffected() == 0 {
-		return ErrUserNotFound
+		return r.versionMismatch(ctx, id)
+	}
+	return nil
+}
+
+func (r *UserRepository) RestoreUserByID(ctx context.Context, id int64) (*domain.User, error) {
+	query := `UPDATE users SET deleted_at = NULL, version = version + 1
+		WHERE id = $1 AND deleted_at IS NOT NULL RETURNING id, name, email, version, email_verified_at`
+
+	var user domain.User
+	if err := r.conn(ctx).QueryRow(ctx, query, id).Scan(&user.ID, &user.Name, &user.Email, &user.Version, &user.EmailVerifiedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		if err := constraintError(err, ErrEmailTaken); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("ошибка при восстановлении пользователя с id %d: %w", id, err)
+	}
+	return &user, nil
+}
+
+func (r *UserRepository) PurgeUserByID(ctx context.Context, id int64) (*domain.User, error) {
+	query := "DELETE FROM users WHERE id = $1 RETURNING id, name, email, version, email_verified_at, deleted_at"
+
+	var user domain.User
+	if err := r.conn(ctx).QueryRow(ctx, query, id).Scan(&user.ID, &user.Name, &user.Email, &user.Version, &user.EmailVerifiedAt, &user.DeletedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("ошибка при окончательном удалении пользователя с id %d: %w", id, err)
+	}
+	return &user, nil
+}
+
+func (r *UserRepository) ListUsers(ctx context.Context, filter domain.UserFilter) (*domain.UserList, error) {
+	column, ok := userSortColumns[filter.SortBy]
+	if !ok {
+		column = "id"
+	}
+	direction, comparison := "ASC", ">"
+	if filter.SortOrder == domain.SortDesc {
+		direction, comparison = "DESC", "<"
+	}
+
+	conditions, args := userFilterConditions(filter)
+
+	list := &domain.UserList{Limit: filter.Limit, Offset: filter.Offset}
+	if filter.After == nil {
+		var total int64
+		countQuery := "SELECT COUNT(*) FROM users" + whereClause(conditions)
+		if err := r.conn(ctx).QueryRow(ctx, countQuery, args...).Scan(&total); err != nil {
+			return nil, fmt.Errorf("ошибка при подсчете пользователей: %w", err)
+		}
+		list.Total = &total
+	} else if column == "id" {
+		args = append(args, filter.After.ID)
+		conditions = append(conditions, fmt.Sprintf("id %s $%d", comparison, len(args)))
+	} else {
+		args = append(args, filter.After.Value, filter.After.ID)
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s ($%d, $%d)", column, comparison, len(args)-1, len(args)))
+	}
+
+	args = append(args, filter.Limit+1, filter.Offset)
+	query := fmt.Sprintf("SELECT id, name, email, version, email_verified_at, deleted_at FROM users%s ORDER BY %s %s, id %s LIMIT $%d OFFSET $%d",
+		whereClause(conditions), column, direction, direction, len(args)-1, len(args))
+	rows, err := r.conn(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении списка пользователей: %w", err)
+	}
+	defer rows.Close()
+
+	list.Users = make([]domain.User, 0, filter.Limit+1)
+	for rows.Next() {
+		var user domain.User
+		if err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.Version, &user.EmailVerifiedAt, &user.DeletedAt); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении пользователя: %w", err)
+		}
+		list.Users = append(list.Users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при получении списка пользователей: %w", err)
+	}
+
+	if len(list.Users) > filter.Limit {
+		list.Users = list.Users[:filter.Limit]
+		list.HasMore = true
+	}
+	return list, nil
+}
+
+func (r *UserRepository) ExportUsers(ctx context.Context, filter domain.UserFilter, fn func(user *domain.User) error) error {
+	column, ok := userSortColumns[filter.SortBy]
+	if !ok {
+		column = "id"
+	}
+	direction := "ASC"
+	if filter.SortOrder == domain.SortDesc {
+		direction = "DESC"
+	}
+
+	conditions, args := userFilterConditions(filter)
+	query := fmt.Sprintf("SELECT id, name, email, version, email_verified_at, deleted_at FROM users%s ORDER BY %s %s, id %s",
+		whereClause(conditions), column, direction, direction)
+	rows, err := r.conn(ctx).Query(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("ошибка при выгрузке пользователей: %w", err)
+	}
+	defer rows.Close()
+
+	var user domain.User
+	for rows.Next() {
+		user = domain.User{}
+		if err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.Version, &user.EmailVerifiedAt, &user.DeletedAt); err != nil {
+			return fmt.Errorf("ошибка при чтении пользователя: %w", err)
+		}
+		if err := fn(&user); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("ошибка при выгрузке пользователей: %w", err)
 	}
 	return nil
 }
+
+func (r *UserRepository) FindExistingEmails(ctx context.Context, emails []string) (map[string]bool, error) {
+	query := "SELECT email FROM users WHERE email = ANY($1) AND deleted_at IS NULL"
+	rows, err := r.conn(ctx).Query(ctx, query, emails)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при проверке существующих email: %w", err)
+	}
+	defer rows.Close()
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении email: %w", err)
+		}
+		existing[email] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при проверке существующих email: %w", err)
+	}
+	return existing, nil
+}
+
+func (r *UserRepository) CopyUsers(ctx context.Context, users []domain.User) (int64, error) {
+	count, err := r.conn(ctx).CopyFrom(ctx, pgx.Identifier{"users"}, []string{"name", "email"},
+		pgx.CopyFromSlice(len(users), func(i int) ([]any, error) {
+			return []any{users[i].Name, users[i].Email}, nil
+		}))
+	if err != nil {
+		if err := constraintError(err, ErrEmailTaken); err != nil {
+			return 0, err
+		}
+		return 0, fmt.Errorf("ошибка при массовой загрузке пользователей: %w", err)
+	}
+	return count, nil
+}
+
+func (r *UserRepository) GetUsersByEmails(ctx context.Context, emails []string) ([]domain.User, error) {
+	query := "SELECT id, name, email, version, email_verified_at FROM users WHERE email = ANY($1) AND deleted_at IS NULL ORDER BY id"
+	rows, err := r.conn(ctx).Query(ctx, query, emails)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении пользователей по email: %w", err)
+	}
+	defer rows.Close()
+
+	users := make([]domain.User, 0, len(emails))
+	for rows.Next() {
+		var user domain.User
+		if err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.Version, &user.EmailVerifiedAt); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении пользователя: %w", err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при получении пользователей по email: %w", err)
+	}
+	return users, nil
+}
+
+// GetUserRoles возвращает роли пользователя; у удаленного пользователя ролей нет.
+func (r *UserRepository) GetUserRoles(ctx context.Context, userID int64) ([]domain.Role, error) {
+	query := `SELECT ur.role FROM user_roles ur JOIN users u ON u.id = ur.user_id
+		WHERE ur.user_id = $1 AND u.deleted_at IS NULL ORDER BY ur.role`
+	return r.queryRoles(ctx, query, userID)
+}
+
+// GetAssignedRoles возвращает роли, назначенные пользователю, в том числе удаленному: они вернутся
+// к нему после восстановления.
+func (r *UserRepository) GetAssignedRoles(ctx context.Context, userID int64) ([]domain.Role, error) {
+	return r.queryRoles(ctx, "SELECT role FROM user_roles WHERE user_id = $1 ORDER BY role", userID)
+}
+
+func (r *UserRepository) queryRoles(ctx context.Context, query string, userID int64) ([]domain.Role, error) {
+	rows, err := r.conn(ctx).Query(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении ролей пользователя: %w", err)
+	}
+	defer rows.Close()
+
+	roles := make([]domain.Role, 0)
+	for rows.Next() {
+		var role domain.Role
+		if err := rows.Scan(&role); err != nil {
+			return nil, fmt.Errorf("ошибка при чтении роли: %w", err)
+		}
+		roles = append(roles, role)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка при получении ролей пользователя: %w", err)
+	}
+	return roles, nil
+}
+
+func (r *UserRepository) SetUserRoles(ctx context.Context, userID int64, roles []domain.Role) error {
+	names := make([]string, len(roles))
+	for i, role := range roles {
+		names[i] = string(role)
+	}
+
+	if _, err := r.conn(ctx).Exec(ctx, "DELETE FROM user_roles WHERE user_id = $1 AND NOT (role = ANY($2))", userID, names); err != nil {
+		return fmt.Errorf("ошибка при изменении ролей пользователя: %w", err)
+	}
+	query := `INSERT INTO user_roles (user_id, role) SELECT $1, unnest($2::varchar[])
+		ON CONFLICT (user_id, role) DO NOTHING`
+	if _, err := r.conn(ctx).Exec(ctx, query, userID, names); err != nil {
+		return fmt.Errorf("ошибка при изменении ролей пользователя: %w", err)
+	}
+	return nil
+}
+
+// HasUsersWithRole сообщает, есть ли неудаленные пользователи с ролью role.
+func (r *UserRepository) HasUsersWithRole(ctx context.Context, role domain.Role) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM user_roles ur JOIN users u ON u.id = ur.user_id
+		WHERE ur.role = $1 AND u.deleted_at IS NULL)`
+	var exists bool
+	if err := r.conn(ctx).QueryRow(ctx, query, string(role)).Scan(&exists); err != nil {
+		return false, fmt.Errorf("ошибка при проверке ролей пользователей: %w", err)
+	}
+	return exists, nil
+}
+
+// MarkEmailVerified подтверждает email пользователя, если адрес не изменился с момента выпуска токена.
+// Уже подтвержденный адрес не меняется, версия при этом не увеличивается.
+func (r *UserRepository) MarkEmailVerified(ctx context.Context, id int64, email string) (*domain.User, error) {
+	query := `UPDATE users SET email_verified_at = COALESCE(email_verified_at, NOW()),
+		version = CASE WHEN email_verified_at IS NULL THEN version + 1 ELSE version END
+		WHERE id = $1 AND email = $2 AND deleted_at IS NULL RETURNING id, name, email, version, email_verified_at`
+
+	var user domain.User
+	if err := r.conn(ctx).QueryRow(ctx, query, id, email).Scan(&user.ID, &user.Name, &user.Email, &user.Version, &user.EmailVerifiedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("ошибка при подтверждении email пользователя с id %d: %w", id, err)
+	}
+	return &user, nil
+}
+
+func (r *UserRepository) versionMismatch(ctx context.Context, id int64) error {
+	query := "SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND deleted_at IS NULL)"
+
+	var exists bool
+	if err := r.conn(ctx).QueryRow(ctx, query, id).Scan(&exists); err != nil {
+		return fmt.Errorf("ошибка при проверке версии пользователя с id %d: %w", id, err)
+	}
+	if exists {
+		return ErrVersionConflict
+	}
+	return ErrUserNotFound
+}
+
+func userFilterConditions(filter domain.UserFilter) ([]string, []any) {
+	var conditions []string
+	var args []any
+
+	switch filter.Deleted {
+	case domain.DeletedOnly:
+		conditions = append(conditions, "deleted_at IS NOT NULL")
+	case domain.DeletedInclude:
+	default:
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+
+	if filter.Name != "" {
+		args = append(args, "%"+escapeLike(filter.Name)+"%")
+		conditions = append(conditions, fmt.Sprintf("name ILIKE $%d", len(args)))
+	}
+	if filter.Email != "" {
+		args = append(args, "%"+escapeLike(filter.Email)+"%")
+		conditions = append(conditions, fmt.Sprintf("email ILIKE $%d", len(args)))
+	}
+
+	return conditions, args
+}
+
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
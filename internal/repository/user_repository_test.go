@@ -2,11 +2,15 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/stretchr/testify/assert"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/modules/postgres"
 	"github.com/testcontainers/testcontainers-go/wait"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
 	"testovoe/internal/domain"
@@ -41,16 +45,7 @@ func setupTestDB(t *testing.T) (*pgxpool.Pool, func()) {
 	if err != nil {
 		t.Fatalf("не удалось подключиться к базе: %v", err)
 	}
-	_, err = pool.Exec(ctx, `
-		CREATE TABLE users (
-			id BIGSERIAL PRIMARY KEY,
-			name VARCHAR(100) NOT NULL,
-			email VARCHAR(100) UNIQUE NOT NULL
-		)
-	`)
-	if err != nil {
-		t.Fatalf("не получилось создать таблицу: %v", err)
-	}
+	applyMigrations(t, pool)
 
 	cleanup := func() {
 		pool.Close()
@@ -60,6 +55,24 @@ func setupTestDB(t *testing.T) (*pgxpool.Pool, func()) {
 	return pool, cleanup
 }
 
+func applyMigrations(t *testing.T, pool *pgxpool.Pool) {
+	files, err := filepath.Glob(filepath.Join("..", "..", "db", "migrations", "*.up.sql"))
+	if err != nil {
+		t.Fatalf("не удалось найти миграции: %v", err)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		migration, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("не удалось прочитать миграцию %s: %v", file, err)
+		}
+		if _, err := pool.Exec(context.Background(), string(migration)); err != nil {
+			t.Fatalf("не получилось применить миграцию %s: %v", file, err)
+		}
+	}
+}
+
 func TestUserRepository_CreateUser(t *testing.T) {
 	pool, cleanup := setupTestDB(t)
 	defer cleanup()
@@ -114,9 +127,10 @@ func TestUserRepository_UpdateUserByID(t *testing.T) {
 	err := pool.QueryRow(context.Background(), "INSERT INTO users (name, email) VALUES ($1, $2) RETURNING id", "Old User", "old@example.com").Scan(&userID)
 	assert.NoError(t, err)
 
-	updateUser := &domain.User{Name: "New User", Email: "new@example.com"}
+	updateUser := &domain.User{Name: "New User", Email: "new@example.com", Version: 1}
 	err = repo.UpdateUserByID(context.Background(), userID, updateUser)
 	assert.NoError(t, err)
+	assert.Equal(t, int64(2), updateUser.Version)
 
 	var updatedUser domain.User
 	err = pool.QueryRow(context.Background(), "SELECT id, name, email FROM users WHERE id = $1", userID).
@@ -128,13 +142,48 @@ func TestUserRepository_UpdateUserByID(t *testing.T) {
 	err = repo.UpdateUserByID(context.Background(), 999, updateUser)
 	assert.Equal(t, ErrUserNotFound, err)
 
+	err = repo.UpdateUserByID(context.Background(), userID, &domain.User{Name: "Stale User", Email: "new@example.com", Version: 1})
+	assert.Equal(t, ErrVersionConflict, err)
+
 	_, err = pool.Exec(context.Background(), "INSERT INTO users (name, email) VALUES ($1, $2)", "Another User", "another@example.com")
 	assert.NoError(t, err)
-	err = repo.UpdateUserByID(context.Background(), userID, &domain.User{Name: "New User", Email: "another@example.com"})
+	err = repo.UpdateUserByID(context.Background(), userID, &domain.User{Name: "New User", Email: "another@example.com", Version: 2})
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "duplicate key value violates unique constraint")
 }
 
+func TestUserRepository_PatchUserByID(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewUserRepository(pool)
+
+	var userID int64
+	err := pool.QueryRow(context.Background(), "INSERT INTO users (name, email) VALUES ($1, $2) RETURNING id", "Old User", "old@example.com").Scan(&userID)
+	assert.NoError(t, err)
+
+	name := "New User"
+	version, err := repo.PatchUserByID(context.Background(), userID, 1, domain.UserPatch{Name: &name})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), version)
+
+	user, err := repo.GetUserByID(context.Background(), userID)
+	assert.NoError(t, err)
+	assert.Equal(t, "New User", user.Name)
+	assert.Equal(t, "old@example.com", user.Email)
+	assert.Equal(t, int64(2), user.Version)
+
+	version, err = repo.PatchUserByID(context.Background(), userID, 2, domain.UserPatch{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), version)
+
+	_, err = repo.PatchUserByID(context.Background(), userID, 1, domain.UserPatch{Name: &name})
+	assert.Equal(t, ErrVersionConflict, err)
+
+	_, err = repo.PatchUserByID(context.Background(), 999, 1, domain.UserPatch{Name: &name})
+	assert.Equal(t, ErrUserNotFound, err)
+}
+
 func TestUserRepository_DeleteUserByID(t *testing.T) {
 	pool, cleanup := setupTestDB(t)
 	defer cleanup()
@@ -145,13 +194,68 @@ func TestUserRepository_DeleteUserByID(t *testing.T) {
 	err := pool.QueryRow(context.Background(), "INSERT INTO users (name, email) VALUES ($1, $2) RETURNING id", "Test User", "test@example.com").Scan(&userID)
 	assert.NoError(t, err)
 
-	err = repo.DeleteUserByID(context.Background(), userID)
+	err = repo.DeleteUserByID(context.Background(), userID, 2)
+	assert.Equal(t, ErrVersionConflict, err)
+
+	err = repo.DeleteUserByID(context.Background(), userID, 1)
 	assert.NoError(t, err)
 
 	_, err = repo.GetUserByID(context.Background(), userID)
 	assert.Equal(t, ErrUserNotFound, err)
 
-	err = repo.DeleteUserByID(context.Background(), 999)
+	var deletedAt *time.Time
+	err = pool.QueryRow(context.Background(), "SELECT deleted_at FROM users WHERE id = $1", userID).Scan(&deletedAt)
+	assert.NoError(t, err)
+	assert.NotNil(t, deletedAt)
+
+	err = repo.DeleteUserByID(context.Background(), userID, 2)
+	assert.Equal(t, ErrUserNotFound, err)
+
+	err = repo.DeleteUserByID(context.Background(), 999, 1)
+	assert.Equal(t, ErrUserNotFound, err)
+}
+
+func TestUserRepository_RestoreUserByID(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewUserRepository(pool)
+
+	user := &domain.User{Name: "Test User", Email: "test@example.com"}
+	assert.NoError(t, repo.CreateUser(context.Background(), user))
+	assert.NoError(t, repo.DeleteUserByID(context.Background(), user.ID, user.Version))
+
+	trash, err := repo.ListUsers(context.Background(), domain.UserFilter{Deleted: domain.DeletedOnly, Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, trash.Users, 1)
+	assert.NotNil(t, trash.Users[0].DeletedAt)
+
+	active, err := repo.ListUsers(context.Background(), domain.UserFilter{Limit: 10})
+	assert.NoError(t, err)
+	assert.Empty(t, active.Users)
+
+	replacement := &domain.User{Name: "Replacement", Email: "test@example.com"}
+	assert.NoError(t, repo.CreateUser(context.Background(), replacement))
+
+	_, err = repo.RestoreUserByID(context.Background(), user.ID)
+	assert.Equal(t, ErrEmailTaken, err)
+
+	purged, err := repo.PurgeUserByID(context.Background(), replacement.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Replacement", purged.Name)
+
+	restored, err := repo.RestoreUserByID(context.Background(), user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), restored.Version)
+
+	restored, err = repo.GetUserByID(context.Background(), user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Test User", restored.Name)
+
+	_, err = repo.RestoreUserByID(context.Background(), user.ID)
+	assert.Equal(t, ErrUserNotFound, err)
+
+	_, err = repo.PurgeUserByID(context.Background(), replacement.ID)
 	assert.Equal(t, ErrUserNotFound, err)
 }
 
@@ -173,3 +277,219 @@ func TestUserRepository_CreateUser_LongFields(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "value too long for type character varying(100)")
 }
+
+func TestUserRepository_ListUsers(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewUserRepository(pool)
+
+	for _, u := range []domain.User{
+		{Name: "Alice", Email: "alice@example.com"},
+		{Name: "Bob", Email: "bob@example.com"},
+		{Name: "Carol", Email: "carol@test.org"},
+		{Name: "100%_user", Email: "percent@example.com"},
+	} {
+		_, err := pool.Exec(context.Background(), "INSERT INTO users (name, email) VALUES ($1, $2)", u.Name, u.Email)
+		assert.NoError(t, err)
+	}
+
+	list, err := repo.ListUsers(context.Background(), domain.UserFilter{
+		Email:     "example.com",
+		SortBy:    domain.UserSortByName,
+		SortOrder: domain.SortDesc,
+		Limit:     2,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), *list.Total)
+	assert.True(t, list.HasMore)
+	assert.Len(t, list.Users, 2)
+	assert.Equal(t, "Bob", list.Users[0].Name)
+	assert.Equal(t, "Alice", list.Users[1].Name)
+
+	list, err = repo.ListUsers(context.Background(), domain.UserFilter{
+		Email:  "example.com",
+		SortBy: domain.UserSortByName,
+		Limit:  2,
+		Offset: 2,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, list.Users, 1)
+	assert.Equal(t, "Bob", list.Users[0].Name)
+
+	list, err = repo.ListUsers(context.Background(), domain.UserFilter{Name: "%_", Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), *list.Total)
+	assert.Equal(t, "100%_user", list.Users[0].Name)
+}
+
+func TestUserRepository_ListUsers_Keyset(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewUserRepository(pool)
+
+	for _, u := range []domain.User{
+		{Name: "Bob", Email: "bob1@example.com"},
+		{Name: "Alice", Email: "alice@example.com"},
+		{Name: "Bob", Email: "bob2@example.com"},
+		{Name: "Carol", Email: "carol@example.com"},
+	} {
+		_, err := pool.Exec(context.Background(), "INSERT INTO users (name, email) VALUES ($1, $2)", u.Name, u.Email)
+		assert.NoError(t, err)
+	}
+
+	filter := domain.UserFilter{SortBy: domain.UserSortByName, SortOrder: domain.SortAsc, Limit: 2}
+	list, err := repo.ListUsers(context.Background(), filter)
+	assert.NoError(t, err)
+	assert.True(t, list.HasMore)
+	assert.Equal(t, []string{"alice@example.com", "bob1@example.com"}, []string{list.Users[0].Email, list.Users[1].Email})
+
+	last := list.Users[1]
+	filter.After = &domain.UserCursor{SortBy: filter.SortBy, SortOrder: filter.SortOrder, Value: last.Name, ID: last.ID}
+	list, err = repo.ListUsers(context.Background(), filter)
+	assert.NoError(t, err)
+	assert.False(t, list.HasMore)
+	assert.Nil(t, list.Total)
+	assert.Equal(t, []string{"bob2@example.com", "carol@example.com"}, []string{list.Users[0].Email, list.Users[1].Email})
+
+	filter = domain.UserFilter{SortBy: domain.UserSortByID, SortOrder: domain.SortDesc, Limit: 10}
+	filter.After = &domain.UserCursor{SortBy: filter.SortBy, SortOrder: filter.SortOrder, ID: 3}
+	list, err = repo.ListUsers(context.Background(), filter)
+	assert.NoError(t, err)
+	assert.Len(t, list.Users, 2)
+	assert.Equal(t, int64(2), list.Users[0].ID)
+	assert.Equal(t, int64(1), list.Users[1].ID)
+}
+
+func TestUserRepository_CopyUsers(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewUserRepository(pool)
+
+	_, err := pool.Exec(context.Background(), "INSERT INTO users (name, email) VALUES ($1, $2)", "Old", "old@example.com")
+	assert.NoError(t, err)
+
+	existing, err := repo.FindExistingEmails(context.Background(), []string{"old@example.com", "new@example.com"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"old@example.com": true}, existing)
+
+	count, err := repo.CopyUsers(context.Background(), []domain.User{
+		{Name: "New", Email: "new@example.com"},
+		{Name: "Other", Email: "other@example.com"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	users, err := repo.GetUsersByEmails(context.Background(), []string{"new@example.com", "other@example.com"})
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+	assert.Equal(t, "New", users[0].Name)
+	assert.Equal(t, int64(1), users[0].Version)
+
+	_, err = repo.CopyUsers(context.Background(), []domain.User{{Name: "Dup", Email: "old@example.com"}})
+	assert.ErrorIs(t, err, ErrEmailTaken)
+}
+
+func TestUserRepository_ExportUsers(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewUserRepository(pool)
+
+	for _, u := range []domain.User{
+		{Name: "Bob", Email: "bob@example.com"},
+		{Name: "Alice", Email: "alice@example.com"},
+		{Name: "Carol", Email: "carol@example.com"},
+	} {
+		_, err := pool.Exec(context.Background(), "INSERT INTO users (name, email) VALUES ($1, $2)", u.Name, u.Email)
+		assert.NoError(t, err)
+	}
+	_, err := pool.Exec(context.Background(), "UPDATE users SET deleted_at = now() WHERE email = 'carol@example.com'")
+	assert.NoError(t, err)
+
+	var emails []string
+	filter := domain.UserFilter{SortBy: domain.UserSortByName, SortOrder: domain.SortDesc, Deleted: domain.DeletedExclude}
+	err = repo.ExportUsers(context.Background(), filter, func(user *domain.User) error {
+		emails = append(emails, user.Email)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"bob@example.com", "alice@example.com"}, emails)
+
+	stop := errors.New("стоп")
+	err = repo.ExportUsers(context.Background(), filter, func(user *domain.User) error { return stop })
+	assert.ErrorIs(t, err, stop)
+}
+
+func TestUserRepository_Roles(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewUserRepository(pool)
+
+	user := &domain.User{Name: "Иван", Email: "ivan@example.com"}
+	assert.NoError(t, repo.CreateUser(context.Background(), user))
+
+	hasAdmin, err := repo.HasUsersWithRole(context.Background(), domain.RoleAdmin)
+	assert.NoError(t, err)
+	assert.False(t, hasAdmin)
+
+	assert.NoError(t, repo.SetUserRoles(context.Background(), user.ID, []domain.Role{domain.RoleViewer, domain.RoleOperator}))
+	roles, err := repo.GetUserRoles(context.Background(), user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Role{domain.RoleOperator, domain.RoleViewer}, roles)
+
+	assert.NoError(t, repo.SetUserRoles(context.Background(), user.ID, []domain.Role{domain.RoleAdmin}))
+	roles, err = repo.GetUserRoles(context.Background(), user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Role{domain.RoleAdmin}, roles)
+	hasAdmin, err = repo.HasUsersWithRole(context.Background(), domain.RoleAdmin)
+	assert.NoError(t, err)
+	assert.True(t, hasAdmin)
+
+	assert.NoError(t, repo.DeleteUserByID(context.Background(), user.ID, user.Version))
+	roles, err = repo.GetUserRoles(context.Background(), user.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, roles)
+	roles, err = repo.GetAssignedRoles(context.Background(), user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Role{domain.RoleAdmin}, roles)
+	hasAdmin, err = repo.HasUsersWithRole(context.Background(), domain.RoleAdmin)
+	assert.NoError(t, err)
+	assert.False(t, hasAdmin)
+}
+
+func TestUserRepository_EmailVerification(t *testing.T) {
+	pool, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewUserRepository(pool)
+	user := &domain.User{Name: "Иван", Email: "ivan@example.com"}
+	assert.NoError(t, repo.CreateUser(context.Background(), user))
+
+	_, err := repo.MarkEmailVerified(context.Background(), user.ID, "other@example.com")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+
+	verified, err := repo.MarkEmailVerified(context.Background(), user.ID, "ivan@example.com")
+	assert.NoError(t, err)
+	assert.NotNil(t, verified.EmailVerifiedAt)
+	assert.Equal(t, user.Version+1, verified.Version)
+
+	again, err := repo.MarkEmailVerified(context.Background(), user.ID, "ivan@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, verified.Version, again.Version)
+
+	// Изменение имени сохраняет подтверждение, смена email сбрасывает его.
+	update := &domain.User{Name: "Иван Иванов", Email: "ivan@example.com", Version: verified.Version}
+	assert.NoError(t, repo.UpdateUserByID(context.Background(), user.ID, update))
+	assert.NotNil(t, update.EmailVerifiedAt)
+
+	email := "ivan.new@example.com"
+	_, err = repo.PatchUserByID(context.Background(), user.ID, update.Version, domain.UserPatch{Email: &email})
+	assert.NoError(t, err)
+	found, err := repo.GetUserByID(context.Background(), user.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, found.EmailVerifiedAt)
+}
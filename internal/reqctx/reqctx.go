@@ -0,0 +1,31 @@
+package reqctx
+
+import "context"
+
+const AnonymousActor = "anonymous"
+
+// SystemActor — автор действий, которые сервис выполняет сам, без запроса (например, при запуске).
+const SystemActor = "system"
+
+type requestIDKey struct{}
+type actorKey struct{}
+
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func Actor(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return AnonymousActor
+}
@@ -2,17 +2,187 @@ package router
 
 import (
 	"github.com/gin-gonic/gin"
+	"testovoe/internal/auth"
 	"testovoe/internal/handler"
+	"testovoe/internal/i18n"
+	"testovoe/internal/middleware"
+	"testovoe/internal/ratelimit"
+	"time"
 )
 
-func SetupRouter(userHandler *handler.UserHandler) *gin.Engine {
-	r := gin.Default()
+// publicRoutes перечисляет маршруты, доступные без аутентификации.
+var publicRoutes = []string{
+	"POST /auth/login",
+	"POST /auth/refresh",
+	"POST /auth/logout",
+	"POST /users/verify-email",
+	"POST /auth/password-reset/request",
+	"POST /auth/password-reset/confirm",
+	"POST /auth/mfa/enroll",
+	"POST /auth/mfa/verify",
+	"GET /auth/sso",
+	"GET /auth/sso/:provider",
+	"GET /auth/sso/:provider/callback",
+	"GET /.well-known/openid-configuration",
+	"GET /.well-known/jwks.json",
+	"GET /oauth/authorize",
+	"POST /oauth/token",
+	"GET /oauth/userinfo",
+	"POST /oauth/userinfo",
+	"POST /oauth/introspect",
+	"POST /oauth/revoke",
+}
+
+// idempotentRoutes перечисляет изменяющие маршруты, которые принимают заголовок Idempotency-Key.
+// Маршруты, ответ которых содержит секреты (токены, API-ключи, секреты клиентов), сюда не входят:
+// сохраненный ответ лежал бы в базе в открытом виде.
+var idempotentRoutes = []string{
+	"POST /users/",
+	"POST /users/import",
+	"PUT /users/:id",
+	"PATCH /users/:id",
+	"DELETE /users/:id",
+	"POST /users/:id/restore",
+	"POST /users/:id/verify-email/resend",
+	"PUT /users/:id/password",
+	"POST /users/:id/password-reset",
+	"PUT /users/:id/roles",
+	"DELETE /users/:id/api-keys/:key_id",
+	"DELETE /users/:id/sessions",
+	"DELETE /users/:id/sessions/:session_id",
+	"DELETE /users/:id/identities/:identity_id",
+	"DELETE /users/:id/lockout",
+	"PUT /mfa/policy",
+	"DELETE /lockouts/ip/:ip",
+	"DELETE /oauth/clients/:client_id",
+}
+
+// Deps — зависимости маршрутизатора: обработчики, способы аутентификации и настройки middleware.
+type Deps struct {
+	UserHandler              *handler.UserHandler
+	AuditHandler             *handler.AuditHandler
+	APIKeyHandler            *handler.APIKeyHandler
+	AuthHandler              *handler.AuthHandler
+	SessionHandler           *handler.SessionHandler
+	EmailVerificationHandler *handler.EmailVerificationHandler
+	PasswordResetHandler     *handler.PasswordResetHandler
+	MFAHandler               *handler.MFAHandler
+	OIDCHandler              *handler.OIDCHandler
+	OAuthClientHandler       *handler.OAuthClientHandler
+	SSOHandler               *handler.SSOHandler
+	LockoutHandler           *handler.LockoutHandler
+
+	Authenticators []auth.Authenticator
+	Limiter        *ratelimit.Limiter
+	Idempotency    middleware.IdempotencyStore
+	IdempotencyTTL time.Duration
+	// IdempotencyLockTimeout — через сколько незавершенный запрос уступает Idempotency-Key повтору.
+	IdempotencyLockTimeout time.Duration
+	Languages              *i18n.Matcher
+	AdminToken             string
+	// TrustedProxies — прокси, которым разрешено передавать адрес клиента в X-Forwarded-For.
+	// Пустой список означает, что адресом клиента всегда считается адрес соединения.
+	TrustedProxies []string
+}
+
+func SetupRouter(deps Deps) *gin.Engine {
+	r := gin.New()
+	// Список проверен при загрузке конфигурации, поэтому ошибка здесь означает ошибку в программе.
+	if err := r.SetTrustedProxies(deps.TrustedProxies); err != nil {
+		panic(err)
+	}
+	r.Use(middleware.RequestID())
+	r.Use(middleware.Logger())
+	r.Use(middleware.Recovery())
+	r.Use(middleware.Locale(deps.Languages))
+	r.Use(middleware.RateLimit(deps.Limiter))
+	r.Use(middleware.Authenticate(deps.Authenticators, publicRoutes...))
+	r.Use(middleware.RateLimitAuthenticated(deps.Limiter))
+	r.Use(middleware.Idempotency(deps.Idempotency, middleware.IdempotencyConfig{
+		TTL:         deps.IdempotencyTTL,
+		LockTimeout: deps.IdempotencyLockTimeout,
+	}, idempotentRoutes...))
+
 	api := r.Group("/users")
 	{
-		api.POST("/", userHandler.CreateUser)
-		api.GET("/:id", userHandler.GetUserByID)
-		api.PUT("/:id", userHandler.UpdateUserByID)
-		api.DELETE("/:id", userHandler.DeleteUserByID)
+		api.GET("/", deps.UserHandler.ListUsers)
+		api.POST("/", deps.UserHandler.CreateUser)
+		api.POST("/import", deps.UserHandler.ImportUsers)
+		api.GET("/export", deps.UserHandler.ExportUsers)
+		api.POST("/verify-email", deps.EmailVerificationHandler.VerifyEmail)
+		api.GET("/:id", deps.UserHandler.GetUserByID)
+		api.PUT("/:id", deps.UserHandler.UpdateUserByID)
+		api.PATCH("/:id", deps.UserHandler.PatchUserByID)
+		api.DELETE("/:id", deps.UserHandler.DeleteUserByID)
+		api.POST("/:id/restore", deps.UserHandler.RestoreUserByID)
+		api.POST("/:id/verify-email/resend", deps.EmailVerificationHandler.ResendVerification)
+		api.GET("/:id/history", deps.AuditHandler.GetUserHistory)
+		api.GET("/:id/roles", deps.UserHandler.GetUserRoles)
+		api.PUT("/:id/password", deps.AuthHandler.SetPassword)
+		api.POST("/:id/password/change", deps.AuthHandler.ChangePassword)
+		api.POST("/:id/password-reset", deps.PasswordResetHandler.SendPasswordReset)
+		api.PUT("/:id/roles", deps.UserHandler.SetUserRoles)
+		api.GET("/:id/api-keys", deps.APIKeyHandler.ListAPIKeys)
+		api.POST("/:id/api-keys", deps.APIKeyHandler.CreateAPIKey)
+		api.POST("/:id/api-keys/:key_id/rotate", deps.APIKeyHandler.RotateAPIKey)
+		api.DELETE("/:id/api-keys/:key_id", deps.APIKeyHandler.RevokeAPIKey)
+		api.GET("/:id/sessions", deps.SessionHandler.ListSessions)
+		api.DELETE("/:id/sessions", deps.SessionHandler.RevokeAllSessions)
+		api.DELETE("/:id/sessions/:session_id", deps.SessionHandler.RevokeSession)
+		api.GET("/:id/mfa", deps.MFAHandler.GetMFAStatus)
+		api.POST("/:id/mfa", deps.MFAHandler.StartMFAEnrollment)
+		api.DELETE("/:id/mfa", deps.MFAHandler.DisableMFA)
+		api.POST("/:id/mfa/confirm", deps.MFAHandler.ConfirmMFAEnrollment)
+		api.POST("/:id/mfa/recovery-codes", deps.MFAHandler.RegenerateRecoveryCodes)
+		api.POST("/:id/mfa/reset", deps.MFAHandler.ResetMFA)
+		api.GET("/:id/identities", deps.SSOHandler.ListIdentities)
+		api.DELETE("/:id/identities/:identity_id", deps.SSOHandler.UnlinkIdentity)
+		api.GET("/:id/lockout", deps.LockoutHandler.GetUserLockout)
+		api.DELETE("/:id/lockout", deps.LockoutHandler.UnlockUser)
+	}
+
+	r.GET("/audit", deps.AuditHandler.ListAuditRecords)
+	r.GET("/mfa/policy", deps.MFAHandler.GetMFAPolicy)
+	r.PUT("/mfa/policy", deps.MFAHandler.SetMFAPolicy)
+	r.GET("/lockouts", deps.LockoutHandler.ListLockouts)
+	r.DELETE("/lockouts/ip/:ip", deps.LockoutHandler.UnlockIP)
+
+	authGroup := r.Group("/auth")
+	{
+		authGroup.POST("/login", deps.AuthHandler.Login)
+		authGroup.POST("/refresh", deps.AuthHandler.Refresh)
+		authGroup.POST("/logout", deps.AuthHandler.Logout)
+		authGroup.POST("/password-reset/request", deps.PasswordResetHandler.RequestPasswordReset)
+		authGroup.POST("/password-reset/confirm", deps.PasswordResetHandler.ConfirmPasswordReset)
+		authGroup.POST("/mfa/enroll", deps.MFAHandler.EnrollMFAChallenge)
+		authGroup.POST("/mfa/verify", deps.MFAHandler.VerifyMFAChallenge)
+		authGroup.GET("/sso", deps.SSOHandler.ListSSOProviders)
+		authGroup.GET("/sso/:provider", deps.SSOHandler.StartSSOLogin)
+		authGroup.GET("/sso/:provider/callback", deps.SSOHandler.CompleteSSOLogin)
+	}
+
+	r.GET("/.well-known/openid-configuration", deps.OIDCHandler.Discovery)
+	r.GET("/.well-known/jwks.json", deps.OIDCHandler.JWKS)
+
+	oauth := r.Group("/oauth")
+	{
+		oauth.GET("/authorize", deps.OIDCHandler.StartAuthorization)
+		oauth.POST("/authorize", deps.OIDCHandler.Authorize)
+		oauth.POST("/token", deps.OIDCHandler.Token)
+		oauth.GET("/userinfo", deps.OIDCHandler.UserInfo)
+		oauth.POST("/userinfo", deps.OIDCHandler.UserInfo)
+		oauth.POST("/introspect", deps.OIDCHandler.Introspect)
+		oauth.POST("/revoke", deps.OIDCHandler.Revoke)
+		oauth.GET("/clients", deps.OAuthClientHandler.ListOAuthClients)
+		oauth.POST("/clients", deps.OAuthClientHandler.CreateOAuthClient)
+		oauth.GET("/clients/:client_id", deps.OAuthClientHandler.GetOAuthClient)
+		oauth.DELETE("/clients/:client_id", deps.OAuthClientHandler.DeleteOAuthClient)
+		oauth.POST("/keys/rotate", deps.OIDCHandler.RotateSigningKey)
+	}
+
+	admin := r.Group("/admin", middleware.RequireAdminToken(deps.AdminToken))
+	{
+		admin.DELETE("/users/:id", deps.UserHandler.PurgeUserByID)
 	}
 
 	return r
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testovoe/internal/apperr"
+	"testovoe/internal/auth"
+	"testovoe/internal/domain"
+	"testovoe/internal/repository"
+	"unicode/utf8"
+)
+
+var ErrAPIKeyNotFound = apperr.New(apperr.KindNotFound, "api_key_not_found", "API-ключ не найден")
+var ErrInvalidAPIKeyName = apperr.New(apperr.KindInvalid, "invalid_api_key_name", "название ключа должно быть непустым и не длиннее 255 символов")
+
+const apiKeyPrefix = "tvk_"
+
+type APIKeyServiceInterface interface {
+	CreateAPIKey(ctx context.Context, userID int64, name string) (*domain.IssuedAPIKey, error)
+	ListAPIKeys(ctx context.Context, userID int64) ([]domain.APIKey, error)
+	RotateAPIKey(ctx context.Context, userID, id int64) (*domain.IssuedAPIKey, error)
+	RevokeAPIKey(ctx context.Context, userID, id int64) error
+	AuthenticateAPIKey(ctx context.Context, key string) (*domain.APIKey, error)
+}
+
+type APIKeyService struct {
+	keys  repository.APIKeyRepositoryInterface
+	users repository.UserRepositoryInterface
+	audit repository.AuditRepositoryInterface
+	tx    repository.TransactorInterface
+}
+
+func NewAPIKeyService(keys repository.APIKeyRepositoryInterface, users repository.UserRepositoryInterface, audit repository.AuditRepositoryInterface, tx repository.TransactorInterface) *APIKeyService {
+	return &APIKeyService{keys: keys, users: users, audit: audit, tx: tx}
+}
+
+func (s *APIKeyService) CreateAPIKey(ctx context.Context, userID int64, name string) (*domain.IssuedAPIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > 255 {
+		return nil, ErrInvalidAPIKeyName
+	}
+
+	secret, prefix, hash, err := generateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	key := &domain.APIKey{UserID: userID, Name: name, Prefix: prefix, KeyHash: hash}
+
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.requireUser(ctx, userID); err != nil {
+			return err
+		}
+		if err := s.keys.CreateAPIKey(ctx, key); err != nil {
+			return err
+		}
+		return s.recordAudit(ctx, domain.AuditActionAPIKeyCreated, nil, key)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &domain.IssuedAPIKey{APIKey: *key, Key: secret}, nil
+}
+
+func (s *APIKeyService) ListAPIKeys(ctx context.Context, userID int64) ([]domain.APIKey, error) {
+	if err := s.requireUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.keys.ListAPIKeysByUser(ctx, userID)
+}
+
+func (s *APIKeyService) RotateAPIKey(ctx context.Context, userID, id int64) (*domain.IssuedAPIKey, error) {
+	secret, prefix, hash, err := generateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+
+	var key *domain.APIKey
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := s.keys.GetAPIKeyByID(ctx, userID, id)
+		if err != nil {
+			return mapAPIKeyError(err)
+		}
+		if before.RevokedAt != nil {
+			return ErrAPIKeyNotFound
+		}
+
+		rotated := *before
+		rotated.Prefix, rotated.KeyHash = prefix, hash
+		if err := s.keys.RotateAPIKey(ctx, &rotated); err != nil {
+			return mapAPIKeyError(err)
+		}
+		key = &rotated
+		return s.recordAudit(ctx, domain.AuditActionAPIKeyRotated, before, key)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &domain.IssuedAPIKey{APIKey: *key, Key: secret}, nil
+}
+
+func (s *APIKeyService) RevokeAPIKey(ctx context.Context, userID, id int64) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := s.keys.GetAPIKeyByID(ctx, userID, id)
+		if err != nil {
+			return mapAPIKeyError(err)
+		}
+		after, err := s.keys.RevokeAPIKey(ctx, userID, id)
+		if err != nil {
+			return mapAPIKeyError(err)
+		}
+		return s.recordAudit(ctx, domain.AuditActionAPIKeyRevoked, before, after)
+	})
+}
+
+// AuthenticateAPIKey возвращает auth.ErrInvalidCredentials для любого неподходящего ключа,
+// не раскрывая, что именно не так: неизвестный префикс, неверный секрет, отзыв или удаление владельца.
+func (s *APIKeyService) AuthenticateAPIKey(ctx context.Context, raw string) (*domain.APIKey, error) {
+	prefix, _, ok := strings.Cut(raw, ".")
+	if !ok || !strings.HasPrefix(prefix, apiKeyPrefix) {
+		return nil, auth.ErrInvalidCredentials
+	}
+
+	key, err := s.keys.GetAPIKeyByPrefix(ctx, prefix)
+	if err != nil {
+		if errors.Is(err, repository.ErrAPIKeyNotFound) {
+			return nil, auth.ErrInvalidCredentials
+		}
+		return nil, err
+	}
+	hash := sha256.Sum256([]byte(raw))
+	if subtle.ConstantTimeCompare(hash[:], key.KeyHash) != 1 || key.RevokedAt != nil {
+		return nil, auth.ErrInvalidCredentials
+	}
+	if _, err := s.users.GetUserByID(ctx, key.UserID); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, auth.ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	if err := s.keys.TouchAPIKey(ctx, key.ID); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (s *APIKeyService) requireUser(ctx context.Context, userID int64) error {
+	if _, err := s.users.GetUserByID(ctx, userID); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *APIKeyService) recordAudit(ctx context.Context, action string, before, after *domain.APIKey) error {
+	record, err := newAuditRecord(ctx, action, domain.AuditEntityAPIKey, after.ID, before, after)
+	if err != nil {
+		return err
+	}
+	return s.audit.CreateAuditRecord(ctx, record)
+}
+
+func mapAPIKeyError(err error) error {
+	if errors.Is(err, repository.ErrAPIKeyNotFound) {
+		return ErrAPIKeyNotFound
+	}
+	return err
+}
+
+// generateAPIKey возвращает ключ вида tvk_<префикс>.<секрет>, его видимый префикс и SHA-256 хеш.
+// Ключ содержит 256 бит случайных данных, поэтому медленное хеширование здесь не нужно.
+func generateAPIKey() (key, prefix string, hash []byte, err error) {
+	id := make([]byte, 6)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", nil, err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", nil, err
+	}
+
+	prefix = apiKeyPrefix + hex.EncodeToString(id)
+	key = prefix + "." + base64.RawURLEncoding.EncodeToString(secret)
+	sum := sha256.Sum256([]byte(key))
+	return key, prefix, sum[:], nil
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"strings"
+	"testing"
+	"testovoe/internal/auth"
+	"testovoe/internal/domain"
+	"testovoe/internal/repository"
+	"time"
+)
+
+type MockAPIKeyRepository struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyRepository) CreateAPIKey(ctx context.Context, key *domain.APIKey) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyRepository) GetAPIKeyByID(ctx context.Context, userID, id int64) (*domain.APIKey, error) {
+	args := m.Called(ctx, userID, id)
+	return args.Get(0).(*domain.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*domain.APIKey, error) {
+	args := m.Called(ctx, prefix)
+	return args.Get(0).(*domain.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) ListAPIKeysByUser(ctx context.Context, userID int64) ([]domain.APIKey, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]domain.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) RotateAPIKey(ctx context.Context, key *domain.APIKey) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyRepository) RevokeAPIKey(ctx context.Context, userID, id int64) (*domain.APIKey, error) {
+	args := m.Called(ctx, userID, id)
+	return args.Get(0).(*domain.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) TouchAPIKey(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func newTestAPIKeyService() (*APIKeyService, *MockAPIKeyRepository, *MockUserRepository, *recordingAuditRepository) {
+	keys := new(MockAPIKeyRepository)
+	users := new(MockUserRepository)
+	audit := new(recordingAuditRepository)
+	return NewAPIKeyService(keys, users, audit, fakeTransactor{}), keys, users, audit
+}
+
+func TestCreateAPIKey(t *testing.T) {
+	service, keys, users, audit := newTestAPIKeyService()
+
+	users.On("GetUserByID", mock.Anything, int64(1)).Return(&domain.User{ID: 1}, nil)
+	keys.On("CreateAPIKey", mock.Anything, mock.AnythingOfType("*domain.APIKey")).Run(func(args mock.Arguments) {
+		args.Get(1).(*domain.APIKey).ID = 5
+	}).Return(nil)
+
+	issued, err := service.CreateAPIKey(context.Background(), 1, "  batch job ")
+	assert.NoError(t, err)
+	assert.Equal(t, "batch job", issued.APIKey.Name)
+	assert.True(t, strings.HasPrefix(issued.Key, issued.APIKey.Prefix+"."))
+
+	hash := sha256.Sum256([]byte(issued.Key))
+	assert.Equal(t, hash[:], issued.APIKey.KeyHash)
+
+	assert.Len(t, audit.records, 1)
+	assert.Equal(t, domain.AuditActionAPIKeyCreated, audit.records[0].Action)
+	assert.NotContains(t, string(audit.records[0].After), "key_hash")
+}
+
+func TestCreateAPIKey_InvalidName(t *testing.T) {
+	service, _, _, _ := newTestAPIKeyService()
+
+	_, err := service.CreateAPIKey(context.Background(), 1, "   ")
+	assert.ErrorIs(t, err, ErrInvalidAPIKeyName)
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	service, keys, users, _ := newTestAPIKeyService()
+
+	raw, prefix, hash, err := generateAPIKey()
+	assert.NoError(t, err)
+	key := &domain.APIKey{ID: 3, UserID: 1, Prefix: prefix, KeyHash: hash}
+
+	keys.On("GetAPIKeyByPrefix", mock.Anything, prefix).Return(key, nil)
+	keys.On("TouchAPIKey", mock.Anything, int64(3)).Return(nil)
+	users.On("GetUserByID", mock.Anything, int64(1)).Return(&domain.User{ID: 1}, nil)
+
+	result, err := service.AuthenticateAPIKey(context.Background(), raw)
+	assert.NoError(t, err)
+	assert.Equal(t, key, result)
+	keys.AssertExpectations(t)
+
+	_, err = service.AuthenticateAPIKey(context.Background(), prefix+".wrong-secret")
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+
+	_, err = service.AuthenticateAPIKey(context.Background(), "garbage")
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+}
+
+func TestAuthenticateAPIKey_Revoked(t *testing.T) {
+	service, keys, _, _ := newTestAPIKeyService()
+
+	raw, prefix, hash, err := generateAPIKey()
+	assert.NoError(t, err)
+	revokedAt := time.Now()
+	keys.On("GetAPIKeyByPrefix", mock.Anything, prefix).Return(&domain.APIKey{ID: 3, UserID: 1, KeyHash: hash, RevokedAt: &revokedAt}, nil)
+
+	_, err = service.AuthenticateAPIKey(context.Background(), raw)
+	assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+	keys.AssertNotCalled(t, "TouchAPIKey", mock.Anything, mock.Anything)
+}
+
+func TestRotateAPIKey(t *testing.T) {
+	service, keys, _, audit := newTestAPIKeyService()
+
+	before := &domain.APIKey{ID: 3, UserID: 1, Name: "ci", Prefix: "tvk_old", KeyHash: []byte("old")}
+	keys.On("GetAPIKeyByID", mock.Anything, int64(1), int64(3)).Return(before, nil)
+	keys.On("RotateAPIKey", mock.Anything, mock.AnythingOfType("*domain.APIKey")).Return(nil)
+
+	issued, err := service.RotateAPIKey(context.Background(), 1, 3)
+	assert.NoError(t, err)
+	assert.NotEqual(t, "tvk_old", issued.APIKey.Prefix)
+	assert.Equal(t, "ci", issued.APIKey.Name)
+	assert.Equal(t, domain.AuditActionAPIKeyRotated, audit.records[0].Action)
+}
+
+func TestRevokeAPIKey_NotFound(t *testing.T) {
+	service, keys, _, _ := newTestAPIKeyService()
+
+	keys.On("GetAPIKeyByID", mock.Anything, int64(1), int64(9)).Return((*domain.APIKey)(nil), repository.ErrAPIKeyNotFound)
+
+	err := service.RevokeAPIKey(context.Background(), 1, 9)
+	assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+}
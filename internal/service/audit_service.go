@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testovoe/internal/apperr"
+	"testovoe/internal/domain"
+	"testovoe/internal/repository"
+	"testovoe/internal/reqctx"
+)
+
+var ErrInvalidTimeRange = apperr.New(apperr.KindInvalid, "invalid_time_range", "недопустимый временной интервал")
+
+type AuditServiceInterface interface {
+	ListAuditRecords(ctx context.Context, filter domain.AuditFilter) (*domain.AuditList, error)
+	GetUserHistory(ctx context.Context, userID int64, filter domain.AuditFilter) (*domain.AuditList, error)
+}
+
+type AuditService struct {
+	repo repository.AuditRepositoryInterface
+}
+
+func NewAuditService(repo repository.AuditRepositoryInterface) *AuditService {
+	return &AuditService{repo: repo}
+}
+
+func (s *AuditService) ListAuditRecords(ctx context.Context, filter domain.AuditFilter) (*domain.AuditList, error) {
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return nil, ErrInvalidTimeRange
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = DefaultListLimit
+	}
+	if filter.Limit < 0 || filter.Limit > MaxListLimit || filter.Offset < 0 {
+		return nil, ErrInvalidPagination
+	}
+
+	return s.repo.ListAuditRecords(ctx, filter)
+}
+
+func (s *AuditService) GetUserHistory(ctx context.Context, userID int64, filter domain.AuditFilter) (*domain.AuditList, error) {
+	filter.EntityType = domain.AuditEntityUser
+	filter.EntityID = userID
+	return s.ListAuditRecords(ctx, filter)
+}
+
+type auditChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+func newAuditRecord(ctx context.Context, action, entityType string, entityID int64, before, after any) (*domain.AuditRecord, error) {
+	record := &domain.AuditRecord{
+		Actor:      reqctx.Actor(ctx),
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		RequestID:  reqctx.RequestID(ctx),
+	}
+
+	beforeFields, err := auditSnapshot(before, &record.Before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := auditSnapshot(after, &record.After)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := make(map[string]auditChange)
+	for field, value := range beforeFields {
+		if !reflect.DeepEqual(value, afterFields[field]) {
+			diff[field] = auditChange{From: value, To: afterFields[field]}
+		}
+	}
+	for field, value := range afterFields {
+		if _, ok := beforeFields[field]; !ok {
+			diff[field] = auditChange{To: value}
+		}
+	}
+	if record.Diff, err = json.Marshal(diff); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+func auditSnapshot(entity any, raw *json.RawMessage) (map[string]any, error) {
+	if entity == nil {
+		return nil, nil
+	}
+	if value := reflect.ValueOf(entity); value.Kind() == reflect.Pointer && value.IsNil() {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return nil, err
+	}
+	*raw = data
+
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+	"testovoe/internal/domain"
+	"time"
+)
+
+type MockAuditRepository struct {
+	mock.Mock
+}
+
+func (m *MockAuditRepository) CreateAuditRecord(ctx context.Context, record *domain.AuditRecord) error {
+	args := m.Called(ctx, record)
+	return args.Error(0)
+}
+
+func (m *MockAuditRepository) CreateAuditRecords(ctx context.Context, records []domain.AuditRecord) error {
+	args := m.Called(ctx, records)
+	return args.Error(0)
+}
+
+func (m *MockAuditRepository) ListAuditRecords(ctx context.Context, filter domain.AuditFilter) (*domain.AuditList, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(*domain.AuditList), args.Error(1)
+}
+
+func TestGetUserHistory(t *testing.T) {
+	mockRepo := new(MockAuditRepository)
+	service := NewAuditService(mockRepo)
+
+	expected := domain.AuditFilter{EntityType: domain.AuditEntityUser, EntityID: 5, Limit: DefaultListLimit}
+	list := &domain.AuditList{Records: []domain.AuditRecord{{ID: 1, EntityID: 5}}, Total: 1}
+	mockRepo.On("ListAuditRecords", mock.Anything, expected).Return(list, nil)
+
+	result, err := service.GetUserHistory(context.Background(), 5, domain.AuditFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, list, result)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestListAuditRecords_InvalidParams(t *testing.T) {
+	mockRepo := new(MockAuditRepository)
+	service := NewAuditService(mockRepo)
+
+	from := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err := service.ListAuditRecords(context.Background(), domain.AuditFilter{From: &from, To: &to})
+	assert.Equal(t, ErrInvalidTimeRange, err)
+
+	_, err = service.ListAuditRecords(context.Background(), domain.AuditFilter{Limit: MaxListLimit + 1})
+	assert.Equal(t, ErrInvalidPagination, err)
+
+	mockRepo.AssertNotCalled(t, "ListAuditRecords")
+}
@@ -0,0 +1,401 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testovoe/internal/apperr"
+	"testovoe/internal/auth"
+	"testovoe/internal/domain"
+	"testovoe/internal/repository"
+	"time"
+	"unicode/utf8"
+)
+
+var ErrInvalidLogin = apperr.New(apperr.KindUnauthorized, "invalid_login", "неверный email или пароль")
+var ErrInvalidRefreshToken = apperr.New(apperr.KindUnauthorized, "invalid_refresh_token", "недействительный refresh-токен")
+var ErrWeakPassword = apperr.New(apperr.KindInvalid, "weak_password", "пароль должен содержать от 8 до 256 символов")
+var ErrWrongPassword = apperr.New(apperr.KindInvalid, "wrong_password", "текущий пароль указан неверно")
+
+const (
+	MinPasswordLength = 8
+	MaxPasswordLength = 256
+)
+
+type AuthServiceInterface interface {
+	Login(ctx context.Context, email, password string, meta domain.SessionMeta) (*domain.TokenPair, error)
+	Refresh(ctx context.Context, refreshToken string, meta domain.SessionMeta) (*domain.TokenPair, error)
+	Logout(ctx context.Context, refreshToken string) error
+	SetPassword(ctx context.Context, userID int64, password string) error
+	ChangePassword(ctx context.Context, userID int64, current, next string) error
+}
+
+type AuthService struct {
+	users       repository.UserRepositoryInterface
+	credentials repository.CredentialRepositoryInterface
+	sessions    repository.SessionRepositoryInterface
+	audit       repository.AuditRepositoryInterface
+	tx          repository.TransactorInterface
+	hasher      *auth.PasswordHasher
+	tokens      *auth.TokenIssuer
+	mfa         MFAChallenger
+	limiter     LoginLimiter
+
+	dummyOnce sync.Once
+	dummyHash string
+}
+
+func NewAuthService(users repository.UserRepositoryInterface, credentials repository.CredentialRepositoryInterface, sessions repository.SessionRepositoryInterface, audit repository.AuditRepositoryInterface, tx repository.TransactorInterface, hasher *auth.PasswordHasher, tokens *auth.TokenIssuer, mfa MFAChallenger, limiter LoginLimiter) *AuthService {
+	return &AuthService{users: users, credentials: credentials, sessions: sessions, audit: audit, tx: tx, hasher: hasher, tokens: tokens, mfa: mfa, limiter: limiter}
+}
+
+// Login проверяет пароль и открывает новую сессию. Если нужен второй фактор, сессия не открывается,
+// а возвращается MFAChallengeError с токеном для POST /auth/mfa/verify. Попытка учитывается до проверки
+// пароля и возвращается, если пароль верный. Пока вход заблокирован после неудачных попыток, пароль
+// не проверяется и возвращается LoginLockedError.
+func (s *AuthService) Login(ctx context.Context, email, password string, meta domain.SessionMeta) (*domain.TokenPair, error) {
+	var attempt *LoginAttempt
+	if s.limiter != nil {
+		var err error
+		if attempt, err = s.limiter.ReserveLogin(ctx, email, meta.IP); err != nil {
+			return nil, err
+		}
+	}
+
+	user, needsRehash, err := s.verifyPassword(ctx, email, password)
+	if errors.Is(err, ErrInvalidLogin) {
+		return nil, s.loginFailed(ctx, attempt, user)
+	}
+	if s.limiter != nil {
+		if err := s.limiter.ReleaseAttempt(ctx, attempt); err != nil {
+			return nil, err
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if needsRehash {
+		rehashed, err := s.hasher.Hash(password)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.storePassword(ctx, user.ID, rehashed, domain.AuditActionPasswordRehashed); err != nil {
+			return nil, err
+		}
+	}
+
+	// Если нужен второй фактор, счетчик неудачных попыток сбрасывается только после его проверки.
+	if s.mfa != nil {
+		if err := s.mfa.Challenge(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
+	if s.limiter != nil {
+		if err := s.limiter.LoginSucceeded(ctx, email); err != nil {
+			return nil, err
+		}
+	}
+
+	var tokens *domain.TokenPair
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		tokens, err = openSession(ctx, s.sessions, s.tokens, user.ID, meta)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// verifyPassword возвращает пользователя, если пароль верный. Иначе возвращается ErrInvalidLogin
+// и найденный по email пользователь или nil.
+func (s *AuthService) verifyPassword(ctx context.Context, email, password string) (*domain.User, bool, error) {
+	user, hash, err := s.credentials.GetCredentialsByEmail(ctx, email)
+	if err != nil && !errors.Is(err, repository.ErrUserNotFound) {
+		return nil, false, err
+	}
+	if user == nil || hash == "" {
+		// Считаем хеш и для несуществующих пользователей, чтобы время ответа не выдавало, есть ли такой email.
+		_, _, _ = s.hasher.Verify(password, s.dummyPasswordHash())
+		return user, false, ErrInvalidLogin
+	}
+
+	ok, needsRehash, err := s.hasher.Verify(password, hash)
+	if err != nil {
+		return nil, false, err
+	}
+	if !ok {
+		return user, false, ErrInvalidLogin
+	}
+	return user, needsRehash, nil
+}
+
+// loginFailed подтверждает неудачную попытку входа. Ответ не меняется, даже если попытка привела
+// к блокировке: о ней клиент узнает при следующем входе.
+func (s *AuthService) loginFailed(ctx context.Context, attempt *LoginAttempt, user *domain.User) error {
+	if s.limiter != nil {
+		var userID int64
+		if user != nil {
+			userID = user.ID
+		}
+		if err := s.limiter.AttemptFailed(ctx, attempt, userID); err != nil {
+			return err
+		}
+	}
+	return ErrInvalidLogin
+}
+
+// Refresh обменивает refresh-токен на новую пару токенов той же сессии. Каждый refresh-токен
+// действует один раз: повторное предъявление уже использованного токена означает, что его
+// перехватили, поэтому сессия отзывается целиком вместе со всеми выданными в ней токенами.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string, meta domain.SessionMeta) (*domain.TokenPair, error) {
+	var tokens *domain.TokenPair
+	reused := false
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		session, used, err := s.claimRefreshToken(ctx, refreshToken)
+		if err != nil {
+			return err
+		}
+		if used {
+			reused = true
+			return revokeSession(ctx, s.sessions, s.audit, session, domain.SessionRevokedReuse, domain.AuditActionSessionReuseDetected)
+		}
+
+		if _, err := s.users.GetUserByID(ctx, session.UserID); err != nil {
+			if errors.Is(err, repository.ErrUserNotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+		if err := s.sessions.TouchSession(ctx, session.ID, normalizeSessionMeta(meta), time.Now().Add(s.tokens.RefreshTTL())); err != nil {
+			return err
+		}
+		tokens, err = issueSessionTokens(ctx, s.sessions, s.tokens, session.UserID, session.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Отзыв сессии при повторном использовании токена должен сохраниться, поэтому ошибка
+	// возвращается уже после фиксации транзакции.
+	if reused {
+		return nil, ErrInvalidRefreshToken
+	}
+	return tokens, nil
+}
+
+// Logout отзывает сессию, к которой относится refresh-токен.
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	reused := false
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		session, used, err := s.claimRefreshToken(ctx, refreshToken)
+		if err != nil {
+			return err
+		}
+		if used {
+			reused = true
+			return revokeSession(ctx, s.sessions, s.audit, session, domain.SessionRevokedReuse, domain.AuditActionSessionReuseDetected)
+		}
+		return revokeSession(ctx, s.sessions, s.audit, session, domain.SessionRevokedLogout, domain.AuditActionSessionRevoked)
+	})
+	if err != nil {
+		return err
+	}
+	if reused {
+		return ErrInvalidRefreshToken
+	}
+	return nil
+}
+
+// claimRefreshToken находит действующую сессию refresh-токена и помечает токен использованным.
+func (s *AuthService) claimRefreshToken(ctx context.Context, refreshToken string) (*domain.Session, bool, error) {
+	if !strings.HasPrefix(refreshToken, sessionTokenPrefix) {
+		return nil, false, ErrInvalidRefreshToken
+	}
+	session, used, err := s.sessions.ClaimSessionToken(ctx, hashSessionToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			return nil, false, ErrInvalidRefreshToken
+		}
+		return nil, false, err
+	}
+	if session.RevokedAt != nil || !session.ExpiresAt.After(time.Now()) {
+		return nil, false, ErrInvalidRefreshToken
+	}
+	return session, used, nil
+}
+
+func (s *AuthService) SetPassword(ctx context.Context, userID int64, password string) error {
+	if err := validatePassword(password); err != nil {
+		return err
+	}
+	hash, err := s.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+	return s.replacePassword(ctx, userID, hash, 0)
+}
+
+// ChangePassword меняет пароль по текущему. Проверка текущего пароля считается попыткой входа
+// в учетную запись: она учитывается до проверки, а неверный пароль приближает блокировку входа
+// и записывается в журнал аудита. Иначе по access-токену можно было бы подбирать пароль в обход блокировки.
+func (s *AuthService) ChangePassword(ctx context.Context, userID int64, current, next string) error {
+	if err := validatePassword(next); err != nil {
+		return err
+	}
+
+	user, err := s.users.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	var attempt *LoginAttempt
+	if s.limiter != nil {
+		if attempt, err = s.limiter.ReserveLogin(ctx, user.Email, ""); err != nil {
+			return err
+		}
+	}
+	err = s.verifyCurrentPassword(ctx, userID, current)
+	if errors.Is(err, ErrWrongPassword) {
+		return s.passwordChangeFailed(ctx, attempt, userID)
+	}
+	if s.limiter != nil {
+		if err := s.limiter.ReleaseAttempt(ctx, attempt); err != nil {
+			return err
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	newHash, err := s.hasher.Hash(next)
+	if err != nil {
+		return err
+	}
+	var keep int64
+	if principal, ok := auth.PrincipalFromContext(ctx); ok && principal.UserID == userID {
+		keep = principal.SessionID
+	}
+	return s.replacePassword(ctx, userID, newHash, keep)
+}
+
+func (s *AuthService) verifyCurrentPassword(ctx context.Context, userID int64, current string) error {
+	hash, err := s.credentials.GetPasswordHash(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	if hash == "" {
+		return ErrWrongPassword
+	}
+	ok, _, err := s.hasher.Verify(current, hash)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrWrongPassword
+	}
+	return nil
+}
+
+// passwordChangeFailed подтверждает неудачную попытку и записывает ее в журнал аудита.
+func (s *AuthService) passwordChangeFailed(ctx context.Context, attempt *LoginAttempt, userID int64) error {
+	if s.limiter != nil {
+		if err := s.limiter.AttemptFailed(ctx, attempt, userID); err != nil {
+			return err
+		}
+	}
+	record, err := newAuditRecord(ctx, domain.AuditActionUserPasswordChangeFailed, domain.AuditEntityUser, userID, nil, nil)
+	if err != nil {
+		return err
+	}
+	if err := s.audit.CreateAuditRecord(ctx, record); err != nil {
+		return err
+	}
+	return ErrWrongPassword
+}
+
+// replacePassword сохраняет новый пароль и в той же транзакции отзывает сессии пользователя, кроме keep:
+// иначе украденный refresh-токен продолжал бы действовать после смены пароля. Пересчет хеша при входе
+// пароль не меняет, поэтому вызывает storePassword напрямую.
+func (s *AuthService) replacePassword(ctx context.Context, userID int64, hash string, keep int64) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.storePassword(ctx, userID, hash, domain.AuditActionUserPasswordChanged); err != nil {
+			return err
+		}
+		return revokeUserSessions(ctx, s.sessions, s.audit, userID, domain.SessionRevokedPasswordChanged, keep)
+	})
+}
+
+// storePassword сохраняет хеш пароля и записывает в журнал аудита действие action в той же транзакции.
+func (s *AuthService) storePassword(ctx context.Context, userID int64, hash, action string) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.credentials.SetPasswordHash(ctx, userID, hash); err != nil {
+			if errors.Is(err, repository.ErrUserNotFound) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+		record, err := newAuditRecord(ctx, action, domain.AuditEntityUser, userID, nil, nil)
+		if err != nil {
+			return err
+		}
+		return s.audit.CreateAuditRecord(ctx, record)
+	})
+}
+
+// openSession создает сессию и выпускает для нее первую пару токенов.
+func openSession(ctx context.Context, sessions repository.SessionRepositoryInterface, tokens *auth.TokenIssuer, userID int64, meta domain.SessionMeta) (*domain.TokenPair, error) {
+	meta = normalizeSessionMeta(meta)
+	session := &domain.Session{
+		UserID:    userID,
+		UserAgent: meta.UserAgent,
+		IP:        meta.IP,
+		ExpiresAt: time.Now().Add(tokens.RefreshTTL()),
+	}
+	if err := sessions.CreateSession(ctx, session); err != nil {
+		return nil, err
+	}
+	return issueSessionTokens(ctx, sessions, tokens, userID, session.ID)
+}
+
+// issueSessionTokens выпускает access-токен сессии и новый refresh-токен, сохраняя хеш последнего.
+func issueSessionTokens(ctx context.Context, sessions repository.SessionRepositoryInterface, tokens *auth.TokenIssuer, userID, sessionID int64) (*domain.TokenPair, error) {
+	accessToken, err := tokens.IssueAccessToken(strconv.FormatInt(userID, 10), sessionID)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, hash, err := generateSessionToken()
+	if err != nil {
+		return nil, err
+	}
+	if err := sessions.CreateSessionToken(ctx, sessionID, hash); err != nil {
+		return nil, err
+	}
+	return &domain.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    auth.BearerScheme,
+		ExpiresIn:    int64(tokens.AccessTTL().Seconds()),
+	}, nil
+}
+
+func (s *AuthService) dummyPasswordHash() string {
+	s.dummyOnce.Do(func() {
+		s.dummyHash, _ = s.hasher.Hash("dummy-password")
+	})
+	return s.dummyHash
+}
+
+func validatePassword(password string) error {
+	if length := utf8.RuneCountInString(password); length < MinPasswordLength || length > MaxPasswordLength {
+		return ErrWeakPassword
+	}
+	return nil
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+	"testovoe/internal/auth"
+	"testovoe/internal/domain"
+	"testovoe/internal/repository"
+	"time"
+)
+
+var testArgon2Params = auth.Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+type MockCredentialRepository struct {
+	mock.Mock
+}
+
+func (m *MockCredentialRepository) GetCredentialsByEmail(ctx context.Context, email string) (*domain.User, string, error) {
+	args := m.Called(ctx, email)
+	return args.Get(0).(*domain.User), args.String(1), args.Error(2)
+}
+
+func (m *MockCredentialRepository) GetPasswordHash(ctx context.Context, userID int64) (string, error) {
+	args := m.Called(ctx, userID)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockCredentialRepository) SetPasswordHash(ctx context.Context, userID int64, hash string) error {
+	args := m.Called(ctx, userID, hash)
+	return args.Error(0)
+}
+
+func newTestTokenIssuer() *auth.TokenIssuer {
+	return auth.NewTokenIssuer(auth.TokenIssuerConfig{
+		Method:     jwt.SigningMethodHS256,
+		Key:        []byte("test-secret"),
+		AccessTTL:  time.Minute,
+		RefreshTTL: time.Hour,
+
+		MFAChallengeTTL: 5 * time.Minute,
+	})
+}
+
+func newTestAuthService(params auth.Argon2Params) (*AuthService, *MockUserRepository, *MockCredentialRepository, *recordingAuditRepository) {
+	users := new(MockUserRepository)
+	credentials := new(MockCredentialRepository)
+	audit := new(recordingAuditRepository)
+	service := NewAuthService(users, credentials, newMemorySessionRepository(), audit, fakeTransactor{}, auth.NewPasswordHasher(params), newTestTokenIssuer(), nil, nil)
+	return service, users, credentials, audit
+}
+
+func TestLogin_Success(t *testing.T) {
+	service, _, credentials, _ := newTestAuthService(testArgon2Params)
+
+	hash, err := auth.NewPasswordHasher(testArgon2Params).Hash("secret-password")
+	assert.NoError(t, err)
+	credentials.On("GetCredentialsByEmail", mock.Anything, "ivan@example.com").Return(&domain.User{ID: 7}, hash, nil)
+
+	tokens, err := service.Login(context.Background(), "ivan@example.com", "secret-password", domain.SessionMeta{})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, tokens.AccessToken)
+	assert.NotEmpty(t, tokens.RefreshToken)
+	assert.Equal(t, "Bearer", tokens.TokenType)
+	assert.Equal(t, int64(60), tokens.ExpiresIn)
+	credentials.AssertNotCalled(t, "SetPasswordHash", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestLogin_RehashesOutdatedHash(t *testing.T) {
+	stronger := testArgon2Params
+	stronger.Iterations = 2
+	service, _, credentials, audit := newTestAuthService(stronger)
+
+	hash, err := auth.NewPasswordHasher(testArgon2Params).Hash("secret-password")
+	assert.NoError(t, err)
+	credentials.On("GetCredentialsByEmail", mock.Anything, "ivan@example.com").Return(&domain.User{ID: 7}, hash, nil)
+	credentials.On("SetPasswordHash", mock.Anything, int64(7), mock.MatchedBy(func(h string) bool {
+		return h != hash
+	})).Return(nil)
+
+	_, err = service.Login(context.Background(), "ivan@example.com", "secret-password", domain.SessionMeta{})
+	assert.NoError(t, err)
+	credentials.AssertExpectations(t)
+	if assert.Len(t, audit.records, 1) {
+		assert.Equal(t, domain.AuditActionPasswordRehashed, audit.records[0].Action)
+		assert.Equal(t, int64(7), audit.records[0].EntityID)
+	}
+}
+
+func TestLogin_InvalidCredentials(t *testing.T) {
+	service, _, credentials, _ := newTestAuthService(testArgon2Params)
+
+	hash, err := auth.NewPasswordHasher(testArgon2Params).Hash("secret-password")
+	assert.NoError(t, err)
+	credentials.On("GetCredentialsByEmail", mock.Anything, "ivan@example.com").Return(&domain.User{ID: 7}, hash, nil)
+	credentials.On("GetCredentialsByEmail", mock.Anything, "nobody@example.com").Return((*domain.User)(nil), "", repository.ErrUserNotFound)
+	credentials.On("GetCredentialsByEmail", mock.Anything, "nopassword@example.com").Return(&domain.User{ID: 8}, "", nil)
+
+	_, err = service.Login(context.Background(), "ivan@example.com", "wrong-password", domain.SessionMeta{})
+	assert.ErrorIs(t, err, ErrInvalidLogin)
+	_, err = service.Login(context.Background(), "nobody@example.com", "secret-password", domain.SessionMeta{})
+	assert.ErrorIs(t, err, ErrInvalidLogin)
+	_, err = service.Login(context.Background(), "nopassword@example.com", "secret-password", domain.SessionMeta{})
+	assert.ErrorIs(t, err, ErrInvalidLogin)
+}
+
+func loginForTest(t *testing.T, service *AuthService, credentials *MockCredentialRepository) *domain.TokenPair {
+	hash, err := auth.NewPasswordHasher(testArgon2Params).Hash("secret-password")
+	assert.NoError(t, err)
+	credentials.On("GetCredentialsByEmail", mock.Anything, "ivan@example.com").Return(&domain.User{ID: 7}, hash, nil)
+
+	tokens, err := service.Login(context.Background(), "ivan@example.com", "secret-password", domain.SessionMeta{UserAgent: "curl/8.0", IP: "10.0.0.1"})
+	assert.NoError(t, err)
+	return tokens
+}
+
+func TestRefresh_RotatesToken(t *testing.T) {
+	service, users, credentials, _ := newTestAuthService(testArgon2Params)
+	users.On("GetUserByID", mock.Anything, int64(7)).Return(&domain.User{ID: 7}, nil)
+	first := loginForTest(t, service, credentials)
+
+	second, err := service.Refresh(context.Background(), first.RefreshToken, domain.SessionMeta{UserAgent: "Firefox", IP: "10.0.0.2"})
+	assert.NoError(t, err)
+	assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+
+	sessions := service.sessions.(*memorySessionRepository)
+	assert.Len(t, sessions.sessions, 1)
+	assert.Equal(t, "Firefox", sessions.sessions[1].UserAgent)
+	assert.Equal(t, "10.0.0.2", sessions.sessions[1].IP)
+
+	_, err = service.Refresh(context.Background(), first.AccessToken, domain.SessionMeta{})
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+}
+
+func TestRefresh_ReuseRevokesSession(t *testing.T) {
+	service, users, credentials, audit := newTestAuthService(testArgon2Params)
+	users.On("GetUserByID", mock.Anything, int64(7)).Return(&domain.User{ID: 7}, nil)
+	first := loginForTest(t, service, credentials)
+
+	second, err := service.Refresh(context.Background(), first.RefreshToken, domain.SessionMeta{})
+	assert.NoError(t, err)
+
+	_, err = service.Refresh(context.Background(), first.RefreshToken, domain.SessionMeta{})
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+
+	sessions := service.sessions.(*memorySessionRepository)
+	assert.NotNil(t, sessions.sessions[1].RevokedAt)
+	assert.Equal(t, domain.SessionRevokedReuse, sessions.sessions[1].RevokeReason)
+	assert.Len(t, audit.records, 1)
+	assert.Equal(t, domain.AuditActionSessionReuseDetected, audit.records[0].Action)
+
+	// Законный токен той же сессии после обнаружения утечки тоже не принимается.
+	_, err = service.Refresh(context.Background(), second.RefreshToken, domain.SessionMeta{})
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+}
+
+func TestLogout(t *testing.T) {
+	service, _, credentials, _ := newTestAuthService(testArgon2Params)
+	tokens := loginForTest(t, service, credentials)
+
+	assert.NoError(t, service.Logout(context.Background(), tokens.RefreshToken))
+	sessions := service.sessions.(*memorySessionRepository)
+	assert.Equal(t, domain.SessionRevokedLogout, sessions.sessions[1].RevokeReason)
+
+	_, err := service.Refresh(context.Background(), tokens.RefreshToken, domain.SessionMeta{})
+	assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+}
+
+func TestChangePassword(t *testing.T) {
+	service, users, credentials, audit := newTestAuthService(testArgon2Params)
+	users.On("GetUserByID", mock.Anything, int64(7)).Return(&domain.User{ID: 7, Email: "ivan@example.com"}, nil)
+
+	hash, err := auth.NewPasswordHasher(testArgon2Params).Hash("old-password")
+	assert.NoError(t, err)
+	credentials.On("GetPasswordHash", mock.Anything, int64(7)).Return(hash, nil)
+	credentials.On("SetPasswordHash", mock.Anything, int64(7), mock.AnythingOfType("string")).Return(nil)
+
+	err = service.ChangePassword(context.Background(), 7, "wrong-password", "new-password")
+	assert.ErrorIs(t, err, ErrWrongPassword)
+
+	err = service.ChangePassword(context.Background(), 7, "old-password", "short")
+	assert.ErrorIs(t, err, ErrWeakPassword)
+
+	err = service.ChangePassword(context.Background(), 7, "old-password", "new-password")
+	assert.NoError(t, err)
+	assert.Len(t, audit.records, 2)
+	assert.Equal(t, domain.AuditActionUserPasswordChangeFailed, audit.records[0].Action)
+	assert.Equal(t, domain.AuditActionUserPasswordChanged, audit.records[1].Action)
+	assert.Empty(t, audit.records[1].After)
+}
+
+func TestChangePassword_RevokesOtherSessions(t *testing.T) {
+	service, users, credentials, audit := newTestAuthService(testArgon2Params)
+	users.On("GetUserByID", mock.Anything, int64(7)).Return(&domain.User{ID: 7, Email: "ivan@example.com"}, nil)
+	sessions := service.sessions.(*memorySessionRepository)
+
+	hash, err := auth.NewPasswordHasher(testArgon2Params).Hash("old-password")
+	assert.NoError(t, err)
+	credentials.On("GetPasswordHash", mock.Anything, int64(7)).Return(hash, nil)
+	credentials.On("SetPasswordHash", mock.Anything, int64(7), mock.AnythingOfType("string")).Return(nil)
+
+	var opened []*domain.Session
+	for range 3 {
+		session := &domain.Session{UserID: 7, ExpiresAt: time.Now().Add(time.Hour)}
+		assert.NoError(t, sessions.CreateSession(context.Background(), session))
+		opened = append(opened, session)
+	}
+	principal := auth.NewPrincipal("7", auth.MethodJWT)
+	principal.SessionID = opened[0].ID
+	ctx := auth.WithPrincipal(context.Background(), principal)
+
+	// Пользователь остается в текущей сессии, остальные отзываются.
+	assert.NoError(t, service.ChangePassword(ctx, 7, "old-password", "new-password"))
+	active, _ := sessions.ListActiveSessions(context.Background(), 7)
+	assert.Equal(t, []int64{opened[0].ID}, sessionIDs(active))
+	assert.Equal(t, domain.SessionRevokedPasswordChanged, sessions.sessions[opened[1].ID].RevokeReason)
+	assert.Len(t, audit.records, 3)
+
+	// Пароль, заданный администратором, отзывает все сессии.
+	assert.NoError(t, service.SetPassword(principalContext("1"), 7, "admin-password"))
+	active, _ = sessions.ListActiveSessions(context.Background(), 7)
+	assert.Empty(t, active)
+}
+
+func sessionIDs(sessions []domain.Session) []int64 {
+	ids := make([]int64, 0, len(sessions))
+	for _, session := range sessions {
+		ids = append(ids, session.ID)
+	}
+	return ids
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"testovoe/internal/apperr"
+	"testovoe/internal/auth"
+	"testovoe/internal/domain"
+)
+
+var ErrForbidden = apperr.New(apperr.KindForbidden, "forbidden", "доступ запрещен")
+
+// Причины отказа в доступе, которые возвращаются клиенту в поле reason.
+const (
+	DenyReasonUnauthenticated   = "unauthenticated"
+	DenyReasonMissingPermission = "missing_permission"
+	// DenyReasonTargetRoles — у пользователя, над которым выполняется действие, есть роль, которой нет у клиента.
+	DenyReasonTargetRoles = "target_roles"
+)
+
+type AccessDeniedError struct {
+	Permission domain.Permission
+	Reason     string
+}
+
+func (e *AccessDeniedError) Error() string {
+	return ErrForbidden.Error() + ": " + string(e.Permission)
+}
+
+func (e *AccessDeniedError) Unwrap() error {
+	return ErrForbidden
+}
+
+type RoleLoader interface {
+	GetUserRoles(ctx context.Context, userID int64) ([]domain.Role, error)
+	GetAssignedRoles(ctx context.Context, userID int64) ([]domain.Role, error)
+}
+
+// Authorizer проверяет права клиента из контекста запроса по ролям, хранящимся в базе.
+type Authorizer struct {
+	roles RoleLoader
+}
+
+func NewAuthorizer(roles RoleLoader) *Authorizer {
+	return &Authorizer{roles: roles}
+}
+
+// Authorize разрешает действие, если у клиента есть право через одну из ролей.
+// ownerID — пользователь, к данным которого относится действие; если он совпадает с клиентом,
+// клиент дополнительно получает роль self. Для действий над коллекцией передается 0.
+func (a *Authorizer) Authorize(ctx context.Context, permission domain.Permission, ownerID int64) error {
+	_, _, err := a.authorize(ctx, permission, ownerID)
+	return err
+}
+
+// AuthorizeTarget проверяет действие над учетной записью другого пользователя: кроме права permission
+// клиенту нужны все роли этого пользователя или право roles:manage. Иначе оператор мог бы, например,
+// сменить email администратора и отправить на него письмо сброса пароля. Над собственной учетной записью
+// действие проверяется как в Authorize.
+func (a *Authorizer) AuthorizeTarget(ctx context.Context, permission domain.Permission, targetID int64) error {
+	principal, roles, err := a.authorize(ctx, permission, targetID)
+	if err != nil || targetID == principal.UserID {
+		return err
+	}
+	if slices.ContainsFunc(roles, func(role domain.Role) bool { return role.Has(domain.PermissionRolesManage) }) {
+		return nil
+	}
+	targetRoles, err := a.roles.GetAssignedRoles(ctx, targetID)
+	if err != nil {
+		return err
+	}
+	for _, role := range targetRoles {
+		if !slices.Contains(roles, role) {
+			return &AccessDeniedError{Permission: permission, Reason: DenyReasonTargetRoles}
+		}
+	}
+	return nil
+}
+
+// authorize возвращает клиента и его роли; если право получено через роль self, роли не загружаются.
+func (a *Authorizer) authorize(ctx context.Context, permission domain.Permission, ownerID int64) (*auth.Principal, []domain.Role, error) {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return nil, nil, &AccessDeniedError{Permission: permission, Reason: DenyReasonUnauthenticated}
+	}
+	if principal.UserID == 0 {
+		return nil, nil, &AccessDeniedError{Permission: permission, Reason: DenyReasonMissingPermission}
+	}
+
+	if ownerID != 0 && ownerID == principal.UserID && domain.RoleSelf.Has(permission) {
+		return principal, nil, nil
+	}
+
+	roles, err := a.roles.GetUserRoles(ctx, principal.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, role := range roles {
+		if role.Has(permission) {
+			return principal, roles, nil
+		}
+	}
+	return nil, nil, &AccessDeniedError{Permission: permission, Reason: DenyReasonMissingPermission}
+}
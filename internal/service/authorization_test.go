@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+	"testovoe/internal/auth"
+	"testovoe/internal/domain"
+)
+
+func principalContext(subject string) context.Context {
+	return auth.WithPrincipal(context.Background(), auth.NewPrincipal(subject, auth.MethodJWT))
+}
+
+func TestAuthorizer(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockRepo.On("GetUserRoles", mock.Anything, int64(1)).Return([]domain.Role{domain.RoleAdmin}, nil)
+	mockRepo.On("GetUserRoles", mock.Anything, int64(2)).Return([]domain.Role{domain.RoleViewer}, nil)
+	mockRepo.On("GetUserRoles", mock.Anything, int64(3)).Return([]domain.Role{}, nil)
+	authz := NewAuthorizer(mockRepo)
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		permission domain.Permission
+		ownerID    int64
+		reason     string
+	}{
+		{"admin purges", principalContext("1"), domain.PermissionUsersPurge, 0, ""},
+		{"viewer reads", principalContext("2"), domain.PermissionUsersRead, 0, ""},
+		{"viewer cannot delete", principalContext("2"), domain.PermissionUsersDelete, 0, DenyReasonMissingPermission},
+		{"self updates own profile", principalContext("3"), domain.PermissionUsersUpdate, 3, ""},
+		{"self cannot update others", principalContext("3"), domain.PermissionUsersUpdate, 4, DenyReasonMissingPermission},
+		{"self cannot list", principalContext("3"), domain.PermissionUsersRead, 0, DenyReasonMissingPermission},
+		{"non-numeric subject", principalContext("service-account"), domain.PermissionUsersRead, 0, DenyReasonMissingPermission},
+		{"anonymous", context.Background(), domain.PermissionUsersRead, 0, DenyReasonUnauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := authz.Authorize(tt.ctx, tt.permission, tt.ownerID)
+			if tt.reason == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			var denied *AccessDeniedError
+			assert.True(t, errors.As(err, &denied))
+			assert.ErrorIs(t, err, ErrForbidden)
+			assert.Equal(t, tt.reason, denied.Reason)
+			assert.Equal(t, tt.permission, denied.Permission)
+		})
+	}
+}
+
+func TestAuthorizedUserService_DeniesBeforeCallingService(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockRepo.On("GetUserRoles", mock.Anything, int64(2)).Return([]domain.Role{domain.RoleViewer}, nil)
+	inner, _ := newTestUserService(mockRepo)
+	service := NewAuthorizedUserService(inner, NewAuthorizer(mockRepo))
+
+	err := service.PurgeUserByID(principalContext("2"), 5)
+	assert.ErrorIs(t, err, ErrForbidden)
+	mockRepo.AssertNotCalled(t, "PurgeUserByID", mock.Anything, mock.Anything)
+}
+
+func TestAuthorizedMFAService_GetMFAStatus(t *testing.T) {
+	inner, users, _, _ := newTestMFAService()
+	users.On("GetUserByID", mock.Anything, int64(7)).Return(&domain.User{ID: 7, Email: "ivan@example.com"}, nil)
+	users.On("GetUserRoles", mock.Anything, int64(1)).Return([]domain.Role{domain.RoleAdmin}, nil)
+	users.On("GetUserRoles", mock.Anything, int64(2)).Return([]domain.Role{domain.RoleViewer}, nil)
+	users.On("GetUserRoles", mock.Anything, int64(3)).Return([]domain.Role{domain.RoleOperator}, nil)
+	service := NewAuthorizedMFAService(inner, NewAuthorizer(users))
+
+	for _, subject := range []string{"7", "1"} {
+		_, err := service.GetMFAStatus(principalContext(subject), 7)
+		assert.NoError(t, err, subject)
+	}
+	for _, subject := range []string{"2", "3"} {
+		_, err := service.GetMFAStatus(principalContext(subject), 7)
+		assert.ErrorIs(t, err, ErrForbidden, subject)
+	}
+}
+
+func TestAuthorizer_AuthorizeTarget(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockRepo.On("GetUserRoles", mock.Anything, int64(1)).Return([]domain.Role{domain.RoleAdmin}, nil)
+	mockRepo.On("GetUserRoles", mock.Anything, int64(3)).Return([]domain.Role{domain.RoleOperator}, nil)
+	mockRepo.On("GetAssignedRoles", mock.Anything, int64(1)).Return([]domain.Role{domain.RoleAdmin}, nil)
+	mockRepo.On("GetAssignedRoles", mock.Anything, int64(2)).Return([]domain.Role{domain.RoleViewer}, nil)
+	mockRepo.On("GetAssignedRoles", mock.Anything, int64(4)).Return([]domain.Role{domain.RoleOperator}, nil)
+	mockRepo.On("GetAssignedRoles", mock.Anything, int64(5)).Return([]domain.Role{}, nil)
+	authz := NewAuthorizer(mockRepo)
+
+	tests := []struct {
+		name       string
+		subject    string
+		permission domain.Permission
+		targetID   int64
+		reason     string
+	}{
+		{"operator updates user without roles", "3", domain.PermissionUsersUpdate, 5, ""},
+		{"operator updates other operator", "3", domain.PermissionUsersUpdate, 4, ""},
+		{"operator updates self", "3", domain.PermissionUsersUpdate, 3, ""},
+		{"operator cannot update viewer", "3", domain.PermissionUsersUpdate, 2, DenyReasonTargetRoles},
+		{"operator cannot update admin", "3", domain.PermissionUsersUpdate, 1, DenyReasonTargetRoles},
+		{"operator cannot delete admin", "3", domain.PermissionUsersDelete, 1, DenyReasonTargetRoles},
+		{"operator cannot reset admin password", "3", domain.PermissionPasswordReset, 1, DenyReasonTargetRoles},
+		{"admin manages roles", "1", domain.PermissionUsersUpdate, 4, ""},
+		{"missing permission comes first", "3", domain.PermissionUsersPurge, 5, DenyReasonMissingPermission},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := authz.AuthorizeTarget(principalContext(tt.subject), tt.permission, tt.targetID)
+			if tt.reason == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			var denied *AccessDeniedError
+			assert.True(t, errors.As(err, &denied))
+			assert.ErrorIs(t, err, ErrForbidden)
+			assert.Equal(t, tt.reason, denied.Reason)
+		})
+	}
+}
+
+func TestAuthorizedServices_OperatorCannotTakeOverAdmin(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockRepo.On("GetUserRoles", mock.Anything, int64(3)).Return([]domain.Role{domain.RoleOperator}, nil)
+	mockRepo.On("GetAssignedRoles", mock.Anything, int64(1)).Return([]domain.Role{domain.RoleAdmin}, nil)
+	inner, _ := newTestUserService(mockRepo)
+	authz := NewAuthorizer(mockRepo)
+	users := NewAuthorizedUserService(inner, authz)
+	resets := NewAuthorizedPasswordResetService(nil, authz)
+	ctx := principalContext("3")
+
+	_, err := users.PatchUserByID(ctx, 1, 1, MergePatch, []byte(`{"email":"operator@example.com"}`))
+	assert.ErrorIs(t, err, ErrForbidden)
+	assert.ErrorIs(t, users.UpdateUserByID(ctx, 1, &domain.User{Name: "Админ", Email: "operator@example.com"}), ErrForbidden)
+	assert.ErrorIs(t, users.DeleteUserByID(ctx, 1, 1), ErrForbidden)
+	assert.ErrorIs(t, users.RestoreUserByID(ctx, 1), ErrForbidden)
+	assert.ErrorIs(t, resets.SendPasswordReset(ctx, 1), ErrForbidden)
+	mockRepo.AssertNotCalled(t, "GetUserByID", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "PatchUserByID", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "UpdateUserByID", mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "DeleteUserByID", mock.Anything, mock.Anything, mock.Anything)
+}
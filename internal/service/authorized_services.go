@@ -0,0 +1,541 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testovoe/internal/auth"
+	"testovoe/internal/domain"
+)
+
+// AuthorizedUserService проверяет права клиента перед каждым вызовом UserServiceInterface,
+// поэтому политика доступа не зависит от транспорта. Изменять, удалять и восстанавливать чужую
+// учетную запись можно только при всех ее ролях (см. Authorizer.AuthorizeTarget).
+type AuthorizedUserService struct {
+	next  UserServiceInterface
+	authz *Authorizer
+}
+
+func NewAuthorizedUserService(next UserServiceInterface, authz *Authorizer) *AuthorizedUserService {
+	return &AuthorizedUserService{next: next, authz: authz}
+}
+
+func (s *AuthorizedUserService) CreateUser(ctx context.Context, user *domain.User) error {
+	if err := s.authz.Authorize(ctx, domain.PermissionUsersCreate, 0); err != nil {
+		return err
+	}
+	return s.next.CreateUser(ctx, user)
+}
+
+func (s *AuthorizedUserService) GetUserByID(ctx context.Context, id int64) (*domain.User, error) {
+	if err := s.authz.Authorize(ctx, domain.PermissionUsersRead, id); err != nil {
+		return nil, err
+	}
+	return s.next.GetUserByID(ctx, id)
+}
+
+func (s *AuthorizedUserService) UpdateUserByID(ctx context.Context, id int64, user *domain.User) error {
+	if err := s.authz.AuthorizeTarget(ctx, domain.PermissionUsersUpdate, id); err != nil {
+		return err
+	}
+	return s.next.UpdateUserByID(ctx, id, user)
+}
+
+func (s *AuthorizedUserService) PatchUserByID(ctx context.Context, id int64, version int64, format PatchFormat, patch []byte) (*domain.User, error) {
+	if err := s.authz.AuthorizeTarget(ctx, domain.PermissionUsersUpdate, id); err != nil {
+		return nil, err
+	}
+	return s.next.PatchUserByID(ctx, id, version, format, patch)
+}
+
+func (s *AuthorizedUserService) DeleteUserByID(ctx context.Context, id int64, version int64) error {
+	if err := s.authz.AuthorizeTarget(ctx, domain.PermissionUsersDelete, id); err != nil {
+		return err
+	}
+	return s.next.DeleteUserByID(ctx, id, version)
+}
+
+func (s *AuthorizedUserService) RestoreUserByID(ctx context.Context, id int64) error {
+	if err := s.authz.AuthorizeTarget(ctx, domain.PermissionUsersRestore, id); err != nil {
+		return err
+	}
+	return s.next.RestoreUserByID(ctx, id)
+}
+
+func (s *AuthorizedUserService) PurgeUserByID(ctx context.Context, id int64) error {
+	if err := s.authz.Authorize(ctx, domain.PermissionUsersPurge, 0); err != nil {
+		return err
+	}
+	return s.next.PurgeUserByID(ctx, id)
+}
+
+func (s *AuthorizedUserService) ListUsers(ctx context.Context, filter domain.UserFilter) (*domain.UserList, error) {
+	if err := s.authz.Authorize(ctx, domain.PermissionUsersRead, 0); err != nil {
+		return nil, err
+	}
+	return s.next.ListUsers(ctx, filter)
+}
+
+func (s *AuthorizedUserService) ExportUsers(ctx context.Context, filter domain.UserFilter, fn func(user *domain.User) error) error {
+	if err := s.authz.Authorize(ctx, domain.PermissionUsersExport, 0); err != nil {
+		return err
+	}
+	return s.next.ExportUsers(ctx, filter, fn)
+}
+
+func (s *AuthorizedUserService) ImportUsers(ctx context.Context, rows []domain.UserImportRow, mode domain.UserImportMode) (*domain.UserImportResult, error) {
+	if err := s.authz.Authorize(ctx, domain.PermissionUsersImport, 0); err != nil {
+		return nil, err
+	}
+	return s.next.ImportUsers(ctx, rows, mode)
+}
+
+func (s *AuthorizedUserService) GetUserRoles(ctx context.Context, id int64) ([]domain.Role, error) {
+	if err := s.authz.Authorize(ctx, domain.PermissionUsersRead, id); err != nil {
+		return nil, err
+	}
+	return s.next.GetUserRoles(ctx, id)
+}
+
+func (s *AuthorizedUserService) SetUserRoles(ctx context.Context, id int64, roles []domain.Role) ([]domain.Role, error) {
+	if err := s.authz.Authorize(ctx, domain.PermissionRolesManage, 0); err != nil {
+		return nil, err
+	}
+	return s.next.SetUserRoles(ctx, id, roles)
+}
+
+// AuthorizedAuditService закрывает общий журнал аудита правом audit:read,
+// а историю пользователя — правом на чтение этого пользователя.
+type AuthorizedAuditService struct {
+	next  AuditServiceInterface
+	authz *Authorizer
+}
+
+func NewAuthorizedAuditService(next AuditServiceInterface, authz *Authorizer) *AuthorizedAuditService {
+	return &AuthorizedAuditService{next: next, authz: authz}
+}
+
+func (s *AuthorizedAuditService) ListAuditRecords(ctx context.Context, filter domain.AuditFilter) (*domain.AuditList, error) {
+	if err := s.authz.Authorize(ctx, domain.PermissionAuditRead, 0); err != nil {
+		return nil, err
+	}
+	return s.next.ListAuditRecords(ctx, filter)
+}
+
+func (s *AuthorizedAuditService) GetUserHistory(ctx context.Context, userID int64, filter domain.AuditFilter) (*domain.AuditList, error) {
+	if err := s.authz.Authorize(ctx, domain.PermissionUsersRead, userID); err != nil {
+		return nil, err
+	}
+	return s.next.GetUserHistory(ctx, userID, filter)
+}
+
+// AuthorizedAPIKeyService позволяет управлять ключами только их владельцу и администратору.
+// Проверка ключа при аутентификации не ограничивается: клиента в этот момент еще нет.
+type AuthorizedAPIKeyService struct {
+	next  APIKeyServiceInterface
+	authz *Authorizer
+}
+
+func NewAuthorizedAPIKeyService(next APIKeyServiceInterface, authz *Authorizer) *AuthorizedAPIKeyService {
+	return &AuthorizedAPIKeyService{next: next, authz: authz}
+}
+
+func (s *AuthorizedAPIKeyService) CreateAPIKey(ctx context.Context, userID int64, name string) (*domain.IssuedAPIKey, error) {
+	if err := s.authz.Authorize(ctx, domain.PermissionAPIKeys, userID); err != nil {
+		return nil, err
+	}
+	return s.next.CreateAPIKey(ctx, userID, name)
+}
+
+func (s *AuthorizedAPIKeyService) ListAPIKeys(ctx context.Context, userID int64) ([]domain.APIKey, error) {
+	if err := s.authz.Authorize(ctx, domain.PermissionAPIKeys, userID); err != nil {
+		return nil, err
+	}
+	return s.next.ListAPIKeys(ctx, userID)
+}
+
+func (s *AuthorizedAPIKeyService) RotateAPIKey(ctx context.Context, userID, id int64) (*domain.IssuedAPIKey, error) {
+	if err := s.authz.Authorize(ctx, domain.PermissionAPIKeys, userID); err != nil {
+		return nil, err
+	}
+	return s.next.RotateAPIKey(ctx, userID, id)
+}
+
+func (s *AuthorizedAPIKeyService) RevokeAPIKey(ctx context.Context, userID, id int64) error {
+	if err := s.authz.Authorize(ctx, domain.PermissionAPIKeys, userID); err != nil {
+		return err
+	}
+	return s.next.RevokeAPIKey(ctx, userID, id)
+}
+
+func (s *AuthorizedAPIKeyService) AuthenticateAPIKey(ctx context.Context, key string) (*domain.APIKey, error) {
+	return s.next.AuthenticateAPIKey(ctx, key)
+}
+
+// AuthorizedSessionService позволяет просматривать и отзывать сессии только их владельцу и администратору.
+type AuthorizedSessionService struct {
+	next  SessionServiceInterface
+	authz *Authorizer
+}
+
+func NewAuthorizedSessionService(next SessionServiceInterface, authz *Authorizer) *AuthorizedSessionService {
+	return &AuthorizedSessionService{next: next, authz: authz}
+}
+
+func (s *AuthorizedSessionService) ListSessions(ctx context.Context, userID int64) ([]domain.Session, error) {
+	if err := s.authz.Authorize(ctx, domain.PermissionSessions, userID); err != nil {
+		return nil, err
+	}
+	return s.next.ListSessions(ctx, userID)
+}
+
+func (s *AuthorizedSessionService) RevokeSession(ctx context.Context, userID, id int64) error {
+	if err := s.authz.Authorize(ctx, domain.PermissionSessions, userID); err != nil {
+		return err
+	}
+	return s.next.RevokeSession(ctx, userID, id)
+}
+
+func (s *AuthorizedSessionService) RevokeAllSessions(ctx context.Context, userID int64) error {
+	if err := s.authz.Authorize(ctx, domain.PermissionSessions, userID); err != nil {
+		return err
+	}
+	return s.next.RevokeAllSessions(ctx, userID)
+}
+
+// AuthorizedEmailVerificationService не ограничивает подтверждение по токену: токен сам по себе
+// доказывает доступ к почтовому ящику. Повторная отправка письма доступна тем, кто может изменять пользователя.
+type AuthorizedEmailVerificationService struct {
+	next  EmailVerificationServiceInterface
+	authz *Authorizer
+}
+
+func NewAuthorizedEmailVerificationService(next EmailVerificationServiceInterface, authz *Authorizer) *AuthorizedEmailVerificationService {
+	return &AuthorizedEmailVerificationService{next: next, authz: authz}
+}
+
+func (s *AuthorizedEmailVerificationService) VerifyEmail(ctx context.Context, token string) (*domain.User, error) {
+	return s.next.VerifyEmail(ctx, token)
+}
+
+func (s *AuthorizedEmailVerificationService) ResendVerification(ctx context.Context, userID int64) error {
+	if err := s.authz.Authorize(ctx, domain.PermissionUsersUpdate, userID); err != nil {
+		return err
+	}
+	return s.next.ResendVerification(ctx, userID)
+}
+
+// AuthorizedAuthService не ограничивает вход и обновление токенов: они доступны без аутентификации.
+type AuthorizedAuthService struct {
+	next  AuthServiceInterface
+	authz *Authorizer
+}
+
+func NewAuthorizedAuthService(next AuthServiceInterface, authz *Authorizer) *AuthorizedAuthService {
+	return &AuthorizedAuthService{next: next, authz: authz}
+}
+
+func (s *AuthorizedAuthService) Login(ctx context.Context, email, password string, meta domain.SessionMeta) (*domain.TokenPair, error) {
+	return s.next.Login(ctx, email, password, meta)
+}
+
+func (s *AuthorizedAuthService) Refresh(ctx context.Context, refreshToken string, meta domain.SessionMeta) (*domain.TokenPair, error) {
+	return s.next.Refresh(ctx, refreshToken, meta)
+}
+
+// Logout не требует аутентификации: достаточно предъявить refresh-токен сессии.
+func (s *AuthorizedAuthService) Logout(ctx context.Context, refreshToken string) error {
+	return s.next.Logout(ctx, refreshToken)
+}
+
+func (s *AuthorizedAuthService) SetPassword(ctx context.Context, userID int64, password string) error {
+	if err := s.authz.Authorize(ctx, domain.PermissionPasswordSet, 0); err != nil {
+		return err
+	}
+	return s.next.SetPassword(ctx, userID, password)
+}
+
+func (s *AuthorizedAuthService) ChangePassword(ctx context.Context, userID int64, current, next string) error {
+	if err := s.authz.Authorize(ctx, domain.PermissionPasswordChange, userID); err != nil {
+		return err
+	}
+	return s.next.ChangePassword(ctx, userID, current, next)
+}
+
+// AuthorizedPasswordResetService не ограничивает запрос и подтверждение сброса: их выполняет
+// пользователь, забывший пароль. Отправить письмо сброса за пользователя может только поддержка,
+// и только пользователю без ролей, которых нет у нее самой.
+type AuthorizedPasswordResetService struct {
+	next  PasswordResetServiceInterface
+	authz *Authorizer
+}
+
+func NewAuthorizedPasswordResetService(next PasswordResetServiceInterface, authz *Authorizer) *AuthorizedPasswordResetService {
+	return &AuthorizedPasswordResetService{next: next, authz: authz}
+}
+
+func (s *AuthorizedPasswordResetService) RequestPasswordReset(ctx context.Context, email string) error {
+	return s.next.RequestPasswordReset(ctx, email)
+}
+
+func (s *AuthorizedPasswordResetService) SendPasswordReset(ctx context.Context, userID int64) error {
+	if err := s.authz.AuthorizeTarget(ctx, domain.PermissionPasswordReset, userID); err != nil {
+		return err
+	}
+	return s.next.SendPasswordReset(ctx, userID)
+}
+
+func (s *AuthorizedPasswordResetService) ConfirmPasswordReset(ctx context.Context, token, password string) error {
+	return s.next.ConfirmPasswordReset(ctx, token, password)
+}
+
+// AuthorizedMFAService разрешает настраивать второй фактор только самому пользователю: для этого
+// нужен доступ к его приложению-аутентификатору. Администратор может сбросить второй фактор
+// и задать политику. Шаги входа доступны без аутентификации: их защищает токен, выданный после проверки пароля.
+type AuthorizedMFAService struct {
+	next  MFAServiceInterface
+	authz *Authorizer
+}
+
+func NewAuthorizedMFAService(next MFAServiceInterface, authz *Authorizer) *AuthorizedMFAService {
+	return &AuthorizedMFAService{next: next, authz: authz}
+}
+
+// GetMFAStatus показывает состояние второго фактора самому пользователю и тем, кто может его сбросить.
+func (s *AuthorizedMFAService) GetMFAStatus(ctx context.Context, userID int64) (*domain.MFAStatus, error) {
+	if err := s.authz.Authorize(ctx, domain.PermissionMFA, userID); err != nil {
+		if !errors.Is(err, ErrForbidden) {
+			return nil, err
+		}
+		if err := s.authz.Authorize(ctx, domain.PermissionMFAReset, 0); err != nil {
+			return nil, err
+		}
+	}
+	return s.next.GetMFAStatus(ctx, userID)
+}
+
+func (s *AuthorizedMFAService) StartMFAEnrollment(ctx context.Context, userID int64) (*domain.MFAEnrollment, error) {
+	if err := s.authz.Authorize(ctx, domain.PermissionMFA, userID); err != nil {
+		return nil, err
+	}
+	return s.next.StartMFAEnrollment(ctx, userID)
+}
+
+func (s *AuthorizedMFAService) ConfirmMFAEnrollment(ctx context.Context, userID int64, code string) ([]string, error) {
+	if err := s.authz.Authorize(ctx, domain.PermissionMFA, userID); err != nil {
+		return nil, err
+	}
+	return s.next.ConfirmMFAEnrollment(ctx, userID, code)
+}
+
+func (s *AuthorizedMFAService) RegenerateRecoveryCodes(ctx context.Context, userID int64, proof domain.MFAProof) ([]string, error) {
+	if err := s.authz.Authorize(ctx, domain.PermissionMFA, userID); err != nil {
+		return nil, err
+	}
+	return s.next.RegenerateRecoveryCodes(ctx, userID, proof)
+}
+
+func (s *AuthorizedMFAService) DisableMFA(ctx context.Context, userID int64, proof domain.MFAProof) error {
+	if err := s.authz.Authorize(ctx, domain.PermissionMFA, userID); err != nil {
+		return err
+	}
+	return s.next.DisableMFA(ctx, userID, proof)
+}
+
+func (s *AuthorizedMFAService) ResetMFA(ctx context.Context, userID int64) error {
+	if err := s.authz.Authorize(ctx, domain.PermissionMFAReset, 0); err != nil {
+		return err
+	}
+	return s.next.ResetMFA(ctx, userID)
+}
+
+func (s *AuthorizedMFAService) GetMFAPolicy(ctx context.Context) ([]domain.Role, error) {
+	if err := s.authz.Authorize(ctx, domain.PermissionRolesManage, 0); err != nil {
+		return nil, err
+	}
+	return s.next.GetMFAPolicy(ctx)
+}
+
+func (s *AuthorizedMFAService) SetMFAPolicy(ctx context.Context, roles []domain.Role) ([]domain.Role, error) {
+	if err := s.authz.Authorize(ctx, domain.PermissionRolesManage, 0); err != nil {
+		return nil, err
+	}
+	return s.next.SetMFAPolicy(ctx, roles)
+}
+
+func (s *AuthorizedMFAService) EnrollMFAChallenge(ctx context.Context, mfaToken string) (*domain.MFAEnrollment, error) {
+	return s.next.EnrollMFAChallenge(ctx, mfaToken)
+}
+
+func (s *AuthorizedMFAService) VerifyMFAChallenge(ctx context.Context, mfaToken string, proof domain.MFAProof, meta domain.SessionMeta) (*domain.TokenPair, error) {
+	return s.next.VerifyMFAChallenge(ctx, mfaToken, proof, meta)
+}
+
+// AuthorizedOAuthClientService разрешает регистрировать клиентов OpenID Connect только администратору.
+type AuthorizedOAuthClientService struct {
+	next  OAuthClientServiceInterface
+	authz *Authorizer
+}
+
+func NewAuthorizedOAuthClientService(next OAuthClientServiceInterface, authz *Authorizer) *AuthorizedOAuthClientService {
+	return &AuthorizedOAuthClientService{next: next, authz: authz}
+}
+
+func (s *AuthorizedOAuthClientService) CreateOAuthClient(ctx context.Context, name string, redirectURIs []string, confidential bool) (*domain.RegisteredOAuthClient, error) {
+	if err := s.authz.Authorize(ctx, domain.PermissionOIDCClients, 0); err != nil {
+		return nil, err
+	}
+	return s.next.CreateOAuthClient(ctx, name, redirectURIs, confidential)
+}
+
+func (s *AuthorizedOAuthClientService) ListOAuthClients(ctx context.Context) ([]domain.OAuthClient, error) {
+	if err := s.authz.Authorize(ctx, domain.PermissionOIDCClients, 0); err != nil {
+		return nil, err
+	}
+	return s.next.ListOAuthClients(ctx)
+}
+
+func (s *AuthorizedOAuthClientService) GetOAuthClient(ctx context.Context, id string) (*domain.OAuthClient, error) {
+	if err := s.authz.Authorize(ctx, domain.PermissionOIDCClients, 0); err != nil {
+		return nil, err
+	}
+	return s.next.GetOAuthClient(ctx, id)
+}
+
+func (s *AuthorizedOAuthClientService) DeleteOAuthClient(ctx context.Context, id string) error {
+	if err := s.authz.Authorize(ctx, domain.PermissionOIDCClients, 0); err != nil {
+		return err
+	}
+	return s.next.DeleteOAuthClient(ctx, id)
+}
+
+// AuthorizedOIDCService защищает выдачу кода авторизации и ротацию ключей. Остальные методы
+// протокола проверяют учетные данные клиента или токен сами и доступны без аутентификации.
+type AuthorizedOIDCService struct {
+	next  OIDCServiceInterface
+	authz *Authorizer
+}
+
+func NewAuthorizedOIDCService(next OIDCServiceInterface, authz *Authorizer) *AuthorizedOIDCService {
+	return &AuthorizedOIDCService{next: next, authz: authz}
+}
+
+func (s *AuthorizedOIDCService) Discovery() *domain.OIDCDiscovery {
+	return s.next.Discovery()
+}
+
+func (s *AuthorizedOIDCService) PublishedKeys(ctx context.Context) (*domain.JSONWebKeySet, error) {
+	return s.next.PublishedKeys(ctx)
+}
+
+func (s *AuthorizedOIDCService) RotateSigningKey(ctx context.Context) (*domain.JSONWebKey, error) {
+	if err := s.authz.Authorize(ctx, domain.PermissionOIDCClients, 0); err != nil {
+		return nil, err
+	}
+	return s.next.RotateSigningKey(ctx)
+}
+
+func (s *AuthorizedOIDCService) LoginRedirect(ctx context.Context, request domain.AuthorizeRequest) (string, error) {
+	return s.next.LoginRedirect(ctx, request)
+}
+
+// Authorize выдает код только по сессии самого пользователя: API-ключ не подтверждает, что вход выполнил человек.
+func (s *AuthorizedOIDCService) Authorize(ctx context.Context, userID int64, request domain.AuthorizeRequest) (string, error) {
+	if principal, ok := auth.PrincipalFromContext(ctx); ok && principal.Method != auth.MethodJWT {
+		return "", &AccessDeniedError{Permission: domain.PermissionOIDCAuthorize, Reason: DenyReasonMissingPermission}
+	}
+	if err := s.authz.Authorize(ctx, domain.PermissionOIDCAuthorize, userID); err != nil {
+		return "", err
+	}
+	return s.next.Authorize(ctx, userID, request)
+}
+
+func (s *AuthorizedOIDCService) Token(ctx context.Context, request domain.TokenRequest) (*domain.OAuthTokenResponse, error) {
+	return s.next.Token(ctx, request)
+}
+
+func (s *AuthorizedOIDCService) UserInfo(ctx context.Context, accessToken string) (*domain.UserInfo, error) {
+	return s.next.UserInfo(ctx, accessToken)
+}
+
+func (s *AuthorizedOIDCService) Introspect(ctx context.Context, clientID, clientSecret, token string) (*domain.TokenIntrospection, error) {
+	return s.next.Introspect(ctx, clientID, clientSecret, token)
+}
+
+func (s *AuthorizedOIDCService) Revoke(ctx context.Context, clientID, clientSecret, token string) error {
+	return s.next.Revoke(ctx, clientID, clientSecret, token)
+}
+
+// AuthorizedSSOService защищает просмотр и отвязку внешних учетных записей. Вход через провайдера
+// доступен без аутентификации: его подтверждает сам провайдер.
+type AuthorizedSSOService struct {
+	next  SSOServiceInterface
+	authz *Authorizer
+}
+
+func NewAuthorizedSSOService(next SSOServiceInterface, authz *Authorizer) *AuthorizedSSOService {
+	return &AuthorizedSSOService{next: next, authz: authz}
+}
+
+func (s *AuthorizedSSOService) ListSSOProviders() []domain.SSOProvider {
+	return s.next.ListSSOProviders()
+}
+
+func (s *AuthorizedSSOService) StartSSOLogin(ctx context.Context, provider string) (*domain.SSOLogin, error) {
+	return s.next.StartSSOLogin(ctx, provider)
+}
+
+func (s *AuthorizedSSOService) CompleteSSOLogin(ctx context.Context, provider, code, state string, meta domain.SessionMeta) (*domain.TokenPair, error) {
+	return s.next.CompleteSSOLogin(ctx, provider, code, state, meta)
+}
+
+func (s *AuthorizedSSOService) ListIdentities(ctx context.Context, userID int64) ([]domain.Identity, error) {
+	if err := s.authz.Authorize(ctx, domain.PermissionIdentities, userID); err != nil {
+		return nil, err
+	}
+	return s.next.ListIdentities(ctx, userID)
+}
+
+func (s *AuthorizedSSOService) UnlinkIdentity(ctx context.Context, userID, identityID int64) error {
+	if err := s.authz.Authorize(ctx, domain.PermissionIdentities, userID); err != nil {
+		return err
+	}
+	return s.next.UnlinkIdentity(ctx, userID, identityID)
+}
+
+// AuthorizedLockoutService разрешает просмотр и снятие блокировок входа только администраторам.
+type AuthorizedLockoutService struct {
+	next  LockoutServiceInterface
+	authz *Authorizer
+}
+
+func NewAuthorizedLockoutService(next LockoutServiceInterface, authz *Authorizer) *AuthorizedLockoutService {
+	return &AuthorizedLockoutService{next: next, authz: authz}
+}
+
+func (s *AuthorizedLockoutService) GetUserLockout(ctx context.Context, userID int64) (*domain.LockoutStatus, error) {
+	if err := s.authz.Authorize(ctx, domain.PermissionLockouts, 0); err != nil {
+		return nil, err
+	}
+	return s.next.GetUserLockout(ctx, userID)
+}
+
+func (s *AuthorizedLockoutService) UnlockUser(ctx context.Context, userID int64) error {
+	if err := s.authz.Authorize(ctx, domain.PermissionLockouts, 0); err != nil {
+		return err
+	}
+	return s.next.UnlockUser(ctx, userID)
+}
+
+func (s *AuthorizedLockoutService) ListLockouts(ctx context.Context) ([]domain.LoginFailure, error) {
+	if err := s.authz.Authorize(ctx, domain.PermissionLockouts, 0); err != nil {
+		return nil, err
+	}
+	return s.next.ListLockouts(ctx)
+}
+
+func (s *AuthorizedLockoutService) UnlockIP(ctx context.Context, ip string) error {
+	if err := s.authz.Authorize(ctx, domain.PermissionLockouts, 0); err != nil {
+		return err
+	}
+	return s.next.UnlockIP(ctx, ip)
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"testovoe/internal/apperr"
+	"testovoe/internal/auth"
+	"testovoe/internal/domain"
+	"testovoe/internal/mail"
+	"testovoe/internal/repository"
+)
+
+var ErrInvalidVerificationToken = apperr.New(apperr.KindInvalid, "invalid_verification_token", "недействительная или просроченная ссылка подтверждения email")
+var ErrEmailAlreadyVerified = apperr.New(apperr.KindConflict, "email_already_verified", "email уже подтвержден")
+
+type EmailVerificationServiceInterface interface {
+	VerifyEmail(ctx context.Context, token string) (*domain.User, error)
+	ResendVerification(ctx context.Context, userID int64) error
+}
+
+// EmailVerificationSender отправляет пользователю ссылку подтверждения email.
+type EmailVerificationSender interface {
+	SendVerification(ctx context.Context, user *domain.User) error
+}
+
+type EmailVerificationService struct {
+	users     repository.UserRepositoryInterface
+	audit     repository.AuditRepositoryInterface
+	tx        repository.TransactorInterface
+	tokens    *auth.TokenIssuer
+	mailer    mail.Mailer
+	verifyURL string
+}
+
+// NewEmailVerificationService создает сервис подтверждения email. verifyURL — адрес страницы,
+// которой в параметре token передается токен; если он пуст, в письмо попадает сам токен.
+func NewEmailVerificationService(users repository.UserRepositoryInterface, audit repository.AuditRepositoryInterface, tx repository.TransactorInterface, tokens *auth.TokenIssuer, mailer mail.Mailer, verifyURL string) *EmailVerificationService {
+	return &EmailVerificationService{users: users, audit: audit, tx: tx, tokens: tokens, mailer: mailer, verifyURL: verifyURL}
+}
+
+func (s *EmailVerificationService) SendVerification(ctx context.Context, user *domain.User) error {
+	token, err := s.tokens.IssueEmailVerificationToken(strconv.FormatInt(user.ID, 10), user.Email)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Здравствуйте, %s!\n\nЧтобы подтвердить адрес %s, ", user.Name, user.Email)
+	if s.verifyURL != "" {
+		link, err := linkWithToken(s.verifyURL, token)
+		if err != nil {
+			return err
+		}
+		body += "перейдите по ссылке:\n" + link + "\n"
+	} else {
+		body += "отправьте этот код в POST /users/verify-email:\n" + token + "\n"
+	}
+	body += fmt.Sprintf("\nСсылка действует %s. Если вы не регистрировались, просто проигнорируйте это письмо.\n", s.tokens.EmailVerificationTTL())
+
+	return s.mailer.Send(ctx, mail.Message{To: user.Email, Subject: "Подтверждение адреса email", Body: body})
+}
+
+// VerifyEmail подтверждает адрес по токену из письма. Токен, выпущенный для прежнего адреса,
+// не принимается; повторное подтверждение того же адреса ничего не меняет.
+func (s *EmailVerificationService) VerifyEmail(ctx context.Context, token string) (*domain.User, error) {
+	subject, email, err := s.tokens.ParseEmailVerificationToken(token)
+	if err != nil {
+		return nil, ErrInvalidVerificationToken
+	}
+	userID, err := strconv.ParseInt(subject, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	var verified *domain.User
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := s.users.GetUserByID(ctx, userID)
+		if err != nil {
+			if errors.Is(err, repository.ErrUserNotFound) {
+				return ErrInvalidVerificationToken
+			}
+			return err
+		}
+		if before.Email != email {
+			return ErrInvalidVerificationToken
+		}
+		if before.EmailVerifiedAt != nil {
+			verified = before
+			return nil
+		}
+
+		if verified, err = s.users.MarkEmailVerified(ctx, userID, email); err != nil {
+			if errors.Is(err, repository.ErrUserNotFound) {
+				return ErrInvalidVerificationToken
+			}
+			return err
+		}
+		return s.recordAudit(ctx, before, verified)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return verified, nil
+}
+
+func (s *EmailVerificationService) ResendVerification(ctx context.Context, userID int64) error {
+	user, err := s.users.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		return ErrEmailAlreadyVerified
+	}
+	return s.SendVerification(ctx, user)
+}
+
+func (s *EmailVerificationService) recordAudit(ctx context.Context, before, after *domain.User) error {
+	record, err := newUserAuditRecord(ctx, domain.AuditActionUserEmailVerified, after.ID, before, after)
+	if err != nil {
+		return err
+	}
+	return s.audit.CreateAuditRecord(ctx, record)
+}
+
+// linkWithToken добавляет токен в параметр token адреса страницы, сохраняя остальные параметры.
+func linkWithToken(base, token string) (string, error) {
+	link, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("некорректный адрес страницы %q: %w", base, err)
+	}
+	query := link.Query()
+	query.Set("token", token)
+	link.RawQuery = query.Encode()
+	return link.String(), nil
+}
+
+// sendVerificationAfterCommit отправляет письмо после фиксации изменения пользователя; в рабочем окружении
+// mailer только ставит его в очередь. Ошибка не отменяет изменение: она записывается в журнал, а письмо
+// можно запросить повторно.
+func sendVerificationAfterCommit(ctx context.Context, sender EmailVerificationSender, user *domain.User) {
+	if sender == nil {
+		return
+	}
+	if err := sender.SendVerification(ctx, user); err != nil {
+		slog.ErrorContext(ctx, "не удалось отправить письмо подтверждения", "user_id", user.ID, "error", err)
+	}
+}
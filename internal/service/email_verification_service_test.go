@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/url"
+	"strings"
+	"testing"
+	"testovoe/internal/auth"
+	"testovoe/internal/domain"
+	"testovoe/internal/mail"
+	"time"
+)
+
+func newTestEmailVerification(repo *MockUserRepository) (*EmailVerificationService, *mail.CaptureMailer, *recordingAuditRepository) {
+	mailer := mail.NewCaptureMailer()
+	audit := new(recordingAuditRepository)
+	tokens := auth.NewTokenIssuer(auth.TokenIssuerConfig{
+		Method:               jwt.SigningMethodHS256,
+		Key:                  []byte("test-secret"),
+		AccessTTL:            time.Minute,
+		EmailVerificationTTL: time.Hour,
+	})
+	service := NewEmailVerificationService(repo, audit, fakeTransactor{}, tokens, mailer, "https://app.example.com/verify?lang=ru")
+	return service, mailer, audit
+}
+
+// tokenFromMessage достает токен из ссылки в письме.
+func tokenFromMessage(t *testing.T, message mail.Message) string {
+	for _, line := range strings.Split(message.Body, "\n") {
+		if strings.HasPrefix(line, "https://") {
+			link, err := url.Parse(line)
+			assert.NoError(t, err)
+			assert.Equal(t, "ru", link.Query().Get("lang"))
+			return link.Query().Get("token")
+		}
+	}
+	t.Fatal("в письме нет ссылки подтверждения")
+	return ""
+}
+
+func TestCreateUser_SendsVerification(t *testing.T) {
+	repo := new(MockUserRepository)
+	verification, mailer, _ := newTestEmailVerification(repo)
+	service := NewUserService(repo, new(recordingAuditRepository), newMemorySessionRepository(), fakeTransactor{}, testCursors, verification)
+
+	verifiedAt := time.Now()
+	user := &domain.User{Name: "Иван", Email: "ivan@example.com", EmailVerifiedAt: &verifiedAt}
+	repo.On("CreateUser", mock.Anything, user).Run(func(args mock.Arguments) {
+		args.Get(1).(*domain.User).ID = 7
+	}).Return(nil)
+
+	assert.NoError(t, service.CreateUser(context.Background(), user))
+	assert.Nil(t, user.EmailVerifiedAt)
+
+	message, ok := mailer.Last("ivan@example.com")
+	assert.True(t, ok)
+	assert.NotEmpty(t, tokenFromMessage(t, message))
+}
+
+func TestVerifyEmail(t *testing.T) {
+	repo := new(MockUserRepository)
+	service, mailer, audit := newTestEmailVerification(repo)
+
+	user := &domain.User{ID: 7, Name: "Иван", Email: "ivan@example.com", Version: 1}
+	assert.NoError(t, service.SendVerification(context.Background(), user))
+	message, _ := mailer.Last("ivan@example.com")
+	token := tokenFromMessage(t, message)
+
+	verifiedAt := time.Now()
+	verified := &domain.User{ID: 7, Name: "Иван", Email: "ivan@example.com", Version: 2, EmailVerifiedAt: &verifiedAt}
+	repo.On("GetUserByID", mock.Anything, int64(7)).Return(user, nil).Once()
+	repo.On("MarkEmailVerified", mock.Anything, int64(7), "ivan@example.com").Return(verified, nil).Once()
+
+	result, err := service.VerifyEmail(context.Background(), token)
+	assert.NoError(t, err)
+	assert.Equal(t, verified, result)
+	assert.Len(t, audit.records, 1)
+	assert.Equal(t, domain.AuditActionUserEmailVerified, audit.records[0].Action)
+
+	// Повторное подтверждение того же адреса ничего не меняет.
+	repo.On("GetUserByID", mock.Anything, int64(7)).Return(verified, nil).Once()
+	result, err = service.VerifyEmail(context.Background(), token)
+	assert.NoError(t, err)
+	assert.Equal(t, verified, result)
+	assert.Len(t, audit.records, 1)
+
+	// После смены адреса старая ссылка не действует.
+	repo.On("GetUserByID", mock.Anything, int64(7)).Return(&domain.User{ID: 7, Email: "new@example.com"}, nil).Once()
+	_, err = service.VerifyEmail(context.Background(), token)
+	assert.ErrorIs(t, err, ErrInvalidVerificationToken)
+
+	_, err = service.VerifyEmail(context.Background(), "garbage")
+	assert.ErrorIs(t, err, ErrInvalidVerificationToken)
+	repo.AssertExpectations(t)
+}
+
+func TestPatchUserByID_EmailChangeResetsVerification(t *testing.T) {
+	repo := new(MockUserRepository)
+	verification, mailer, _ := newTestEmailVerification(repo)
+	service := NewUserService(repo, new(recordingAuditRepository), newMemorySessionRepository(), fakeTransactor{}, testCursors, verification)
+
+	verifiedAt := time.Now()
+	current := &domain.User{ID: 1, Name: "Иван", Email: "ivan@example.com", Version: 1, EmailVerifiedAt: &verifiedAt}
+	repo.On("GetUserByID", mock.Anything, int64(1)).Return(current, nil)
+	email := "ivan.new@example.com"
+	repo.On("PatchUserByID", mock.Anything, int64(1), int64(1), domain.UserPatch{Email: &email}).Return(int64(2), nil)
+
+	updated, err := service.PatchUserByID(context.Background(), 1, 1, MergePatch, []byte(`{"email":"ivan.new@example.com"}`))
+	assert.NoError(t, err)
+	assert.Nil(t, updated.EmailVerifiedAt)
+	_, ok := mailer.Last("ivan.new@example.com")
+	assert.True(t, ok)
+
+	_, err = service.PatchUserByID(context.Background(), 1, 1, MergePatch, []byte(`{"email_verified_at":null}`))
+	assert.ErrorIs(t, err, ErrImmutableField)
+}
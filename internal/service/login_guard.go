@@ -0,0 +1,386 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"testovoe/internal/apperr"
+	"testovoe/internal/domain"
+	"testovoe/internal/repository"
+	"time"
+)
+
+var ErrLoginLocked = apperr.New(apperr.KindTooManyRequests, "login_locked", "слишком много неудачных попыток входа, попробуйте позже")
+var ErrLockNotFound = apperr.New(apperr.KindNotFound, "lock_not_found", "блокировка входа не найдена")
+
+// LoginLockedError возвращается при входе, пока действует блокировка; RetryAfter — сколько осталось ждать.
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return ErrLoginLocked.Error()
+}
+
+func (e *LoginLockedError) Unwrap() error {
+	return ErrLoginLocked
+}
+
+// LockoutPolicy задает, после скольких неудачных попыток вход блокируется. Первая блокировка длится
+// Duration, каждая следующая неудачная попытка после ее окончания удваивает срок, но не больше MaxDuration.
+// Счетчик сбрасывается, если неудачных попыток не было дольше ResetAfter.
+type LockoutPolicy struct {
+	AccountThreshold int
+	IPThreshold      int
+	Duration         time.Duration
+	MaxDuration      time.Duration
+	ResetAfter       time.Duration
+}
+
+// LoginLimiter ограничивает подбор пароля при входе. Попытка резервируется до проверки пароля:
+// ReserveLogin сразу учитывает ее как неудачную, а после проверки вызывается AttemptFailed или,
+// если пароль верный, ReleaseAttempt. Так параллельные запросы не успевают проверить больше паролей,
+// чем разрешает порог.
+type LoginLimiter interface {
+	CheckLogin(ctx context.Context, email, ip string) error
+	ReserveLogin(ctx context.Context, email, ip string) (*LoginAttempt, error)
+	ReleaseAttempt(ctx context.Context, attempt *LoginAttempt) error
+	AttemptFailed(ctx context.Context, attempt *LoginAttempt, userID int64) error
+	LoginSucceeded(ctx context.Context, email string) error
+}
+
+// MFALimiter ограничивает подбор кодов второго фактора так же, как LoginLimiter — подбор пароля.
+// challenge — токен входа, выданный после проверки пароля, или пустая строка, если код проверяется
+// в уже открытой сессии.
+type MFALimiter interface {
+	ReserveMFA(ctx context.Context, userID int64, challenge, ip string) (*LoginAttempt, error)
+	ReleaseAttempt(ctx context.Context, attempt *LoginAttempt) error
+	AttemptFailed(ctx context.Context, attempt *LoginAttempt, userID int64) error
+	MFASucceeded(ctx context.Context, userID int64) error
+}
+
+// LoginAttempt — зарезервированная попытка: счетчики, которые она увеличила, и блокировки,
+// которые она поставила.
+type LoginAttempt struct {
+	reserved []reservedTarget
+}
+
+type reservedTarget struct {
+	target   lockTarget
+	failure  *domain.LoginFailure
+	previous *time.Time
+	locked   bool
+}
+
+type LockoutServiceInterface interface {
+	GetUserLockout(ctx context.Context, userID int64) (*domain.LockoutStatus, error)
+	UnlockUser(ctx context.Context, userID int64) error
+	ListLockouts(ctx context.Context) ([]domain.LoginFailure, error)
+	UnlockIP(ctx context.Context, ip string) error
+}
+
+// LoginGuard считает неудачные попытки входа отдельно по учетной записи и по адресу клиента.
+// Учетная запись определяется введенным email, а не найденным пользователем, поэтому блокировка
+// не выдает, зарегистрирован ли адрес.
+type LoginGuard struct {
+	repo   repository.LoginFailureRepositoryInterface
+	users  repository.UserRepositoryInterface
+	audit  repository.AuditRepositoryInterface
+	tx     repository.TransactorInterface
+	policy LockoutPolicy
+	now    func() time.Time
+}
+
+func NewLoginGuard(repo repository.LoginFailureRepositoryInterface, users repository.UserRepositoryInterface, audit repository.AuditRepositoryInterface, tx repository.TransactorInterface, policy LockoutPolicy) *LoginGuard {
+	return &LoginGuard{repo: repo, users: users, audit: audit, tx: tx, policy: policy, now: time.Now}
+}
+
+// CheckLogin возвращает LoginLockedError, если заблокирована учетная запись или адрес клиента.
+func (g *LoginGuard) CheckLogin(ctx context.Context, email, ip string) error {
+	return g.checkLocks(ctx, g.targets(email, ip))
+}
+
+// ReserveLogin учитывает попытку входа до проверки пароля. Если учетная запись или адрес клиента
+// заблокированы, попытка не учитывается и возвращается LoginLockedError.
+func (g *LoginGuard) ReserveLogin(ctx context.Context, email, ip string) (*LoginAttempt, error) {
+	return g.reserve(ctx, g.targets(email, ip))
+}
+
+// LoginSucceeded сбрасывает счетчик учетной записи. Счетчик адреса не сбрасывается: иначе владелец
+// одной учетной записи мог бы обнулять его между попытками подбора чужих паролей.
+func (g *LoginGuard) LoginSucceeded(ctx context.Context, email string) error {
+	_, err := g.repo.ClearLoginFailures(ctx, domain.LockScopeAccount, loginKey(email))
+	if err != nil && !errors.Is(err, repository.ErrLoginFailureNotFound) {
+		return err
+	}
+	return nil
+}
+
+// ReserveMFA учитывает попытку проверки второго фактора до проверки кода. Возвращает LoginLockedError,
+// если проверка второго фактора пользователя или адрес клиента заблокированы, и ErrInvalidMFAToken,
+// если по токену входа исчерпаны попытки: такой токен больше не принимается, и нужно заново войти по паролю.
+func (g *LoginGuard) ReserveMFA(ctx context.Context, userID int64, challenge, ip string) (*LoginAttempt, error) {
+	var targets []lockTarget
+	if challenge != "" {
+		targets = append(targets, lockTarget{scope: domain.LockScopeMFAChallenge, key: mfaChallengeKey(challenge), threshold: g.policy.AccountThreshold, invalidates: true})
+	}
+	return g.reserve(ctx, append(targets, g.mfaTargets(userID, ip)...))
+}
+
+// ReleaseAttempt возвращает попытку, которая оказалась успешной: счетчики уменьшаются, а блокировки,
+// поставленные при резервировании, снимаются.
+func (g *LoginGuard) ReleaseAttempt(ctx context.Context, attempt *LoginAttempt) error {
+	if attempt == nil {
+		return nil
+	}
+	return g.tx.WithinTx(ctx, func(ctx context.Context) error {
+		for _, reserved := range attempt.reserved {
+			var lockedUntil *time.Time
+			if reserved.locked {
+				lockedUntil = reserved.failure.LockedUntil
+			}
+			if err := g.repo.ReleaseLoginFailure(ctx, reserved.target.scope, reserved.target.key, lockedUntil, reserved.previous); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// AttemptFailed подтверждает, что зарезервированная попытка неудачна, и записывает поставленные
+// ею блокировки в журнал. userID — найденный пользователь или 0; он нужен, чтобы блокировка попала
+// в его историю, блокировка адреса записывается в общий журнал.
+func (g *LoginGuard) AttemptFailed(ctx context.Context, attempt *LoginAttempt, userID int64) error {
+	if attempt == nil {
+		return nil
+	}
+	for _, reserved := range attempt.reserved {
+		if !reserved.locked {
+			continue
+		}
+		entityType, entityID := domain.AuditEntityLogin, int64(0)
+		if reserved.target.scope != domain.LockScopeIP && userID != 0 {
+			entityType, entityID = domain.AuditEntityUser, userID
+		}
+		record, err := newAuditRecord(ctx, domain.AuditActionLoginLocked, entityType, entityID, nil, reserved.failure)
+		if err != nil {
+			return err
+		}
+		if err := g.audit.CreateAuditRecord(ctx, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MFASucceeded сбрасывает счетчики пользователя после проверки второго фактора. Если второй фактор
+// подключен, вход считается успешным только теперь, поэтому и счетчик учетной записи сбрасывается здесь.
+func (g *LoginGuard) MFASucceeded(ctx context.Context, userID int64) error {
+	user, err := g.users.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil
+		}
+		return err
+	}
+	for _, target := range g.userTargets(user) {
+		if _, err := g.repo.ClearLoginFailures(ctx, target.scope, target.key); err != nil && !errors.Is(err, repository.ErrLoginFailureNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetUserLockout объединяет счетчики входа по паролю и по второму фактору.
+func (g *LoginGuard) GetUserLockout(ctx context.Context, userID int64) (*domain.LockoutStatus, error) {
+	user, err := g.users.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	status := &domain.LockoutStatus{}
+	for _, target := range g.userTargets(user) {
+		failure, err := g.repo.GetLoginFailure(ctx, target.scope, target.key)
+		if err != nil {
+			if errors.Is(err, repository.ErrLoginFailureNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		status.Failures = max(status.Failures, failure.Failures)
+		if failure.Locked(g.now()) && (status.LockedUntil == nil || failure.LockedUntil.After(*status.LockedUntil)) {
+			status.Locked, status.LockedUntil = true, failure.LockedUntil
+		}
+	}
+	return status, nil
+}
+
+// UnlockUser снимает блокировку входа по паролю и по второму фактору.
+func (g *LoginGuard) UnlockUser(ctx context.Context, userID int64) error {
+	user, err := g.users.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	unlocked := false
+	for _, target := range g.userTargets(user) {
+		err := g.unlock(ctx, target.scope, target.key, domain.AuditEntityUser, userID)
+		if err != nil && !errors.Is(err, ErrLockNotFound) {
+			return err
+		}
+		unlocked = unlocked || err == nil
+	}
+	if !unlocked {
+		return ErrLockNotFound
+	}
+	return nil
+}
+
+func (g *LoginGuard) ListLockouts(ctx context.Context) ([]domain.LoginFailure, error) {
+	return g.repo.ListLoginLocks(ctx, g.now())
+}
+
+func (g *LoginGuard) UnlockIP(ctx context.Context, ip string) error {
+	return g.unlock(ctx, domain.LockScopeIP, ip, domain.AuditEntityLogin, 0)
+}
+
+func (g *LoginGuard) unlock(ctx context.Context, scope, key, entityType string, entityID int64) error {
+	return g.tx.WithinTx(ctx, func(ctx context.Context) error {
+		failure, err := g.repo.ClearLoginFailures(ctx, scope, key)
+		if err != nil {
+			if errors.Is(err, repository.ErrLoginFailureNotFound) {
+				return ErrLockNotFound
+			}
+			return err
+		}
+		record, err := newAuditRecord(ctx, domain.AuditActionLoginUnlocked, entityType, entityID, failure, nil)
+		if err != nil {
+			return err
+		}
+		return g.audit.CreateAuditRecord(ctx, record)
+	})
+}
+
+// lockTarget — счетчик неудачных попыток. Счетчик с invalidates не блокирует вход, а делает
+// недействительным то, по чему он ведется, когда попытки исчерпаны.
+type lockTarget struct {
+	scope       string
+	key         string
+	threshold   int
+	invalidates bool
+}
+
+func (g *LoginGuard) targets(email, ip string) []lockTarget {
+	targets := []lockTarget{{scope: domain.LockScopeAccount, key: loginKey(email), threshold: g.policy.AccountThreshold}}
+	return g.withIP(targets, ip)
+}
+
+func (g *LoginGuard) mfaTargets(userID int64, ip string) []lockTarget {
+	targets := []lockTarget{{scope: domain.LockScopeMFA, key: strconv.FormatInt(userID, 10), threshold: g.policy.AccountThreshold}}
+	return g.withIP(targets, ip)
+}
+
+// userTargets — счетчики, которые относятся к пользователю: по email и по второму фактору.
+func (g *LoginGuard) userTargets(user *domain.User) []lockTarget {
+	return append(g.targets(user.Email, ""), g.mfaTargets(user.ID, "")...)
+}
+
+func (g *LoginGuard) withIP(targets []lockTarget, ip string) []lockTarget {
+	if ip != "" {
+		targets = append(targets, lockTarget{scope: domain.LockScopeIP, key: ip, threshold: g.policy.IPThreshold})
+	}
+	return targets
+}
+
+func (g *LoginGuard) checkLocks(ctx context.Context, targets []lockTarget) error {
+	now := g.now()
+	var until time.Time
+	for _, target := range targets {
+		failure, err := g.repo.GetLoginFailure(ctx, target.scope, target.key)
+		if err != nil {
+			if errors.Is(err, repository.ErrLoginFailureNotFound) {
+				continue
+			}
+			return err
+		}
+		if failure.Locked(now) && failure.LockedUntil.After(until) {
+			until = *failure.LockedUntil
+		}
+	}
+	if until.IsZero() {
+		return nil
+	}
+	return &LoginLockedError{RetryAfter: until.Sub(now)}
+}
+
+// reserve в одной транзакции увеличивает счетчики и блокирует те, что достигли порога. Строки
+// счетчиков остаются заблокированными до конца транзакции, поэтому параллельные попытки
+// резервируются по очереди и каждая видит блокировку, поставленную предыдущей. Если вход уже
+// заблокирован, транзакция откатывается и попытка не учитывается.
+func (g *LoginGuard) reserve(ctx context.Context, targets []lockTarget) (*LoginAttempt, error) {
+	now := g.now()
+	attempt := &LoginAttempt{}
+	err := g.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var until time.Time
+		for _, target := range targets {
+			failure, err := g.repo.RecordLoginFailure(ctx, target.scope, target.key, now, now.Add(-g.policy.ResetAfter))
+			if err != nil {
+				return err
+			}
+			if target.invalidates && failure.Failures > target.threshold {
+				return ErrInvalidMFAToken
+			}
+			if failure.Locked(now) && failure.LockedUntil.After(until) {
+				until = *failure.LockedUntil
+			}
+			attempt.reserved = append(attempt.reserved, reservedTarget{target: target, failure: failure, previous: failure.LockedUntil})
+		}
+		if !until.IsZero() {
+			return &LoginLockedError{RetryAfter: until.Sub(now)}
+		}
+
+		for i := range attempt.reserved {
+			reserved := &attempt.reserved[i]
+			if reserved.target.invalidates || reserved.failure.Failures < reserved.target.threshold {
+				continue
+			}
+			lockedUntil := now.Add(g.lockDuration(reserved.failure.Failures - reserved.target.threshold))
+			if err := g.repo.LockLogin(ctx, reserved.target.scope, reserved.target.key, lockedUntil); err != nil {
+				return err
+			}
+			reserved.failure.LockedUntil, reserved.locked = &lockedUntil, true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return attempt, nil
+}
+
+// lockDuration удваивает срок блокировки за каждую неудачную попытку сверх порога.
+func (g *LoginGuard) lockDuration(excess int) time.Duration {
+	duration := g.policy.Duration
+	for i := 0; i < excess && duration < g.policy.MaxDuration; i++ {
+		duration *= 2
+	}
+	if duration > g.policy.MaxDuration {
+		duration = g.policy.MaxDuration
+	}
+	return duration
+}
+
+func loginKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// mfaChallengeKey — SHA-256 токена входа: сам токен длиннее ключа и не должен храниться в базе.
+func mfaChallengeKey(challenge string) string {
+	return base64.RawURLEncoding.EncodeToString(hashSessionToken(challenge))
+}
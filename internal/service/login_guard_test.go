@@ -0,0 +1,336 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"maps"
+	"testing"
+	"testovoe/internal/auth"
+	"testovoe/internal/domain"
+	"testovoe/internal/repository"
+	"time"
+)
+
+type memoryLoginFailureRepository struct {
+	failures map[[2]string]domain.LoginFailure
+}
+
+func newMemoryLoginFailureRepository() *memoryLoginFailureRepository {
+	return &memoryLoginFailureRepository{failures: make(map[[2]string]domain.LoginFailure)}
+}
+
+func (r *memoryLoginFailureRepository) GetLoginFailure(ctx context.Context, scope, key string) (*domain.LoginFailure, error) {
+	failure, ok := r.failures[[2]string{scope, key}]
+	if !ok {
+		return nil, repository.ErrLoginFailureNotFound
+	}
+	return &failure, nil
+}
+
+func (r *memoryLoginFailureRepository) RecordLoginFailure(ctx context.Context, scope, key string, at, resetBefore time.Time) (*domain.LoginFailure, error) {
+	failure, ok := r.failures[[2]string{scope, key}]
+	if !ok {
+		failure = domain.LoginFailure{Scope: scope, Key: key}
+	}
+	if failure.LastFailureAt.Before(resetBefore) {
+		failure.Failures = 0
+	}
+	failure.Failures++
+	failure.LastFailureAt = at
+	r.failures[[2]string{scope, key}] = failure
+	return &failure, nil
+}
+
+func (r *memoryLoginFailureRepository) LockLogin(ctx context.Context, scope, key string, until time.Time) error {
+	failure := r.failures[[2]string{scope, key}]
+	failure.LockedUntil = &until
+	r.failures[[2]string{scope, key}] = failure
+	return nil
+}
+
+func (r *memoryLoginFailureRepository) ReleaseLoginFailure(ctx context.Context, scope, key string, lockedUntil, previous *time.Time) error {
+	failure, ok := r.failures[[2]string{scope, key}]
+	if !ok {
+		return nil
+	}
+	failure.Failures = max(failure.Failures-1, 0)
+	if lockedUntil != nil && failure.LockedUntil != nil && failure.LockedUntil.Equal(*lockedUntil) {
+		failure.LockedUntil = previous
+	}
+	r.failures[[2]string{scope, key}] = failure
+	return nil
+}
+
+func (r *memoryLoginFailureRepository) ClearLoginFailures(ctx context.Context, scope, key string) (*domain.LoginFailure, error) {
+	failure, ok := r.failures[[2]string{scope, key}]
+	if !ok {
+		return nil, repository.ErrLoginFailureNotFound
+	}
+	delete(r.failures, [2]string{scope, key})
+	return &failure, nil
+}
+
+func (r *memoryLoginFailureRepository) ListLoginLocks(ctx context.Context, at time.Time) ([]domain.LoginFailure, error) {
+	locks := make([]domain.LoginFailure, 0)
+	for _, failure := range r.failures {
+		if failure.Locked(at) {
+			locks = append(locks, failure)
+		}
+	}
+	return locks, nil
+}
+
+// WithinTx откатывает изменения счетчиков, если fn вернула ошибку, как это сделала бы база.
+func (r *memoryLoginFailureRepository) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	snapshot := maps.Clone(r.failures)
+	if err := fn(ctx); err != nil {
+		r.failures = snapshot
+		return err
+	}
+	return nil
+}
+
+var testLockoutPolicy = LockoutPolicy{
+	AccountThreshold: 3,
+	IPThreshold:      5,
+	Duration:         time.Minute,
+	MaxDuration:      5 * time.Minute,
+	ResetAfter:       time.Hour,
+}
+
+func newTestLoginGuard() (*LoginGuard, *memoryLoginFailureRepository, *MockUserRepository, *recordingAuditRepository, *time.Time) {
+	repo := newMemoryLoginFailureRepository()
+	users := new(MockUserRepository)
+	audit := new(recordingAuditRepository)
+	guard := NewLoginGuard(repo, users, audit, repo, testLockoutPolicy)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	guard.now = func() time.Time { return now }
+	return guard, repo, users, audit, &now
+}
+
+// failLogin резервирует попытку входа и подтверждает, что она неудачна.
+func failLogin(t *testing.T, guard *LoginGuard, email, ip string, userID int64) {
+	t.Helper()
+	attempt, err := guard.ReserveLogin(context.Background(), email, ip)
+	assert.NoError(t, err)
+	assert.NoError(t, guard.AttemptFailed(context.Background(), attempt, userID))
+}
+
+func TestLoginGuard_LocksAccountWithBackoff(t *testing.T) {
+	guard, _, _, audit, now := newTestLoginGuard()
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		failLogin(t, guard, "Ivan@Example.com", "", 7)
+	}
+	assert.NoError(t, guard.CheckLogin(ctx, "ivan@example.com", ""))
+
+	failLogin(t, guard, "ivan@example.com", "", 7)
+	var locked *LoginLockedError
+	assert.True(t, errors.As(guard.CheckLogin(ctx, " IVAN@example.com", ""), &locked))
+	assert.Equal(t, time.Minute, locked.RetryAfter)
+	assert.ErrorIs(t, locked, ErrLoginLocked)
+	assert.Len(t, audit.records, 1)
+	assert.Equal(t, domain.AuditActionLoginLocked, audit.records[0].Action)
+	assert.Equal(t, domain.AuditEntityUser, audit.records[0].EntityType)
+	assert.Equal(t, int64(7), audit.records[0].EntityID)
+
+	// Каждая следующая ошибка после окончания блокировки удваивает ее срок до максимума.
+	for _, expected := range []time.Duration{2 * time.Minute, 4 * time.Minute, 5 * time.Minute} {
+		*now = now.Add(time.Hour - time.Second)
+		assert.NoError(t, guard.CheckLogin(ctx, "ivan@example.com", ""))
+		failLogin(t, guard, "ivan@example.com", "", 7)
+		assert.True(t, errors.As(guard.CheckLogin(ctx, "ivan@example.com", ""), &locked))
+		assert.Equal(t, expected, locked.RetryAfter)
+	}
+}
+
+func TestLoginGuard_ReservesAttempts(t *testing.T) {
+	guard, repo, _, audit, _ := newTestLoginGuard()
+	ctx := context.Background()
+	key := [2]string{domain.LockScopeAccount, "ivan@example.com"}
+
+	// Параллельные попытки учитываются до проверки пароля: сверх порога их не пропустить.
+	var attempts []*LoginAttempt
+	for range testLockoutPolicy.AccountThreshold {
+		attempt, err := guard.ReserveLogin(ctx, "ivan@example.com", "10.0.0.1")
+		assert.NoError(t, err)
+		attempts = append(attempts, attempt)
+	}
+	_, err := guard.ReserveLogin(ctx, "ivan@example.com", "10.0.0.1")
+	assert.ErrorIs(t, err, ErrLoginLocked)
+	assert.Equal(t, testLockoutPolicy.AccountThreshold, repo.failures[key].Failures)
+
+	// Верный пароль возвращает попытку вместе с поставленной ею блокировкой.
+	assert.NoError(t, guard.ReleaseAttempt(ctx, attempts[len(attempts)-1]))
+	assert.NoError(t, guard.CheckLogin(ctx, "ivan@example.com", "10.0.0.1"))
+	assert.Equal(t, testLockoutPolicy.AccountThreshold-1, repo.failures[key].Failures)
+	assert.Equal(t, 2, repo.failures[[2]string{domain.LockScopeIP, "10.0.0.1"}].Failures)
+	assert.Empty(t, audit.records)
+}
+
+func TestLoginGuard_ResetsAfterQuietPeriod(t *testing.T) {
+	guard, _, _, _, now := newTestLoginGuard()
+	ctx := context.Background()
+
+	failLogin(t, guard, "ivan@example.com", "", 0)
+	failLogin(t, guard, "ivan@example.com", "", 0)
+	*now = now.Add(2 * time.Hour)
+	failLogin(t, guard, "ivan@example.com", "", 0)
+	assert.NoError(t, guard.CheckLogin(ctx, "ivan@example.com", ""))
+}
+
+func TestLoginGuard_LocksIPAcrossAccounts(t *testing.T) {
+	guard, _, _, audit, _ := newTestLoginGuard()
+	ctx := context.Background()
+
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com"} {
+		failLogin(t, guard, email, "10.0.0.1", 0)
+	}
+	assert.ErrorIs(t, guard.CheckLogin(ctx, "f@example.com", "10.0.0.1"), ErrLoginLocked)
+	assert.NoError(t, guard.CheckLogin(ctx, "f@example.com", "10.0.0.2"))
+	assert.Len(t, audit.records, 1)
+	assert.Equal(t, domain.AuditEntityLogin, audit.records[0].EntityType)
+
+	// Успешный вход не сбрасывает счетчик адреса.
+	assert.NoError(t, guard.LoginSucceeded(ctx, "f@example.com"))
+	assert.ErrorIs(t, guard.CheckLogin(ctx, "f@example.com", "10.0.0.1"), ErrLoginLocked)
+
+	locks, err := guard.ListLockouts(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, locks, 1)
+	assert.Equal(t, domain.LockScopeIP, locks[0].Scope)
+
+	assert.NoError(t, guard.UnlockIP(ctx, "10.0.0.1"))
+	assert.NoError(t, guard.CheckLogin(ctx, "f@example.com", "10.0.0.1"))
+	assert.ErrorIs(t, guard.UnlockIP(ctx, "10.0.0.1"), ErrLockNotFound)
+	assert.Equal(t, domain.AuditActionLoginUnlocked, audit.records[1].Action)
+}
+
+func TestLoginGuard_UnlockUser(t *testing.T) {
+	guard, _, users, audit, _ := newTestLoginGuard()
+	ctx := context.Background()
+	users.On("GetUserByID", mock.Anything, int64(7)).Return(&domain.User{ID: 7, Email: "Ivan@example.com"}, nil)
+
+	for i := 0; i < 3; i++ {
+		failLogin(t, guard, "ivan@example.com", "", 7)
+	}
+	status, err := guard.GetUserLockout(ctx, 7)
+	assert.NoError(t, err)
+	assert.True(t, status.Locked)
+	assert.Equal(t, 3, status.Failures)
+
+	assert.NoError(t, guard.UnlockUser(ctx, 7))
+	assert.NoError(t, guard.CheckLogin(ctx, "ivan@example.com", ""))
+	assert.Equal(t, domain.AuditActionLoginUnlocked, audit.records[len(audit.records)-1].Action)
+
+	status, err = guard.GetUserLockout(ctx, 7)
+	assert.NoError(t, err)
+	assert.False(t, status.Locked)
+	assert.ErrorIs(t, guard.UnlockUser(ctx, 7), ErrLockNotFound)
+}
+
+func TestLogin_LockedAccountSkipsPasswordCheck(t *testing.T) {
+	guard, _, _, _, _ := newTestLoginGuard()
+	users := new(MockUserRepository)
+	credentials := new(MockCredentialRepository)
+	service := NewAuthService(users, credentials, newMemorySessionRepository(), new(recordingAuditRepository), fakeTransactor{},
+		auth.NewPasswordHasher(testArgon2Params), newTestTokenIssuer(), nil, guard)
+
+	hash, err := auth.NewPasswordHasher(testArgon2Params).Hash("secret-password")
+	assert.NoError(t, err)
+	credentials.On("GetCredentialsByEmail", mock.Anything, "ivan@example.com").Return(&domain.User{ID: 7}, hash, nil)
+
+	meta := domain.SessionMeta{IP: "10.0.0.1"}
+	for i := 0; i < 3; i++ {
+		_, err := service.Login(context.Background(), "ivan@example.com", "wrong-password", meta)
+		assert.ErrorIs(t, err, ErrInvalidLogin)
+	}
+
+	_, err = service.Login(context.Background(), "ivan@example.com", "secret-password", meta)
+	assert.ErrorIs(t, err, ErrLoginLocked)
+	credentials.AssertNumberOfCalls(t, "GetCredentialsByEmail", 3)
+}
+
+func TestChangePassword_CountsTowardsLockout(t *testing.T) {
+	guard, _, _, audit, _ := newTestLoginGuard()
+	users := new(MockUserRepository)
+	credentials := new(MockCredentialRepository)
+	service := NewAuthService(users, credentials, newMemorySessionRepository(), audit, fakeTransactor{},
+		auth.NewPasswordHasher(testArgon2Params), newTestTokenIssuer(), nil, guard)
+
+	hash, err := auth.NewPasswordHasher(testArgon2Params).Hash("secret-password")
+	assert.NoError(t, err)
+	users.On("GetUserByID", mock.Anything, int64(7)).Return(&domain.User{ID: 7, Email: "ivan@example.com"}, nil)
+	credentials.On("GetPasswordHash", mock.Anything, int64(7)).Return(hash, nil)
+	credentials.On("SetPasswordHash", mock.Anything, int64(7), mock.AnythingOfType("string")).Return(nil)
+
+	// Верный пароль не расходует попытку.
+	assert.NoError(t, service.ChangePassword(context.Background(), 7, "secret-password", "secret-password"))
+	for i := 0; i < 3; i++ {
+		assert.ErrorIs(t, service.ChangePassword(context.Background(), 7, "wrong-password", "new-password"), ErrWrongPassword)
+	}
+
+	// После порога заблокированы и смена пароля, и вход по паролю.
+	assert.ErrorIs(t, service.ChangePassword(context.Background(), 7, "secret-password", "new-password"), ErrLoginLocked)
+	assert.ErrorIs(t, guard.CheckLogin(context.Background(), "ivan@example.com", ""), ErrLoginLocked)
+	credentials.AssertNumberOfCalls(t, "GetPasswordHash", 4)
+
+	var actions []string
+	for _, record := range audit.records {
+		actions = append(actions, record.Action)
+	}
+	assert.Contains(t, actions, domain.AuditActionLoginLocked)
+	assert.Contains(t, actions, domain.AuditActionUserPasswordChangeFailed)
+}
+
+func TestVerifyMFAChallenge_LimitsAttempts(t *testing.T) {
+	guard, repo, guardUsers, _, now := newTestLoginGuard()
+	guardUsers.On("GetUserByID", mock.Anything, int64(7)).Return(&domain.User{ID: 7, Email: "ivan@example.com"}, nil)
+	mfaService, users, _, _ := newTestMFAService()
+	users.On("GetUserByID", mock.Anything, int64(7)).Return(&domain.User{ID: 7, Email: "ivan@example.com"}, nil)
+	secret, _ := enrollForTest(t, mfaService)
+	mfaService.limiter = guard
+
+	authService, _, credentials, _ := newTestAuthService(testArgon2Params)
+	authService.mfa, authService.limiter = mfaService, guard
+	hash, err := auth.NewPasswordHasher(testArgon2Params).Hash("secret-password")
+	assert.NoError(t, err)
+	credentials.On("GetCredentialsByEmail", mock.Anything, "ivan@example.com").Return(&domain.User{ID: 7}, hash, nil)
+	ctx, meta := context.Background(), domain.SessionMeta{IP: "10.0.0.1"}
+
+	// Верный пароль не сбрасывает счетчик, пока не проверен второй фактор.
+	_, err = authService.Login(ctx, "ivan@example.com", "wrong-password", meta)
+	assert.ErrorIs(t, err, ErrInvalidLogin)
+	_, err = authService.Login(ctx, "ivan@example.com", "secret-password", meta)
+	var challenge *MFAChallengeError
+	assert.ErrorAs(t, err, &challenge)
+	assert.Equal(t, 1, repo.failures[[2]string{domain.LockScopeAccount, "ivan@example.com"}].Failures)
+
+	for range testLockoutPolicy.AccountThreshold {
+		_, err = mfaService.VerifyMFAChallenge(ctx, challenge.Token, domain.MFAProof{Code: "000000"}, meta)
+		assert.ErrorIs(t, err, ErrInvalidMFACode)
+	}
+	// Токен с исчерпанными попытками больше не принимается даже с верным кодом.
+	next := testMFANow.Add(auth.TOTPPeriod)
+	mfaService.now = func() time.Time { return next }
+	_, err = mfaService.VerifyMFAChallenge(ctx, challenge.Token, domain.MFAProof{Code: totpCodeForTest(t, secret, next)}, meta)
+	assert.ErrorIs(t, err, ErrInvalidMFAToken)
+
+	// Новый токен не помогает: проверка второго фактора пользователя заблокирована.
+	_, err = authService.Login(ctx, "ivan@example.com", "secret-password", meta)
+	assert.ErrorAs(t, err, &challenge)
+	_, err = mfaService.VerifyMFAChallenge(ctx, challenge.Token, domain.MFAProof{Code: totpCodeForTest(t, secret, next)}, meta)
+	assert.ErrorIs(t, err, ErrLoginLocked)
+
+	*now = now.Add(testLockoutPolicy.Duration)
+	tokens, err := mfaService.VerifyMFAChallenge(ctx, challenge.Token, domain.MFAProof{Code: totpCodeForTest(t, secret, next)}, meta)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, tokens.AccessToken)
+	_, locked := repo.failures[[2]string{domain.LockScopeAccount, "ivan@example.com"}]
+	assert.False(t, locked)
+	_, locked = repo.failures[[2]string{domain.LockScopeMFA, "7"}]
+	assert.False(t, locked)
+}
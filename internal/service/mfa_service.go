@@ -0,0 +1,542 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"strconv"
+	"strings"
+	"testovoe/internal/apperr"
+	"testovoe/internal/auth"
+	"testovoe/internal/domain"
+	"testovoe/internal/repository"
+	"time"
+)
+
+var ErrMFARequired = apperr.New(apperr.KindUnauthorized, "mfa_required", "требуется подтверждение вторым фактором")
+var ErrInvalidMFACode = apperr.New(apperr.KindInvalid, "invalid_mfa_code", "неверный код второго фактора")
+var ErrInvalidMFAToken = apperr.New(apperr.KindUnauthorized, "invalid_mfa_token", "недействительный или просроченный токен второго фактора")
+var ErrMFAAlreadyEnabled = apperr.New(apperr.KindConflict, "mfa_already_enabled", "второй фактор уже подключен")
+var ErrMFANotEnabled = apperr.New(apperr.KindConflict, "mfa_not_enabled", "второй фактор не настроен")
+var ErrMFAEnforced = apperr.New(apperr.KindConflict, "mfa_enforced", "второй фактор обязателен для роли пользователя")
+
+const recoveryCodeCount = 10
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// MFAChallengeError возвращается при входе, если пароль верен, но требуется второй фактор.
+// Token нужно предъявить в POST /auth/mfa/verify вместе с кодом. EnrollmentRequired означает,
+// что второй фактор обязателен по политике, но еще не настроен, и сначала нужно вызвать POST /auth/mfa/enroll.
+type MFAChallengeError struct {
+	Token              string
+	EnrollmentRequired bool
+}
+
+func (e *MFAChallengeError) Error() string {
+	return ErrMFARequired.Error()
+}
+
+func (e *MFAChallengeError) Unwrap() error {
+	return ErrMFARequired
+}
+
+// MFAChallenger решает, нужен ли пользователю второй фактор после проверки пароля.
+type MFAChallenger interface {
+	Challenge(ctx context.Context, userID int64) error
+}
+
+type MFAServiceInterface interface {
+	GetMFAStatus(ctx context.Context, userID int64) (*domain.MFAStatus, error)
+	StartMFAEnrollment(ctx context.Context, userID int64) (*domain.MFAEnrollment, error)
+	ConfirmMFAEnrollment(ctx context.Context, userID int64, code string) ([]string, error)
+	RegenerateRecoveryCodes(ctx context.Context, userID int64, proof domain.MFAProof) ([]string, error)
+	DisableMFA(ctx context.Context, userID int64, proof domain.MFAProof) error
+	ResetMFA(ctx context.Context, userID int64) error
+	GetMFAPolicy(ctx context.Context) ([]domain.Role, error)
+	SetMFAPolicy(ctx context.Context, roles []domain.Role) ([]domain.Role, error)
+	EnrollMFAChallenge(ctx context.Context, mfaToken string) (*domain.MFAEnrollment, error)
+	VerifyMFAChallenge(ctx context.Context, mfaToken string, proof domain.MFAProof, meta domain.SessionMeta) (*domain.TokenPair, error)
+}
+
+type MFAService struct {
+	users    repository.UserRepositoryInterface
+	mfa      repository.MFARepositoryInterface
+	sessions repository.SessionRepositoryInterface
+	audit    repository.AuditRepositoryInterface
+	tx       repository.TransactorInterface
+	tokens   *auth.TokenIssuer
+	limiter  MFALimiter
+	// issuer показывается в приложении-аутентификаторе рядом с адресом пользователя.
+	issuer string
+	now    func() time.Time
+}
+
+func NewMFAService(users repository.UserRepositoryInterface, mfa repository.MFARepositoryInterface, sessions repository.SessionRepositoryInterface, audit repository.AuditRepositoryInterface, tx repository.TransactorInterface, tokens *auth.TokenIssuer, limiter MFALimiter, issuer string) *MFAService {
+	return &MFAService{users: users, mfa: mfa, sessions: sessions, audit: audit, tx: tx, tokens: tokens, limiter: limiter, issuer: issuer, now: time.Now}
+}
+
+func (s *MFAService) GetMFAStatus(ctx context.Context, userID int64) (*domain.MFAStatus, error) {
+	if _, err := s.getUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	required, err := s.mfa.UserRequiresMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	status := &domain.MFAStatus{Required: required}
+
+	mfa, err := s.getMFA(ctx, userID)
+	if err != nil && !errors.Is(err, ErrMFANotEnabled) {
+		return nil, err
+	}
+	if mfa.Enabled() {
+		status.Enabled, status.EnabledAt = true, mfa.ConfirmedAt
+		if status.RecoveryCodesLeft, err = s.mfa.CountRecoveryCodes(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+// StartMFAEnrollment создает новый секрет TOTP. Второй фактор начинает действовать только после
+// подтверждения кодом из приложения, поэтому повторный вызов до подтверждения просто заменяет секрет.
+func (s *MFAService) StartMFAEnrollment(ctx context.Context, userID int64) (*domain.MFAEnrollment, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.startEnrollment(ctx, user)
+}
+
+func (s *MFAService) ConfirmMFAEnrollment(ctx context.Context, userID int64, code string) ([]string, error) {
+	attempt, err := s.reserveAttempt(ctx, userID, "", "")
+	if err != nil {
+		return nil, err
+	}
+	var codes []string
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if _, err := s.getUser(ctx, userID); err != nil {
+			return err
+		}
+		var err error
+		codes, err = s.confirmEnrollment(ctx, userID, code)
+		return err
+	})
+	if err := s.attemptResult(ctx, attempt, userID, err); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// RegenerateRecoveryCodes выпускает новый набор кодов восстановления; прежние коды перестают действовать.
+func (s *MFAService) RegenerateRecoveryCodes(ctx context.Context, userID int64, proof domain.MFAProof) ([]string, error) {
+	attempt, err := s.reserveAttempt(ctx, userID, "", "")
+	if err != nil {
+		return nil, err
+	}
+	var codes []string
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		mfa, err := s.getEnabledMFA(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if err := s.verifyProof(ctx, mfa, proof); err != nil {
+			return err
+		}
+		if codes, err = s.replaceRecoveryCodes(ctx, userID); err != nil {
+			return err
+		}
+		return s.writeAudit(ctx, domain.AuditActionUserMFARecoveryCodesGenerated, userID)
+	})
+	if err := s.attemptResult(ctx, attempt, userID, err); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableMFA отключает второй фактор по коду пользователя. Если второй фактор обязателен
+// для одной из ролей пользователя, отключить его может только администратор через ResetMFA.
+func (s *MFAService) DisableMFA(ctx context.Context, userID int64, proof domain.MFAProof) error {
+	attempt, err := s.reserveAttempt(ctx, userID, "", "")
+	if err != nil {
+		return err
+	}
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		mfa, err := s.getEnabledMFA(ctx, userID)
+		if err != nil {
+			return err
+		}
+		required, err := s.mfa.UserRequiresMFA(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if required {
+			return ErrMFAEnforced
+		}
+		if err := s.verifyProof(ctx, mfa, proof); err != nil {
+			return err
+		}
+		return s.deleteMFA(ctx, userID)
+	})
+	return s.attemptResult(ctx, attempt, userID, err)
+}
+
+// ResetMFA отключает второй фактор без кода, например если пользователь потерял устройство.
+// Если второй фактор обязателен, при следующем входе пользователю придется настроить его заново.
+func (s *MFAService) ResetMFA(ctx context.Context, userID int64) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if _, err := s.getUser(ctx, userID); err != nil {
+			return err
+		}
+		return s.deleteMFA(ctx, userID)
+	})
+}
+
+func (s *MFAService) GetMFAPolicy(ctx context.Context) ([]domain.Role, error) {
+	return s.mfa.ListMFARequiredRoles(ctx)
+}
+
+// SetMFAPolicy задает роли, для которых второй фактор обязателен. Политика применяется при следующем
+// входе: уже открытые сессии не прерываются.
+func (s *MFAService) SetMFAPolicy(ctx context.Context, roles []domain.Role) ([]domain.Role, error) {
+	unique, err := normalizeRoles(roles)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := s.mfa.ListMFARequiredRoles(ctx)
+		if err != nil {
+			return err
+		}
+		if err := s.mfa.SetMFARequiredRoles(ctx, unique); err != nil {
+			return err
+		}
+
+		type policySnapshot struct {
+			Roles []domain.Role `json:"roles"`
+		}
+		record, err := newAuditRecord(ctx, domain.AuditActionMFAPolicyChanged, domain.AuditEntityMFAPolicy, 0,
+			policySnapshot{Roles: before}, policySnapshot{Roles: unique})
+		if err != nil {
+			return err
+		}
+		return s.audit.CreateAuditRecord(ctx, record)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return unique, nil
+}
+
+// Challenge возвращает MFAChallengeError, если пользователь подключил второй фактор
+// или обязан подключить его по политике.
+func (s *MFAService) Challenge(ctx context.Context, userID int64) error {
+	mfa, err := s.getMFA(ctx, userID)
+	if err != nil && !errors.Is(err, ErrMFANotEnabled) {
+		return err
+	}
+	enrollment := false
+	if !mfa.Enabled() {
+		required, err := s.mfa.UserRequiresMFA(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if !required {
+			return nil
+		}
+		enrollment = true
+	}
+
+	token, err := s.tokens.IssueMFAToken(strconv.FormatInt(userID, 10))
+	if err != nil {
+		return err
+	}
+	return &MFAChallengeError{Token: token, EnrollmentRequired: enrollment}
+}
+
+// EnrollMFAChallenge позволяет настроить обязательный второй фактор прямо во время входа,
+// предъявив токен, выданный после проверки пароля.
+func (s *MFAService) EnrollMFAChallenge(ctx context.Context, mfaToken string) (*domain.MFAEnrollment, error) {
+	userID, _, err := s.parseChallenge(mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, ErrInvalidMFAToken
+		}
+		return nil, err
+	}
+	return s.startEnrollment(ctx, user)
+}
+
+// VerifyMFAChallenge завершает вход: проверяет код и открывает сессию. Если настройка второго
+// фактора была начата во время входа, код подтверждает ее, а в ответе возвращаются коды восстановления.
+// Неверные коды считаются по пользователю и по токену: после порога токен перестает действовать,
+// а проверка второго фактора блокируется так же, как вход по паролю. Токен действует для одного входа:
+// он отмечается использованным в той же транзакции, в которой открывается сессия.
+func (s *MFAService) VerifyMFAChallenge(ctx context.Context, mfaToken string, proof domain.MFAProof, meta domain.SessionMeta) (*domain.TokenPair, error) {
+	userID, challenge, err := s.parseChallenge(mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	attempt, err := s.reserveAttempt(ctx, userID, mfaToken, meta.IP)
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens *domain.TokenPair
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if _, err := s.getUser(ctx, userID); err != nil {
+			if errors.Is(err, ErrUserNotFound) {
+				return ErrInvalidMFAToken
+			}
+			return err
+		}
+		mfa, err := s.getMFA(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		var codes []string
+		if mfa.Enabled() {
+			err = s.verifyProof(ctx, mfa, proof)
+		} else {
+			codes, err = s.confirmEnrollment(ctx, userID, proof.Code)
+		}
+		if err != nil {
+			return err
+		}
+
+		// Параллельный запрос с тем же токеном ждет здесь фиксации первого и получает отказ.
+		fresh, err := s.mfa.UseMFAChallenge(ctx, challenge.ID, challenge.ExpiresAt)
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			return ErrInvalidMFAToken
+		}
+		if tokens, err = openSession(ctx, s.sessions, s.tokens, userID, meta); err != nil {
+			return err
+		}
+		tokens.RecoveryCodes = codes
+		return nil
+	})
+	if err := s.attemptResult(ctx, attempt, userID, err); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// reserveAttempt учитывает попытку до проверки кода, чтобы параллельные запросы не проверили
+// больше кодов, чем разрешает порог.
+func (s *MFAService) reserveAttempt(ctx context.Context, userID int64, challenge, ip string) (*LoginAttempt, error) {
+	if s.limiter == nil {
+		return nil, nil
+	}
+	return s.limiter.ReserveMFA(ctx, userID, challenge, ip)
+}
+
+// attemptResult подтверждает неверный код или возвращает попытку, если код не понадобился или оказался
+// верным. Результат записывается после транзакции, в которой проверялся код: при неверном коде она откатывается.
+func (s *MFAService) attemptResult(ctx context.Context, attempt *LoginAttempt, userID int64, err error) error {
+	if s.limiter == nil {
+		return err
+	}
+	if errors.Is(err, ErrInvalidMFACode) {
+		if err := s.limiter.AttemptFailed(ctx, attempt, userID); err != nil {
+			return err
+		}
+		return ErrInvalidMFACode
+	}
+	if releaseErr := s.limiter.ReleaseAttempt(ctx, attempt); releaseErr != nil {
+		return releaseErr
+	}
+	if err != nil {
+		return err
+	}
+	return s.limiter.MFASucceeded(ctx, userID)
+}
+
+func (s *MFAService) parseChallenge(mfaToken string) (int64, *auth.MFAChallenge, error) {
+	challenge, err := s.tokens.ParseMFAToken(mfaToken)
+	if err != nil {
+		return 0, nil, ErrInvalidMFAToken
+	}
+	userID, err := strconv.ParseInt(challenge.Subject, 10, 64)
+	if err != nil {
+		return 0, nil, ErrInvalidMFAToken
+	}
+	return userID, challenge, nil
+}
+
+func (s *MFAService) startEnrollment(ctx context.Context, user *domain.User) (*domain.MFAEnrollment, error) {
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		mfa, err := s.getMFA(ctx, user.ID)
+		if err != nil && !errors.Is(err, ErrMFANotEnabled) {
+			return err
+		}
+		if mfa.Enabled() {
+			return ErrMFAAlreadyEnabled
+		}
+		return s.mfa.SaveMFASecret(ctx, user.ID, secret)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &domain.MFAEnrollment{Secret: secret, URI: auth.TOTPURI(s.issuer, user.Email, secret)}, nil
+}
+
+// confirmEnrollment включает второй фактор по первому коду из приложения и выпускает коды восстановления.
+func (s *MFAService) confirmEnrollment(ctx context.Context, userID int64, code string) ([]string, error) {
+	mfa, err := s.getMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa.Enabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if err := s.verifyTOTP(ctx, mfa, code); err != nil {
+		return nil, err
+	}
+	if err := s.mfa.ConfirmMFA(ctx, userID); err != nil {
+		return nil, err
+	}
+	codes, err := s.replaceRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.writeAudit(ctx, domain.AuditActionUserMFAEnabled, userID); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// verifyProof принимает код из приложения или один из кодов восстановления.
+func (s *MFAService) verifyProof(ctx context.Context, mfa *domain.MFA, proof domain.MFAProof) error {
+	if proof.Code != "" {
+		return s.verifyTOTP(ctx, mfa, proof.Code)
+	}
+	if proof.RecoveryCode == "" {
+		return ErrInvalidMFACode
+	}
+	if err := s.mfa.UseRecoveryCode(ctx, mfa.UserID, hashRecoveryCode(proof.RecoveryCode)); err != nil {
+		if errors.Is(err, repository.ErrRecoveryCodeNotFound) {
+			return ErrInvalidMFACode
+		}
+		return err
+	}
+	return s.writeAudit(ctx, domain.AuditActionUserMFARecoveryCodeUsed, mfa.UserID)
+}
+
+// verifyTOTP проверяет код и запоминает его интервал, чтобы перехваченный код нельзя было предъявить повторно.
+func (s *MFAService) verifyTOTP(ctx context.Context, mfa *domain.MFA, code string) error {
+	step, ok := auth.ValidateTOTP(mfa.Secret, code, s.now())
+	if !ok {
+		return ErrInvalidMFACode
+	}
+	fresh, err := s.mfa.UseTOTPStep(ctx, mfa.UserID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+func (s *MFAService) replaceRecoveryCodes(ctx context.Context, userID int64) ([]string, error) {
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfa.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s *MFAService) deleteMFA(ctx context.Context, userID int64) error {
+	if err := s.mfa.DeleteMFA(ctx, userID); err != nil {
+		if errors.Is(err, repository.ErrMFANotFound) {
+			return ErrMFANotEnabled
+		}
+		return err
+	}
+	return s.writeAudit(ctx, domain.AuditActionUserMFADisabled, userID)
+}
+
+func (s *MFAService) getUser(ctx context.Context, userID int64) (*domain.User, error) {
+	user, err := s.users.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *MFAService) getMFA(ctx context.Context, userID int64) (*domain.MFA, error) {
+	mfa, err := s.mfa.GetMFA(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrMFANotFound) {
+			return nil, ErrMFANotEnabled
+		}
+		return nil, err
+	}
+	return mfa, nil
+}
+
+func (s *MFAService) getEnabledMFA(ctx context.Context, userID int64) (*domain.MFA, error) {
+	if _, err := s.getUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	mfa, err := s.getMFA(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !mfa.Enabled() {
+		return nil, ErrMFANotEnabled
+	}
+	return mfa, nil
+}
+
+func (s *MFAService) writeAudit(ctx context.Context, action string, userID int64) error {
+	record, err := newAuditRecord(ctx, action, domain.AuditEntityUser, userID, nil, nil)
+	if err != nil {
+		return err
+	}
+	return s.audit.CreateAuditRecord(ctx, record)
+}
+
+// generateRecoveryCodes возвращает коды вида xxxx-xxxx-xxxx-xxxx (80 бит) и их SHA-256 хеши.
+func generateRecoveryCodes() ([]string, [][]byte, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([][]byte, recoveryCodeCount)
+	for i := range codes {
+		secret := make([]byte, 10)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(recoveryCodeEncoding.EncodeToString(secret))
+		codes[i] = raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:16]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode не учитывает регистр, дефисы и пробелы, чтобы код можно было ввести как удобно.
+func hashRecoveryCode(code string) []byte {
+	normalized := strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(code)))
+	sum := sha256.Sum256([]byte(normalized))
+	return sum[:]
+}
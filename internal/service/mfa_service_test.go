@@ -0,0 +1,266 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+	"testovoe/internal/auth"
+	"testovoe/internal/domain"
+	"testovoe/internal/repository"
+	"time"
+)
+
+// memoryMFARepository хранит настройки второго фактора в памяти.
+type memoryMFARepository struct {
+	records  map[int64]*domain.MFA
+	codes    map[int64]map[string]bool
+	required map[int64]bool
+	policy   []domain.Role
+	used     map[string]bool
+}
+
+func newMemoryMFARepository() *memoryMFARepository {
+	return &memoryMFARepository{records: map[int64]*domain.MFA{}, codes: map[int64]map[string]bool{}, required: map[int64]bool{}, used: map[string]bool{}}
+}
+
+func (r *memoryMFARepository) GetMFA(ctx context.Context, userID int64) (*domain.MFA, error) {
+	mfa, ok := r.records[userID]
+	if !ok {
+		return nil, repository.ErrMFANotFound
+	}
+	copied := *mfa
+	return &copied, nil
+}
+
+func (r *memoryMFARepository) SaveMFASecret(ctx context.Context, userID int64, secret string) error {
+	if mfa, ok := r.records[userID]; ok && mfa.ConfirmedAt != nil {
+		return nil
+	}
+	r.records[userID] = &domain.MFA{UserID: userID, Secret: secret, CreatedAt: time.Now()}
+	return nil
+}
+
+func (r *memoryMFARepository) ConfirmMFA(ctx context.Context, userID int64) error {
+	mfa, ok := r.records[userID]
+	if !ok || mfa.ConfirmedAt != nil {
+		return repository.ErrMFANotFound
+	}
+	now := time.Now()
+	mfa.ConfirmedAt = &now
+	return nil
+}
+
+func (r *memoryMFARepository) DeleteMFA(ctx context.Context, userID int64) error {
+	if _, ok := r.records[userID]; !ok {
+		return repository.ErrMFANotFound
+	}
+	delete(r.records, userID)
+	delete(r.codes, userID)
+	return nil
+}
+
+func (r *memoryMFARepository) UseTOTPStep(ctx context.Context, userID, step int64) (bool, error) {
+	mfa := r.records[userID]
+	if mfa.LastUsedStep != nil && *mfa.LastUsedStep >= step {
+		return false, nil
+	}
+	mfa.LastUsedStep = &step
+	return true, nil
+}
+
+func (r *memoryMFARepository) UseMFAChallenge(ctx context.Context, jti string, expiresAt time.Time) (bool, error) {
+	if r.used[jti] {
+		return false, nil
+	}
+	r.used[jti] = true
+	return true, nil
+}
+
+func (r *memoryMFARepository) ReplaceRecoveryCodes(ctx context.Context, userID int64, hashes [][]byte) error {
+	r.codes[userID] = map[string]bool{}
+	for _, hash := range hashes {
+		r.codes[userID][string(hash)] = false
+	}
+	return nil
+}
+
+func (r *memoryMFARepository) UseRecoveryCode(ctx context.Context, userID int64, hash []byte) error {
+	used, ok := r.codes[userID][string(hash)]
+	if !ok || used {
+		return repository.ErrRecoveryCodeNotFound
+	}
+	r.codes[userID][string(hash)] = true
+	return nil
+}
+
+func (r *memoryMFARepository) CountRecoveryCodes(ctx context.Context, userID int64) (int, error) {
+	count := 0
+	for _, used := range r.codes[userID] {
+		if !used {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *memoryMFARepository) UserRequiresMFA(ctx context.Context, userID int64) (bool, error) {
+	return r.required[userID], nil
+}
+
+func (r *memoryMFARepository) ListMFARequiredRoles(ctx context.Context) ([]domain.Role, error) {
+	return append([]domain.Role{}, r.policy...), nil
+}
+
+func (r *memoryMFARepository) SetMFARequiredRoles(ctx context.Context, roles []domain.Role) error {
+	r.policy = roles
+	return nil
+}
+
+var testMFANow = time.Unix(1700000000, 0)
+
+func newTestMFAService() (*MFAService, *MockUserRepository, *memoryMFARepository, *recordingAuditRepository) {
+	users := new(MockUserRepository)
+	repo := newMemoryMFARepository()
+	audit := new(recordingAuditRepository)
+	service := NewMFAService(users, repo, newMemorySessionRepository(), audit, fakeTransactor{}, newTestTokenIssuer(), nil, "testovoe")
+	service.now = func() time.Time { return testMFANow }
+	return service, users, repo, audit
+}
+
+func totpCodeForTest(t *testing.T, secret string, at time.Time) string {
+	code, err := auth.TOTPCode(secret, auth.TOTPStep(at))
+	assert.NoError(t, err)
+	return code
+}
+
+func enrollForTest(t *testing.T, service *MFAService) (string, []string) {
+	enrollment, err := service.StartMFAEnrollment(context.Background(), 7)
+	assert.NoError(t, err)
+	codes, err := service.ConfirmMFAEnrollment(context.Background(), 7, totpCodeForTest(t, enrollment.Secret, testMFANow))
+	assert.NoError(t, err)
+	return enrollment.Secret, codes
+}
+
+func TestMFAEnrollment(t *testing.T) {
+	service, users, repo, audit := newTestMFAService()
+	users.On("GetUserByID", mock.Anything, int64(7)).Return(&domain.User{ID: 7, Email: "ivan@example.com"}, nil)
+
+	enrollment, err := service.StartMFAEnrollment(context.Background(), 7)
+	assert.NoError(t, err)
+	assert.Contains(t, enrollment.URI, "otpauth://totp/testovoe:ivan@example.com")
+
+	_, err = service.ConfirmMFAEnrollment(context.Background(), 7, "000000")
+	assert.ErrorIs(t, err, ErrInvalidMFACode)
+	assert.Nil(t, repo.records[7].ConfirmedAt)
+
+	codes, err := service.ConfirmMFAEnrollment(context.Background(), 7, totpCodeForTest(t, enrollment.Secret, testMFANow))
+	assert.NoError(t, err)
+	assert.Len(t, codes, recoveryCodeCount)
+	assert.Equal(t, domain.AuditActionUserMFAEnabled, audit.records[len(audit.records)-1].Action)
+
+	status, err := service.GetMFAStatus(context.Background(), 7)
+	assert.NoError(t, err)
+	assert.True(t, status.Enabled)
+	assert.Equal(t, recoveryCodeCount, status.RecoveryCodesLeft)
+
+	_, err = service.StartMFAEnrollment(context.Background(), 7)
+	assert.ErrorIs(t, err, ErrMFAAlreadyEnabled)
+}
+
+func TestLogin_MFAChallenge(t *testing.T) {
+	mfaService, users, _, _ := newTestMFAService()
+	users.On("GetUserByID", mock.Anything, int64(7)).Return(&domain.User{ID: 7, Email: "ivan@example.com"}, nil)
+	secret, codes := enrollForTest(t, mfaService)
+
+	authService, _, credentials, _ := newTestAuthService(testArgon2Params)
+	authService.mfa = mfaService
+	hash, err := auth.NewPasswordHasher(testArgon2Params).Hash("secret-password")
+	assert.NoError(t, err)
+	credentials.On("GetCredentialsByEmail", mock.Anything, "ivan@example.com").Return(&domain.User{ID: 7}, hash, nil)
+
+	_, err = authService.Login(context.Background(), "ivan@example.com", "secret-password", domain.SessionMeta{})
+	var challenge *MFAChallengeError
+	assert.True(t, errors.As(err, &challenge))
+	assert.False(t, challenge.EnrollmentRequired)
+
+	// Код, уже использованный при подключении, повторно не принимается.
+	_, err = mfaService.VerifyMFAChallenge(context.Background(), challenge.Token, domain.MFAProof{Code: totpCodeForTest(t, secret, testMFANow)}, domain.SessionMeta{})
+	assert.ErrorIs(t, err, ErrInvalidMFACode)
+
+	next := testMFANow.Add(auth.TOTPPeriod)
+	mfaService.now = func() time.Time { return next }
+	tokens, err := mfaService.VerifyMFAChallenge(context.Background(), challenge.Token, domain.MFAProof{Code: totpCodeForTest(t, secret, next)}, domain.SessionMeta{})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, tokens.AccessToken)
+	assert.Empty(t, tokens.RecoveryCodes)
+
+	// Токен действует для одного входа: второй фактор по нему повторно не проходится.
+	_, err = mfaService.VerifyMFAChallenge(context.Background(), challenge.Token, domain.MFAProof{RecoveryCode: codes[0]}, domain.SessionMeta{})
+	assert.ErrorIs(t, err, ErrInvalidMFAToken)
+
+	_, err = authService.Login(context.Background(), "ivan@example.com", "secret-password", domain.SessionMeta{})
+	assert.True(t, errors.As(err, &challenge))
+	_, err = mfaService.VerifyMFAChallenge(context.Background(), challenge.Token, domain.MFAProof{RecoveryCode: codes[1]}, domain.SessionMeta{})
+	assert.NoError(t, err)
+	_, err = authService.Login(context.Background(), "ivan@example.com", "secret-password", domain.SessionMeta{})
+	assert.True(t, errors.As(err, &challenge))
+	_, err = mfaService.VerifyMFAChallenge(context.Background(), challenge.Token, domain.MFAProof{RecoveryCode: codes[1]}, domain.SessionMeta{})
+	assert.ErrorIs(t, err, ErrInvalidMFACode)
+
+	_, err = mfaService.VerifyMFAChallenge(context.Background(), "not-a-token", domain.MFAProof{Code: "123456"}, domain.SessionMeta{})
+	assert.ErrorIs(t, err, ErrInvalidMFAToken)
+}
+
+func TestLogin_MFAEnrollmentRequiredByPolicy(t *testing.T) {
+	mfaService, users, repo, _ := newTestMFAService()
+	users.On("GetUserByID", mock.Anything, int64(7)).Return(&domain.User{ID: 7, Email: "ivan@example.com"}, nil)
+	repo.required[7] = true
+
+	err := mfaService.Challenge(context.Background(), 7)
+	var challenge *MFAChallengeError
+	assert.True(t, errors.As(err, &challenge))
+	assert.True(t, challenge.EnrollmentRequired)
+
+	_, err = mfaService.VerifyMFAChallenge(context.Background(), challenge.Token, domain.MFAProof{Code: "123456"}, domain.SessionMeta{})
+	assert.ErrorIs(t, err, ErrMFANotEnabled)
+
+	enrollment, err := mfaService.EnrollMFAChallenge(context.Background(), challenge.Token)
+	assert.NoError(t, err)
+	tokens, err := mfaService.VerifyMFAChallenge(context.Background(), challenge.Token,
+		domain.MFAProof{Code: totpCodeForTest(t, enrollment.Secret, testMFANow)}, domain.SessionMeta{})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, tokens.AccessToken)
+	assert.Len(t, tokens.RecoveryCodes, recoveryCodeCount)
+}
+
+func TestDisableMFA(t *testing.T) {
+	service, users, repo, audit := newTestMFAService()
+	users.On("GetUserByID", mock.Anything, int64(7)).Return(&domain.User{ID: 7, Email: "ivan@example.com"}, nil)
+	_, codes := enrollForTest(t, service)
+
+	repo.required[7] = true
+	assert.ErrorIs(t, service.DisableMFA(context.Background(), 7, domain.MFAProof{RecoveryCode: codes[0]}), ErrMFAEnforced)
+
+	repo.required[7] = false
+	assert.ErrorIs(t, service.DisableMFA(context.Background(), 7, domain.MFAProof{RecoveryCode: "wrong"}), ErrInvalidMFACode)
+	assert.NoError(t, service.DisableMFA(context.Background(), 7, domain.MFAProof{RecoveryCode: codes[0]}))
+	assert.Empty(t, repo.records)
+	assert.Equal(t, domain.AuditActionUserMFADisabled, audit.records[len(audit.records)-1].Action)
+
+	assert.ErrorIs(t, service.ResetMFA(context.Background(), 7), ErrMFANotEnabled)
+}
+
+func TestSetMFAPolicy(t *testing.T) {
+	service, _, repo, audit := newTestMFAService()
+
+	_, err := service.SetMFAPolicy(context.Background(), []domain.Role{domain.RoleSelf})
+	assert.ErrorIs(t, err, ErrInvalidRole)
+
+	roles, err := service.SetMFAPolicy(context.Background(), []domain.Role{domain.RoleOperator, domain.RoleAdmin, domain.RoleAdmin})
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Role{domain.RoleAdmin, domain.RoleOperator}, roles)
+	assert.Equal(t, roles, repo.policy)
+	assert.Equal(t, domain.AuditEntityMFAPolicy, audit.records[0].EntityType)
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+	"testovoe/internal/apperr"
+	"testovoe/internal/domain"
+	"testovoe/internal/repository"
+	"unicode/utf8"
+)
+
+var ErrOAuthClientNotFound = apperr.New(apperr.KindNotFound, "oauth_client_not_found", "клиент OIDC не найден")
+var ErrInvalidOAuthClient = apperr.New(apperr.KindInvalid, "invalid_oauth_client", "некорректные данные клиента OIDC")
+
+const (
+	oauthClientIDPrefix     = "tvc_"
+	oauthClientSecretPrefix = "tvs_"
+	maxOAuthClientName      = 255
+	maxRedirectURIs         = 10
+)
+
+type OAuthClientServiceInterface interface {
+	CreateOAuthClient(ctx context.Context, name string, redirectURIs []string, confidential bool) (*domain.RegisteredOAuthClient, error)
+	ListOAuthClients(ctx context.Context) ([]domain.OAuthClient, error)
+	GetOAuthClient(ctx context.Context, id string) (*domain.OAuthClient, error)
+	DeleteOAuthClient(ctx context.Context, id string) error
+}
+
+type OAuthClientService struct {
+	repo  repository.OAuthClientRepositoryInterface
+	audit repository.AuditRepositoryInterface
+	tx    repository.TransactorInterface
+}
+
+func NewOAuthClientService(repo repository.OAuthClientRepositoryInterface, audit repository.AuditRepositoryInterface, tx repository.TransactorInterface) *OAuthClientService {
+	return &OAuthClientService{repo: repo, audit: audit, tx: tx}
+}
+
+// CreateOAuthClient регистрирует приложение. Секрет конфиденциального клиента возвращается один раз.
+func (s *OAuthClientService) CreateOAuthClient(ctx context.Context, name string, redirectURIs []string, confidential bool) (*domain.RegisteredOAuthClient, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxOAuthClientName {
+		return nil, ErrInvalidOAuthClient
+	}
+	if len(redirectURIs) == 0 || len(redirectURIs) > maxRedirectURIs {
+		return nil, ErrInvalidOAuthClient
+	}
+	for _, redirectURI := range redirectURIs {
+		if !validRedirectURI(redirectURI) {
+			return nil, ErrInvalidOAuthClient
+		}
+	}
+
+	id, err := randomToken(oauthClientIDPrefix, 16)
+	if err != nil {
+		return nil, err
+	}
+	client := &domain.OAuthClient{ID: id, Name: name, RedirectURIs: redirectURIs}
+	registered := &domain.RegisteredOAuthClient{}
+	if confidential {
+		if registered.Secret, err = randomToken(oauthClientSecretPrefix, 32); err != nil {
+			return nil, err
+		}
+		client.SecretHash = hashClientSecret(registered.Secret)
+	}
+
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.CreateOAuthClient(ctx, client); err != nil {
+			return err
+		}
+		record, err := newAuditRecord(ctx, domain.AuditActionOAuthClientCreated, domain.AuditEntityOAuthClient, 0, nil, client)
+		if err != nil {
+			return err
+		}
+		return s.audit.CreateAuditRecord(ctx, record)
+	})
+	if err != nil {
+		return nil, err
+	}
+	registered.Client = *client
+	return registered, nil
+}
+
+func (s *OAuthClientService) ListOAuthClients(ctx context.Context) ([]domain.OAuthClient, error) {
+	return s.repo.ListOAuthClients(ctx)
+}
+
+func (s *OAuthClientService) GetOAuthClient(ctx context.Context, id string) (*domain.OAuthClient, error) {
+	client, err := s.repo.GetOAuthClient(ctx, id)
+	if err != nil {
+		return nil, mapOAuthClientError(err)
+	}
+	return client, nil
+}
+
+// DeleteOAuthClient удаляет клиента; все выданные ему токены перестают действовать.
+func (s *OAuthClientService) DeleteOAuthClient(ctx context.Context, id string) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		client, err := s.repo.DeleteOAuthClient(ctx, id)
+		if err != nil {
+			return mapOAuthClientError(err)
+		}
+		record, err := newAuditRecord(ctx, domain.AuditActionOAuthClientDeleted, domain.AuditEntityOAuthClient, 0, client, nil)
+		if err != nil {
+			return err
+		}
+		return s.audit.CreateAuditRecord(ctx, record)
+	})
+}
+
+func mapOAuthClientError(err error) error {
+	if errors.Is(err, repository.ErrOAuthClientNotFound) {
+		return ErrOAuthClientNotFound
+	}
+	return err
+}
+
+// validRedirectURI принимает абсолютные адреса без фрагмента. Кроме http(s) допускаются собственные
+// схемы мобильных приложений; http разрешен, так как внутренние приложения не всегда доступны по https.
+func validRedirectURI(raw string) bool {
+	if len(raw) > 2048 {
+		return false
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil || !parsed.IsAbs() || parsed.Fragment != "" || strings.Contains(raw, "#") {
+		return false
+	}
+	switch strings.ToLower(parsed.Scheme) {
+	case "http", "https":
+		return parsed.Host != ""
+	case "javascript", "data", "file", "vbscript":
+		return false
+	}
+	return true
+}
+
+// randomToken возвращает prefix и size случайных байт в base64url.
+func randomToken(prefix string, size int) (string, error) {
+	secret := make([]byte, size)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return prefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+func hashClientSecret(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
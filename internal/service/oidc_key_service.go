@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sync"
+	"testovoe/internal/auth"
+	"testovoe/internal/domain"
+	"testovoe/internal/repository"
+	"time"
+)
+
+const (
+	signingKeyAlgorithm = "RS256"
+	signingKeyBits      = 2048
+)
+
+// OIDCKeyService хранит ключи подписи ID-токенов в базе и ротирует их. Ключ старше rotation
+// заменяется новым при следующей подписи. Замененный ключ еще retention публикуется в JWKS,
+// чтобы клиенты могли проверить подписанные им токены. Если задан box, закрытые ключи хранятся
+// в базе зашифрованными, а ключ, сохраненный без шифрования, заменяется при следующей подписи.
+type OIDCKeyService struct {
+	repo      repository.OIDCRepositoryInterface
+	audit     repository.AuditRepositoryInterface
+	tx        repository.TransactorInterface
+	box       *auth.SecretBox
+	rotation  time.Duration
+	retention time.Duration
+	now       func() time.Time
+
+	mu     sync.Mutex
+	parsed map[string]*rsa.PrivateKey
+}
+
+func NewOIDCKeyService(repo repository.OIDCRepositoryInterface, audit repository.AuditRepositoryInterface, tx repository.TransactorInterface, box *auth.SecretBox, rotation, retention time.Duration) *OIDCKeyService {
+	return &OIDCKeyService{
+		repo: repo, audit: audit, tx: tx, box: box, rotation: rotation, retention: retention, now: time.Now,
+		parsed: map[string]*rsa.PrivateKey{},
+	}
+}
+
+// ActiveKey возвращает ключ, которым подписываются новые токены, при необходимости создавая его.
+func (s *OIDCKeyService) ActiveKey(ctx context.Context) (string, *rsa.PrivateKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys, err := s.repo.ListSigningKeys(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(keys) == 0 || s.now().Sub(keys[0].CreatedAt) >= s.rotation || (s.box != nil && !auth.IsSealed(keys[0].PrivateKey)) {
+		key, err := s.createKey(ctx)
+		if err != nil {
+			return "", nil, err
+		}
+		keys = []domain.SigningKey{*key}
+	}
+	private, err := s.parse(keys[0])
+	if err != nil {
+		return "", nil, err
+	}
+	return keys[0].ID, private, nil
+}
+
+// PublishedKeys возвращает открытые ключи для JWKS: действующий ключ и ключи, замененные
+// не раньше чем retention назад.
+func (s *OIDCKeyService) PublishedKeys(ctx context.Context) (*domain.JSONWebKeySet, error) {
+	keys, err := s.repo.ListSigningKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	set := &domain.JSONWebKeySet{Keys: make([]domain.JSONWebKey, 0, len(keys))}
+	for i, key := range keys {
+		// keys упорядочены от нового к старому: ключ keys[i] заменен ключом keys[i-1].
+		if i > 0 && s.now().Sub(keys[i-1].CreatedAt) > s.retention {
+			break
+		}
+		private, err := s.parse(key)
+		if err != nil {
+			return nil, err
+		}
+		set.Keys = append(set.Keys, publicJWK(key.ID, &private.PublicKey))
+	}
+	return set, nil
+}
+
+// RotateSigningKey немедленно заменяет ключ подписи, например при подозрении на компрометацию.
+func (s *OIDCKeyService) RotateSigningKey(ctx context.Context) (*domain.JSONWebKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, err := s.createKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	private, err := s.parse(*key)
+	if err != nil {
+		return nil, err
+	}
+	jwk := publicJWK(key.ID, &private.PublicKey)
+	return &jwk, nil
+}
+
+func (s *OIDCKeyService) createKey(ctx context.Context) (*domain.SigningKey, error) {
+	private, err := rsa.GenerateKey(rand.Reader, signingKeyBits)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	key := &domain.SigningKey{
+		ID:         jwkThumbprint(&private.PublicKey),
+		Algorithm:  signingKeyAlgorithm,
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+	}
+	if s.box != nil {
+		if key.PrivateKey, err = s.box.Seal([]byte(key.PrivateKey), []byte(key.ID)); err != nil {
+			return nil, err
+		}
+	}
+
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.CreateSigningKey(ctx, key); err != nil {
+			return err
+		}
+		record, err := newAuditRecord(ctx, domain.AuditActionOIDCSigningKeyRotated, domain.AuditEntitySigningKey, 0,
+			nil, map[string]string{"kid": key.ID})
+		if err != nil {
+			return err
+		}
+		return s.audit.CreateAuditRecord(ctx, record)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.parsed[key.ID] = private
+	return key, nil
+}
+
+func (s *OIDCKeyService) parse(key domain.SigningKey) (*rsa.PrivateKey, error) {
+	if private, ok := s.parsed[key.ID]; ok {
+		return private, nil
+	}
+	encoded := []byte(key.PrivateKey)
+	if auth.IsSealed(key.PrivateKey) {
+		if s.box == nil {
+			return nil, fmt.Errorf("ключ подписи %s зашифрован, задайте OIDC_KEY_ENCRYPTION_SECRET", key.ID)
+		}
+		opened, err := s.box.Open(key.PrivateKey, []byte(key.ID))
+		if err != nil {
+			return nil, fmt.Errorf("ключ подписи %s: %w", key.ID, err)
+		}
+		encoded = opened
+	}
+	block, _ := pem.Decode(encoded)
+	if block == nil {
+		return nil, fmt.Errorf("ключ подписи %s поврежден", key.ID)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("ключ подписи %s поврежден: %w", key.ID, err)
+	}
+	private, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("ключ подписи %s не является ключом RSA", key.ID)
+	}
+	s.parsed[key.ID] = private
+	return private, nil
+}
+
+func publicJWK(kid string, key *rsa.PublicKey) domain.JSONWebKey {
+	return domain.JSONWebKey{
+		KeyType:   "RSA",
+		Use:       "sig",
+		Algorithm: signingKeyAlgorithm,
+		KeyID:     kid,
+		N:         base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+// jwkThumbprint вычисляет идентификатор ключа по RFC 7638.
+func jwkThumbprint(key *rsa.PublicKey) string {
+	jwk := publicJWK("", key)
+	canonical := `{"e":"` + jwk.E + `","kty":"RSA","n":"` + jwk.N + `"}`
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,623 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"net/url"
+	"strconv"
+	"strings"
+	"testovoe/internal/domain"
+	"testovoe/internal/i18n"
+	"testovoe/internal/repository"
+	"time"
+)
+
+// Коды ошибок OAuth 2.0 (RFC 6749, раздел 5.2), которые возвращаются клиентам без перевода.
+const (
+	OAuthInvalidRequest          = "invalid_request"
+	OAuthInvalidClient           = "invalid_client"
+	OAuthInvalidGrant            = "invalid_grant"
+	OAuthInvalidScope            = "invalid_scope"
+	OAuthUnsupportedGrantType    = "unsupported_grant_type"
+	OAuthUnsupportedResponseType = "unsupported_response_type"
+	OAuthInvalidToken            = "invalid_token"
+)
+
+const (
+	oauthTokenPrefix   = "tvo_"
+	oauthCodePrefix    = "tvg_"
+	pkceMethodS256     = "S256"
+	minPKCEVerifierLen = 43
+	maxPKCEVerifierLen = 128
+)
+
+var supportedScopes = []string{domain.ScopeOpenID, domain.ScopeProfile, domain.ScopeEmail, domain.ScopeOfflineAccess}
+
+// OAuthError — ошибка протокола OAuth; Code передается клиенту как есть, Description поясняет причину.
+// Description — ключ каталога i18n, Args — аргументы для него.
+type OAuthError struct {
+	Code        string
+	Description string
+	Args        []any
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Localize(context.Background())
+}
+
+// Localize возвращает пояснение на языке запроса.
+func (e *OAuthError) Localize(ctx context.Context) string {
+	return i18n.T(ctx, e.Description, e.Args...)
+}
+
+func oauthError(code, description string, args ...any) *OAuthError {
+	return &OAuthError{Code: code, Description: description, Args: args}
+}
+
+type OIDCConfig struct {
+	Issuer string
+	// LoginURL — страница входа, на которую отправляется браузер из /oauth/authorize.
+	LoginURL   string
+	CodeTTL    time.Duration
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	IDTokenTTL time.Duration
+}
+
+type OIDCServiceInterface interface {
+	Discovery() *domain.OIDCDiscovery
+	PublishedKeys(ctx context.Context) (*domain.JSONWebKeySet, error)
+	RotateSigningKey(ctx context.Context) (*domain.JSONWebKey, error)
+	LoginRedirect(ctx context.Context, request domain.AuthorizeRequest) (string, error)
+	Authorize(ctx context.Context, userID int64, request domain.AuthorizeRequest) (string, error)
+	Token(ctx context.Context, request domain.TokenRequest) (*domain.OAuthTokenResponse, error)
+	UserInfo(ctx context.Context, accessToken string) (*domain.UserInfo, error)
+	Introspect(ctx context.Context, clientID, clientSecret, token string) (*domain.TokenIntrospection, error)
+	Revoke(ctx context.Context, clientID, clientSecret, token string) error
+}
+
+type OIDCService struct {
+	users   repository.UserRepositoryInterface
+	clients repository.OAuthClientRepositoryInterface
+	repo    repository.OIDCRepositoryInterface
+	audit   repository.AuditRepositoryInterface
+	tx      repository.TransactorInterface
+	keys    *OIDCKeyService
+	cfg     OIDCConfig
+	now     func() time.Time
+}
+
+func NewOIDCService(users repository.UserRepositoryInterface, clients repository.OAuthClientRepositoryInterface, repo repository.OIDCRepositoryInterface, audit repository.AuditRepositoryInterface, tx repository.TransactorInterface, keys *OIDCKeyService, cfg OIDCConfig) *OIDCService {
+	cfg.Issuer = strings.TrimSuffix(cfg.Issuer, "/")
+	return &OIDCService{users: users, clients: clients, repo: repo, audit: audit, tx: tx, keys: keys, cfg: cfg, now: time.Now}
+}
+
+func (s *OIDCService) Discovery() *domain.OIDCDiscovery {
+	return &domain.OIDCDiscovery{
+		Issuer:                            s.cfg.Issuer,
+		AuthorizationEndpoint:             s.cfg.Issuer + "/oauth/authorize",
+		TokenEndpoint:                     s.cfg.Issuer + "/oauth/token",
+		UserInfoEndpoint:                  s.cfg.Issuer + "/oauth/userinfo",
+		JWKSURI:                           s.cfg.Issuer + "/.well-known/jwks.json",
+		IntrospectionEndpoint:             s.cfg.Issuer + "/oauth/introspect",
+		RevocationEndpoint:                s.cfg.Issuer + "/oauth/revoke",
+		ScopesSupported:                   supportedScopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code", "refresh_token"},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{signingKeyAlgorithm},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{pkceMethodS256},
+		ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "name", "email", "email_verified"},
+	}
+}
+
+func (s *OIDCService) PublishedKeys(ctx context.Context) (*domain.JSONWebKeySet, error) {
+	return s.keys.PublishedKeys(ctx)
+}
+
+func (s *OIDCService) RotateSigningKey(ctx context.Context) (*domain.JSONWebKey, error) {
+	return s.keys.RotateSigningKey(ctx)
+}
+
+// LoginRedirect проверяет клиента и адрес возврата и возвращает адрес страницы входа, которой
+// передаются исходные параметры запроса. После входа страница вызывает POST /oauth/authorize.
+func (s *OIDCService) LoginRedirect(ctx context.Context, request domain.AuthorizeRequest) (string, error) {
+	if _, err := s.authorizeClient(ctx, request); err != nil {
+		return "", err
+	}
+	if s.cfg.LoginURL == "" {
+		return "", oauthError(OAuthInvalidRequest, "страница входа не настроена")
+	}
+	login, err := url.Parse(s.cfg.LoginURL)
+	if err != nil {
+		return "", err
+	}
+	query := login.Query()
+	for key, values := range authorizeQuery(request) {
+		query[key] = values
+	}
+	login.RawQuery = query.Encode()
+	return login.String(), nil
+}
+
+// Authorize выдает код авторизации пользователю userID и возвращает адрес возврата клиента с кодом.
+// Ошибки в клиенте или адресе возврата возвращаются как OAuthError: перенаправлять на непроверенный адрес нельзя.
+// Остальные ошибки передаются клиенту в параметрах адреса возврата.
+func (s *OIDCService) Authorize(ctx context.Context, userID int64, request domain.AuthorizeRequest) (string, error) {
+	client, err := s.authorizeClient(ctx, request)
+	if err != nil {
+		return "", err
+	}
+	if oauthErr := validateAuthorizeRequest(request); oauthErr != nil {
+		return s.redirect(request, url.Values{"error": {oauthErr.Code}, "error_description": {oauthErr.Localize(ctx)}}), nil
+	}
+	if _, err := s.users.GetUserByID(ctx, userID); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return "", ErrUserNotFound
+		}
+		return "", err
+	}
+
+	code, err := randomToken(oauthCodePrefix, 32)
+	if err != nil {
+		return "", err
+	}
+	now := s.now()
+	err = s.repo.CreateAuthorizationCode(ctx, &domain.AuthorizationCode{
+		CodeHash:      hashSessionToken(code),
+		ClientID:      client.ID,
+		UserID:        userID,
+		RedirectURI:   request.RedirectURI,
+		Scope:         normalizeScope(request.Scope),
+		Nonce:         request.Nonce,
+		CodeChallenge: request.CodeChallenge,
+		AuthTime:      now,
+		ExpiresAt:     now.Add(s.cfg.CodeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return s.redirect(request, url.Values{"code": {code}}), nil
+}
+
+// Token обменивает код авторизации или refresh-токен на токены.
+func (s *OIDCService) Token(ctx context.Context, request domain.TokenRequest) (*domain.OAuthTokenResponse, error) {
+	client, err := s.authenticateClient(ctx, request.ClientID, request.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	switch request.GrantType {
+	case "authorization_code":
+		return s.exchangeCode(ctx, client, request)
+	case "refresh_token":
+		return s.refresh(ctx, client, request.RefreshToken)
+	case "":
+		return nil, oauthError(OAuthInvalidRequest, "не указан grant_type")
+	}
+	return nil, oauthError(OAuthUnsupportedGrantType, "поддерживаются authorization_code и refresh_token")
+}
+
+func (s *OIDCService) UserInfo(ctx context.Context, accessToken string) (*domain.UserInfo, error) {
+	token, user, err := s.activeToken(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	if token == nil || token.Kind != domain.OAuthTokenAccess {
+		return nil, oauthError(OAuthInvalidToken, "access-токен недействителен")
+	}
+	info := &domain.UserInfo{Subject: strconv.FormatInt(user.ID, 10)}
+	scopes := strings.Fields(token.Scope)
+	if hasScope(scopes, domain.ScopeProfile) {
+		info.Name = user.Name
+	}
+	if hasScope(scopes, domain.ScopeEmail) {
+		verified := user.EmailVerifiedAt != nil
+		info.Email, info.EmailVerified = user.Email, &verified
+	}
+	return info, nil
+}
+
+// Introspect сообщает, действует ли токен (RFC 7662). Вызывать его может только конфиденциальный клиент.
+func (s *OIDCService) Introspect(ctx context.Context, clientID, clientSecret, token string) (*domain.TokenIntrospection, error) {
+	client, err := s.authenticateClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if !client.Confidential {
+		return nil, oauthError(OAuthInvalidClient, "интроспекция доступна только конфиденциальным клиентам")
+	}
+
+	stored, user, err := s.activeToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		return &domain.TokenIntrospection{Active: false}, nil
+	}
+	tokenType := "Bearer"
+	if stored.Kind == domain.OAuthTokenRefresh {
+		tokenType = "refresh_token"
+	}
+	return &domain.TokenIntrospection{
+		Active:    true,
+		Scope:     stored.Scope,
+		ClientID:  stored.ClientID,
+		Subject:   strconv.FormatInt(user.ID, 10),
+		TokenType: tokenType,
+		ExpiresAt: stored.ExpiresAt.Unix(),
+		IssuedAt:  stored.CreatedAt.Unix(),
+		Issuer:    s.cfg.Issuer,
+	}, nil
+}
+
+// Revoke отзывает токен клиента (RFC 7009). Отзыв refresh-токена отзывает и все access-токены,
+// выпущенные по тому же коду авторизации. Неизвестный токен ошибкой не считается.
+func (s *OIDCService) Revoke(ctx context.Context, clientID, clientSecret, token string) error {
+	client, err := s.authenticateClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(token, oauthTokenPrefix) {
+		return nil
+	}
+	stored, err := s.repo.GetOAuthToken(ctx, hashSessionToken(token))
+	if err != nil {
+		if errors.Is(err, repository.ErrOAuthTokenNotFound) {
+			return nil
+		}
+		return err
+	}
+	if stored.ClientID != client.ID {
+		return nil
+	}
+	if stored.Kind == domain.OAuthTokenRefresh {
+		return s.repo.RevokeOAuthGrant(ctx, stored.GrantID)
+	}
+	return s.repo.RevokeOAuthToken(ctx, stored.TokenHash)
+}
+
+func (s *OIDCService) exchangeCode(ctx context.Context, client *domain.OAuthClient, request domain.TokenRequest) (*domain.OAuthTokenResponse, error) {
+	if !strings.HasPrefix(request.Code, oauthCodePrefix) {
+		return nil, oauthError(OAuthInvalidGrant, "код авторизации недействителен")
+	}
+
+	var response *domain.OAuthTokenResponse
+	reused := false
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		code, err := s.repo.ClaimAuthorizationCode(ctx, hashSessionToken(request.Code))
+		if err != nil {
+			if errors.Is(err, repository.ErrAuthorizationCodeNotFound) {
+				return oauthError(OAuthInvalidGrant, "код авторизации недействителен")
+			}
+			return err
+		}
+		if code.ClientID != client.ID {
+			return oauthError(OAuthInvalidGrant, "код авторизации выдан другому клиенту")
+		}
+		// Повторное предъявление кода означает, что его перехватили: токены, выданные по нему, отзываются.
+		if code.UsedAt != nil {
+			reused = true
+			if err := s.repo.RevokeOAuthGrant(ctx, code.ID); err != nil {
+				return err
+			}
+			record, err := newAuditRecord(ctx, domain.AuditActionOAuthCodeReuseDetected, domain.AuditEntityOAuthClient, 0,
+				nil, map[string]any{"client_id": client.ID, "user_id": code.UserID})
+			if err != nil {
+				return err
+			}
+			return s.audit.CreateAuditRecord(ctx, record)
+		}
+		if !code.ExpiresAt.After(s.now()) {
+			return oauthError(OAuthInvalidGrant, "срок действия кода авторизации истек")
+		}
+		if code.RedirectURI != request.RedirectURI {
+			return oauthError(OAuthInvalidGrant, "redirect_uri не совпадает с указанным при авторизации")
+		}
+		if !verifyPKCE(request.CodeVerifier, code.CodeChallenge) {
+			return oauthError(OAuthInvalidGrant, "code_verifier не соответствует code_challenge")
+		}
+
+		user, err := s.users.GetUserByID(ctx, code.UserID)
+		if err != nil {
+			if errors.Is(err, repository.ErrUserNotFound) {
+				return oauthError(OAuthInvalidGrant, "пользователь не найден")
+			}
+			return err
+		}
+		response, err = s.issueTokens(ctx, client, user, code.ID, code.Scope, code.Nonce, code.AuthTime)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return nil, oauthError(OAuthInvalidGrant, "код авторизации уже использован")
+	}
+	return response, nil
+}
+
+// refresh выпускает новые токены по refresh-токену; предъявленный токен перестает действовать.
+// Повторное предъявление уже обмененного refresh-токена отзывает всю цепочку токенов. Токен блокируется
+// до конца транзакции, поэтому из двух параллельных обменов второй считается повторным.
+func (s *OIDCService) refresh(ctx context.Context, client *domain.OAuthClient, refreshToken string) (*domain.OAuthTokenResponse, error) {
+	if !strings.HasPrefix(refreshToken, oauthTokenPrefix) {
+		return nil, oauthError(OAuthInvalidGrant, "refresh-токен недействителен")
+	}
+
+	var response *domain.OAuthTokenResponse
+	reused := false
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		stored, err := s.repo.ClaimOAuthToken(ctx, hashSessionToken(refreshToken))
+		if err != nil {
+			if errors.Is(err, repository.ErrOAuthTokenNotFound) {
+				return oauthError(OAuthInvalidGrant, "refresh-токен недействителен")
+			}
+			return err
+		}
+		if stored.Kind != domain.OAuthTokenRefresh || stored.ClientID != client.ID || !stored.ExpiresAt.After(s.now()) {
+			return oauthError(OAuthInvalidGrant, "refresh-токен недействителен")
+		}
+		if stored.RevokedAt != nil {
+			reused = true
+			return s.repo.RevokeOAuthGrant(ctx, stored.GrantID)
+		}
+
+		user, err := s.users.GetUserByID(ctx, stored.UserID)
+		if err != nil {
+			if errors.Is(err, repository.ErrUserNotFound) {
+				return oauthError(OAuthInvalidGrant, "пользователь не найден")
+			}
+			return err
+		}
+		response, err = s.issueTokens(ctx, client, user, stored.GrantID, stored.Scope, "", time.Time{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return nil, oauthError(OAuthInvalidGrant, "refresh-токен недействителен")
+	}
+	return response, nil
+}
+
+func (s *OIDCService) issueTokens(ctx context.Context, client *domain.OAuthClient, user *domain.User, grantID int64, scope, nonce string, authTime time.Time) (*domain.OAuthTokenResponse, error) {
+	now := s.now()
+	accessToken, err := s.storeToken(ctx, domain.OAuthTokenAccess, client, user, grantID, scope, now.Add(s.cfg.AccessTTL))
+	if err != nil {
+		return nil, err
+	}
+	response := &domain.OAuthTokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(s.cfg.AccessTTL.Seconds()),
+		Scope:       scope,
+	}
+
+	scopes := strings.Fields(scope)
+	if hasScope(scopes, domain.ScopeOfflineAccess) {
+		if response.RefreshToken, err = s.storeToken(ctx, domain.OAuthTokenRefresh, client, user, grantID, scope, now.Add(s.cfg.RefreshTTL)); err != nil {
+			return nil, err
+		}
+	}
+	if hasScope(scopes, domain.ScopeOpenID) {
+		if response.IDToken, err = s.idToken(ctx, client, user, scopes, nonce, authTime); err != nil {
+			return nil, err
+		}
+	}
+	return response, nil
+}
+
+func (s *OIDCService) storeToken(ctx context.Context, kind string, client *domain.OAuthClient, user *domain.User, grantID int64, scope string, expiresAt time.Time) (string, error) {
+	token, err := randomToken(oauthTokenPrefix, 32)
+	if err != nil {
+		return "", err
+	}
+	err = s.repo.CreateOAuthToken(ctx, &domain.OAuthToken{
+		TokenHash: hashSessionToken(token),
+		Kind:      kind,
+		GrantID:   grantID,
+		ClientID:  client.ID,
+		UserID:    user.ID,
+		Scope:     scope,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (s *OIDCService) idToken(ctx context.Context, client *domain.OAuthClient, user *domain.User, scopes []string, nonce string, authTime time.Time) (string, error) {
+	kid, key, err := s.keys.ActiveKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	now := s.now()
+	claims := jwt.MapClaims{
+		"iss": s.cfg.Issuer,
+		"sub": strconv.FormatInt(user.ID, 10),
+		"aud": client.ID,
+		"iat": now.Unix(),
+		"exp": now.Add(s.cfg.IDTokenTTL).Unix(),
+	}
+	if !authTime.IsZero() {
+		claims["auth_time"] = authTime.Unix()
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+	if hasScope(scopes, domain.ScopeProfile) {
+		claims["name"] = user.Name
+	}
+	if hasScope(scopes, domain.ScopeEmail) {
+		claims["email"] = user.Email
+		claims["email_verified"] = user.EmailVerifiedAt != nil
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	return token.SignedString(key)
+}
+
+// activeToken возвращает действующий токен и его пользователя; для недействительного токена возвращает nil без ошибки.
+func (s *OIDCService) activeToken(ctx context.Context, token string) (*domain.OAuthToken, *domain.User, error) {
+	if !strings.HasPrefix(token, oauthTokenPrefix) {
+		return nil, nil, nil
+	}
+	stored, err := s.repo.GetOAuthToken(ctx, hashSessionToken(token))
+	if err != nil {
+		if errors.Is(err, repository.ErrOAuthTokenNotFound) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	if stored.RevokedAt != nil || !stored.ExpiresAt.After(s.now()) {
+		return nil, nil, nil
+	}
+	user, err := s.users.GetUserByID(ctx, stored.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	return stored, user, nil
+}
+
+// authorizeClient проверяет клиента и адрес возврата запроса авторизации.
+func (s *OIDCService) authorizeClient(ctx context.Context, request domain.AuthorizeRequest) (*domain.OAuthClient, error) {
+	if request.ClientID == "" || request.RedirectURI == "" {
+		return nil, oauthError(OAuthInvalidRequest, "не указаны client_id или redirect_uri")
+	}
+	client, err := s.clients.GetOAuthClient(ctx, request.ClientID)
+	if err != nil {
+		if errors.Is(err, repository.ErrOAuthClientNotFound) {
+			return nil, oauthError(OAuthInvalidClient, "клиент не зарегистрирован")
+		}
+		return nil, err
+	}
+	for _, registered := range client.RedirectURIs {
+		if registered == request.RedirectURI {
+			return client, nil
+		}
+	}
+	return nil, oauthError(OAuthInvalidRequest, "redirect_uri не зарегистрирован для клиента")
+}
+
+// authenticateClient проверяет клиента на token, introspection и revocation endpoint.
+// Конфиденциальный клиент обязан предъявить секрет, публичный — только client_id.
+func (s *OIDCService) authenticateClient(ctx context.Context, clientID, clientSecret string) (*domain.OAuthClient, error) {
+	if clientID == "" {
+		return nil, oauthError(OAuthInvalidClient, "не указан client_id")
+	}
+	client, err := s.clients.GetOAuthClient(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, repository.ErrOAuthClientNotFound) {
+			return nil, oauthError(OAuthInvalidClient, "неверные учетные данные клиента")
+		}
+		return nil, err
+	}
+	if !client.Confidential {
+		if clientSecret != "" {
+			return nil, oauthError(OAuthInvalidClient, "у публичного клиента нет секрета")
+		}
+		return client, nil
+	}
+	if subtle.ConstantTimeCompare(hashClientSecret(clientSecret), client.SecretHash) != 1 {
+		return nil, oauthError(OAuthInvalidClient, "неверные учетные данные клиента")
+	}
+	return client, nil
+}
+
+func (s *OIDCService) redirect(request domain.AuthorizeRequest, params url.Values) string {
+	// Разбор уже проверен при регистрации клиента.
+	target, _ := url.Parse(request.RedirectURI)
+	query := target.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if request.State != "" {
+		query.Set("state", request.State)
+	}
+	// Параметр iss (RFC 9207) защищает клиента, работающего с несколькими провайдерами, от подмены ответа.
+	query.Set("iss", s.cfg.Issuer)
+	target.RawQuery = query.Encode()
+	return target.String()
+}
+
+// validateAuthorizeRequest проверяет параметры, ошибки в которых передаются клиенту через адрес возврата.
+func validateAuthorizeRequest(request domain.AuthorizeRequest) *OAuthError {
+	if request.ResponseType != "code" {
+		return oauthError(OAuthUnsupportedResponseType, "поддерживается только response_type=code")
+	}
+	scopes := strings.Fields(request.Scope)
+	if !hasScope(scopes, domain.ScopeOpenID) {
+		return oauthError(OAuthInvalidScope, "scope должен содержать openid")
+	}
+	for _, scope := range scopes {
+		if !hasScope(supportedScopes, scope) {
+			return oauthError(OAuthInvalidScope, "неподдерживаемый scope %s", scope)
+		}
+	}
+	if request.CodeChallenge == "" || request.CodeChallengeMethod != pkceMethodS256 {
+		return oauthError(OAuthInvalidRequest, "требуется PKCE с code_challenge_method=S256")
+	}
+	if len(request.CodeChallenge) != base64.RawURLEncoding.EncodedLen(sha256.Size) {
+		return oauthError(OAuthInvalidRequest, "некорректный code_challenge")
+	}
+	return nil
+}
+
+func verifyPKCE(verifier, challenge string) bool {
+	if len(verifier) < minPKCEVerifierLen || len(verifier) > maxPKCEVerifierLen {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+// normalizeScope убирает повторы, сохраняя порядок scope из запроса.
+func normalizeScope(scope string) string {
+	unique := make([]string, 0)
+	for _, item := range strings.Fields(scope) {
+		if !hasScope(unique, item) {
+			unique = append(unique, item)
+		}
+	}
+	return strings.Join(unique, " ")
+}
+
+func hasScope(scopes []string, scope string) bool {
+	for _, item := range scopes {
+		if item == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func authorizeQuery(request domain.AuthorizeRequest) url.Values {
+	query := url.Values{}
+	for key, value := range map[string]string{
+		"response_type":         request.ResponseType,
+		"client_id":             request.ClientID,
+		"redirect_uri":          request.RedirectURI,
+		"scope":                 request.Scope,
+		"state":                 request.State,
+		"nonce":                 request.Nonce,
+		"code_challenge":        request.CodeChallenge,
+		"code_challenge_method": request.CodeChallengeMethod,
+	} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	return query
+}
@@ -0,0 +1,645 @@
+package service
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"math/big"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"testovoe/internal/auth"
+	"testovoe/internal/domain"
+	"testovoe/internal/repository"
+	"time"
+)
+
+// memoryOIDCRepository хранит клиентов, коды, токены и ключи подписи в памяти. Методы выполняются
+// под общей блокировкой, чтобы проверять параллельные обмены токенов.
+type memoryOIDCRepository struct {
+	mu      sync.Mutex
+	clients map[string]*domain.OAuthClient
+	codes   map[string]*domain.AuthorizationCode
+	tokens  map[string]*domain.OAuthToken
+	keys    []domain.SigningKey
+	nextID  int64
+	now     func() time.Time
+}
+
+func newMemoryOIDCRepository(now func() time.Time) *memoryOIDCRepository {
+	return &memoryOIDCRepository{
+		clients: map[string]*domain.OAuthClient{},
+		codes:   map[string]*domain.AuthorizationCode{},
+		tokens:  map[string]*domain.OAuthToken{},
+		now:     now,
+	}
+}
+
+func (r *memoryOIDCRepository) CreateOAuthClient(ctx context.Context, client *domain.OAuthClient) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	client.Confidential = len(client.SecretHash) > 0
+	client.CreatedAt = r.now()
+	copied := *client
+	r.clients[client.ID] = &copied
+	return nil
+}
+
+func (r *memoryOIDCRepository) GetOAuthClient(ctx context.Context, id string) (*domain.OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	client, ok := r.clients[id]
+	if !ok {
+		return nil, repository.ErrOAuthClientNotFound
+	}
+	copied := *client
+	return &copied, nil
+}
+
+func (r *memoryOIDCRepository) ListOAuthClients(ctx context.Context) ([]domain.OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	clients := make([]domain.OAuthClient, 0, len(r.clients))
+	for _, client := range r.clients {
+		clients = append(clients, *client)
+	}
+	return clients, nil
+}
+
+func (r *memoryOIDCRepository) DeleteOAuthClient(ctx context.Context, id string) (*domain.OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	client, ok := r.clients[id]
+	if !ok {
+		return nil, repository.ErrOAuthClientNotFound
+	}
+	delete(r.clients, id)
+	return client, nil
+}
+
+func (r *memoryOIDCRepository) CreateAuthorizationCode(ctx context.Context, code *domain.AuthorizationCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	code.ID = r.nextID
+	code.CreatedAt = r.now()
+	copied := *code
+	r.codes[string(code.CodeHash)] = &copied
+	return nil
+}
+
+func (r *memoryOIDCRepository) ClaimAuthorizationCode(ctx context.Context, hash []byte) (*domain.AuthorizationCode, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	code, ok := r.codes[string(hash)]
+	if !ok {
+		return nil, repository.ErrAuthorizationCodeNotFound
+	}
+	claimed := *code
+	if code.UsedAt == nil {
+		now := r.now()
+		code.UsedAt = &now
+	}
+	return &claimed, nil
+}
+
+func (r *memoryOIDCRepository) CreateOAuthToken(ctx context.Context, token *domain.OAuthToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token.CreatedAt = r.now()
+	copied := *token
+	r.tokens[string(token.TokenHash)] = &copied
+	return nil
+}
+
+func (r *memoryOIDCRepository) GetOAuthToken(ctx context.Context, hash []byte) (*domain.OAuthToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.tokens[string(hash)]
+	if !ok {
+		return nil, repository.ErrOAuthTokenNotFound
+	}
+	copied := *token
+	return &copied, nil
+}
+
+func (r *memoryOIDCRepository) ClaimOAuthToken(ctx context.Context, hash []byte) (*domain.OAuthToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token, ok := r.tokens[string(hash)]
+	if !ok {
+		return nil, repository.ErrOAuthTokenNotFound
+	}
+	claimed := *token
+	r.revoke(token)
+	return &claimed, nil
+}
+
+func (r *memoryOIDCRepository) RevokeOAuthToken(ctx context.Context, hash []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if token, ok := r.tokens[string(hash)]; ok {
+		r.revoke(token)
+	}
+	return nil
+}
+
+func (r *memoryOIDCRepository) RevokeOAuthGrant(ctx context.Context, grantID int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, token := range r.tokens {
+		if token.GrantID == grantID {
+			r.revoke(token)
+		}
+	}
+	return nil
+}
+
+func (r *memoryOIDCRepository) revoke(token *domain.OAuthToken) {
+	if token.RevokedAt == nil {
+		now := r.now()
+		token.RevokedAt = &now
+	}
+}
+
+func (r *memoryOIDCRepository) ListSigningKeys(ctx context.Context) ([]domain.SigningKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	keys := make([]domain.SigningKey, 0, len(r.keys))
+	for i := len(r.keys) - 1; i >= 0; i-- {
+		keys = append(keys, r.keys[i])
+	}
+	return keys, nil
+}
+
+func (r *memoryOIDCRepository) CreateSigningKey(ctx context.Context, key *domain.SigningKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key.CreatedAt = r.now()
+	r.keys = append(r.keys, *key)
+	return nil
+}
+
+const (
+	testOIDCIssuer   = "https://id.example.com"
+	testRedirectURI  = "https://app.example.com/callback"
+	testClientSecret = "tvs_test-secret"
+	testPKCEVerifier = "dBjftJeZ4CVP-mJ92K9z-7d7oBmuyVfWq2kl9VvKXlwA1b2c3"
+)
+
+type oidcTestEnv struct {
+	service *OIDCService
+	keys    *OIDCKeyService
+	repo    *memoryOIDCRepository
+	users   *MockUserRepository
+	audit   *recordingAuditRepository
+	now     time.Time
+}
+
+func newOIDCTestEnv() *oidcTestEnv {
+	env := &oidcTestEnv{users: new(MockUserRepository), audit: new(recordingAuditRepository), now: time.Unix(1700000000, 0)}
+	clock := func() time.Time { return env.now }
+	env.repo = newMemoryOIDCRepository(clock)
+	env.keys = NewOIDCKeyService(env.repo, env.audit, fakeTransactor{}, nil, 30*24*time.Hour, 24*time.Hour)
+	env.keys.now = clock
+	env.service = NewOIDCService(env.users, env.repo, env.repo, env.audit, fakeTransactor{}, env.keys, OIDCConfig{
+		Issuer:     testOIDCIssuer + "/",
+		LoginURL:   "https://login.example.com/signin",
+		CodeTTL:    time.Minute,
+		AccessTTL:  time.Hour,
+		RefreshTTL: 30 * 24 * time.Hour,
+		IDTokenTTL: time.Hour,
+	})
+	env.service.now = clock
+
+	verified := env.now
+	env.users.On("GetUserByID", mock.Anything, int64(7)).
+		Return(&domain.User{ID: 7, Name: "Иван", Email: "ivan@example.com", EmailVerifiedAt: &verified}, nil)
+	env.users.On("GetUserByID", mock.Anything, mock.Anything).Return((*domain.User)(nil), repository.ErrUserNotFound)
+
+	_ = env.repo.CreateOAuthClient(context.Background(), &domain.OAuthClient{
+		ID: "tvc_web", Name: "Web", SecretHash: hashClientSecret(testClientSecret), RedirectURIs: []string{testRedirectURI},
+	})
+	_ = env.repo.CreateOAuthClient(context.Background(), &domain.OAuthClient{
+		ID: "tvc_spa", Name: "SPA", RedirectURIs: []string{testRedirectURI},
+	})
+	return env
+}
+
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func testAuthorizeRequest(clientID, scope string) domain.AuthorizeRequest {
+	return domain.AuthorizeRequest{
+		ResponseType:        "code",
+		ClientID:            clientID,
+		RedirectURI:         testRedirectURI,
+		Scope:               scope,
+		State:               "xyz",
+		Nonce:               "n-0S6",
+		CodeChallenge:       pkceChallenge(testPKCEVerifier),
+		CodeChallengeMethod: "S256",
+	}
+}
+
+// authorizeForTest выдает код пользователю 7 и возвращает параметры адреса возврата.
+func (env *oidcTestEnv) authorize(t *testing.T, clientID, scope string) url.Values {
+	location, err := env.service.Authorize(context.Background(), 7, testAuthorizeRequest(clientID, scope))
+	assert.NoError(t, err)
+	parsed, err := url.Parse(location)
+	assert.NoError(t, err)
+	return parsed.Query()
+}
+
+func (env *oidcTestEnv) exchange(clientID, secret, code string) (*domain.OAuthTokenResponse, error) {
+	return env.service.Token(context.Background(), domain.TokenRequest{
+		GrantType:    "authorization_code",
+		Code:         code,
+		RedirectURI:  testRedirectURI,
+		CodeVerifier: testPKCEVerifier,
+		ClientID:     clientID,
+		ClientSecret: secret,
+	})
+}
+
+func assertOAuthError(t *testing.T, err error, code string) {
+	t.Helper()
+	var oauthErr *OAuthError
+	if assert.True(t, errors.As(err, &oauthErr), "ожидалась ошибка OAuth, получено %v", err) {
+		assert.Equal(t, code, oauthErr.Code)
+	}
+}
+
+// publicKeyFromJWKS восстанавливает открытый ключ из JWKS, как это делает клиент.
+func publicKeyFromJWKS(t *testing.T, set *domain.JSONWebKeySet, kid string) *rsa.PublicKey {
+	for _, key := range set.Keys {
+		if key.KeyID != kid {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(key.N)
+		assert.NoError(t, err)
+		e, err := base64.RawURLEncoding.DecodeString(key.E)
+		assert.NoError(t, err)
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	t.Fatalf("ключ %s не опубликован", kid)
+	return nil
+}
+
+func TestOIDCAuthorizationCodeFlow(t *testing.T) {
+	env := newOIDCTestEnv()
+
+	params := env.authorize(t, "tvc_web", "openid profile email offline_access")
+	assert.Equal(t, "xyz", params.Get("state"))
+	assert.Equal(t, testOIDCIssuer, params.Get("iss"))
+
+	tokens, err := env.exchange("tvc_web", testClientSecret, params.Get("code"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer", tokens.TokenType)
+	assert.Equal(t, int64(3600), tokens.ExpiresIn)
+	assert.NotEmpty(t, tokens.RefreshToken)
+
+	jwks, err := env.service.PublishedKeys(context.Background())
+	assert.NoError(t, err)
+	claims := jwt.MapClaims{}
+	parsed, err := jwt.ParseWithClaims(tokens.IDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		return publicKeyFromJWKS(t, jwks, token.Header["kid"].(string)), nil
+	}, jwt.WithValidMethods([]string{"RS256"}), jwt.WithTimeFunc(func() time.Time { return env.now }))
+	assert.NoError(t, err)
+	assert.True(t, parsed.Valid)
+	assert.Equal(t, testOIDCIssuer, claims["iss"])
+	assert.Equal(t, "7", claims["sub"])
+	assert.Equal(t, "tvc_web", claims["aud"])
+	assert.Equal(t, "n-0S6", claims["nonce"])
+	assert.Equal(t, "ivan@example.com", claims["email"])
+	assert.Equal(t, true, claims["email_verified"])
+	assert.Equal(t, "Иван", claims["name"])
+
+	info, err := env.service.UserInfo(context.Background(), tokens.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "7", info.Subject)
+	assert.Equal(t, "ivan@example.com", info.Email)
+}
+
+func TestOIDCUserInfo_LimitedByScope(t *testing.T) {
+	env := newOIDCTestEnv()
+
+	params := env.authorize(t, "tvc_spa", "openid")
+	tokens, err := env.exchange("tvc_spa", "", params.Get("code"))
+	assert.NoError(t, err)
+	assert.Empty(t, tokens.RefreshToken)
+
+	info, err := env.service.UserInfo(context.Background(), tokens.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.UserInfo{Subject: "7"}, info)
+}
+
+func TestOIDCAuthorize_InvalidRedirectURI(t *testing.T) {
+	env := newOIDCTestEnv()
+	request := testAuthorizeRequest("tvc_web", "openid")
+	request.RedirectURI = "https://evil.example.com/callback"
+
+	_, err := env.service.Authorize(context.Background(), 7, request)
+
+	assertOAuthError(t, err, OAuthInvalidRequest)
+}
+
+func TestOIDCAuthorize_ErrorsReturnedToClient(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*domain.AuthorizeRequest)
+		code   string
+	}{
+		{"без PKCE", func(r *domain.AuthorizeRequest) { r.CodeChallenge = "" }, OAuthInvalidRequest},
+		{"PKCE plain", func(r *domain.AuthorizeRequest) { r.CodeChallengeMethod = "plain" }, OAuthInvalidRequest},
+		{"без openid", func(r *domain.AuthorizeRequest) { r.Scope = "profile" }, OAuthInvalidScope},
+		{"неизвестный scope", func(r *domain.AuthorizeRequest) { r.Scope = "openid admin" }, OAuthInvalidScope},
+		{"implicit flow", func(r *domain.AuthorizeRequest) { r.ResponseType = "token" }, OAuthUnsupportedResponseType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newOIDCTestEnv()
+			request := testAuthorizeRequest("tvc_web", "openid")
+			tt.modify(&request)
+
+			location, err := env.service.Authorize(context.Background(), 7, request)
+
+			assert.NoError(t, err)
+			parsed, _ := url.Parse(location)
+			assert.Equal(t, tt.code, parsed.Query().Get("error"))
+			assert.Equal(t, "xyz", parsed.Query().Get("state"))
+			assert.Empty(t, parsed.Query().Get("code"))
+			assert.Empty(t, env.repo.codes)
+		})
+	}
+}
+
+func TestOIDCLoginRedirect(t *testing.T) {
+	env := newOIDCTestEnv()
+
+	location, err := env.service.LoginRedirect(context.Background(), testAuthorizeRequest("tvc_web", "openid"))
+
+	assert.NoError(t, err)
+	parsed, _ := url.Parse(location)
+	assert.Equal(t, "login.example.com", parsed.Host)
+	assert.Equal(t, "tvc_web", parsed.Query().Get("client_id"))
+	assert.Equal(t, testRedirectURI, parsed.Query().Get("redirect_uri"))
+}
+
+func TestOIDCToken_InvalidClientCredentials(t *testing.T) {
+	env := newOIDCTestEnv()
+	params := env.authorize(t, "tvc_web", "openid")
+
+	_, err := env.exchange("tvc_web", "tvs_wrong", params.Get("code"))
+	assertOAuthError(t, err, OAuthInvalidClient)
+
+	_, err = env.exchange("tvc_web", "", params.Get("code"))
+	assertOAuthError(t, err, OAuthInvalidClient)
+}
+
+func TestOIDCToken_WrongCodeVerifier(t *testing.T) {
+	env := newOIDCTestEnv()
+	params := env.authorize(t, "tvc_spa", "openid")
+
+	_, err := env.service.Token(context.Background(), domain.TokenRequest{
+		GrantType:    "authorization_code",
+		Code:         params.Get("code"),
+		RedirectURI:  testRedirectURI,
+		CodeVerifier: "wrong-verifier-wrong-verifier-wrong-verifier-1",
+		ClientID:     "tvc_spa",
+	})
+
+	assertOAuthError(t, err, OAuthInvalidGrant)
+}
+
+func TestOIDCToken_ExpiredCode(t *testing.T) {
+	env := newOIDCTestEnv()
+	params := env.authorize(t, "tvc_spa", "openid")
+	env.now = env.now.Add(2 * time.Minute)
+
+	_, err := env.exchange("tvc_spa", "", params.Get("code"))
+
+	assertOAuthError(t, err, OAuthInvalidGrant)
+}
+
+func TestOIDCToken_CodeReuseRevokesIssuedTokens(t *testing.T) {
+	env := newOIDCTestEnv()
+	params := env.authorize(t, "tvc_web", "openid offline_access")
+	tokens, err := env.exchange("tvc_web", testClientSecret, params.Get("code"))
+	assert.NoError(t, err)
+
+	_, err = env.exchange("tvc_web", testClientSecret, params.Get("code"))
+
+	assertOAuthError(t, err, OAuthInvalidGrant)
+	_, err = env.service.UserInfo(context.Background(), tokens.AccessToken)
+	assertOAuthError(t, err, OAuthInvalidToken)
+	_, err = env.service.Token(context.Background(), domain.TokenRequest{
+		GrantType: "refresh_token", RefreshToken: tokens.RefreshToken, ClientID: "tvc_web", ClientSecret: testClientSecret,
+	})
+	assertOAuthError(t, err, OAuthInvalidGrant)
+	assert.Equal(t, domain.AuditActionOAuthCodeReuseDetected, env.audit.records[len(env.audit.records)-1].Action)
+}
+
+func TestOIDCRefresh_RotatesToken(t *testing.T) {
+	env := newOIDCTestEnv()
+	params := env.authorize(t, "tvc_spa", "openid offline_access")
+	first, err := env.exchange("tvc_spa", "", params.Get("code"))
+	assert.NoError(t, err)
+	refresh := func(token string) (*domain.OAuthTokenResponse, error) {
+		return env.service.Token(context.Background(), domain.TokenRequest{GrantType: "refresh_token", RefreshToken: token, ClientID: "tvc_spa"})
+	}
+
+	second, err := refresh(first.RefreshToken)
+	assert.NoError(t, err)
+	assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+	assert.NotEmpty(t, second.IDToken)
+
+	// Повторное использование старого refresh-токена отзывает и новый.
+	_, err = refresh(first.RefreshToken)
+	assertOAuthError(t, err, OAuthInvalidGrant)
+	_, err = refresh(second.RefreshToken)
+	assertOAuthError(t, err, OAuthInvalidGrant)
+}
+
+func TestOIDCRefresh_ConcurrentExchange(t *testing.T) {
+	env := newOIDCTestEnv()
+	params := env.authorize(t, "tvc_spa", "openid offline_access")
+	first, err := env.exchange("tvc_spa", "", params.Get("code"))
+	assert.NoError(t, err)
+
+	// Из параллельных обменов одного refresh-токена успешен только один, остальные считаются повторными.
+	const exchanges = 8
+	var wg sync.WaitGroup
+	results := make(chan error, exchanges)
+	for range exchanges {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := env.service.Token(context.Background(), domain.TokenRequest{GrantType: "refresh_token", RefreshToken: first.RefreshToken, ClientID: "tvc_spa"})
+			results <- err
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	succeeded := 0
+	for err := range results {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		assertOAuthError(t, err, OAuthInvalidGrant)
+	}
+	assert.Equal(t, 1, succeeded)
+}
+
+func TestOIDCToken_UnsupportedGrantType(t *testing.T) {
+	env := newOIDCTestEnv()
+
+	_, err := env.service.Token(context.Background(), domain.TokenRequest{GrantType: "password", ClientID: "tvc_spa"})
+
+	assertOAuthError(t, err, OAuthUnsupportedGrantType)
+}
+
+func TestOIDCIntrospectAndRevoke(t *testing.T) {
+	env := newOIDCTestEnv()
+	params := env.authorize(t, "tvc_web", "openid offline_access")
+	tokens, err := env.exchange("tvc_web", testClientSecret, params.Get("code"))
+	assert.NoError(t, err)
+
+	introspection, err := env.service.Introspect(context.Background(), "tvc_web", testClientSecret, tokens.AccessToken)
+	assert.NoError(t, err)
+	assert.True(t, introspection.Active)
+	assert.Equal(t, "7", introspection.Subject)
+	assert.Equal(t, "tvc_web", introspection.ClientID)
+
+	_, err = env.service.Introspect(context.Background(), "tvc_spa", "", tokens.AccessToken)
+	assertOAuthError(t, err, OAuthInvalidClient)
+
+	// Отзыв refresh-токена отзывает и access-токен того же кода.
+	assert.NoError(t, env.service.Revoke(context.Background(), "tvc_web", testClientSecret, tokens.RefreshToken))
+	introspection, err = env.service.Introspect(context.Background(), "tvc_web", testClientSecret, tokens.AccessToken)
+	assert.NoError(t, err)
+	assert.False(t, introspection.Active)
+
+	assert.NoError(t, env.service.Revoke(context.Background(), "tvc_web", testClientSecret, "tvo_unknown"))
+}
+
+func TestOIDCRevoke_IgnoresOtherClientTokens(t *testing.T) {
+	env := newOIDCTestEnv()
+	params := env.authorize(t, "tvc_web", "openid")
+	tokens, err := env.exchange("tvc_web", testClientSecret, params.Get("code"))
+	assert.NoError(t, err)
+
+	assert.NoError(t, env.service.Revoke(context.Background(), "tvc_spa", "", tokens.AccessToken))
+
+	_, err = env.service.UserInfo(context.Background(), tokens.AccessToken)
+	assert.NoError(t, err)
+}
+
+func TestOIDCKeys_RotationAndRetention(t *testing.T) {
+	env := newOIDCTestEnv()
+
+	firstKID, _, err := env.keys.ActiveKey(context.Background())
+	assert.NoError(t, err)
+	sameKID, _, err := env.keys.ActiveKey(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, firstKID, sameKID)
+
+	env.now = env.now.Add(31 * 24 * time.Hour)
+	secondKID, _, err := env.keys.ActiveKey(context.Background())
+	assert.NoError(t, err)
+	assert.NotEqual(t, firstKID, secondKID)
+
+	set, err := env.keys.PublishedKeys(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, set.Keys, 2)
+	assert.Equal(t, secondKID, set.Keys[0].KeyID)
+
+	env.now = env.now.Add(25 * time.Hour)
+	set, err = env.keys.PublishedKeys(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, set.Keys, 1)
+	assert.Equal(t, secondKID, set.Keys[0].KeyID)
+}
+
+func TestOIDCKeys_ManualRotation(t *testing.T) {
+	env := newOIDCTestEnv()
+	firstKID, _, err := env.keys.ActiveKey(context.Background())
+	assert.NoError(t, err)
+	env.now = env.now.Add(time.Second)
+
+	rotated, err := env.keys.RotateSigningKey(context.Background())
+
+	assert.NoError(t, err)
+	activeKID, _, _ := env.keys.ActiveKey(context.Background())
+	assert.Equal(t, rotated.KeyID, activeKID)
+	assert.NotEqual(t, firstKID, activeKID)
+	assert.Equal(t, domain.AuditActionOIDCSigningKeyRotated, env.audit.records[len(env.audit.records)-1].Action)
+}
+
+func TestOIDCKeys_Encrypted(t *testing.T) {
+	env := newOIDCTestEnv()
+	plainKID, _, err := env.keys.ActiveKey(context.Background())
+	assert.NoError(t, err)
+	env.now = env.now.Add(time.Second)
+
+	box, err := auth.NewSecretBox(strings.Repeat("k", auth.SecretBoxMinSecretLength))
+	assert.NoError(t, err)
+	keys := NewOIDCKeyService(env.repo, env.audit, fakeTransactor{}, box, 30*24*time.Hour, 24*time.Hour)
+	keys.now = env.keys.now
+
+	// Ключ, сохраненный без шифрования, заменяется зашифрованным, но еще публикуется в JWKS.
+	sealedKID, _, err := keys.ActiveKey(context.Background())
+	assert.NoError(t, err)
+	assert.NotEqual(t, plainKID, sealedKID)
+	assert.True(t, auth.IsSealed(env.repo.keys[1].PrivateKey))
+	assert.NotContains(t, env.repo.keys[1].PrivateKey, "PRIVATE KEY")
+
+	// Другой экземпляр с тем же секретом расшифровывает ключ из базы.
+	restarted := NewOIDCKeyService(env.repo, env.audit, fakeTransactor{}, box, 30*24*time.Hour, 24*time.Hour)
+	restarted.now = env.keys.now
+	set, err := restarted.PublishedKeys(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, set.Keys, 2)
+	assert.Equal(t, sealedKID, set.Keys[0].KeyID)
+
+	// Без секрета зашифрованный ключ не читается.
+	unconfigured := NewOIDCKeyService(env.repo, env.audit, fakeTransactor{}, nil, 30*24*time.Hour, 24*time.Hour)
+	unconfigured.now = env.keys.now
+	_, _, err = unconfigured.ActiveKey(context.Background())
+	assert.ErrorContains(t, err, "OIDC_KEY_ENCRYPTION_SECRET")
+}
+
+func TestCreateOAuthClient(t *testing.T) {
+	repo := newMemoryOIDCRepository(time.Now)
+	audit := new(recordingAuditRepository)
+	service := NewOAuthClientService(repo, audit, fakeTransactor{})
+
+	registered, err := service.CreateOAuthClient(context.Background(), "  Web  ", []string{testRedirectURI}, true)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Web", registered.Client.Name)
+	assert.True(t, registered.Client.Confidential)
+	assert.NotEmpty(t, registered.Secret)
+	stored, _ := repo.GetOAuthClient(context.Background(), registered.Client.ID)
+	assert.Equal(t, hashClientSecret(registered.Secret), stored.SecretHash)
+	assert.Equal(t, domain.AuditActionOAuthClientCreated, audit.records[0].Action)
+}
+
+func TestCreateOAuthClient_InvalidRedirectURI(t *testing.T) {
+	service := NewOAuthClientService(newMemoryOIDCRepository(time.Now), new(recordingAuditRepository), fakeTransactor{})
+
+	for _, uri := range []string{"", "/callback", "https://app.example.com/cb#fragment", "javascript:alert(1)"} {
+		_, err := service.CreateOAuthClient(context.Background(), "Web", []string{uri}, false)
+		assert.ErrorIs(t, err, ErrInvalidOAuthClient, uri)
+	}
+}
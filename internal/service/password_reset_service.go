@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testovoe/internal/apperr"
+	"testovoe/internal/auth"
+	"testovoe/internal/domain"
+	"testovoe/internal/mail"
+	"testovoe/internal/repository"
+	"time"
+)
+
+var ErrInvalidResetToken = apperr.New(apperr.KindInvalid, "invalid_reset_token", "недействительная или просроченная ссылка сброса пароля")
+
+const (
+	passwordResetTokenPrefix = "tvp_"
+	passwordResetWindow      = time.Hour
+)
+
+type PasswordResetConfig struct {
+	// URL — адрес страницы сброса пароля, которой в параметре token передается токен.
+	URL string
+	TTL time.Duration
+	// PerHour ограничивает число писем для одной учетной записи за час.
+	PerHour int
+}
+
+// TaskRunner выполняет задачу вне запроса. Реализация — background.Pool.
+type TaskRunner interface {
+	Go(ctx context.Context, task func(ctx context.Context)) error
+}
+
+type PasswordResetServiceInterface interface {
+	RequestPasswordReset(ctx context.Context, email string) error
+	SendPasswordReset(ctx context.Context, userID int64) error
+	ConfirmPasswordReset(ctx context.Context, token, password string) error
+}
+
+type PasswordResetService struct {
+	users       repository.UserRepositoryInterface
+	credentials repository.CredentialRepositoryInterface
+	resets      repository.PasswordResetRepositoryInterface
+	sessions    repository.SessionRepositoryInterface
+	audit       repository.AuditRepositoryInterface
+	tx          repository.TransactorInterface
+	hasher      *auth.PasswordHasher
+	mailer      mail.Mailer
+	tasks       TaskRunner
+	cfg         PasswordResetConfig
+	now         func() time.Time
+}
+
+func NewPasswordResetService(users repository.UserRepositoryInterface, credentials repository.CredentialRepositoryInterface, resets repository.PasswordResetRepositoryInterface, sessions repository.SessionRepositoryInterface, audit repository.AuditRepositoryInterface, tx repository.TransactorInterface, hasher *auth.PasswordHasher, mailer mail.Mailer, tasks TaskRunner, cfg PasswordResetConfig) *PasswordResetService {
+	return &PasswordResetService{
+		users: users, credentials: credentials, resets: resets, sessions: sessions, audit: audit, tx: tx,
+		hasher: hasher, mailer: mailer, tasks: tasks, cfg: cfg, now: time.Now,
+	}
+}
+
+// RequestPasswordReset отправляет письмо со ссылкой сброса пароля. Поиск пользователя, выпуск токена
+// и отправка выполняются в фоне, поэтому ни результат, ни время ответа не зависят от того,
+// зарегистрирован ли адрес и не превышен ли лимит писем. Без tasks запрос обрабатывается сразу.
+func (s *PasswordResetService) RequestPasswordReset(ctx context.Context, email string) error {
+	email = strings.TrimSpace(email)
+	if s.tasks == nil {
+		return s.requestPasswordReset(ctx, email)
+	}
+	return s.tasks.Go(ctx, func(ctx context.Context) {
+		if err := s.requestPasswordReset(ctx, email); err != nil {
+			slog.ErrorContext(ctx, "ошибка при обработке запроса сброса пароля", "error", err)
+		}
+	})
+}
+
+func (s *PasswordResetService) requestPasswordReset(ctx context.Context, email string) error {
+	user, _, err := s.credentials.GetCredentialsByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil
+		}
+		return err
+	}
+	return s.issue(ctx, user)
+}
+
+// SendPasswordReset отправляет письмо сброса пароля по запросу сотрудника поддержки.
+func (s *PasswordResetService) SendPasswordReset(ctx context.Context, userID int64) error {
+	user, err := s.users.GetUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	return s.issue(ctx, user)
+}
+
+// ConfirmPasswordReset устанавливает новый пароль по токену из письма. Токен погашается,
+// остальные токены пользователя перестают действовать, все его сессии отзываются.
+func (s *PasswordResetService) ConfirmPasswordReset(ctx context.Context, token, password string) error {
+	if !strings.HasPrefix(token, passwordResetTokenPrefix) {
+		return ErrInvalidResetToken
+	}
+	if err := validatePassword(password); err != nil {
+		return err
+	}
+	hash, err := s.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		claimed, err := s.resets.ClaimPasswordResetToken(ctx, hashSessionToken(token))
+		if err != nil {
+			if errors.Is(err, repository.ErrPasswordResetTokenNotFound) {
+				return ErrInvalidResetToken
+			}
+			return err
+		}
+		if err := s.credentials.SetPasswordHash(ctx, claimed.UserID, hash); err != nil {
+			if errors.Is(err, repository.ErrUserNotFound) {
+				return ErrInvalidResetToken
+			}
+			return err
+		}
+		if err := s.resets.InvalidatePasswordResetTokens(ctx, claimed.UserID); err != nil {
+			return err
+		}
+		if err := revokeUserSessions(ctx, s.sessions, s.audit, claimed.UserID, domain.SessionRevokedPasswordReset, 0); err != nil {
+			return err
+		}
+		record, err := newAuditRecord(ctx, domain.AuditActionUserPasswordReset, domain.AuditEntityUser, claimed.UserID, nil, nil)
+		if err != nil {
+			return err
+		}
+		return s.audit.CreateAuditRecord(ctx, record)
+	})
+}
+
+// issue выпускает токен и отправляет письмо, если лимит писем для учетной записи не исчерпан.
+// При превышении лимита письмо молча не отправляется. Строка пользователя блокируется до подсчета,
+// поэтому параллельные запросы не могут вместе превысить лимит.
+func (s *PasswordResetService) issue(ctx context.Context, user *domain.User) error {
+	token, hash, err := generatePasswordResetToken()
+	if err != nil {
+		return err
+	}
+	now := s.now()
+
+	sent := false
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.resets.LockPasswordResets(ctx, user.ID); err != nil {
+			return err
+		}
+		count, err := s.resets.CountPasswordResetTokensSince(ctx, user.ID, now.Add(-passwordResetWindow))
+		if err != nil {
+			return err
+		}
+		if count >= s.cfg.PerHour {
+			return nil
+		}
+
+		reset := &domain.PasswordResetToken{UserID: user.ID, TokenHash: hash, ExpiresAt: now.Add(s.cfg.TTL)}
+		if err := s.resets.CreatePasswordResetToken(ctx, reset); err != nil {
+			return err
+		}
+		record, err := newAuditRecord(ctx, domain.AuditActionUserPasswordResetRequested, domain.AuditEntityUser, user.ID, nil, nil)
+		if err != nil {
+			return err
+		}
+		if err := s.audit.CreateAuditRecord(ctx, record); err != nil {
+			return err
+		}
+		sent = true
+		return nil
+	})
+	if err != nil || !sent {
+		return err
+	}
+	return s.sendMail(ctx, user, token)
+}
+
+func (s *PasswordResetService) sendMail(ctx context.Context, user *domain.User, token string) error {
+	body := fmt.Sprintf("Здравствуйте, %s!\n\nЧтобы задать новый пароль, ", user.Name)
+	if s.cfg.URL != "" {
+		link, err := linkWithToken(s.cfg.URL, token)
+		if err != nil {
+			return err
+		}
+		body += "перейдите по ссылке:\n" + link + "\n"
+	} else {
+		body += "отправьте этот код в POST /auth/password-reset/confirm:\n" + token + "\n"
+	}
+	body += fmt.Sprintf("\nСсылка действует %s и может быть использована один раз. "+
+		"После смены пароля будет выполнен выход на всех устройствах.\n"+
+		"Если вы не запрашивали сброс пароля, просто проигнорируйте это письмо.\n", s.cfg.TTL)
+
+	return s.mailer.Send(ctx, mail.Message{To: user.Email, Subject: "Сброс пароля", Body: body})
+}
+
+// generatePasswordResetToken возвращает токен вида tvp_<секрет> и его SHA-256 хеш.
+func generatePasswordResetToken() (string, []byte, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, err
+	}
+	token := passwordResetTokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	return token, hashSessionToken(token), nil
+}
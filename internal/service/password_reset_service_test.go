@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/url"
+	"strings"
+	"testing"
+	"testovoe/internal/auth"
+	"testovoe/internal/background"
+	"testovoe/internal/domain"
+	"testovoe/internal/mail"
+	"testovoe/internal/repository"
+	"time"
+)
+
+// memoryPasswordResetRepository хранит токены сброса в памяти, повторяя проверки срока и повторного использования.
+type memoryPasswordResetRepository struct {
+	tokens []*domain.PasswordResetToken
+}
+
+func (r *memoryPasswordResetRepository) CreatePasswordResetToken(ctx context.Context, token *domain.PasswordResetToken) error {
+	token.ID = int64(len(r.tokens) + 1)
+	token.CreatedAt = time.Now()
+	stored := *token
+	r.tokens = append(r.tokens, &stored)
+	return nil
+}
+
+func (r *memoryPasswordResetRepository) LockPasswordResets(ctx context.Context, userID int64) error {
+	return nil
+}
+
+func (r *memoryPasswordResetRepository) CountPasswordResetTokensSince(ctx context.Context, userID int64, since time.Time) (int, error) {
+	count := 0
+	for _, token := range r.tokens {
+		if token.UserID == userID && !token.CreatedAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *memoryPasswordResetRepository) ClaimPasswordResetToken(ctx context.Context, hash []byte) (*domain.PasswordResetToken, error) {
+	for _, token := range r.tokens {
+		if string(token.TokenHash) == string(hash) && token.UsedAt == nil && token.ExpiresAt.After(time.Now()) {
+			now := time.Now()
+			token.UsedAt = &now
+			claimed := *token
+			return &claimed, nil
+		}
+	}
+	return nil, repository.ErrPasswordResetTokenNotFound
+}
+
+func (r *memoryPasswordResetRepository) InvalidatePasswordResetTokens(ctx context.Context, userID int64) error {
+	now := time.Now()
+	for _, token := range r.tokens {
+		if token.UserID == userID && token.UsedAt == nil {
+			token.UsedAt = &now
+		}
+	}
+	return nil
+}
+
+type passwordResetFixture struct {
+	service     *PasswordResetService
+	users       *MockUserRepository
+	credentials *MockCredentialRepository
+	resets      *memoryPasswordResetRepository
+	sessions    *memorySessionRepository
+	mailer      *mail.CaptureMailer
+	audit       *recordingAuditRepository
+}
+
+func newTestPasswordReset() *passwordResetFixture {
+	f := &passwordResetFixture{
+		users:       new(MockUserRepository),
+		credentials: new(MockCredentialRepository),
+		resets:      new(memoryPasswordResetRepository),
+		sessions:    newMemorySessionRepository(),
+		mailer:      mail.NewCaptureMailer(),
+		audit:       new(recordingAuditRepository),
+	}
+	f.service = NewPasswordResetService(f.users, f.credentials, f.resets, f.sessions, f.audit, fakeTransactor{},
+		auth.NewPasswordHasher(testArgon2Params), f.mailer, nil, PasswordResetConfig{
+			URL:     "https://app.example.com/reset",
+			TTL:     time.Hour,
+			PerHour: 2,
+		})
+	return f
+}
+
+// resetTokenFromMessage достает токен из ссылки в письме сброса пароля.
+func resetTokenFromMessage(t *testing.T, message mail.Message) string {
+	for _, line := range strings.Split(message.Body, "\n") {
+		if strings.HasPrefix(line, "https://") {
+			link, err := url.Parse(line)
+			assert.NoError(t, err)
+			return link.Query().Get("token")
+		}
+	}
+	t.Fatal("в письме нет ссылки сброса пароля")
+	return ""
+}
+
+func TestRequestPasswordReset_UnknownEmail(t *testing.T) {
+	f := newTestPasswordReset()
+	f.credentials.On("GetCredentialsByEmail", mock.Anything, "ghost@example.com").Return((*domain.User)(nil), "", repository.ErrUserNotFound)
+
+	assert.NoError(t, f.service.RequestPasswordReset(context.Background(), "ghost@example.com"))
+	assert.Empty(t, f.mailer.Messages())
+	assert.Empty(t, f.audit.records)
+}
+
+func TestRequestPasswordReset_Throttled(t *testing.T) {
+	f := newTestPasswordReset()
+	user := &domain.User{ID: 7, Name: "Иван", Email: "ivan@example.com"}
+	f.credentials.On("GetCredentialsByEmail", mock.Anything, "ivan@example.com").Return(user, "", nil)
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, f.service.RequestPasswordReset(context.Background(), "ivan@example.com"))
+	}
+	assert.Len(t, f.mailer.Messages(), 2)
+	assert.Len(t, f.resets.tokens, 2)
+}
+
+func TestRequestPasswordReset_Background(t *testing.T) {
+	f := newTestPasswordReset()
+	tasks := background.NewPool(10, 1)
+	f.service.tasks = tasks
+	user := &domain.User{ID: 7, Name: "Иван", Email: "ivan@example.com"}
+	f.credentials.On("GetCredentialsByEmail", mock.Anything, "ivan@example.com").Return(user, "", nil)
+	f.credentials.On("GetCredentialsByEmail", mock.Anything, "ghost@example.com").Return((*domain.User)(nil), "", repository.ErrUserNotFound)
+
+	// Отмена запроса не прерывает обработку в фоне.
+	ctx, cancel := context.WithCancel(context.Background())
+	assert.NoError(t, f.service.RequestPasswordReset(ctx, " ivan@example.com "))
+	assert.NoError(t, f.service.RequestPasswordReset(ctx, "ghost@example.com"))
+	cancel()
+
+	assert.NoError(t, tasks.Close(context.Background()))
+	assert.Len(t, f.mailer.Messages(), 1)
+	f.credentials.AssertExpectations(t)
+}
+
+func TestConfirmPasswordReset(t *testing.T) {
+	f := newTestPasswordReset()
+	user := &domain.User{ID: 7, Name: "Иван", Email: "ivan@example.com"}
+	f.credentials.On("GetCredentialsByEmail", mock.Anything, "ivan@example.com").Return(user, "", nil)
+	f.credentials.On("SetPasswordHash", mock.Anything, int64(7), mock.Anything).Return(nil).Once()
+
+	assert.NoError(t, f.sessions.CreateSession(context.Background(), &domain.Session{UserID: 7}))
+	assert.NoError(t, f.service.RequestPasswordReset(context.Background(), "ivan@example.com"))
+	assert.NoError(t, f.service.RequestPasswordReset(context.Background(), "ivan@example.com"))
+	messages := f.mailer.Messages()
+	first, second := resetTokenFromMessage(t, messages[0]), resetTokenFromMessage(t, messages[1])
+
+	assert.NoError(t, f.service.ConfirmPasswordReset(context.Background(), second, "new-secret-password"))
+
+	active, _ := f.sessions.ListActiveSessions(context.Background(), 7)
+	assert.Empty(t, active)
+	assert.Equal(t, domain.AuditActionUserPasswordReset, f.audit.records[len(f.audit.records)-1].Action)
+
+	// Токен одноразовый, а остальные выпущенные токены после сброса недействительны.
+	assert.ErrorIs(t, f.service.ConfirmPasswordReset(context.Background(), second, "another-password"), ErrInvalidResetToken)
+	assert.ErrorIs(t, f.service.ConfirmPasswordReset(context.Background(), first, "another-password"), ErrInvalidResetToken)
+	f.credentials.AssertExpectations(t)
+}
+
+func TestConfirmPasswordReset_Expired(t *testing.T) {
+	f := newTestPasswordReset()
+	token, hash, err := generatePasswordResetToken()
+	assert.NoError(t, err)
+	assert.NoError(t, f.resets.CreatePasswordResetToken(context.Background(), &domain.PasswordResetToken{
+		UserID: 7, TokenHash: hash, ExpiresAt: time.Now().Add(-time.Minute),
+	}))
+
+	assert.ErrorIs(t, f.service.ConfirmPasswordReset(context.Background(), token, "new-secret-password"), ErrInvalidResetToken)
+}
+
+func TestConfirmPasswordReset_WeakPassword(t *testing.T) {
+	f := newTestPasswordReset()
+	token, hash, err := generatePasswordResetToken()
+	assert.NoError(t, err)
+	assert.NoError(t, f.resets.CreatePasswordResetToken(context.Background(), &domain.PasswordResetToken{
+		UserID: 7, TokenHash: hash, ExpiresAt: time.Now().Add(time.Hour),
+	}))
+
+	assert.ErrorIs(t, f.service.ConfirmPasswordReset(context.Background(), token, "short"), ErrWeakPassword)
+	assert.Nil(t, f.resets.tokens[0].UsedAt)
+}
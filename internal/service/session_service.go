@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"testovoe/internal/apperr"
+	"testovoe/internal/auth"
+	"testovoe/internal/domain"
+	"testovoe/internal/repository"
+	"unicode/utf8"
+)
+
+var ErrSessionNotFound = apperr.New(apperr.KindNotFound, "session_not_found", "сессия не найдена")
+
+const (
+	sessionTokenPrefix    = "tvr_"
+	maxSessionUserAgent   = 512
+	maxSessionIPAddrChars = 45
+)
+
+type SessionServiceInterface interface {
+	ListSessions(ctx context.Context, userID int64) ([]domain.Session, error)
+	RevokeSession(ctx context.Context, userID, id int64) error
+	RevokeAllSessions(ctx context.Context, userID int64) error
+}
+
+type SessionService struct {
+	sessions repository.SessionRepositoryInterface
+	users    repository.UserRepositoryInterface
+	audit    repository.AuditRepositoryInterface
+	tx       repository.TransactorInterface
+}
+
+func NewSessionService(sessions repository.SessionRepositoryInterface, users repository.UserRepositoryInterface, audit repository.AuditRepositoryInterface, tx repository.TransactorInterface) *SessionService {
+	return &SessionService{sessions: sessions, users: users, audit: audit, tx: tx}
+}
+
+// ListSessions возвращает действующие сессии пользователя; сессия, от имени которой выполняется
+// запрос, помечается полем current.
+func (s *SessionService) ListSessions(ctx context.Context, userID int64) ([]domain.Session, error) {
+	if err := s.requireUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	sessions, err := s.sessions.ListActiveSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if principal, ok := auth.PrincipalFromContext(ctx); ok && principal.SessionID != 0 {
+		for i := range sessions {
+			sessions[i].Current = sessions[i].ID == principal.SessionID
+		}
+	}
+	return sessions, nil
+}
+
+func (s *SessionService) RevokeSession(ctx context.Context, userID, id int64) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := s.sessions.GetSession(ctx, userID, id)
+		if err != nil {
+			return mapSessionError(err)
+		}
+		if before.RevokedAt != nil {
+			return ErrSessionNotFound
+		}
+		return revokeSession(ctx, s.sessions, s.audit, before, domain.SessionRevokedByUser, domain.AuditActionSessionRevoked)
+	})
+}
+
+func (s *SessionService) RevokeAllSessions(ctx context.Context, userID int64) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.requireUser(ctx, userID); err != nil {
+			return err
+		}
+		return revokeUserSessions(ctx, s.sessions, s.audit, userID, domain.SessionRevokedByUser, 0)
+	})
+}
+
+// SessionActive реализует auth.SessionValidator.
+func (s *SessionService) SessionActive(ctx context.Context, id int64) (bool, error) {
+	return s.sessions.IsSessionActive(ctx, id)
+}
+
+func (s *SessionService) requireUser(ctx context.Context, userID int64) error {
+	if _, err := s.users.GetUserByID(ctx, userID); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// revokeUserSessions отзывает действующие сессии пользователя, кроме сессии keep (0 — отзываются все),
+// записывая отзыв каждой в журнал аудита.
+func revokeUserSessions(ctx context.Context, sessions repository.SessionRepositoryInterface, audit repository.AuditRepositoryInterface, userID int64, reason string, keep int64) error {
+	active, err := sessions.ListActiveSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for i := range active {
+		if active[i].ID == keep {
+			continue
+		}
+		if err := revokeSession(ctx, sessions, audit, &active[i], reason, domain.AuditActionSessionRevoked); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func revokeSession(ctx context.Context, sessions repository.SessionRepositoryInterface, audit repository.AuditRepositoryInterface, before *domain.Session, reason, action string) error {
+	after, err := sessions.RevokeSession(ctx, before.UserID, before.ID, reason)
+	if err != nil {
+		return mapSessionError(err)
+	}
+	record, err := newAuditRecord(ctx, action, domain.AuditEntitySession, after.ID, before, after)
+	if err != nil {
+		return err
+	}
+	return audit.CreateAuditRecord(ctx, record)
+}
+
+func mapSessionError(err error) error {
+	if errors.Is(err, repository.ErrSessionNotFound) {
+		return ErrSessionNotFound
+	}
+	return err
+}
+
+// generateSessionToken возвращает refresh-токен вида tvr_<секрет> и его SHA-256 хеш.
+func generateSessionToken() (string, []byte, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, err
+	}
+	token := sessionTokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	return token, hashSessionToken(token), nil
+}
+
+func hashSessionToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+// normalizeSessionMeta обрезает сведения о клиенте до размеров колонок таблицы sessions.
+func normalizeSessionMeta(meta domain.SessionMeta) domain.SessionMeta {
+	meta.UserAgent = truncateRunes(meta.UserAgent, maxSessionUserAgent)
+	meta.IP = truncateRunes(meta.IP, maxSessionIPAddrChars)
+	return meta
+}
+
+func truncateRunes(s string, limit int) string {
+	if utf8.RuneCountInString(s) <= limit {
+		return s
+	}
+	return string([]rune(s)[:limit])
+}
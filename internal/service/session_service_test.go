@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+	"testovoe/internal/auth"
+	"testovoe/internal/domain"
+	"testovoe/internal/repository"
+	"time"
+)
+
+// memorySessionRepository хранит сессии в памяти, чтобы проверять ротацию токенов целиком.
+type memorySessionRepository struct {
+	sessions map[int64]*domain.Session
+	tokens   map[string]*sessionTokenRow
+	nextID   int64
+}
+
+type sessionTokenRow struct {
+	sessionID int64
+	used      bool
+}
+
+func newMemorySessionRepository() *memorySessionRepository {
+	return &memorySessionRepository{sessions: map[int64]*domain.Session{}, tokens: map[string]*sessionTokenRow{}}
+}
+
+func (r *memorySessionRepository) CreateSession(ctx context.Context, session *domain.Session) error {
+	r.nextID++
+	session.ID = r.nextID
+	session.CreatedAt, session.LastUsedAt = time.Now(), time.Now()
+	stored := *session
+	r.sessions[session.ID] = &stored
+	return nil
+}
+
+func (r *memorySessionRepository) GetSession(ctx context.Context, userID, id int64) (*domain.Session, error) {
+	session, ok := r.sessions[id]
+	if !ok || session.UserID != userID {
+		return nil, repository.ErrSessionNotFound
+	}
+	copied := *session
+	return &copied, nil
+}
+
+func (r *memorySessionRepository) ListActiveSessions(ctx context.Context, userID int64) ([]domain.Session, error) {
+	sessions := make([]domain.Session, 0)
+	for id := int64(1); id <= r.nextID; id++ {
+		if session, ok := r.sessions[id]; ok && session.UserID == userID && session.RevokedAt == nil {
+			sessions = append(sessions, *session)
+		}
+	}
+	return sessions, nil
+}
+
+func (r *memorySessionRepository) IsSessionActive(ctx context.Context, id int64) (bool, error) {
+	session, ok := r.sessions[id]
+	return ok && session.RevokedAt == nil, nil
+}
+
+func (r *memorySessionRepository) TouchSession(ctx context.Context, id int64, meta domain.SessionMeta, expiresAt time.Time) error {
+	session := r.sessions[id]
+	session.UserAgent, session.IP, session.ExpiresAt = meta.UserAgent, meta.IP, expiresAt
+	return nil
+}
+
+func (r *memorySessionRepository) RevokeSession(ctx context.Context, userID, id int64, reason string) (*domain.Session, error) {
+	session, ok := r.sessions[id]
+	if !ok || session.UserID != userID || session.RevokedAt != nil {
+		return nil, repository.ErrSessionNotFound
+	}
+	now := time.Now()
+	session.RevokedAt, session.RevokeReason = &now, reason
+	copied := *session
+	return &copied, nil
+}
+
+func (r *memorySessionRepository) CreateSessionToken(ctx context.Context, sessionID int64, hash []byte) error {
+	r.tokens[hex.EncodeToString(hash)] = &sessionTokenRow{sessionID: sessionID}
+	return nil
+}
+
+func (r *memorySessionRepository) ClaimSessionToken(ctx context.Context, hash []byte) (*domain.Session, bool, error) {
+	token, ok := r.tokens[hex.EncodeToString(hash)]
+	if !ok {
+		return nil, false, repository.ErrSessionNotFound
+	}
+	used := token.used
+	token.used = true
+	copied := *r.sessions[token.sessionID]
+	return &copied, used, nil
+}
+
+func TestSessionService_ListMarksCurrent(t *testing.T) {
+	sessions := newMemorySessionRepository()
+	users := new(MockUserRepository)
+	service := NewSessionService(sessions, users, new(recordingAuditRepository), fakeTransactor{})
+
+	users.On("GetUserByID", mock.Anything, int64(7)).Return(&domain.User{ID: 7}, nil)
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, sessions.CreateSession(context.Background(), &domain.Session{UserID: 7, ExpiresAt: time.Now().Add(time.Hour)}))
+	}
+	assert.NoError(t, sessions.CreateSession(context.Background(), &domain.Session{UserID: 8, ExpiresAt: time.Now().Add(time.Hour)}))
+
+	principal := auth.NewPrincipal("7", auth.MethodJWT)
+	principal.SessionID = 2
+	list, err := service.ListSessions(auth.WithPrincipal(context.Background(), principal), 7)
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+	assert.False(t, list[0].Current)
+	assert.True(t, list[1].Current)
+}
+
+func TestSessionService_Revoke(t *testing.T) {
+	sessions := newMemorySessionRepository()
+	users := new(MockUserRepository)
+	audit := new(recordingAuditRepository)
+	service := NewSessionService(sessions, users, audit, fakeTransactor{})
+
+	users.On("GetUserByID", mock.Anything, int64(7)).Return(&domain.User{ID: 7}, nil)
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, sessions.CreateSession(context.Background(), &domain.Session{UserID: 7, ExpiresAt: time.Now().Add(time.Hour)}))
+	}
+
+	assert.NoError(t, service.RevokeSession(context.Background(), 7, 1))
+	assert.ErrorIs(t, service.RevokeSession(context.Background(), 7, 1), ErrSessionNotFound)
+	assert.ErrorIs(t, service.RevokeSession(context.Background(), 8, 2), ErrSessionNotFound)
+	assert.Equal(t, domain.SessionRevokedByUser, sessions.sessions[1].RevokeReason)
+
+	assert.NoError(t, service.RevokeAllSessions(context.Background(), 7))
+	active, _ := sessions.ListActiveSessions(context.Background(), 7)
+	assert.Empty(t, active)
+	assert.Len(t, audit.records, 3)
+	for _, record := range audit.records {
+		assert.Equal(t, domain.AuditActionSessionRevoked, record.Action)
+		assert.Equal(t, domain.AuditEntitySession, record.EntityType)
+	}
+}
@@ -0,0 +1,244 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testovoe/internal/apperr"
+	"testovoe/internal/auth"
+	"testovoe/internal/domain"
+	"testovoe/internal/repository"
+	"testovoe/internal/sso"
+	"time"
+)
+
+var ErrSSOProviderNotFound = apperr.New(apperr.KindNotFound, "sso_provider_not_found", "провайдер входа не найден")
+var ErrInvalidSSOState = apperr.New(apperr.KindInvalid, "invalid_sso_state", "вход через провайдера устарел или уже завершен")
+var ErrSSOLoginFailed = apperr.New(apperr.KindUnauthorized, "sso_login_failed", "провайдер не подтвердил вход")
+var ErrSSOEmailNotVerified = apperr.New(apperr.KindForbidden, "sso_email_not_verified", "провайдер не подтвердил email")
+var ErrSSOAccountNotFound = apperr.New(apperr.KindForbidden, "sso_account_not_found", "пользователь с таким email не найден")
+var ErrIdentityNotFound = apperr.New(apperr.KindNotFound, "identity_not_found", "внешняя учетная запись не найдена")
+
+type SSOServiceInterface interface {
+	ListSSOProviders() []domain.SSOProvider
+	StartSSOLogin(ctx context.Context, provider string) (*domain.SSOLogin, error)
+	CompleteSSOLogin(ctx context.Context, provider, code, state string, meta domain.SessionMeta) (*domain.TokenPair, error)
+	ListIdentities(ctx context.Context, userID int64) ([]domain.Identity, error)
+	UnlinkIdentity(ctx context.Context, userID, identityID int64) error
+}
+
+// SSOService выполняет вход через внешние провайдеры OpenID Connect. Учетная запись провайдера
+// при первом входе связывается с пользователем, у которого тот же email, если провайдер его подтвердил.
+// Новые пользователи при этом не создаются.
+type SSOService struct {
+	providers  map[string]*sso.Provider
+	order      []string
+	users      repository.UserRepositoryInterface
+	identities repository.IdentityRepositoryInterface
+	sessions   repository.SessionRepositoryInterface
+	audit      repository.AuditRepositoryInterface
+	tx         repository.TransactorInterface
+	tokens     *auth.TokenIssuer
+	mfa        MFAChallenger
+	limiter    LoginLimiter
+	stateTTL   time.Duration
+	now        func() time.Time
+}
+
+func NewSSOService(providers []*sso.Provider, users repository.UserRepositoryInterface, identities repository.IdentityRepositoryInterface, sessions repository.SessionRepositoryInterface, audit repository.AuditRepositoryInterface, tx repository.TransactorInterface, tokens *auth.TokenIssuer, mfa MFAChallenger, limiter LoginLimiter, stateTTL time.Duration) *SSOService {
+	s := &SSOService{
+		providers: map[string]*sso.Provider{}, users: users, identities: identities, sessions: sessions,
+		audit: audit, tx: tx, tokens: tokens, mfa: mfa, limiter: limiter, stateTTL: stateTTL, now: time.Now,
+	}
+	for _, provider := range providers {
+		s.providers[provider.Name()] = provider
+		s.order = append(s.order, provider.Name())
+	}
+	return s
+}
+
+func (s *SSOService) ListSSOProviders() []domain.SSOProvider {
+	providers := make([]domain.SSOProvider, 0, len(s.order))
+	for _, name := range s.order {
+		providers = append(providers, domain.SSOProvider{
+			Name:        name,
+			DisplayName: s.providers[name].DisplayName(),
+			LoginURL:    "/auth/sso/" + name,
+		})
+	}
+	return providers
+}
+
+// StartSSOLogin готовит вход: сохраняет state, nonce и PKCE verifier и возвращает адрес страницы входа провайдера.
+func (s *SSOService) StartSSOLogin(ctx context.Context, name string) (*domain.SSOLogin, error) {
+	provider, ok := s.providers[name]
+	if !ok {
+		return nil, ErrSSOProviderNotFound
+	}
+
+	state, err := randomToken("", 32)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomToken("", 32)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := randomToken("", 32)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+
+	url, err := provider.AuthCodeURL(ctx, state, nonce, base64.RawURLEncoding.EncodeToString(sum[:]))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSSOLoginFailed, err)
+	}
+	expiresAt := s.now().Add(s.stateTTL)
+	err = s.identities.CreateSSOState(ctx, &domain.SSOState{
+		StateHash:    hashSessionToken(state),
+		Provider:     name,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    expiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &domain.SSOLogin{URL: url, State: state, ExpiresAt: expiresAt}, nil
+}
+
+// CompleteSSOLogin обменивает код провайдера на ID-токен и открывает сессию связанного пользователя.
+// Как и при входе по паролю, блокировка входа возвращается в виде LoginLockedError,
+// а подключенный второй фактор — в виде MFAChallengeError.
+func (s *SSOService) CompleteSSOLogin(ctx context.Context, name, code, state string, meta domain.SessionMeta) (*domain.TokenPair, error) {
+	provider, ok := s.providers[name]
+	if !ok {
+		return nil, ErrSSOProviderNotFound
+	}
+	if code == "" || state == "" {
+		return nil, ErrInvalidSSOState
+	}
+
+	stored, err := s.identities.ClaimSSOState(ctx, hashSessionToken(state))
+	if err != nil {
+		if errors.Is(err, repository.ErrSSOStateNotFound) {
+			return nil, ErrInvalidSSOState
+		}
+		return nil, err
+	}
+	if stored.Provider != name || !stored.ExpiresAt.After(s.now()) {
+		return nil, ErrInvalidSSOState
+	}
+
+	claims, err := provider.Exchange(ctx, code, stored.CodeVerifier, stored.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSSOLoginFailed, err)
+	}
+
+	var userID int64
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		identity, err := s.identities.GetIdentity(ctx, name, claims.Subject)
+		if err == nil {
+			userID = identity.UserID
+			return s.identities.TouchIdentity(ctx, identity.ID, claims.Email)
+		}
+		if !errors.Is(err, repository.ErrIdentityNotFound) {
+			return err
+		}
+		userID, err = s.link(ctx, name, claims)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if s.limiter != nil {
+		user, err := s.users.GetUserByID(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.limiter.CheckLogin(ctx, user.Email, meta.IP); err != nil {
+			return nil, err
+		}
+	}
+	if s.mfa != nil {
+		if err := s.mfa.Challenge(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	var tokens *domain.TokenPair
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		tokens, err = openSession(ctx, s.sessions, s.tokens, userID, meta)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// link связывает учетную запись провайдера с пользователем по подтвержденному провайдером email.
+func (s *SSOService) link(ctx context.Context, provider string, claims *sso.Claims) (int64, error) {
+	if claims.Email == "" || !claims.EmailVerified {
+		return 0, ErrSSOEmailNotVerified
+	}
+	userID, err := s.identities.FindUserIDByEmail(ctx, claims.Email)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return 0, ErrSSOAccountNotFound
+		}
+		return 0, err
+	}
+
+	identity := &domain.Identity{UserID: userID, Provider: provider, Subject: claims.Subject, Email: claims.Email}
+	if err := s.identities.CreateIdentity(ctx, identity); err != nil {
+		if errors.Is(err, repository.ErrIdentityTaken) {
+			return 0, ErrSSOLoginFailed
+		}
+		return 0, err
+	}
+	if err := s.identities.TouchIdentity(ctx, identity.ID, claims.Email); err != nil {
+		return 0, err
+	}
+	record, err := newAuditRecord(ctx, domain.AuditActionUserIdentityLinked, domain.AuditEntityUser, userID, nil, identity)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.audit.CreateAuditRecord(ctx, record); err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+func (s *SSOService) ListIdentities(ctx context.Context, userID int64) ([]domain.Identity, error) {
+	if _, err := s.users.GetUserByID(ctx, userID); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return s.identities.ListIdentities(ctx, userID)
+}
+
+// UnlinkIdentity отвязывает учетную запись провайдера. При следующем входе через этого провайдера
+// она снова свяжется с пользователем по email, если провайдер его подтвердит.
+func (s *SSOService) UnlinkIdentity(ctx context.Context, userID, identityID int64) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		identity, err := s.identities.DeleteIdentity(ctx, userID, identityID)
+		if err != nil {
+			if errors.Is(err, repository.ErrIdentityNotFound) {
+				return ErrIdentityNotFound
+			}
+			return err
+		}
+		record, err := newAuditRecord(ctx, domain.AuditActionUserIdentityUnlinked, domain.AuditEntityUser, userID, identity, nil)
+		if err != nil {
+			return err
+		}
+		return s.audit.CreateAuditRecord(ctx, record)
+	})
+}
@@ -0,0 +1,278 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/url"
+	"strings"
+	"testing"
+	"testovoe/internal/domain"
+	"testovoe/internal/repository"
+	"testovoe/internal/sso"
+	"testovoe/internal/sso/ssotest"
+	"time"
+)
+
+// memoryIdentityRepository хранит внешние учетные записи и незавершенные входы в памяти.
+type memoryIdentityRepository struct {
+	identities map[int64]*domain.Identity
+	states     map[string]*domain.SSOState
+	emails     map[string]int64
+	nextID     int64
+}
+
+func newMemoryIdentityRepository() *memoryIdentityRepository {
+	return &memoryIdentityRepository{identities: map[int64]*domain.Identity{}, states: map[string]*domain.SSOState{}, emails: map[string]int64{}}
+}
+
+func (r *memoryIdentityRepository) GetIdentity(ctx context.Context, provider, subject string) (*domain.Identity, error) {
+	for _, identity := range r.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			copied := *identity
+			return &copied, nil
+		}
+	}
+	return nil, repository.ErrIdentityNotFound
+}
+
+func (r *memoryIdentityRepository) CreateIdentity(ctx context.Context, identity *domain.Identity) error {
+	if _, err := r.GetIdentity(ctx, identity.Provider, identity.Subject); err == nil {
+		return repository.ErrIdentityTaken
+	}
+	r.nextID++
+	identity.ID = r.nextID
+	identity.CreatedAt = time.Now()
+	copied := *identity
+	r.identities[identity.ID] = &copied
+	return nil
+}
+
+func (r *memoryIdentityRepository) ListIdentities(ctx context.Context, userID int64) ([]domain.Identity, error) {
+	identities := make([]domain.Identity, 0)
+	for id := int64(1); id <= r.nextID; id++ {
+		if identity, ok := r.identities[id]; ok && identity.UserID == userID {
+			identities = append(identities, *identity)
+		}
+	}
+	return identities, nil
+}
+
+func (r *memoryIdentityRepository) DeleteIdentity(ctx context.Context, userID, id int64) (*domain.Identity, error) {
+	identity, ok := r.identities[id]
+	if !ok || identity.UserID != userID {
+		return nil, repository.ErrIdentityNotFound
+	}
+	delete(r.identities, id)
+	return identity, nil
+}
+
+func (r *memoryIdentityRepository) TouchIdentity(ctx context.Context, id int64, email string) error {
+	now := time.Now()
+	r.identities[id].LastLoginAt = &now
+	r.identities[id].Email = email
+	return nil
+}
+
+func (r *memoryIdentityRepository) FindUserIDByEmail(ctx context.Context, email string) (int64, error) {
+	if id, ok := r.emails[strings.ToLower(email)]; ok {
+		return id, nil
+	}
+	return 0, repository.ErrUserNotFound
+}
+
+func (r *memoryIdentityRepository) CreateSSOState(ctx context.Context, state *domain.SSOState) error {
+	copied := *state
+	r.states[string(state.StateHash)] = &copied
+	return nil
+}
+
+func (r *memoryIdentityRepository) ClaimSSOState(ctx context.Context, hash []byte) (*domain.SSOState, error) {
+	state, ok := r.states[string(hash)]
+	if !ok {
+		return nil, repository.ErrSSOStateNotFound
+	}
+	delete(r.states, string(hash))
+	return state, nil
+}
+
+// stubMFAChallenger требует второй фактор у перечисленных пользователей.
+type stubMFAChallenger map[int64]bool
+
+func (s stubMFAChallenger) Challenge(ctx context.Context, userID int64) error {
+	if s[userID] {
+		return &MFAChallengeError{Token: "mfa-token"}
+	}
+	return nil
+}
+
+type ssoTestEnv struct {
+	service    *SSOService
+	fake       *ssotest.Provider
+	identities *memoryIdentityRepository
+	audit      *recordingAuditRepository
+}
+
+func newSSOTestEnv(t *testing.T, mfa MFAChallenger, limiter LoginLimiter) *ssoTestEnv {
+	fake := ssotest.NewProvider("testovoe", "secret")
+	t.Cleanup(fake.Close)
+	provider := sso.NewProvider(sso.ProviderConfig{
+		Name:         "corp",
+		DisplayName:  "Корпоративный вход",
+		Issuer:       fake.Issuer(),
+		ClientID:     fake.ClientID,
+		ClientSecret: fake.ClientSecret,
+		RedirectURL:  "https://api.example.com/auth/sso/corp/callback",
+	}, nil)
+
+	users := new(MockUserRepository)
+	users.On("GetUserByID", mock.Anything, int64(7)).Return(&domain.User{ID: 7, Email: "ivan@example.com"}, nil)
+	users.On("GetUserByID", mock.Anything, mock.Anything).Return((*domain.User)(nil), repository.ErrUserNotFound)
+
+	env := &ssoTestEnv{fake: fake, identities: newMemoryIdentityRepository(), audit: new(recordingAuditRepository)}
+	env.identities.emails["ivan@example.com"] = 7
+	env.service = NewSSOService([]*sso.Provider{provider}, users, env.identities, newMemorySessionRepository(), env.audit,
+		fakeTransactor{}, newTestTokenIssuer(), mfa, limiter, 10*time.Minute)
+	return env
+}
+
+// login проходит вход у провайдера и возвращает code и state из адреса возврата.
+func (env *ssoTestEnv) login(t *testing.T, user ssotest.User) (string, string) {
+	env.fake.SetUser(user)
+	start, err := env.service.StartSSOLogin(context.Background(), "corp")
+	assert.NoError(t, err)
+	params, err := env.fake.Login(start.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, start.State, params.Get("state"))
+	return params.Get("code"), params.Get("state")
+}
+
+func TestSSOLogin_LinksByVerifiedEmail(t *testing.T) {
+	env := newSSOTestEnv(t, nil, nil)
+
+	code, state := env.login(t, ssotest.User{Subject: "corp-1", Email: "Ivan@Example.com", EmailVerified: true})
+	tokens, err := env.service.CompleteSSOLogin(context.Background(), "corp", code, state, domain.SessionMeta{})
+
+	assert.NoError(t, err)
+	assert.NotEmpty(t, tokens.AccessToken)
+	identities, _ := env.identities.ListIdentities(context.Background(), 7)
+	assert.Len(t, identities, 1)
+	assert.Equal(t, "corp-1", identities[0].Subject)
+	assert.NotNil(t, identities[0].LastLoginAt)
+	assert.Equal(t, domain.AuditActionUserIdentityLinked, env.audit.records[0].Action)
+	assert.Equal(t, int64(7), env.audit.records[0].EntityID)
+
+	// Повторный вход находит связанную учетную запись, даже если email у провайдера сменился.
+	code, state = env.login(t, ssotest.User{Subject: "corp-1", Email: "ivan.petrov@example.com"})
+	_, err = env.service.CompleteSSOLogin(context.Background(), "corp", code, state, domain.SessionMeta{})
+	assert.NoError(t, err)
+	assert.Len(t, env.audit.records, 1)
+	identities, _ = env.identities.ListIdentities(context.Background(), 7)
+	assert.Equal(t, "ivan.petrov@example.com", identities[0].Email)
+}
+
+func TestSSOLogin_RequiresVerifiedEmail(t *testing.T) {
+	env := newSSOTestEnv(t, nil, nil)
+
+	code, state := env.login(t, ssotest.User{Subject: "corp-1", Email: "ivan@example.com", EmailVerified: false})
+	_, err := env.service.CompleteSSOLogin(context.Background(), "corp", code, state, domain.SessionMeta{})
+
+	assert.ErrorIs(t, err, ErrSSOEmailNotVerified)
+	assert.Empty(t, env.identities.identities)
+}
+
+func TestSSOLogin_UnknownEmail(t *testing.T) {
+	env := newSSOTestEnv(t, nil, nil)
+
+	code, state := env.login(t, ssotest.User{Subject: "corp-2", Email: "stranger@example.com", EmailVerified: true})
+	_, err := env.service.CompleteSSOLogin(context.Background(), "corp", code, state, domain.SessionMeta{})
+
+	assert.ErrorIs(t, err, ErrSSOAccountNotFound)
+}
+
+func TestSSOLogin_StateUsedOnce(t *testing.T) {
+	env := newSSOTestEnv(t, nil, nil)
+
+	code, state := env.login(t, ssotest.User{Subject: "corp-1", Email: "ivan@example.com", EmailVerified: true})
+	_, err := env.service.CompleteSSOLogin(context.Background(), "corp", code, state, domain.SessionMeta{})
+	assert.NoError(t, err)
+
+	_, err = env.service.CompleteSSOLogin(context.Background(), "corp", code, state, domain.SessionMeta{})
+	assert.ErrorIs(t, err, ErrInvalidSSOState)
+}
+
+func TestSSOLogin_ExpiredState(t *testing.T) {
+	env := newSSOTestEnv(t, nil, nil)
+
+	code, state := env.login(t, ssotest.User{Subject: "corp-1", Email: "ivan@example.com", EmailVerified: true})
+	env.service.now = func() time.Time { return time.Now().Add(time.Hour) }
+	_, err := env.service.CompleteSSOLogin(context.Background(), "corp", code, state, domain.SessionMeta{})
+
+	assert.ErrorIs(t, err, ErrInvalidSSOState)
+}
+
+func TestSSOLogin_ProviderRejectsCode(t *testing.T) {
+	env := newSSOTestEnv(t, nil, nil)
+
+	_, state := env.login(t, ssotest.User{Subject: "corp-1", Email: "ivan@example.com", EmailVerified: true})
+	_, err := env.service.CompleteSSOLogin(context.Background(), "corp", "forged-code", state, domain.SessionMeta{})
+
+	assert.ErrorIs(t, err, ErrSSOLoginFailed)
+}
+
+func TestSSOLogin_MFAChallenge(t *testing.T) {
+	env := newSSOTestEnv(t, stubMFAChallenger{7: true}, nil)
+
+	code, state := env.login(t, ssotest.User{Subject: "corp-1", Email: "ivan@example.com", EmailVerified: true})
+	_, err := env.service.CompleteSSOLogin(context.Background(), "corp", code, state, domain.SessionMeta{})
+
+	var challenge *MFAChallengeError
+	assert.ErrorAs(t, err, &challenge)
+}
+
+func TestSSOLogin_Locked(t *testing.T) {
+	guard, _, _, _, _ := newTestLoginGuard()
+	for range testLockoutPolicy.AccountThreshold {
+		failLogin(t, guard, "ivan@example.com", "", 7)
+	}
+	env := newSSOTestEnv(t, nil, guard)
+
+	code, state := env.login(t, ssotest.User{Subject: "corp-1", Email: "ivan@example.com", EmailVerified: true})
+	_, err := env.service.CompleteSSOLogin(context.Background(), "corp", code, state, domain.SessionMeta{})
+
+	var locked *LoginLockedError
+	assert.ErrorAs(t, err, &locked)
+}
+
+func TestStartSSOLogin(t *testing.T) {
+	env := newSSOTestEnv(t, nil, nil)
+
+	start, err := env.service.StartSSOLogin(context.Background(), "corp")
+	assert.NoError(t, err)
+	parsed, _ := url.Parse(start.URL)
+	assert.Equal(t, "S256", parsed.Query().Get("code_challenge_method"))
+	assert.Equal(t, "https://api.example.com/auth/sso/corp/callback", parsed.Query().Get("redirect_uri"))
+	assert.NotEmpty(t, parsed.Query().Get("nonce"))
+
+	_, err = env.service.StartSSOLogin(context.Background(), "unknown")
+	assert.ErrorIs(t, err, ErrSSOProviderNotFound)
+
+	assert.Equal(t, []domain.SSOProvider{{Name: "corp", DisplayName: "Корпоративный вход", LoginURL: "/auth/sso/corp"}}, env.service.ListSSOProviders())
+}
+
+func TestUnlinkIdentity(t *testing.T) {
+	env := newSSOTestEnv(t, nil, nil)
+	identity := &domain.Identity{UserID: 7, Provider: "corp", Subject: "corp-1"}
+	assert.NoError(t, env.identities.CreateIdentity(context.Background(), identity))
+
+	assert.ErrorIs(t, env.service.UnlinkIdentity(context.Background(), 8, identity.ID), ErrIdentityNotFound)
+	assert.NoError(t, env.service.UnlinkIdentity(context.Background(), 7, identity.ID))
+
+	identities, err := env.service.ListIdentities(context.Background(), 7)
+	assert.NoError(t, err)
+	assert.Empty(t, identities)
+	assert.Equal(t, domain.AuditActionUserIdentityUnlinked, env.audit.records[0].Action)
+
+	_, err = env.service.ListIdentities(context.Background(), 8)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
@@ -1,36 +1,103 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"sort"
+	"strconv"
+	"testovoe/internal/apperr"
 	"testovoe/internal/domain"
+	"testovoe/internal/i18n"
+	"testovoe/internal/pagination"
 	"testovoe/internal/repository"
+	"testovoe/internal/reqctx"
+	"time"
 )
 
-var ErrUserNotFound = errors.New("пользователь не найден")
-var ErrEmptyFields = errors.New("имя пользователя или email не могут быть пустыми")
+var ErrUserNotFound = apperr.New(apperr.KindNotFound, "user_not_found", "пользователь не найден")
+var ErrInvalidSort = apperr.New(apperr.KindInvalid, "invalid_sort", "недопустимые параметры сортировки")
+var ErrInvalidPagination = apperr.New(apperr.KindInvalid, "invalid_pagination", "недопустимые параметры пагинации")
+var ErrInvalidCursor = apperr.New(apperr.KindInvalid, "invalid_cursor", "недействительный курсор")
+var ErrInvalidPatch = apperr.New(apperr.KindInvalid, "invalid_patch", "некорректный патч")
+var ErrPatchTestFailed = apperr.New(apperr.KindConflict, "patch_test_failed", "проверка в патче не прошла")
+var ErrImmutableField = apperr.New(apperr.KindValidation, "immutable_field", "поле не может быть изменено")
+var ErrInvalidFilter = apperr.New(apperr.KindInvalid, "invalid_filter", "недопустимые параметры фильтрации")
+var ErrEmailTaken = apperr.New(apperr.KindConflict, "email_taken", "email уже используется")
+var ErrVersionConflict = apperr.New(apperr.KindPreconditionFailed, "version_conflict", "пользователь был изменен другим запросом")
+var ErrInvalidImportMode = apperr.New(apperr.KindInvalid, "invalid_import_mode", "недопустимый режим импорта")
+var ErrImportTooLarge = apperr.New(apperr.KindTooLarge, "import_too_large", "слишком много строк для импорта")
+var ErrImportRejected = apperr.New(apperr.KindValidation, "import_rejected", "импорт отклонен из-за ошибок в данных")
+var ErrInvalidRole = apperr.New(apperr.KindInvalid, "invalid_role", "недопустимая роль")
+
+type PatchFormat int
+
+const (
+	MergePatch PatchFormat = iota
+	JSONPatch
+)
+
+const (
+	DefaultListLimit = 20
+	MaxListLimit     = 100
+	MaxImportRows    = 100000
+)
 
 type UserServiceInterface interface {
 	CreateUser(ctx context.Context, user *domain.User) error
 	GetUserByID(ctx context.Context, id int64) (*domain.User, error)
 	UpdateUserByID(ctx context.Context, id int64, user *domain.User) error
-	DeleteUserByID(ctx context.Context, id int64) error
+	PatchUserByID(ctx context.Context, id int64, version int64, format PatchFormat, patch []byte) (*domain.User, error)
+	DeleteUserByID(ctx context.Context, id int64, version int64) error
+	RestoreUserByID(ctx context.Context, id int64) error
+	PurgeUserByID(ctx context.Context, id int64) error
+	ListUsers(ctx context.Context, filter domain.UserFilter) (*domain.UserList, error)
+	ExportUsers(ctx context.Context, filter domain.UserFilter, fn func(user *domain.User) error) error
+	ImportUsers(ctx context.Context, rows []domain.UserImportRow, mode domain.UserImportMode) (*domain.UserImportResult, error)
+	GetUserRoles(ctx context.Context, id int64) ([]domain.Role, error)
+	SetUserRoles(ctx context.Context, id int64, roles []domain.Role) ([]domain.Role, error)
 }
 
 type UserService struct {
-	repo repository.UserRepositoryInterface
+	repo         repository.UserRepositoryInterface
+	audit        repository.AuditRepositoryInterface
+	sessions     repository.SessionRepositoryInterface
+	tx           repository.TransactorInterface
+	cursors      *pagination.CursorCodec
+	verification EmailVerificationSender
 }
 
-func NewUserService(repo repository.UserRepositoryInterface) *UserService {
-	return &UserService{repo: repo}
+// NewUserService создает сервис пользователей. verification отправляет ссылку подтверждения
+// при создании пользователя и смене email; nil отключает отправку. Сессии удаленного пользователя отзываются.
+func NewUserService(repo repository.UserRepositoryInterface, audit repository.AuditRepositoryInterface, sessions repository.SessionRepositoryInterface,
+	tx repository.TransactorInterface, cursors *pagination.CursorCodec, verification EmailVerificationSender) *UserService {
+	return &UserService{repo: repo, audit: audit, sessions: sessions, tx: tx, cursors: cursors, verification: verification}
 }
 
 func (s *UserService) CreateUser(ctx context.Context, user *domain.User) error {
-	if user.Name == "" || user.Email == "" {
-		return ErrEmptyFields
+	if err := normalizeUser(user); err != nil {
+		return err
 	}
+	user.EmailVerifiedAt = nil
 
-	return s.repo.CreateUser(ctx, user)
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.CreateUser(ctx, user); err != nil {
+			if errors.Is(err, repository.ErrEmailTaken) {
+				return ErrEmailTaken
+			}
+			return err
+		}
+		return s.recordAudit(ctx, domain.AuditActionUserCreated, user.ID, nil, user)
+	})
+	if err != nil {
+		return err
+	}
+	sendVerificationAfterCommit(ctx, s.verification, user)
+	return nil
 }
 
 func (s *UserService) GetUserByID(ctx context.Context, id int64) (*domain.User, error) {
@@ -38,12 +105,526 @@ func (s *UserService) GetUserByID(ctx context.Context, id int64) (*domain.User,
 }
 
 func (s *UserService) UpdateUserByID(ctx context.Context, id int64, user *domain.User) error {
-	if user.Name == "" || user.Email == "" {
-		return ErrEmptyFields
+	if err := normalizeUser(user); err != nil {
+		return err
+	}
+
+	emailChanged := false
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := s.repo.GetUserByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if user.Version == domain.AnyVersion {
+			user.Version = before.Version
+		}
+		if err := s.repo.UpdateUserByID(ctx, id, user); err != nil {
+			if errors.Is(err, repository.ErrVersionConflict) {
+				return ErrVersionConflict
+			}
+			return err
+		}
+		user.ID = id
+		emailChanged = before.Email != user.Email
+		return s.recordAudit(ctx, domain.AuditActionUserUpdated, id, before, user)
+	})
+	if err != nil {
+		return err
+	}
+	if emailChanged {
+		sendVerificationAfterCommit(ctx, s.verification, user)
+	}
+	return nil
+}
+
+func (s *UserService) PatchUserByID(ctx context.Context, id int64, version int64, format PatchFormat, patch []byte) (*domain.User, error) {
+	current, err := s.repo.GetUserByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	if version == domain.AnyVersion {
+		version = current.Version
+	}
+	if current.Version != version {
+		return nil, ErrVersionConflict
+	}
+
+	updated, err := applyUserPatch(current, format, patch)
+	if err != nil {
+		return nil, err
+	}
+	if updated.ID != current.ID || updated.Version != current.Version || updated.DeletedAt != nil ||
+		!sameTime(updated.EmailVerifiedAt, current.EmailVerifiedAt) {
+		return nil, ErrImmutableField
+	}
+	if err := normalizeUser(updated); err != nil {
+		return nil, err
+	}
+
+	var changes domain.UserPatch
+	if updated.Name != current.Name {
+		changes.Name = &updated.Name
+	}
+	if updated.Email != current.Email {
+		changes.Email = &updated.Email
+		updated.EmailVerifiedAt = nil
+	}
+	if changes.IsEmpty() {
+		return current, nil
+	}
+
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if updated.Version, err = s.repo.PatchUserByID(ctx, id, version, changes); err != nil {
+			switch {
+			case errors.Is(err, repository.ErrUserNotFound):
+				return ErrUserNotFound
+			case errors.Is(err, repository.ErrVersionConflict):
+				return ErrVersionConflict
+			}
+			return err
+		}
+		return s.recordAudit(ctx, domain.AuditActionUserUpdated, id, current, updated)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if changes.Email != nil {
+		sendVerificationAfterCommit(ctx, s.verification, updated)
+	}
+	return updated, nil
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func applyUserPatch(user *domain.User, format PatchFormat, patch []byte) (*domain.User, error) {
+	original, err := json.Marshal(user)
+	if err != nil {
+		return nil, err
+	}
+
+	var patched []byte
+	switch format {
+	case MergePatch:
+		if !json.Valid(patch) || !bytes.HasPrefix(bytes.TrimSpace(patch), []byte("{")) {
+			return nil, ErrInvalidPatch
+		}
+		patched, err = jsonpatch.MergePatch(original, patch)
+	case JSONPatch:
+		var ops jsonpatch.Patch
+		if ops, err = jsonpatch.DecodePatch(patch); err != nil {
+			return nil, ErrInvalidPatch
+		}
+		patched, err = ops.Apply(original)
+	default:
+		return nil, ErrInvalidPatch
+	}
+	if err != nil {
+		if errors.Is(err, jsonpatch.ErrTestFailed) {
+			return nil, ErrPatchTestFailed
+		}
+		return nil, ErrInvalidPatch
+	}
+
+	var updated domain.User
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&updated); err != nil {
+		return nil, ErrInvalidPatch
+	}
+	return &updated, nil
+}
+
+func (s *UserService) DeleteUserByID(ctx context.Context, id int64, version int64) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := s.repo.GetUserByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if version == domain.AnyVersion {
+			version = before.Version
+		}
+		if err := s.repo.DeleteUserByID(ctx, id, version); err != nil {
+			if errors.Is(err, repository.ErrVersionConflict) {
+				return ErrVersionConflict
+			}
+			return err
+		}
+		// Иначе access-токены удаленного пользователя действовали бы до истечения, а refresh-токены — дольше.
+		if err := revokeUserSessions(ctx, s.sessions, s.audit, id, domain.SessionRevokedUserDeleted, 0); err != nil {
+			return err
+		}
+		return s.recordAudit(ctx, domain.AuditActionUserDeleted, id, before, nil)
+	})
+}
+
+func (s *UserService) RestoreUserByID(ctx context.Context, id int64) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		restored, err := s.repo.RestoreUserByID(ctx, id)
+		switch {
+		case errors.Is(err, repository.ErrUserNotFound):
+			return ErrUserNotFound
+		case errors.Is(err, repository.ErrEmailTaken):
+			return ErrEmailTaken
+		case err != nil:
+			return err
+		}
+		return s.recordAudit(ctx, domain.AuditActionUserRestored, id, nil, restored)
+	})
+}
+
+func (s *UserService) PurgeUserByID(ctx context.Context, id int64) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		purged, err := s.repo.PurgeUserByID(ctx, id)
+		if err != nil {
+			if errors.Is(err, repository.ErrUserNotFound) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+		return s.recordAudit(ctx, domain.AuditActionUserPurged, id, purged, nil)
+	})
+}
+
+func (s *UserService) GetUserRoles(ctx context.Context, id int64) ([]domain.Role, error) {
+	if err := s.requireUser(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.GetUserRoles(ctx, id)
+}
+
+func (s *UserService) SetUserRoles(ctx context.Context, id int64, roles []domain.Role) ([]domain.Role, error) {
+	unique, err := normalizeRoles(roles)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.requireUser(ctx, id); err != nil {
+			return err
+		}
+		return s.replaceRoles(ctx, id, unique)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return unique, nil
+}
+
+// replaceRoles заменяет роли пользователя и записывает изменение в журнал аудита.
+func (s *UserService) replaceRoles(ctx context.Context, id int64, roles []domain.Role) error {
+	before, err := s.repo.GetUserRoles(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.SetUserRoles(ctx, id, roles); err != nil {
+		return err
+	}
+
+	type roleSnapshot struct {
+		Roles []domain.Role `json:"roles"`
+	}
+	record, err := newAuditRecord(ctx, domain.AuditActionUserRolesChanged, domain.AuditEntityUser, id,
+		roleSnapshot{Roles: before}, roleSnapshot{Roles: roles})
+	if err != nil {
+		return err
+	}
+	return s.audit.CreateAuditRecord(ctx, record)
+}
+
+// BootstrapAdmin назначает первого администратора: выдает роль admin пользователю с адресом email,
+// если ни у кого из пользователей ее еще нет, и создает пользователя, если его нет. Пароль новый
+// пользователь задает через сброс пароля. Если администратор уже есть, ничего не меняется и
+// возвращается nil. Действия записываются в журнал аудита от имени system.
+func (s *UserService) BootstrapAdmin(ctx context.Context, email string) (*domain.User, error) {
+	ctx = reqctx.WithActor(ctx, reqctx.SystemActor)
+	candidate := &domain.User{Name: "Администратор", Email: email}
+	if err := normalizeUser(candidate); err != nil {
+		return nil, err
+	}
+
+	var admin *domain.User
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		exists, err := s.repo.HasUsersWithRole(ctx, domain.RoleAdmin)
+		if err != nil || exists {
+			return err
+		}
+
+		users, err := s.repo.GetUsersByEmails(ctx, []string{candidate.Email})
+		if err != nil {
+			return err
+		}
+		if len(users) > 0 {
+			candidate = &users[0]
+		} else {
+			if err := s.repo.CreateUser(ctx, candidate); err != nil {
+				if errors.Is(err, repository.ErrEmailTaken) {
+					return ErrEmailTaken
+				}
+				return err
+			}
+			if err := s.recordAudit(ctx, domain.AuditActionUserCreated, candidate.ID, nil, candidate); err != nil {
+				return err
+			}
+		}
+
+		roles, err := s.repo.GetUserRoles(ctx, candidate.ID)
+		if err != nil {
+			return err
+		}
+		if roles, err = normalizeRoles(append(roles, domain.RoleAdmin)); err != nil {
+			return err
+		}
+		if err := s.replaceRoles(ctx, candidate.ID, roles); err != nil {
+			return err
+		}
+		admin = candidate
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return admin, nil
+}
+
+// normalizeRoles проверяет, что роли можно выдавать, убирает повторы и сортирует их.
+func normalizeRoles(roles []domain.Role) ([]domain.Role, error) {
+	unique := make([]domain.Role, 0, len(roles))
+	seen := make(map[domain.Role]bool, len(roles))
+	for _, role := range roles {
+		if !role.Assignable() {
+			return nil, ErrInvalidRole
+		}
+		if !seen[role] {
+			seen[role] = true
+			unique = append(unique, role)
+		}
+	}
+	sort.Slice(unique, func(i, j int) bool { return unique[i] < unique[j] })
+	return unique, nil
+}
+
+func (s *UserService) requireUser(ctx context.Context, id int64) error {
+	if _, err := s.repo.GetUserByID(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func normalizeUserFilter(filter *domain.UserFilter) error {
+	switch filter.SortBy {
+	case "":
+		filter.SortBy = domain.UserSortByID
+	case domain.UserSortByID, domain.UserSortByName, domain.UserSortByEmail:
+	default:
+		return ErrInvalidSort
+	}
+
+	switch filter.SortOrder {
+	case "":
+		filter.SortOrder = domain.SortAsc
+	case domain.SortAsc, domain.SortDesc:
+	default:
+		return ErrInvalidSort
+	}
+
+	switch filter.Deleted {
+	case "":
+		filter.Deleted = domain.DeletedExclude
+	case domain.DeletedExclude, domain.DeletedOnly, domain.DeletedInclude:
+	default:
+		return ErrInvalidFilter
+	}
+	return nil
+}
+
+// userFilterHash возвращает хеш условий отбора. Он записывается в курсор, чтобы курсор нельзя было
+// продолжить с другими условиями: страницы получились бы несогласованными.
+func userFilterHash(filter domain.UserFilter) string {
+	sum := sha256.Sum256([]byte(strconv.Quote(filter.Name) + strconv.Quote(filter.Email) + string(filter.Deleted)))
+	return base64.RawURLEncoding.EncodeToString(sum[:12])
+}
+
+func (s *UserService) ListUsers(ctx context.Context, filter domain.UserFilter) (*domain.UserList, error) {
+	if err := normalizeUserFilter(&filter); err != nil {
+		return nil, err
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = DefaultListLimit
+	}
+	if filter.Limit < 0 || filter.Limit > MaxListLimit || filter.Offset < 0 {
+		return nil, ErrInvalidPagination
+	}
+
+	if filter.Cursor != "" {
+		if filter.Offset != 0 {
+			return nil, ErrInvalidPagination
+		}
+		var after domain.UserCursor
+		if err := s.cursors.Decode(filter.Cursor, &after); err != nil {
+			return nil, ErrInvalidCursor
+		}
+		if after.SortBy != filter.SortBy || after.SortOrder != filter.SortOrder || after.Filter != userFilterHash(filter) {
+			return nil, ErrInvalidCursor
+		}
+		filter.After = &after
+	}
+
+	list, err := s.repo.ListUsers(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if list.HasMore && len(list.Users) > 0 {
+		last := list.Users[len(list.Users)-1]
+		next := domain.UserCursor{SortBy: filter.SortBy, SortOrder: filter.SortOrder, Filter: userFilterHash(filter), ID: last.ID}
+		switch filter.SortBy {
+		case domain.UserSortByName:
+			next.Value = last.Name
+		case domain.UserSortByEmail:
+			next.Value = last.Email
+		}
+		if list.NextCursor, err = s.cursors.Encode(next); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// ExportUsers передает fn всех пользователей, подходящих под фильтр, по мере чтения из базы.
+// Пагинация фильтра игнорируется.
+func (s *UserService) ExportUsers(ctx context.Context, filter domain.UserFilter, fn func(user *domain.User) error) error {
+	if err := normalizeUserFilter(&filter); err != nil {
+		return err
+	}
+	filter.Limit, filter.Offset, filter.Cursor, filter.After = 0, 0, "", nil
+	return s.repo.ExportUsers(ctx, filter, fn)
+}
+
+func (s *UserService) ImportUsers(ctx context.Context, rows []domain.UserImportRow, mode domain.UserImportMode) (*domain.UserImportResult, error) {
+	switch mode {
+	case "":
+		mode = domain.ImportAtomic
+	case domain.ImportAtomic, domain.ImportSkipInvalid:
+	default:
+		return nil, ErrInvalidImportMode
+	}
+	if len(rows) > MaxImportRows {
+		return nil, ErrImportTooLarge
+	}
+
+	result := &domain.UserImportResult{Mode: mode, Total: len(rows), Errors: []domain.UserImportRowError{}}
+	// Отчет отдается клиенту как есть, поэтому причины сразу переводятся на язык запроса.
+	rejectRow := func(row domain.UserImportRow, reason string, args ...any) {
+		result.Errors = append(result.Errors, domain.UserImportRowError{Line: row.Line, Email: row.User.Email, Error: i18n.T(ctx, reason, args...)})
+	}
+
+	candidates := make([]domain.UserImportRow, 0, len(rows))
+	seen := make(map[string]int, len(rows))
+	for _, row := range rows {
+		if row.ParseError != "" {
+			rejectRow(row, row.ParseError)
+			continue
+		}
+		if err := normalizeUser(&row.User); err != nil {
+			rejectRow(row, violationSummary(ctx, err))
+			continue
+		}
+		if line, ok := seen[row.User.Email]; ok {
+			rejectRow(row, "email повторяется в строке %d", line)
+			continue
+		}
+		seen[row.User.Email] = row.Line
+		candidates = append(candidates, row)
+	}
+
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		emails := make([]string, len(candidates))
+		for i, row := range candidates {
+			emails[i] = row.User.Email
+		}
+		existing, err := s.repo.FindExistingEmails(ctx, emails)
+		if err != nil {
+			return err
+		}
+
+		users := make([]domain.User, 0, len(candidates))
+		for _, row := range candidates {
+			if existing[row.User.Email] {
+				rejectRow(row, ErrEmailTaken.Message)
+				continue
+			}
+			users = append(users, row.User)
+		}
+
+		if len(result.Errors) > 0 && mode == domain.ImportAtomic {
+			return ErrImportRejected
+		}
+		if len(users) == 0 {
+			return nil
+		}
+
+		if _, err := s.repo.CopyUsers(ctx, users); err != nil {
+			if errors.Is(err, repository.ErrEmailTaken) {
+				return ErrEmailTaken
+			}
+			return err
+		}
+
+		inserted := make([]string, len(users))
+		for i, user := range users {
+			inserted[i] = user.Email
+		}
+		created, err := s.repo.GetUsersByEmails(ctx, inserted)
+		if err != nil {
+			return err
+		}
+
+		records := make([]domain.AuditRecord, 0, len(created))
+		for i := range created {
+			record, err := newUserAuditRecord(ctx, domain.AuditActionUserCreated, created[i].ID, nil, &created[i])
+			if err != nil {
+				return err
+			}
+			records = append(records, *record)
+		}
+		if err := s.audit.CreateAuditRecords(ctx, records); err != nil {
+			return err
+		}
+
+		result.Imported = len(created)
+		return nil
+	})
+	sort.Slice(result.Errors, func(i, j int) bool { return result.Errors[i].Line < result.Errors[j].Line })
+	result.Skipped = result.Total - result.Imported
+	if err != nil {
+		if errors.Is(err, ErrImportRejected) {
+			return result, err
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *UserService) recordAudit(ctx context.Context, action string, userID int64, before, after *domain.User) error {
+	record, err := newUserAuditRecord(ctx, action, userID, before, after)
+	if err != nil {
+		return err
 	}
-	return s.repo.UpdateUserByID(ctx, id, user)
+	return s.audit.CreateAuditRecord(ctx, record)
 }
 
-func (s *UserService) DeleteUserByID(ctx context.Context, id int64) error {
-	return s.repo.DeleteUserByID(ctx, id)
+func newUserAuditRecord(ctx context.Context, action string, userID int64, before, after *domain.User) (*domain.AuditRecord, error) {
+	return newAuditRecord(ctx, action, domain.AuditEntityUser, userID, before, after)
 }
@@ -6,9 +6,44 @@ import (
 	"github.com/stretchr/testify/mock"
 	"testing"
 	"testovoe/internal/domain"
+	"testovoe/internal/i18n"
+	"testovoe/internal/pagination"
 	"testovoe/internal/repository"
+	"testovoe/internal/reqctx"
+	"time"
 )
 
+var testCursors = pagination.NewCursorCodec([]byte("test-secret"))
+
+type fakeTransactor struct{}
+
+func (fakeTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+type recordingAuditRepository struct {
+	records []domain.AuditRecord
+}
+
+func (r *recordingAuditRepository) CreateAuditRecord(ctx context.Context, record *domain.AuditRecord) error {
+	r.records = append(r.records, *record)
+	return nil
+}
+
+func (r *recordingAuditRepository) CreateAuditRecords(ctx context.Context, records []domain.AuditRecord) error {
+	r.records = append(r.records, records...)
+	return nil
+}
+
+func (r *recordingAuditRepository) ListAuditRecords(ctx context.Context, filter domain.AuditFilter) (*domain.AuditList, error) {
+	return &domain.AuditList{Records: r.records, Total: int64(len(r.records))}, nil
+}
+
+func newTestUserService(repo repository.UserRepositoryInterface) (*UserService, *recordingAuditRepository) {
+	audit := new(recordingAuditRepository)
+	return NewUserService(repo, audit, newMemorySessionRepository(), fakeTransactor{}, testCursors, nil), audit
+}
+
 type MockUserRepository struct {
 	mock.Mock
 }
@@ -28,14 +63,79 @@ func (m *MockUserRepository) UpdateUserByID(ctx context.Context, id int64, user
 	return args.Error(0)
 }
 
-func (m *MockUserRepository) DeleteUserByID(ctx context.Context, id int64) error {
+func (m *MockUserRepository) PatchUserByID(ctx context.Context, id int64, version int64, patch domain.UserPatch) (int64, error) {
+	args := m.Called(ctx, id, version, patch)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockUserRepository) DeleteUserByID(ctx context.Context, id int64, version int64) error {
+	args := m.Called(ctx, id, version)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) RestoreUserByID(ctx context.Context, id int64) (*domain.User, error) {
 	args := m.Called(ctx, id)
+	return args.Get(0).(*domain.User), args.Error(1)
+}
+
+func (m *MockUserRepository) PurgeUserByID(ctx context.Context, id int64) (*domain.User, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*domain.User), args.Error(1)
+}
+
+func (m *MockUserRepository) ListUsers(ctx context.Context, filter domain.UserFilter) (*domain.UserList, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(*domain.UserList), args.Error(1)
+}
+
+func (m *MockUserRepository) ExportUsers(ctx context.Context, filter domain.UserFilter, fn func(user *domain.User) error) error {
+	args := m.Called(ctx, filter, fn)
 	return args.Error(0)
 }
 
+func (m *MockUserRepository) FindExistingEmails(ctx context.Context, emails []string) (map[string]bool, error) {
+	args := m.Called(ctx, emails)
+	return args.Get(0).(map[string]bool), args.Error(1)
+}
+
+func (m *MockUserRepository) CopyUsers(ctx context.Context, users []domain.User) (int64, error) {
+	args := m.Called(ctx, users)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockUserRepository) GetUsersByEmails(ctx context.Context, emails []string) ([]domain.User, error) {
+	args := m.Called(ctx, emails)
+	return args.Get(0).([]domain.User), args.Error(1)
+}
+
+func (m *MockUserRepository) GetUserRoles(ctx context.Context, userID int64) ([]domain.Role, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]domain.Role), args.Error(1)
+}
+
+func (m *MockUserRepository) GetAssignedRoles(ctx context.Context, userID int64) ([]domain.Role, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]domain.Role), args.Error(1)
+}
+
+func (m *MockUserRepository) SetUserRoles(ctx context.Context, userID int64, roles []domain.Role) error {
+	args := m.Called(ctx, userID, roles)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) HasUsersWithRole(ctx context.Context, role domain.Role) (bool, error) {
+	args := m.Called(ctx, role)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockUserRepository) MarkEmailVerified(ctx context.Context, id int64, email string) (*domain.User, error) {
+	args := m.Called(ctx, id, email)
+	return args.Get(0).(*domain.User), args.Error(1)
+}
+
 func TestCreateUser_Success(t *testing.T) {
 	mockRepo := new(MockUserRepository)
-	service := NewUserService(mockRepo)
+	service, _ := newTestUserService(mockRepo)
 
 	user := &domain.User{Name: "Test User", Email: "test@example.com"}
 	mockRepo.On("CreateUser", mock.Anything, user).Return(nil)
@@ -47,22 +147,22 @@ func TestCreateUser_Success(t *testing.T) {
 
 func TestCreateUser_EmptyFields(t *testing.T) {
 	mockRepo := new(MockUserRepository)
-	service := NewUserService(mockRepo)
+	service, _ := newTestUserService(mockRepo)
 
 	user := &domain.User{Name: "", Email: "test@example.com"}
 	err := service.CreateUser(context.Background(), user)
-	assert.Equal(t, ErrEmptyFields, err)
+	assert.ErrorIs(t, err, ErrInvalidUser)
 
 	user = &domain.User{Name: "Test User", Email: ""}
 	err = service.CreateUser(context.Background(), user)
-	assert.Equal(t, ErrEmptyFields, err)
+	assert.ErrorIs(t, err, ErrInvalidUser)
 
 	mockRepo.AssertNotCalled(t, "CreateUser")
 }
 
 func TestGetUserByID_Success(t *testing.T) {
 	mockRepo := new(MockUserRepository)
-	service := NewUserService(mockRepo)
+	service, _ := newTestUserService(mockRepo)
 
 	expectedUser := &domain.User{ID: 1, Name: "Test User", Email: "test@example.com"}
 	mockRepo.On("GetUserByID", mock.Anything, int64(1)).Return(expectedUser, nil)
@@ -75,7 +175,7 @@ func TestGetUserByID_Success(t *testing.T) {
 
 func TestGetUserByID_NotFound(t *testing.T) {
 	mockRepo := new(MockUserRepository)
-	service := NewUserService(mockRepo)
+	service, _ := newTestUserService(mockRepo)
 
 	mockRepo.On("GetUserByID", mock.Anything, int64(1)).Return((*domain.User)(nil), repository.ErrUserNotFound)
 
@@ -87,9 +187,11 @@ func TestGetUserByID_NotFound(t *testing.T) {
 
 func TestUpdateUserByID_Success(t *testing.T) {
 	mockRepo := new(MockUserRepository)
-	service := NewUserService(mockRepo)
+	service, _ := newTestUserService(mockRepo)
 
-	user := &domain.User{Name: "Updated User", Email: "updated@example.com"}
+	before := &domain.User{ID: 1, Name: "Test User", Email: "test@example.com", Version: 1}
+	mockRepo.On("GetUserByID", mock.Anything, int64(1)).Return(before, nil)
+	user := &domain.User{Name: "Updated User", Email: "updated@example.com", Version: 1}
 	mockRepo.On("UpdateUserByID", mock.Anything, int64(1), user).Return(nil)
 	err := service.UpdateUserByID(context.Background(), 1, user)
 	assert.NoError(t, err)
@@ -98,37 +200,584 @@ func TestUpdateUserByID_Success(t *testing.T) {
 
 func TestUpdateUserByID_EmptyFields(t *testing.T) {
 	mockRepo := new(MockUserRepository)
-	service := NewUserService(mockRepo)
+	service, _ := newTestUserService(mockRepo)
 
 	user := &domain.User{Name: "", Email: "updated@example.com"}
 	err := service.UpdateUserByID(context.Background(), 1, user)
-	assert.Equal(t, ErrEmptyFields, err)
+	assert.ErrorIs(t, err, ErrInvalidUser)
 
 	user = &domain.User{Name: "Updated User", Email: ""}
 	err = service.UpdateUserByID(context.Background(), 1, user)
-	assert.Equal(t, ErrEmptyFields, err)
+	assert.ErrorIs(t, err, ErrInvalidUser)
 
 	mockRepo.AssertNotCalled(t, "UpdateUserByID")
 }
 
 func TestDeleteUserByID_Success(t *testing.T) {
 	mockRepo := new(MockUserRepository)
-	service := NewUserService(mockRepo)
+	service, _ := newTestUserService(mockRepo)
 
-	mockRepo.On("DeleteUserByID", mock.Anything, int64(1)).Return(nil)
+	before := &domain.User{ID: 1, Name: "Test User", Email: "test@example.com", Version: 1}
+	mockRepo.On("GetUserByID", mock.Anything, int64(1)).Return(before, nil)
+	mockRepo.On("DeleteUserByID", mock.Anything, int64(1), int64(1)).Return(nil)
 
-	err := service.DeleteUserByID(context.Background(), 1)
+	err := service.DeleteUserByID(context.Background(), 1, 1)
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeleteUserByID_RevokesSessions(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	sessions := newMemorySessionRepository()
+	audit := new(recordingAuditRepository)
+	service := NewUserService(mockRepo, audit, sessions, fakeTransactor{}, testCursors, nil)
+
+	own := &domain.Session{UserID: 1, ExpiresAt: time.Now().Add(time.Hour)}
+	other := &domain.Session{UserID: 2, ExpiresAt: time.Now().Add(time.Hour)}
+	assert.NoError(t, sessions.CreateSession(context.Background(), own))
+	assert.NoError(t, sessions.CreateSession(context.Background(), other))
+
+	mockRepo.On("GetUserByID", mock.Anything, int64(1)).Return(&domain.User{ID: 1, Version: 1}, nil)
+	mockRepo.On("DeleteUserByID", mock.Anything, int64(1), int64(1)).Return(nil)
+	assert.NoError(t, service.DeleteUserByID(context.Background(), 1, 1))
+
+	active, _ := sessions.IsSessionActive(context.Background(), own.ID)
+	assert.False(t, active)
+	assert.Equal(t, domain.SessionRevokedUserDeleted, sessions.sessions[own.ID].RevokeReason)
+	active, _ = sessions.IsSessionActive(context.Background(), other.ID)
+	assert.True(t, active)
+	assert.Equal(t, domain.AuditActionSessionRevoked, audit.records[0].Action)
+	assert.Equal(t, domain.AuditActionUserDeleted, audit.records[1].Action)
+}
+
+func TestDeleteUserByID_AnyVersion(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service, _ := newTestUserService(mockRepo)
+
+	before := &domain.User{ID: 1, Name: "Test User", Email: "test@example.com", Version: 7}
+	mockRepo.On("GetUserByID", mock.Anything, int64(1)).Return(before, nil)
+	mockRepo.On("DeleteUserByID", mock.Anything, int64(1), int64(7)).Return(nil)
+
+	err := service.DeleteUserByID(context.Background(), 1, domain.AnyVersion)
 	assert.NoError(t, err)
 	mockRepo.AssertExpectations(t)
 }
 
 func TestDeleteUserByID_NotFound(t *testing.T) {
 	mockRepo := new(MockUserRepository)
-	service := NewUserService(mockRepo)
+	service, _ := newTestUserService(mockRepo)
 
-	mockRepo.On("DeleteUserByID", mock.Anything, int64(1)).Return(repository.ErrUserNotFound)
+	mockRepo.On("GetUserByID", mock.Anything, int64(1)).Return((*domain.User)(nil), repository.ErrUserNotFound)
 
-	err := service.DeleteUserByID(context.Background(), 1)
+	err := service.DeleteUserByID(context.Background(), 1, 1)
 	assert.Equal(t, repository.ErrUserNotFound, err)
 	mockRepo.AssertExpectations(t)
+	mockRepo.AssertNotCalled(t, "DeleteUserByID")
+}
+
+func TestListUsers_Defaults(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service, _ := newTestUserService(mockRepo)
+
+	expected := domain.UserFilter{SortBy: domain.UserSortByID, SortOrder: domain.SortAsc, Deleted: domain.DeletedExclude, Limit: DefaultListLimit}
+	list := &domain.UserList{Users: []domain.User{}, Limit: DefaultListLimit}
+	mockRepo.On("ListUsers", mock.Anything, expected).Return(list, nil)
+
+	result, err := service.ListUsers(context.Background(), domain.UserFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, list, result)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestListUsers_InvalidParams(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service, _ := newTestUserService(mockRepo)
+
+	_, err := service.ListUsers(context.Background(), domain.UserFilter{SortBy: "password"})
+	assert.Equal(t, ErrInvalidSort, err)
+
+	_, err = service.ListUsers(context.Background(), domain.UserFilter{SortOrder: "up"})
+	assert.Equal(t, ErrInvalidSort, err)
+
+	_, err = service.ListUsers(context.Background(), domain.UserFilter{Limit: MaxListLimit + 1})
+	assert.Equal(t, ErrInvalidPagination, err)
+
+	_, err = service.ListUsers(context.Background(), domain.UserFilter{Offset: -1})
+	assert.Equal(t, ErrInvalidPagination, err)
+
+	_, err = service.ListUsers(context.Background(), domain.UserFilter{Deleted: "all"})
+	assert.Equal(t, ErrInvalidFilter, err)
+
+	mockRepo.AssertNotCalled(t, "ListUsers")
+}
+
+func TestListUsers_Cursor(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service, _ := newTestUserService(mockRepo)
+
+	firstFilter := domain.UserFilter{SortBy: domain.UserSortByName, SortOrder: domain.SortAsc, Deleted: domain.DeletedExclude, Limit: 2}
+	firstPage := &domain.UserList{
+		Users:   []domain.User{{ID: 3, Name: "Alice"}, {ID: 1, Name: "Bob"}},
+		Limit:   2,
+		HasMore: true,
+	}
+	mockRepo.On("ListUsers", mock.Anything, firstFilter).Return(firstPage, nil)
+
+	result, err := service.ListUsers(context.Background(), domain.UserFilter{SortBy: domain.UserSortByName, Limit: 2})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, result.NextCursor)
+
+	secondFilter := firstFilter
+	secondFilter.Cursor = result.NextCursor
+	secondFilter.After = &domain.UserCursor{SortBy: domain.UserSortByName, SortOrder: domain.SortAsc, Filter: userFilterHash(firstFilter), Value: "Bob", ID: 1}
+	secondPage := &domain.UserList{Users: []domain.User{{ID: 2, Name: "Carol"}}, Limit: 2}
+	mockRepo.On("ListUsers", mock.Anything, secondFilter).Return(secondPage, nil)
+
+	result, err = service.ListUsers(context.Background(), domain.UserFilter{SortBy: domain.UserSortByName, Limit: 2, Cursor: result.NextCursor})
+	assert.NoError(t, err)
+	assert.Empty(t, result.NextCursor)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestListUsers_InvalidCursor(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service, _ := newTestUserService(mockRepo)
+
+	_, err := service.ListUsers(context.Background(), domain.UserFilter{Cursor: "garbage"})
+	assert.Equal(t, ErrInvalidCursor, err)
+
+	nameFilter := domain.UserFilter{SortBy: domain.UserSortByName, SortOrder: domain.SortAsc, Deleted: domain.DeletedExclude}
+	cursor, err := testCursors.Encode(domain.UserCursor{SortBy: domain.UserSortByName, SortOrder: domain.SortAsc, Filter: userFilterHash(nameFilter), Value: "Bob", ID: 1})
+	assert.NoError(t, err)
+
+	// Курсор выдан для других условий отбора.
+	_, err = service.ListUsers(context.Background(), domain.UserFilter{SortBy: domain.UserSortByName, Name: "bo", Cursor: cursor})
+	assert.Equal(t, ErrInvalidCursor, err)
+	_, err = service.ListUsers(context.Background(), domain.UserFilter{SortBy: domain.UserSortByName, Deleted: domain.DeletedInclude, Cursor: cursor})
+	assert.Equal(t, ErrInvalidCursor, err)
+
+	_, err = service.ListUsers(context.Background(), domain.UserFilter{SortBy: domain.UserSortByEmail, Cursor: cursor})
+	assert.Equal(t, ErrInvalidCursor, err)
+
+	_, err = service.ListUsers(context.Background(), domain.UserFilter{SortBy: domain.UserSortByName, Cursor: cursor, Offset: 10})
+	assert.Equal(t, ErrInvalidPagination, err)
+
+	mockRepo.AssertNotCalled(t, "ListUsers")
+}
+
+func TestPatchUserByID_MergePatch(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service, _ := newTestUserService(mockRepo)
+
+	current := &domain.User{ID: 1, Name: "Test User", Email: "test@example.com", Version: 1}
+	mockRepo.On("GetUserByID", mock.Anything, int64(1)).Return(current, nil)
+	name := "Patched User"
+	mockRepo.On("PatchUserByID", mock.Anything, int64(1), int64(1), domain.UserPatch{Name: &name}).Return(int64(2), nil)
+
+	user, err := service.PatchUserByID(context.Background(), 1, 1, MergePatch, []byte(`{"name":"Patched User"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.User{ID: 1, Name: "Patched User", Email: "test@example.com", Version: 2}, user)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPatchUserByID_JSONPatch(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service, _ := newTestUserService(mockRepo)
+
+	current := &domain.User{ID: 1, Name: "Test User", Email: "test@example.com", Version: 1}
+	mockRepo.On("GetUserByID", mock.Anything, int64(1)).Return(current, nil)
+	email := "patched@example.com"
+	mockRepo.On("PatchUserByID", mock.Anything, int64(1), int64(1), domain.UserPatch{Email: &email}).Return(int64(2), nil)
+
+	patch := []byte(`[
+		{"op":"test","path":"/email","value":"test@example.com"},
+		{"op":"replace","path":"/email","value":"patched@example.com"}
+	]`)
+	user, err := service.PatchUserByID(context.Background(), 1, 1, JSONPatch, patch)
+	assert.NoError(t, err)
+	assert.Equal(t, "patched@example.com", user.Email)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPatchUserByID_NoChanges(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service, _ := newTestUserService(mockRepo)
+
+	current := &domain.User{ID: 1, Name: "Test User", Email: "test@example.com", Version: 1}
+	mockRepo.On("GetUserByID", mock.Anything, int64(1)).Return(current, nil)
+
+	user, err := service.PatchUserByID(context.Background(), 1, 1, MergePatch, []byte(`{"name":"Test User"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, current, user)
+	mockRepo.AssertNotCalled(t, "PatchUserByID")
+}
+
+func TestPatchUserByID_Invalid(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service, _ := newTestUserService(mockRepo)
+
+	current := &domain.User{ID: 1, Name: "Test User", Email: "test@example.com", Version: 1}
+	mockRepo.On("GetUserByID", mock.Anything, int64(1)).Return(current, nil)
+
+	_, err := service.PatchUserByID(context.Background(), 1, 1, MergePatch, []byte(`{"name":null}`))
+	assert.ErrorIs(t, err, ErrInvalidUser)
+
+	_, err = service.PatchUserByID(context.Background(), 1, 1, MergePatch, []byte(`{"id":2}`))
+	assert.Equal(t, ErrImmutableField, err)
+
+	_, err = service.PatchUserByID(context.Background(), 1, 1, MergePatch, []byte(`{"version":5}`))
+	assert.Equal(t, ErrImmutableField, err)
+
+	_, err = service.PatchUserByID(context.Background(), 1, 1, MergePatch, []byte(`{"name":5}`))
+	assert.Equal(t, ErrInvalidPatch, err)
+
+	_, err = service.PatchUserByID(context.Background(), 1, 1, MergePatch, []byte(`{"role":"admin"}`))
+	assert.Equal(t, ErrInvalidPatch, err)
+
+	_, err = service.PatchUserByID(context.Background(), 1, 1, JSONPatch, []byte(`[{"op":"test","path":"/name","value":"Other"}]`))
+	assert.Equal(t, ErrPatchTestFailed, err)
+
+	_, err = service.PatchUserByID(context.Background(), 1, 1, JSONPatch, []byte(`{"op":"replace"}`))
+	assert.Equal(t, ErrInvalidPatch, err)
+
+	mockRepo.AssertNotCalled(t, "PatchUserByID")
+}
+
+func TestPatchUserByID_NotFound(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service, _ := newTestUserService(mockRepo)
+
+	mockRepo.On("GetUserByID", mock.Anything, int64(1)).Return((*domain.User)(nil), repository.ErrUserNotFound)
+
+	_, err := service.PatchUserByID(context.Background(), 1, 1, MergePatch, []byte(`{"name":"Patched User"}`))
+	assert.Equal(t, ErrUserNotFound, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRestoreUserByID(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service, _ := newTestUserService(mockRepo)
+
+	mockRepo.On("RestoreUserByID", mock.Anything, int64(1)).Return(&domain.User{ID: 1, Version: 3}, nil)
+	mockRepo.On("RestoreUserByID", mock.Anything, int64(2)).Return((*domain.User)(nil), repository.ErrEmailTaken)
+	mockRepo.On("RestoreUserByID", mock.Anything, int64(3)).Return((*domain.User)(nil), repository.ErrUserNotFound)
+
+	assert.NoError(t, service.RestoreUserByID(context.Background(), 1))
+	assert.Equal(t, ErrEmailTaken, service.RestoreUserByID(context.Background(), 2))
+	assert.Equal(t, ErrUserNotFound, service.RestoreUserByID(context.Background(), 3))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPurgeUserByID(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service, _ := newTestUserService(mockRepo)
+
+	mockRepo.On("PurgeUserByID", mock.Anything, int64(1)).Return(&domain.User{ID: 1, Version: 3}, nil)
+	mockRepo.On("PurgeUserByID", mock.Anything, int64(2)).Return((*domain.User)(nil), repository.ErrUserNotFound)
+
+	assert.NoError(t, service.PurgeUserByID(context.Background(), 1))
+	assert.Equal(t, ErrUserNotFound, service.PurgeUserByID(context.Background(), 2))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateUserByID_VersionConflict(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service, _ := newTestUserService(mockRepo)
+
+	before := &domain.User{ID: 1, Name: "Test User", Email: "test@example.com", Version: 2}
+	mockRepo.On("GetUserByID", mock.Anything, int64(1)).Return(before, nil)
+	user := &domain.User{Name: "Updated User", Email: "updated@example.com", Version: 1}
+	mockRepo.On("UpdateUserByID", mock.Anything, int64(1), user).Return(repository.ErrVersionConflict)
+
+	err := service.UpdateUserByID(context.Background(), 1, user)
+	assert.Equal(t, ErrVersionConflict, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateUserByID_NotFound(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service, _ := newTestUserService(mockRepo)
+
+	mockRepo.On("GetUserByID", mock.Anything, int64(1)).Return((*domain.User)(nil), repository.ErrUserNotFound)
+	user := &domain.User{Name: "Updated User", Email: "updated@example.com", Version: 1}
+
+	err := service.UpdateUserByID(context.Background(), 1, user)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+	mockRepo.AssertNotCalled(t, "UpdateUserByID")
+}
+
+func TestPatchUserByID_VersionConflict(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service, _ := newTestUserService(mockRepo)
+
+	current := &domain.User{ID: 1, Name: "Test User", Email: "test@example.com", Version: 3}
+	mockRepo.On("GetUserByID", mock.Anything, int64(1)).Return(current, nil)
+
+	_, err := service.PatchUserByID(context.Background(), 1, 2, MergePatch, []byte(`{"name":"Patched User"}`))
+	assert.Equal(t, ErrVersionConflict, err)
+	mockRepo.AssertNotCalled(t, "PatchUserByID")
+}
+
+func TestDeleteUserByID_VersionConflict(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service, _ := newTestUserService(mockRepo)
+
+	before := &domain.User{ID: 1, Name: "Test User", Email: "test@example.com", Version: 3}
+	mockRepo.On("GetUserByID", mock.Anything, int64(1)).Return(before, nil)
+	mockRepo.On("DeleteUserByID", mock.Anything, int64(1), int64(2)).Return(repository.ErrVersionConflict)
+
+	err := service.DeleteUserByID(context.Background(), 1, 2)
+	assert.Equal(t, ErrVersionConflict, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateUser_RecordsAudit(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service, audit := newTestUserService(mockRepo)
+
+	user := &domain.User{Name: "Test User", Email: "test@example.com"}
+	mockRepo.On("CreateUser", mock.Anything, user).Run(func(args mock.Arguments) {
+		args.Get(1).(*domain.User).ID = 7
+	}).Return(nil)
+
+	ctx := reqctx.WithActor(reqctx.WithRequestID(context.Background(), "req-1"), "admin@example.com")
+	err := service.CreateUser(ctx, user)
+	assert.NoError(t, err)
+
+	assert.Len(t, audit.records, 1)
+	record := audit.records[0]
+	assert.Equal(t, domain.AuditActionUserCreated, record.Action)
+	assert.Equal(t, "admin@example.com", record.Actor)
+	assert.Equal(t, "req-1", record.RequestID)
+	assert.Equal(t, int64(7), record.EntityID)
+	assert.Nil(t, record.Before)
+	assert.JSONEq(t, `{"email":{"from":null,"to":"test@example.com"},"id":{"from":null,"to":7},
+		"name":{"from":null,"to":"Test User"},"version":{"from":null,"to":0}}`, string(record.Diff))
+}
+
+func TestPatchUserByID_RecordsAuditDiff(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service, audit := newTestUserService(mockRepo)
+
+	current := &domain.User{ID: 1, Name: "Test User", Email: "old@example.com", Version: 1}
+	mockRepo.On("GetUserByID", mock.Anything, int64(1)).Return(current, nil)
+	email := "new@example.com"
+	mockRepo.On("PatchUserByID", mock.Anything, int64(1), int64(1), domain.UserPatch{Email: &email}).Return(int64(2), nil)
+
+	_, err := service.PatchUserByID(context.Background(), 1, 1, MergePatch, []byte(`{"email":"new@example.com"}`))
+	assert.NoError(t, err)
+
+	assert.Len(t, audit.records, 1)
+	record := audit.records[0]
+	assert.Equal(t, domain.AuditActionUserUpdated, record.Action)
+	assert.Equal(t, reqctx.AnonymousActor, record.Actor)
+	assert.JSONEq(t, `{"email":{"from":"old@example.com","to":"new@example.com"},"version":{"from":1,"to":2}}`, string(record.Diff))
+}
+
+func TestDeleteUserByID_NoAuditOnFailure(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service, audit := newTestUserService(mockRepo)
+
+	before := &domain.User{ID: 1, Name: "Test User", Email: "test@example.com", Version: 3}
+	mockRepo.On("GetUserByID", mock.Anything, int64(1)).Return(before, nil)
+	mockRepo.On("DeleteUserByID", mock.Anything, int64(1), int64(2)).Return(repository.ErrVersionConflict)
+
+	err := service.DeleteUserByID(context.Background(), 1, 2)
+	assert.Equal(t, ErrVersionConflict, err)
+	assert.Empty(t, audit.records)
+}
+
+func importRows() []domain.UserImportRow {
+	return []domain.UserImportRow{
+		{Line: 2, User: domain.User{Name: "Иван", Email: "ivan@example.com"}},
+		{Line: 3, User: domain.User{Name: "", Email: "empty@example.com"}},
+		{Line: 4, User: domain.User{Name: "Иван 2", Email: "ivan@example.com"}},
+		{Line: 5, ParseError: "недостаточно колонок"},
+		{Line: 6, User: domain.User{Name: "Петр", Email: "taken@example.com"}},
+	}
+}
+
+func TestImportUsers_AtomicRejectsAll(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service, audit := newTestUserService(mockRepo)
+
+	mockRepo.On("FindExistingEmails", mock.Anything, []string{"ivan@example.com", "taken@example.com"}).
+		Return(map[string]bool{"taken@example.com": true}, nil)
+
+	result, err := service.ImportUsers(context.Background(), importRows(), "")
+	assert.ErrorIs(t, err, ErrImportRejected)
+	assert.Equal(t, domain.ImportAtomic, result.Mode)
+	assert.Equal(t, 0, result.Imported)
+	assert.Equal(t, 5, result.Skipped)
+
+	lines := make([]int, len(result.Errors))
+	for i, rowErr := range result.Errors {
+		lines[i] = rowErr.Line
+	}
+	assert.Equal(t, []int{3, 4, 5, 6}, lines)
+	assert.Equal(t, ErrEmailTaken.Error(), result.Errors[3].Error)
+	assert.Empty(t, audit.records)
+	mockRepo.AssertNotCalled(t, "CopyUsers", mock.Anything, mock.Anything)
+}
+
+func TestImportUsers_SkipInvalid(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service, audit := newTestUserService(mockRepo)
+
+	valid := []domain.User{{Name: "Иван", Email: "ivan@example.com"}}
+	mockRepo.On("FindExistingEmails", mock.Anything, []string{"ivan@example.com", "taken@example.com"}).
+		Return(map[string]bool{"taken@example.com": true}, nil)
+	mockRepo.On("CopyUsers", mock.Anything, valid).Return(int64(1), nil)
+	mockRepo.On("GetUsersByEmails", mock.Anything, []string{"ivan@example.com"}).
+		Return([]domain.User{{ID: 10, Name: "Иван", Email: "ivan@example.com", Version: 1}}, nil)
+
+	result, err := service.ImportUsers(context.Background(), importRows(), domain.ImportSkipInvalid)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Imported)
+	assert.Equal(t, 4, result.Skipped)
+	assert.Len(t, result.Errors, 4)
+
+	assert.Len(t, audit.records, 1)
+	assert.Equal(t, domain.AuditActionUserCreated, audit.records[0].Action)
+	assert.Equal(t, int64(10), audit.records[0].EntityID)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestImportUsers_NormalizesBeforeDeduplication(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service, _ := newTestUserService(mockRepo)
+
+	rows := []domain.UserImportRow{
+		{Line: 2, User: domain.User{Name: " Иван ", Email: "ivan@Example.COM"}},
+		{Line: 3, User: domain.User{Name: "Иван", Email: " ivan@example.com"}},
+	}
+	mockRepo.On("FindExistingEmails", mock.Anything, []string{"ivan@example.com"}).Return(map[string]bool{}, nil)
+
+	result, err := service.ImportUsers(context.Background(), rows, "")
+	assert.ErrorIs(t, err, ErrImportRejected)
+	assert.Equal(t, []domain.UserImportRowError{{Line: 3, Email: "ivan@example.com", Error: "email повторяется в строке 2"}}, result.Errors)
+}
+
+func TestImportUsers_LocalizesReport(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service, _ := newTestUserService(mockRepo)
+
+	rows := []domain.UserImportRow{{Line: 2, User: domain.User{Name: "", Email: "bad"}}}
+	mockRepo.On("FindExistingEmails", mock.Anything, []string{}).Return(map[string]bool{}, nil)
+
+	ctx := i18n.WithLanguage(context.Background(), i18n.English)
+	result, err := service.ImportUsers(ctx, rows, "")
+	assert.ErrorIs(t, err, ErrImportRejected)
+	assert.Equal(t, "name is required; invalid email", result.Errors[0].Error)
+}
+
+func TestImportUsers_InvalidMode(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service, _ := newTestUserService(mockRepo)
+
+	_, err := service.ImportUsers(context.Background(), importRows(), "partial")
+	assert.ErrorIs(t, err, ErrInvalidImportMode)
+}
+
+func TestExportUsers_NormalizesFilter(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service, _ := newTestUserService(mockRepo)
+
+	expected := domain.UserFilter{Name: "ив", SortBy: domain.UserSortByID, SortOrder: domain.SortAsc, Deleted: domain.DeletedExclude}
+	mockRepo.On("ExportUsers", mock.Anything, expected, mock.Anything).Return(nil)
+
+	err := service.ExportUsers(context.Background(), domain.UserFilter{Name: "ив", Limit: 5, Offset: 10}, func(*domain.User) error { return nil })
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestExportUsers_InvalidSort(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service, _ := newTestUserService(mockRepo)
+
+	err := service.ExportUsers(context.Background(), domain.UserFilter{SortBy: "password"}, func(*domain.User) error { return nil })
+	assert.ErrorIs(t, err, ErrInvalidSort)
+	mockRepo.AssertNotCalled(t, "ExportUsers", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSetUserRoles(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service, audit := newTestUserService(mockRepo)
+
+	mockRepo.On("GetUserByID", mock.Anything, int64(1)).Return(&domain.User{ID: 1}, nil)
+	mockRepo.On("GetUserRoles", mock.Anything, int64(1)).Return([]domain.Role{domain.RoleViewer}, nil)
+	mockRepo.On("SetUserRoles", mock.Anything, int64(1), []domain.Role{domain.RoleAdmin, domain.RoleOperator}).Return(nil)
+
+	roles, err := service.SetUserRoles(context.Background(), 1, []domain.Role{domain.RoleOperator, domain.RoleAdmin, domain.RoleOperator})
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Role{domain.RoleAdmin, domain.RoleOperator}, roles)
+
+	assert.Len(t, audit.records, 1)
+	assert.Equal(t, domain.AuditActionUserRolesChanged, audit.records[0].Action)
+	assert.JSONEq(t, `{"roles":{"from":["viewer"],"to":["admin","operator"]}}`, string(audit.records[0].Diff))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSetUserRoles_RejectsSelf(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service, _ := newTestUserService(mockRepo)
+
+	_, err := service.SetUserRoles(context.Background(), 1, []domain.Role{domain.RoleSelf})
+	assert.ErrorIs(t, err, ErrInvalidRole)
+}
+
+func TestBootstrapAdmin_CreatesUser(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service, audit := newTestUserService(mockRepo)
+
+	mockRepo.On("HasUsersWithRole", mock.Anything, domain.RoleAdmin).Return(false, nil)
+	mockRepo.On("GetUsersByEmails", mock.Anything, []string{"admin@example.com"}).Return([]domain.User{}, nil)
+	mockRepo.On("CreateUser", mock.Anything, mock.AnythingOfType("*domain.User")).Run(func(args mock.Arguments) {
+		args.Get(1).(*domain.User).ID = 1
+	}).Return(nil)
+	mockRepo.On("GetUserRoles", mock.Anything, int64(1)).Return([]domain.Role{}, nil)
+	mockRepo.On("SetUserRoles", mock.Anything, int64(1), []domain.Role{domain.RoleAdmin}).Return(nil)
+
+	admin, err := service.BootstrapAdmin(context.Background(), " admin@Example.com ")
+	assert.NoError(t, err)
+	if assert.NotNil(t, admin) {
+		assert.Equal(t, int64(1), admin.ID)
+		assert.Equal(t, "admin@example.com", admin.Email)
+	}
+	if assert.Len(t, audit.records, 2) {
+		assert.Equal(t, domain.AuditActionUserCreated, audit.records[0].Action)
+		assert.Equal(t, domain.AuditActionUserRolesChanged, audit.records[1].Action)
+		assert.Equal(t, reqctx.SystemActor, audit.records[1].Actor)
+	}
+	mockRepo.AssertExpectations(t)
+}
+
+func TestBootstrapAdmin_ExistingUser(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service, _ := newTestUserService(mockRepo)
+
+	mockRepo.On("HasUsersWithRole", mock.Anything, domain.RoleAdmin).Return(false, nil)
+	mockRepo.On("GetUsersByEmails", mock.Anything, []string{"ivan@example.com"}).Return([]domain.User{{ID: 7, Name: "Иван", Email: "ivan@example.com"}}, nil)
+	mockRepo.On("GetUserRoles", mock.Anything, int64(7)).Return([]domain.Role{domain.RoleViewer}, nil)
+	mockRepo.On("SetUserRoles", mock.Anything, int64(7), []domain.Role{domain.RoleAdmin, domain.RoleViewer}).Return(nil)
+
+	admin, err := service.BootstrapAdmin(context.Background(), "ivan@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), admin.ID)
+	mockRepo.AssertNotCalled(t, "CreateUser")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestBootstrapAdmin_AdminExists(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service, audit := newTestUserService(mockRepo)
+
+	mockRepo.On("HasUsersWithRole", mock.Anything, domain.RoleAdmin).Return(true, nil)
+
+	admin, err := service.BootstrapAdmin(context.Background(), "ivan@example.com")
+	assert.NoError(t, err)
+	assert.Nil(t, admin)
+	assert.Empty(t, audit.records)
+	mockRepo.AssertNotCalled(t, "GetUsersByEmails")
+	mockRepo.AssertNotCalled(t, "SetUserRoles")
 }
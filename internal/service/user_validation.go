@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"golang.org/x/net/idna"
+	"golang.org/x/text/unicode/norm"
+	"net/mail"
+	"strings"
+	"testovoe/internal/apperr"
+	"testovoe/internal/domain"
+	"testovoe/internal/i18n"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ErrInvalidUser = apperr.New(apperr.KindValidation, "invalid_user", "данные пользователя не прошли проверку")
+
+// Коды ошибок отдельных полей пользователя.
+const (
+	FieldRequired          = "required"
+	FieldTooLong           = "too_long"
+	FieldInvalidCharacters = "invalid_characters"
+	FieldInvalidEmail      = "invalid_email"
+	FieldInvalidDomain     = "invalid_domain"
+)
+
+// maxEmailLocalLength — предел длины локальной части адреса в байтах по RFC 5321.
+const maxEmailLocalLength = 64
+
+// normalizeUser приводит имя и email к канонической форме и проверяет их. Строки обрезаются по краям и
+// переводятся в NFC, домен email — в нижний регистр и Unicode-форму IDN, поэтому адреса в punycode
+// и в Unicode считаются одинаковыми. Возвращает ErrInvalidUser со всеми нарушениями сразу.
+func normalizeUser(user *domain.User) error {
+	var fields []apperr.FieldError
+	user.Name, fields = normalizeName(user.Name, fields)
+	user.Email, fields = normalizeEmail(user.Email, fields)
+	if len(fields) > 0 {
+		return ErrInvalidUser.WithFields(fields...)
+	}
+	return nil
+}
+
+func normalizeName(name string, fields []apperr.FieldError) (string, []apperr.FieldError) {
+	name = norm.NFC.String(strings.TrimSpace(name))
+	switch {
+	case name == "":
+		return name, append(fields, apperr.FieldError{Field: "name", Code: FieldRequired, Message: "укажите имя"})
+	case utf8.RuneCountInString(name) > domain.UserNameMaxLength:
+		return name, append(fields, apperr.FieldError{Field: "name", Code: FieldTooLong, Message: "имя длиннее %d символов", Args: []any{domain.UserNameMaxLength}})
+	case strings.IndexFunc(name, unicode.IsControl) >= 0 || !utf8.ValidString(name):
+		return name, append(fields, apperr.FieldError{Field: "name", Code: FieldInvalidCharacters, Message: "имя содержит недопустимые символы"})
+	}
+	return name, fields
+}
+
+func normalizeEmail(email string, fields []apperr.FieldError) (string, []apperr.FieldError) {
+	email = norm.NFC.String(strings.TrimSpace(email))
+	invalid := func(code, message string, args ...any) (string, []apperr.FieldError) {
+		return email, append(fields, apperr.FieldError{Field: "email", Code: code, Message: message, Args: args})
+	}
+	if email == "" {
+		return invalid(FieldRequired, "укажите email")
+	}
+
+	// ParseAddress принимает и форму «Имя <адрес>», поэтому имя и угловые скобки отсекаются отдельно.
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Name != "" || strings.ContainsAny(email, "<>") {
+		return invalid(FieldInvalidEmail, "некорректный email")
+	}
+	at := strings.LastIndexByte(email, '@')
+	local, host := email[:at], email[at+1:]
+	if len(local) > maxEmailLocalLength {
+		return invalid(FieldTooLong, "имя ящика в email длиннее %d байт", maxEmailLocalLength)
+	}
+
+	ascii, err := idna.Lookup.ToASCII(host)
+	if err != nil || !strings.Contains(ascii, ".") {
+		return invalid(FieldInvalidDomain, "некорректный домен email")
+	}
+	host, err = idna.Lookup.ToUnicode(ascii)
+	if err != nil {
+		return invalid(FieldInvalidDomain, "некорректный домен email")
+	}
+	email = local + "@" + host
+	if utf8.RuneCountInString(email) > domain.UserEmailMaxLength {
+		return invalid(FieldTooLong, "email длиннее %d символов", domain.UserEmailMaxLength)
+	}
+	return email, fields
+}
+
+// violationSummary собирает сообщения об ошибках полей на языке запроса в одну строку для отчета об импорте.
+func violationSummary(ctx context.Context, err error) string {
+	appErr, ok := apperr.As(err)
+	if !ok || len(appErr.Fields) == 0 {
+		return i18n.T(ctx, err.Error())
+	}
+	messages := make([]string, len(appErr.Fields))
+	for i, field := range appErr.Fields {
+		messages[i] = i18n.T(ctx, field.Message, field.Args...)
+	}
+	return strings.Join(messages, "; ")
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"testovoe/internal/apperr"
+	"testovoe/internal/domain"
+	"testovoe/internal/i18n"
+)
+
+func fieldCodes(err error) map[string]string {
+	codes := make(map[string]string)
+	if appErr, ok := apperr.As(err); ok {
+		for _, field := range appErr.Fields {
+			codes[field.Field] = field.Code
+		}
+	}
+	return codes
+}
+
+func TestNormalizeUser_Normalizes(t *testing.T) {
+	user := &domain.User{Name: "  José ", Email: " Ivan@Пример.РФ\t"}
+
+	assert.NoError(t, normalizeUser(user))
+	assert.Equal(t, "José", user.Name)
+	assert.Equal(t, "Ivan@пример.рф", user.Email)
+
+	// Домен в punycode приводится к той же форме, что и в Unicode.
+	user = &domain.User{Name: "Иван", Email: "ivan@xn--e1afmkfd.xn--p1ai"}
+	assert.NoError(t, normalizeUser(user))
+	assert.Equal(t, "ivan@пример.рф", user.Email)
+}
+
+func TestNormalizeUser_ValidEmails(t *testing.T) {
+	for _, email := range []string{
+		"test@example.com",
+		"first.last+tag@sub.example.co.uk",
+		`"john doe"@example.com`,
+		"иван@пример.рф",
+	} {
+		user := &domain.User{Name: "Test", Email: email}
+		assert.NoError(t, normalizeUser(user), email)
+	}
+}
+
+func TestNormalizeUser_ReportsAllViolations(t *testing.T) {
+	tests := []struct {
+		name  string
+		user  domain.User
+		codes map[string]string
+	}{
+		{"empty", domain.User{Name: " ", Email: ""}, map[string]string{"name": FieldRequired, "email": FieldRequired}},
+		{"long name", domain.User{Name: strings.Repeat("я", 101), Email: "a@example.com"}, map[string]string{"name": FieldTooLong}},
+		{"control characters", domain.User{Name: "Иван\x00", Email: "a@example.com"}, map[string]string{"name": FieldInvalidCharacters}},
+		{"display name", domain.User{Name: "Иван", Email: "Иван <a@example.com>"}, map[string]string{"email": FieldInvalidEmail}},
+		{"double dot", domain.User{Name: "Иван", Email: "a..b@example.com"}, map[string]string{"email": FieldInvalidEmail}},
+		{"no domain dot", domain.User{Name: "Иван", Email: "a@localhost"}, map[string]string{"email": FieldInvalidDomain}},
+		{"bad domain", domain.User{Name: "Иван", Email: "a@-example.com"}, map[string]string{"email": FieldInvalidDomain}},
+		{"long local part", domain.User{Name: "Иван", Email: strings.Repeat("a", 65) + "@example.com"}, map[string]string{"email": FieldTooLong}},
+		{"long email", domain.User{Name: "Иван", Email: strings.Repeat("a", 60) + "@" + strings.Repeat("b", 40) + ".com"}, map[string]string{"email": FieldTooLong}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := normalizeUser(&tt.user)
+			assert.ErrorIs(t, err, ErrInvalidUser)
+			assert.Equal(t, tt.codes, fieldCodes(err))
+		})
+	}
+}
+
+func TestViolationSummary_FormatsLimits(t *testing.T) {
+	user := domain.User{Name: strings.Repeat("я", 101), Email: strings.Repeat("a", 65) + "@example.com"}
+	err := normalizeUser(&user)
+	assert.Equal(t, "имя длиннее 100 символов; имя ящика в email длиннее 64 байт", violationSummary(context.Background(), err))
+
+	ctx := i18n.WithLanguage(context.Background(), i18n.English)
+	assert.Equal(t, "name is longer than 100 characters; email local part is longer than 64 bytes", violationSummary(ctx, err))
+}
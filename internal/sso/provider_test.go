@@ -0,0 +1,85 @@
+package sso
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"net/url"
+	"testing"
+	"testovoe/internal/sso/ssotest"
+)
+
+const testVerifier = "dBjftJeZ4CVP-mJ92K9z-7d7oBmuyVfWq2kl9VvKXlwA1b2c3"
+
+func newTestProvider(fake *ssotest.Provider, secret string) *Provider {
+	return NewProvider(ProviderConfig{
+		Name:         "corp",
+		Issuer:       fake.Issuer(),
+		ClientID:     fake.ClientID,
+		ClientSecret: secret,
+		RedirectURL:  "https://app.example.com/auth/sso/corp/callback",
+	}, nil)
+}
+
+func login(t *testing.T, fake *ssotest.Provider, provider *Provider, nonce string) url.Values {
+	sum := sha256.Sum256([]byte(testVerifier))
+	authURL, err := provider.AuthCodeURL(context.Background(), "state-1", nonce, base64.RawURLEncoding.EncodeToString(sum[:]))
+	assert.NoError(t, err)
+	params, err := fake.Login(authURL)
+	assert.NoError(t, err)
+	assert.Equal(t, "state-1", params.Get("state"))
+	return params
+}
+
+func TestProvider_Exchange(t *testing.T) {
+	fake := ssotest.NewProvider("client", "secret")
+	defer fake.Close()
+	fake.SetUser(ssotest.User{Subject: "u-1", Email: "Ivan@Example.com", EmailVerified: true, Name: "Иван"})
+	provider := newTestProvider(fake, "secret")
+
+	params := login(t, fake, provider, "nonce-1")
+	claims, err := provider.Exchange(context.Background(), params.Get("code"), testVerifier, "nonce-1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, &Claims{Subject: "u-1", Email: "Ivan@Example.com", EmailVerified: true, Name: "Иван"}, claims)
+}
+
+func TestProvider_Exchange_WrongNonce(t *testing.T) {
+	fake := ssotest.NewProvider("client", "secret")
+	defer fake.Close()
+	fake.SetUser(ssotest.User{Subject: "u-1"})
+	fake.Nonce = "replayed"
+	provider := newTestProvider(fake, "secret")
+
+	params := login(t, fake, provider, "nonce-1")
+	_, err := provider.Exchange(context.Background(), params.Get("code"), testVerifier, "nonce-1")
+
+	assert.ErrorIs(t, err, ErrInvalidIDToken)
+}
+
+func TestProvider_Exchange_Rejected(t *testing.T) {
+	fake := ssotest.NewProvider("client", "secret")
+	defer fake.Close()
+	fake.SetUser(ssotest.User{Subject: "u-1"})
+
+	provider := newTestProvider(fake, "wrong-secret")
+	params := login(t, fake, provider, "nonce-1")
+	_, err := provider.Exchange(context.Background(), params.Get("code"), testVerifier, "nonce-1")
+	assert.Error(t, err)
+
+	provider = newTestProvider(fake, "secret")
+	params = login(t, fake, provider, "nonce-1")
+	_, err = provider.Exchange(context.Background(), params.Get("code"), "wrong-verifier-wrong-verifier-wrong-verifier", "nonce-1")
+	assert.Error(t, err)
+}
+
+func TestProvider_UnknownIssuer(t *testing.T) {
+	fake := ssotest.NewProvider("client", "secret")
+	defer fake.Close()
+	provider := NewProvider(ProviderConfig{Name: "corp", Issuer: fake.Issuer() + "/other", ClientID: "client"}, nil)
+
+	_, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "challenge")
+
+	assert.Error(t, err)
+}